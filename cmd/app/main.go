@@ -2,14 +2,20 @@ package main
 
 import (
 	"log"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	authHandler "github.com/rkweber-max/checkout-backend/internal/handler"
 	"github.com/rkweber-max/checkout-backend/internal/middleware"
 	"github.com/rkweber-max/checkout-backend/pkg/config"
 	"github.com/rkweber-max/checkout-backend/pkg/database"
+	"github.com/rkweber-max/checkout-backend/pkg/mail"
+	"github.com/rkweber-max/checkout-backend/pkg/notify"
+	"github.com/rkweber-max/checkout-backend/pkg/scheduler"
+	"github.com/rkweber-max/checkout-backend/pkg/storage"
 	"go.uber.org/fx"
 
+	userDomain "github.com/rkweber-max/checkout-backend/internal/user/domain"
 	userHandler "github.com/rkweber-max/checkout-backend/internal/user/handler"
 	userRepo "github.com/rkweber-max/checkout-backend/internal/user/repository"
 	userService "github.com/rkweber-max/checkout-backend/internal/user/service"
@@ -18,7 +24,12 @@ import (
 	productRepo "github.com/rkweber-max/checkout-backend/internal/product/repository"
 	productService "github.com/rkweber-max/checkout-backend/internal/product/service"
 
+	inventoryHandler "github.com/rkweber-max/checkout-backend/internal/inventory/handler"
+	inventoryRepo "github.com/rkweber-max/checkout-backend/internal/inventory/repository"
+	inventoryService "github.com/rkweber-max/checkout-backend/internal/inventory/service"
+
 	checkoutHandler "github.com/rkweber-max/checkout-backend/internal/checkout/handler"
+	checkoutRepo "github.com/rkweber-max/checkout-backend/internal/checkout/repository"
 	checkoutService "github.com/rkweber-max/checkout-backend/internal/checkout/service"
 )
 
@@ -28,17 +39,81 @@ func main() {
 			config.LoadConfig,
 			newGinEngine,
 			database.NewPostgresDB,
+			storage.NewStorage,
+			notify.NewNotifier,
+			mail.NewMailer,
 			authHandler.NewAuthHandler,
+			authHandler.NewRegistrationHandler,
+			userService.NewRegistrationService,
+			userService.NewVerificationService,
+			authHandler.NewPasswordHandler,
+			userService.NewPasswordResetService,
 			userHandler.NewUserHandler,
 			userRepo.NewUserRepository,
 			userService.NewUserService,
+			userRepo.NewRoleRepository,
+			userService.NewRoleService,
+			userHandler.NewRoleHandler,
+			userRepo.NewAPIKeyRepository,
+			userService.NewAPIKeyService,
+			userHandler.NewAPIKeyHandler,
+			userRepo.NewIdentityRepository,
+			userService.NewOIDCService,
+			authHandler.NewOIDCHandler,
+			userRepo.NewTokenRepository,
+			userService.NewTokenService,
+			userRepo.NewLoginRepository,
+			userService.NewLoginService,
+			userRepo.NewMFARepository,
+			userService.NewMFAService,
+			authHandler.NewMFAHandler,
+			userRepo.NewKeyRepository,
+			userService.NewKeyService,
 			productRepo.NewProductRepository,
 			productService.NewProductService,
 			productHandler.NewProductHandler,
+			productRepo.NewCategoryRepository,
+			productService.NewCategoryService,
+			productHandler.NewCategoryHandler,
+			productRepo.NewVariantRepository,
+			productService.NewVariantService,
+			productHandler.NewVariantHandler,
+			productService.NewImportService,
+			productHandler.NewImportHandler,
+			productRepo.NewImageRepository,
+			productService.NewImageService,
+			productHandler.NewImageHandler,
+			productRepo.NewPriceRepository,
+			productService.NewPriceService,
+			productHandler.NewPriceHandler,
+			productService.NewTrashService,
+			productHandler.NewTrashHandler,
+			productRepo.NewBundleRepository,
+			productService.NewBundleService,
+			productHandler.NewBundleHandler,
+			productRepo.NewAttributeRepository,
+			productService.NewAttributeService,
+			productHandler.NewAttributeHandler,
+			productRepo.NewReviewRepository,
+			productService.NewReviewService,
+			productHandler.NewReviewHandler,
+			productRepo.NewWishlistRepository,
+			productService.NewWishlistService,
+			productHandler.NewWishlistHandler,
+			inventoryRepo.NewInventoryRepository,
+			inventoryService.NewInventoryService,
+			inventoryHandler.NewInventoryHandler,
+			inventoryRepo.NewPurchasingRepository,
+			inventoryService.NewPurchasingService,
+			inventoryHandler.NewPurchasingHandler,
+			inventoryRepo.NewReorderRepository,
+			inventoryService.NewReorderService,
+			inventoryHandler.NewReorderHandler,
+			checkoutRepo.NewOrderRepository,
 			checkoutService.NewCheckoutService,
 			checkoutHandler.NewCheckoutHandler,
 		),
-		fx.Invoke(registerRoutes),
+		fx.Invoke(registerJobs, registerRoutes),
 	).Run()
 }
 
@@ -46,63 +121,301 @@ func newGinEngine() *gin.Engine {
 	return gin.New()
 }
 
+func registerJobs(
+	lc fx.Lifecycle,
+	prices productService.PriceService,
+	trash productService.TrashService,
+	tokens userService.TokenService,
+	keys userService.KeyService,
+	logins userService.LoginService,
+	sso userService.OIDCService,
+	reorder inventoryService.ReorderService,
+	wishlists productService.WishlistService,
+) {
+	scheduler.Every(lc, "scheduled-prices", time.Minute, prices.ApplyDueSchedules)
+	scheduler.Every(lc, "product-trash-purge", time.Hour, trash.Purge)
+	scheduler.Every(lc, "low-stock", time.Hour, reorder.CheckLowStock)
+	scheduler.Every(lc, "back-in-stock", 5*time.Minute, wishlists.NotifyBackInStock)
+	scheduler.Every(lc, "token-cleanup", time.Hour, tokens.Cleanup)
+	scheduler.Every(lc, "jwt-key-rotation", time.Hour, keys.Rotate)
+	scheduler.Every(lc, "login-throttle-cleanup", time.Hour, logins.Cleanup)
+	scheduler.Every(lc, "oidc-state-cleanup", time.Hour, sso.Cleanup)
+}
+
 func registerRoutes(
 	router *gin.Engine,
 	authHandler *authHandler.AuthHandler,
+	registrationHandler *authHandler.RegistrationHandler,
+	passwordHandler *authHandler.PasswordHandler,
+	mfaHandler *authHandler.MFAHandler,
+	oidcHandler *authHandler.OIDCHandler,
 	userHandler *userHandler.UserHandler,
+	roleHandler *userHandler.RoleHandler,
+	apiKeyHandler *userHandler.APIKeyHandler,
+	tokens userService.TokenService,
+	keys userService.KeyService,
+	apiKeys userService.APIKeyService,
 	productHandler *productHandler.ProductHandler,
+	categoryHandler *productHandler.CategoryHandler,
+	variantHandler *productHandler.VariantHandler,
+	importHandler *productHandler.ImportHandler,
+	imageHandler *productHandler.ImageHandler,
+	priceHandler *productHandler.PriceHandler,
+	trashHandler *productHandler.TrashHandler,
+	bundleHandler *productHandler.BundleHandler,
+	attributeHandler *productHandler.AttributeHandler,
+	reviewHandler *productHandler.ReviewHandler,
+	wishlistHandler *productHandler.WishlistHandler,
+	inventoryHandler *inventoryHandler.InventoryHandler,
+	purchasingHandler *inventoryHandler.PurchasingHandler,
+	reorderHandler *inventoryHandler.ReorderHandler,
+	store storage.Storage,
 	checkoutHandler *checkoutHandler.CheckoutHandler,
 	config *config.Config,
 ) {
 	router.Use(gin.Logger(), gin.Recovery())
 
+	if local, ok := store.(*storage.LocalStorage); ok {
+		router.Static("/media", local.Dir())
+	}
+
+	router.GET("/.well-known/jwks.json", authHandler.JWKS)
+
 	api := router.Group("/api")
 	{
 		// Public routes
 		api.POST("/login", authHandler.Login)
+		api.POST("/token/refresh", authHandler.Refresh)
+		api.POST("/register", registrationHandler.Register)
+		api.GET("/register/verify", registrationHandler.Verify)
+		api.POST("/register/resend", registrationHandler.ResendVerification)
+		api.POST("/password/forgot", passwordHandler.Forgot)
+		api.POST("/password/reset", passwordHandler.Reset)
+		api.POST("/login/mfa", mfaHandler.LoginVerify)
+		api.POST("/login/mfa/enroll", mfaHandler.LoginEnroll)
+		api.POST("/login/mfa/confirm", mfaHandler.LoginConfirm)
+		api.GET("/login/oidc", oidcHandler.Start)
+		api.GET("/login/oidc/callback", oidcHandler.Callback)
+
+		// Routes of the logged-in user's own session
+		session := api.Group("/")
+		session.Use(middleware.JWTAuthMiddleware(keys, keys.TokenOptions(), tokens))
+		session.POST("/logout", authHandler.Logout)
+		session.POST("/mfa/enroll", mfaHandler.Enroll)
+		session.POST("/mfa/confirm", mfaHandler.Confirm)
+		session.POST("/mfa/recovery-codes", mfaHandler.RegenerateRecoveryCodes)
+		session.DELETE("/mfa", mfaHandler.Disable)
 
-		// Authenticated routes
+		// Authenticated routes, open to users and to API keys
 		authenticated := api.Group("/")
-		authenticated.Use(middleware.JWTAuthMiddleware(config))
+		authenticated.Use(middleware.AuthMiddleware(keys, keys.TokenOptions(), tokens, apiKeys))
 
-		// Admin routes
+		// Admin routes. Each area requires its own permission, so admins
+		// can hand parts of the back office to custom roles.
 		admin := authenticated.Group("/admin")
-		admin.Use(middleware.AuthorizationRole("admin"))
 		{
-			admin.POST("/users", userHandler.Create)
-			admin.GET("/users", userHandler.List)
-			admin.GET("/users/:id", userHandler.GetByID)
-			admin.GET("/users/email/:email", userHandler.GetByEmail)
-			admin.PUT("/users/:id", userHandler.Update)
-			admin.DELETE("/users/:id", userHandler.Delete)
+			usersRead := admin.Group("", middleware.RequirePermission(userDomain.PermUsersRead))
+			usersRead.GET("/users", userHandler.List)
+			usersRead.GET("/users/:id", userHandler.GetByID)
+			usersRead.GET("/users/email/:email", userHandler.GetByEmail)
+
+			usersWrite := admin.Group("", middleware.RequirePermission(userDomain.PermUsersWrite))
+			usersWrite.POST("/users", userHandler.Create)
+			usersWrite.PUT("/users/:id", userHandler.Update)
+			usersWrite.PATCH("/users/:id", userHandler.Patch)
+			usersWrite.DELETE("/users/:id", userHandler.Delete)
+
+			security := admin.Group("", middleware.RequirePermission(userDomain.PermUsersSecurity))
+			security.DELETE("/users/:id/sessions", userHandler.RevokeSessions)
+			security.POST("/users/:id/unlock", userHandler.Unlock)
+			security.GET("/users/:id/login-events", userHandler.LoginEvents)
+			security.DELETE("/users/:id/mfa", mfaHandler.Reset)
+			security.GET("/mfa-policies", mfaHandler.Policies)
+			security.PUT("/mfa-policies/:role", mfaHandler.SetPolicy)
+
+			roles := admin.Group("", middleware.RequirePermission(userDomain.PermRolesManage))
+			roles.GET("/permissions", roleHandler.Permissions)
+			roles.GET("/roles", roleHandler.List)
+			roles.POST("/roles", roleHandler.Create)
+			roles.GET("/roles/:name", roleHandler.Get)
+			roles.PUT("/roles/:name", roleHandler.Update)
+			roles.DELETE("/roles/:name", roleHandler.Delete)
+			roles.GET("/users/:id/roles", roleHandler.UserRoles)
+			roles.PUT("/users/:id/roles", roleHandler.SetUserRoles)
+
+			apiKeyAdmin := admin.Group("", middleware.RequirePermission(userDomain.PermAPIKeysManage))
+			apiKeyAdmin.POST("/api-keys", apiKeyHandler.Create)
+			apiKeyAdmin.GET("/api-keys", apiKeyHandler.List)
+			apiKeyAdmin.GET("/api-keys/:id", apiKeyHandler.Get)
+			apiKeyAdmin.DELETE("/api-keys/:id", apiKeyHandler.Revoke)
+
+			categories := admin.Group("", middleware.RequirePermission(userDomain.PermCategoriesWrite))
+			categories.POST("/categories", categoryHandler.Create)
+			categories.GET("/categories", categoryHandler.GetTree)
+			categories.GET("/categories/:id", categoryHandler.GetByID)
+			categories.PUT("/categories/:id", categoryHandler.Update)
+			categories.POST("/categories/:id/move", categoryHandler.Move)
+			categories.DELETE("/categories/:id", categoryHandler.Delete)
+			categories.POST("/categories/:id/attributes", attributeHandler.Define)
+			categories.GET("/categories/:id/attributes", attributeHandler.List)
+			categories.PUT("/categories/:id/attributes/:attributeId", attributeHandler.Update)
+			categories.DELETE("/categories/:id/attributes/:attributeId", attributeHandler.Delete)
+
+			products := admin.Group("", middleware.RequirePermission(userDomain.PermProductsWrite))
+			products.PUT("/products/:id/categories", categoryHandler.AssignProduct)
+
+			products.POST("/products/:id/options", variantHandler.AddOptionType)
+			products.GET("/products/:id/options", variantHandler.ListOptionTypes)
+			products.DELETE("/products/:id/options/:optionId", variantHandler.DeleteOptionType)
+			products.GET("/products/:id/variants", variantHandler.ListVariants)
+			products.POST("/products/:id/variants/generate", variantHandler.GenerateVariants)
+			products.PUT("/products/:id/variants/:variantId", variantHandler.UpdateVariant)
+			products.DELETE("/products/:id/variants/:variantId", variantHandler.DeleteVariant)
+
+			products.GET("/products/:id/price-history", priceHandler.History)
+			products.POST("/products/:id/scheduled-prices", priceHandler.Schedule)
+			products.GET("/products/:id/scheduled-prices", priceHandler.ListSchedules)
+			products.DELETE("/products/:id/scheduled-prices/:scheduleId", priceHandler.CancelSchedule)
+
+			products.GET("/products/trash", trashHandler.List)
+			products.POST("/products/:id/restore", trashHandler.Restore)
+
+			products.POST("/products/import", importHandler.Import)
+			products.GET("/products/import/jobs/:jobId", importHandler.GetJob)
+			products.GET("/products/export", importHandler.Export)
+
+			warehouses := admin.Group("", middleware.RequirePermission(userDomain.PermWarehousesManage))
+			warehouses.POST("/warehouses", inventoryHandler.CreateWarehouse)
+			warehouses.PUT("/warehouses/:id", inventoryHandler.UpdateWarehouse)
+
+			purchasingRead := admin.Group("", middleware.RequirePermission(userDomain.PermPurchasingRead))
+			purchasingRead.GET("/suppliers", purchasingHandler.ListSuppliers)
+			purchasingRead.GET("/suppliers/:id", purchasingHandler.GetSupplier)
+			purchasingRead.GET("/purchase-orders", purchasingHandler.ListPurchaseOrders)
+			purchasingRead.GET("/purchase-orders/:id", purchasingHandler.GetPurchaseOrder)
+			purchasingRead.GET("/products/:id/cost", purchasingHandler.ProductCost)
+			purchasingRead.GET("/products/:id/reorder-rule", reorderHandler.GetRule)
+			purchasingRead.GET("/low-stock", reorderHandler.LowStock)
+
+			purchasingWrite := admin.Group("", middleware.RequirePermission(userDomain.PermPurchasingWrite))
+			purchasingWrite.POST("/suppliers", purchasingHandler.CreateSupplier)
+			purchasingWrite.PUT("/suppliers/:id", purchasingHandler.UpdateSupplier)
+			purchasingWrite.POST("/purchase-orders", purchasingHandler.CreatePurchaseOrder)
+			purchasingWrite.POST("/purchase-orders/:id/submit", purchasingHandler.SubmitPurchaseOrder)
+			purchasingWrite.POST("/purchase-orders/:id/cancel", purchasingHandler.CancelPurchaseOrder)
+			purchasingWrite.PUT("/products/:id/reorder-rule", reorderHandler.SetRule)
+			purchasingWrite.DELETE("/products/:id/reorder-rule", reorderHandler.DeleteRule)
 		}
 
 		// Customer routes
 		customer := authenticated.Group("/customer")
-		customer.Use(middleware.AuthorizationRole("customer"))
 		{
-			customer.POST("/products", productHandler.CreateProduct)
-			customer.GET("/products", productHandler.GetAllProducts)
-			customer.GET("/products/:id", productHandler.GetProductByID)
-			customer.PUT("/products/:id", productHandler.UpdateProduct)
-			customer.DELETE("/products/:id", productHandler.DeleteProduct)
+			browse := customer.Group("", middleware.RequirePermission(userDomain.PermProductsRead))
+			browse.GET("/products", productHandler.GetAllProducts)
+			browse.GET("/products/:id", productHandler.GetProductByID)
+			browse.GET("/products/:id/images", imageHandler.List)
+			browse.GET("/products/:id/reviews", reviewHandler.List)
+			browse.GET("/categories", categoryHandler.GetTree)
+			browse.GET("/categories/:id/products", categoryHandler.ListProducts)
+			browse.GET("/categories/:id/attributes", attributeHandler.List)
+
+			reviews := customer.Group("", middleware.RequirePermission(userDomain.PermReviewsWrite))
+			reviews.POST("/products/:id/reviews", reviewHandler.Create)
+			reviews.PUT("/products/:id/reviews/mine", reviewHandler.UpdateMine)
+			reviews.DELETE("/products/:id/reviews/mine", reviewHandler.DeleteMine)
 
-			customer.POST("/checkout", checkoutHandler.Checkout)
+			wishlist := customer.Group("", middleware.RequirePermission(userDomain.PermWishlistManage))
+			wishlist.POST("/products/:id/notify-me", wishlistHandler.Subscribe)
+			wishlist.DELETE("/products/:id/notify-me", wishlistHandler.Unsubscribe)
+			wishlist.GET("/wishlist", wishlistHandler.List)
+			wishlist.PUT("/wishlist/:productId", wishlistHandler.Add)
+			wishlist.DELETE("/wishlist/:productId", wishlistHandler.Remove)
+			wishlist.GET("/notify-me", wishlistHandler.Subscriptions)
+
+			customer.POST("/checkout", middleware.RequirePermission(userDomain.PermOrdersCreate), checkoutHandler.Checkout)
+		}
+
+		// Seller routes. Sellers manage only the products they own; users
+		// with products:write may act on any product.
+		seller := authenticated.Group("/seller")
+		{
+			sellerProducts := seller.Group("", middleware.RequireAnyPermission(userDomain.PermProductsWrite, userDomain.PermProductsWriteOwn))
+			sellerProducts.POST("/products", productHandler.CreateProduct)
+			sellerProducts.GET("/products", productHandler.GetMyProducts)
+
+			sellerOrders := seller.Group("", middleware.RequirePermission(userDomain.PermOrdersReadOwn))
+			sellerOrders.GET("/orders", checkoutHandler.GetSellerOrders)
+			sellerOrders.GET("/orders/:id", checkoutHandler.GetSellerOrder)
+
+			owned := sellerProducts.Group("/products/:id")
+			owned.Use(productHandler.RequireOwner())
+			{
+				owned.GET("", productHandler.GetProductByID)
+				owned.PUT("", productHandler.UpdateProduct)
+				owned.PATCH("", productHandler.PatchProduct)
+				owned.DELETE("", productHandler.DeleteProduct)
+
+				owned.POST("/images", imageHandler.Upload)
+				owned.GET("/images", imageHandler.List)
+				owned.PUT("/images/order", imageHandler.Reorder)
+				owned.PUT("/images/:imageId/primary", imageHandler.SetPrimary)
+				owned.DELETE("/images/:imageId", imageHandler.Delete)
+
+				owned.POST("/options", variantHandler.AddOptionType)
+				owned.GET("/options", variantHandler.ListOptionTypes)
+				owned.DELETE("/options/:optionId", variantHandler.DeleteOptionType)
+				owned.GET("/variants", variantHandler.ListVariants)
+				owned.POST("/variants/generate", variantHandler.GenerateVariants)
+				owned.PUT("/variants/:variantId", variantHandler.UpdateVariant)
+				owned.DELETE("/variants/:variantId", variantHandler.DeleteVariant)
+
+				owned.PUT("/attributes", attributeHandler.SetProductAttributes)
+
+				owned.PUT("/bundle", bundleHandler.Set)
+				owned.GET("/bundle", bundleHandler.Get)
+				owned.DELETE("/bundle", bundleHandler.Delete)
+			}
 		}
 
 		// Employees routes
 		employee := authenticated.Group("/employee")
-		employee.Use(middleware.AuthorizationRole("employee"))
 		{
-			employee.POST("/checkout", checkoutHandler.Checkout)
+			employee.GET("/products/barcode/:gtin", middleware.RequirePermission(userDomain.PermPOSUse), productHandler.GetProductByBarcode)
+			employee.POST("/checkout", middleware.RequirePermission(userDomain.PermOrdersCreate), checkoutHandler.Checkout)
+
+			employee.GET("/purchase-orders/:id", middleware.RequirePermission(userDomain.PermPurchasingRead), purchasingHandler.GetPurchaseOrder)
+			employee.POST("/purchase-orders/:id/receive", middleware.RequirePermission(userDomain.PermPurchasingReceive), purchasingHandler.Receive)
+
+			moderation := employee.Group("", middleware.RequirePermission(userDomain.PermReviewsModerate))
+			moderation.GET("/reviews/pending", reviewHandler.Pending)
+			moderation.POST("/reviews/:reviewId/approve", reviewHandler.Approve)
+			moderation.POST("/reviews/:reviewId/reject", reviewHandler.Reject)
 		}
 
 		// Shared routes
 		sharedCheckout := authenticated.Group("/checkout")
-		sharedCheckout.Use(middleware.AuthorizationRole("customer", "employee"))
+		sharedCheckout.Use(middleware.RequirePermission(userDomain.PermOrdersCreate))
 		{
 			sharedCheckout.POST("/", checkoutHandler.Checkout)
 		}
+
+		inventory := authenticated.Group("/inventory")
+		{
+			stock := inventory.Group("", middleware.RequirePermission(userDomain.PermInventoryRead))
+			stock.GET("/warehouses", inventoryHandler.ListWarehouses)
+			stock.GET("/warehouses/:id", inventoryHandler.GetWarehouse)
+			stock.GET("/warehouses/:id/stock", inventoryHandler.WarehouseStock)
+			stock.GET("/variants/:variantId/stock", inventoryHandler.VariantStock)
+			stock.GET("/movements", inventoryHandler.Movements)
+			stock.GET("/transfers", inventoryHandler.ListTransfers)
+			stock.GET("/transfers/:id", inventoryHandler.GetTransfer)
+
+			moves := inventory.Group("", middleware.RequirePermission(userDomain.PermInventoryWrite))
+			moves.POST("/warehouses/:id/adjustments", inventoryHandler.Adjust)
+			moves.POST("/transfers", inventoryHandler.CreateTransfer)
+			moves.POST("/transfers/:id/receive", inventoryHandler.ReceiveTransfer)
+			moves.POST("/transfers/:id/cancel", inventoryHandler.CancelTransfer)
+		}
 	}
 
 	log.Printf("🚀 Server running on port %s", config.AppPort)
@@ -0,0 +1,16 @@
+package auth
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrInvalidAPIKey is returned for keys that are unknown, expired or
+// revoked.
+var ErrInvalidAPIKey = errors.New("invalid, expired or revoked API key")
+
+// APIKeyVerifier resolves an API key to the subject it acts as, limited to
+// the permissions the key grants.
+type APIKeyVerifier interface {
+	VerifyAPIKey(ctx context.Context, key, ip string) (*Subject, error)
+}
@@ -0,0 +1,21 @@
+package auth
+
+import "context"
+
+type actorKey struct{}
+
+// WithActor returns a context carrying the ID of the user performing the
+// request, so lower layers can attribute changes to them.
+func WithActor(ctx context.Context, userID uint) context.Context {
+	return context.WithValue(ctx, actorKey{}, userID)
+}
+
+// ActorFromContext returns the acting user, or nil for system actions such
+// as scheduled jobs.
+func ActorFromContext(ctx context.Context) *uint {
+	id, ok := ctx.Value(actorKey{}).(uint)
+	if !ok {
+		return nil
+	}
+	return &id
+}
@@ -0,0 +1,9 @@
+package auth
+
+import "context"
+
+// Denylist tells whether an access token was revoked before it expired,
+// e.g. on logout.
+type Denylist interface {
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
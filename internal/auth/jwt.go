@@ -1,6 +1,10 @@
 package auth
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"time"
 
@@ -8,24 +12,131 @@ import (
 	"github.com/rkweber-max/checkout-backend/internal/user/domain"
 )
 
-func GenerateToken(userID uint, secret string, role domain.Role) (string, error) {
-	if secret == "" {
-		return "", fmt.Errorf("JWT secret cannot be empty")
+// AccessToken is a signed JWT together with the claims the server needs to
+// revoke it: its ID and when it expires on its own.
+type AccessToken struct {
+	Token     string
+	JTI       string
+	ExpiresAt time.Time
+}
+
+// TokenOptions are the claims shared by every access token the server
+// issues and requires from the tokens it accepts.
+type TokenOptions struct {
+	Issuer   string
+	Audience string
+	TTL      time.Duration
+}
+
+// Subject is who an access token is issued to and what they may do. Role
+// is the user's primary role; Roles and Permissions are everything they
+// hold when the token is issued.
+type Subject struct {
+	UserID      uint
+	Role        domain.Role
+	Roles       []domain.Role
+	Permissions []domain.Permission
+}
+
+// GenerateToken signs an access token for the subject with key, naming the
+// key in the kid header. Permissions are embedded so requests can be
+// authorized without a lookup; role changes apply from the next token.
+func GenerateToken(key *Key, opts TokenOptions, subject Subject) (*AccessToken, error) {
+	return IssueToken(key, opts, jwt.MapClaims{
+		"sub":   subject.UserID,
+		"role":  subject.Role,
+		"roles": subject.Roles,
+		"perms": subject.Permissions,
+	})
+}
+
+// IssueToken signs claims with key after adding the iss, aud, jti, iat and
+// exp claims from opts. The audience keeps tokens issued for one purpose,
+// such as email verification, from being accepted for another.
+func IssueToken(key *Key, opts TokenOptions, claims jwt.MapClaims) (*AccessToken, error) {
+	method, err := signingMethod(key.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	jti, err := RandomToken(16)
+	if err != nil {
+		return nil, err
 	}
 
-	claims := jwt.MapClaims{
-		"sub":  userID,
-		"exp":  time.Now().Add(time.Hour * 24).Unix(),
-		"iat":  time.Now().Unix(),
-		"role": role,
+	now := time.Now()
+	expiresAt := now.Add(opts.TTL)
+	claims["iss"] = opts.Issuer
+	claims["aud"] = opts.Audience
+	claims["jti"] = jti
+	claims["exp"] = expiresAt.Unix()
+	claims["iat"] = now.Unix()
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = key.ID
+
+	tokenString, err := token.SignedString(key.Private)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign token: %w", err)
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return &AccessToken{Token: tokenString, JTI: jti, ExpiresAt: expiresAt}, nil
+}
+
+// ParseToken verifies the token against the key named by its kid header.
+// Only the algorithm of that key is accepted, and the issuer, audience and
+// expiry must be present and valid.
+func ParseToken(ctx context.Context, keys KeyProvider, opts TokenOptions, tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	var key *Key
+
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		if kid == "" {
+			return nil, errors.New("token has no kid header")
+		}
 
-	tokenString, err := token.SignedString([]byte(secret))
+		var err error
+		key, err = keys.VerificationKey(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		if t.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("unexpected signing algorithm %q", t.Method.Alg())
+		}
+		return key.Public(), nil
+	},
+		jwt.WithValidMethods([]string{AlgorithmRS256, AlgorithmEdDSA}),
+		jwt.WithIssuer(opts.Issuer),
+		jwt.WithAudience(opts.Audience),
+		jwt.WithExpirationRequired(),
+	)
 	if err != nil {
-		return "", fmt.Errorf("failed to sign token: %w", err)
+		return nil, err
 	}
 
-	return tokenString, nil
+	return claims, nil
+}
+
+// RandomToken returns n random bytes encoded as hex.
+func RandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func signingMethod(algorithm string) (jwt.SigningMethod, error) {
+	switch algorithm {
+	case AlgorithmRS256:
+		return jwt.SigningMethodRS256, nil
+	case AlgorithmEdDSA:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
 }
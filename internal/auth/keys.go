@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+const rsaKeyBits = 2048
+
+// Key is an asymmetric key pair used to sign access tokens. ID is
+// published as the kid header so verifiers can pick the right public key.
+type Key struct {
+	ID        string
+	Algorithm string
+	Private   crypto.Signer
+}
+
+func (k *Key) Public() crypto.PublicKey {
+	return k.Private.Public()
+}
+
+// KeyProvider supplies the key to sign new tokens with and the keys that
+// tokens may still be verified against.
+type KeyProvider interface {
+	SigningKey(ctx context.Context) (*Key, error)
+	VerificationKey(ctx context.Context, kid string) (*Key, error)
+}
+
+// GenerateKey creates a new key pair for the algorithm.
+func GenerateKey(algorithm string) (crypto.Signer, error) {
+	switch algorithm {
+	case AlgorithmRS256:
+		return rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case AlgorithmEdDSA:
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		return private, err
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+}
+
+// EncodePrivateKey serializes a private key as a PKCS #8 PEM block.
+func EncodePrivateKey(key crypto.Signer) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// DecodePrivateKey parses a key written by EncodePrivateKey.
+func DecodePrivateKey(data string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("invalid PEM private key")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("private key cannot sign")
+	}
+	return signer, nil
+}
+
+// JWK is the public part of a key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicJWK describes the public key of k.
+func PublicJWK(k *Key) (JWK, error) {
+	jwk := JWK{KeyID: k.ID, Use: "sig", Algorithm: k.Algorithm}
+	switch public := k.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	default:
+		return JWK{}, fmt.Errorf("unsupported public key type %T", public)
+	}
+	return jwk, nil
+}
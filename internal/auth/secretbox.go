@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+const sealedPrefix = "v1:"
+
+// SecretBox encrypts secrets the server must read back, such as TOTP
+// seeds, before they are stored. Without a key it stores them as given,
+// which is only meant for development.
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+// NewSecretBox derives an AES-256-GCM key from passphrase.
+func NewSecretBox(passphrase string) (*SecretBox, error) {
+	if passphrase == "" {
+		return &SecretBox{}, nil
+	}
+
+	key := sha256.Sum256([]byte(passphrase))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretBox{aead: aead}, nil
+}
+
+// Encrypted reports whether the box has a key.
+func (b *SecretBox) Encrypted() bool {
+	return b.aead != nil
+}
+
+func (b *SecretBox) Seal(plaintext string) (string, error) {
+	if b.aead == nil {
+		return plaintext, nil
+	}
+
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return sealedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open reverses Seal. Values stored before a key was configured are
+// returned as they are.
+func (b *SecretBox) Open(stored string) (string, error) {
+	if !strings.HasPrefix(stored, sealedPrefix) {
+		return stored, nil
+	}
+	if b.aead == nil {
+		return "", errors.New("secret is encrypted but no key is configured")
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, sealedPrefix))
+	if err != nil {
+		return "", err
+	}
+	size := b.aead.NonceSize()
+	if len(sealed) < size {
+		return "", errors.New("sealed secret is too short")
+	}
+	plaintext, err := b.aead.Open(nil, sealed[:size], sealed[size:], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,14 @@
+package domain
+
+import (
+	"errors"
+
+	inventory "github.com/rkweber-max/checkout-backend/internal/inventory/domain"
+)
+
+var (
+	ErrInvalidItem       = errors.New("invalid order item")
+	ErrInsufficientStock = inventory.ErrInsufficientStock
+	ErrOrderNotFound     = errors.New("order not found")
+	ErrEmailNotVerified  = errors.New("verify your email address before checking out")
+)
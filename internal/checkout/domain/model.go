@@ -1,5 +1,7 @@
 package domain
 
+import "time"
+
 type PaymentType string
 
 const (
@@ -9,19 +11,95 @@ const (
 )
 
 type CheckoutRequest struct {
-	ProductIDs  []int        `json:"product_ids" binding:"required"`
+	Items       []LineItem   `json:"items" binding:"required,min=1,dive"`
 	PaymentType PaymentType  `json:"payment_type" binding:"required"`
 	Customer    CustomerInfo `json:"customer" binding:"required"`
 }
 
+// LineItem references the product being bought, either by ID or by the
+// barcode read at the counter, and, for products sold in variants, the
+// exact variant.
+type LineItem struct {
+	ProductID int64  `json:"product_id" binding:"required_without=Barcode"`
+	Barcode   string `json:"barcode" binding:"required_without=ProductID"`
+	VariantID *int64 `json:"variant_id"`
+	Quantity  int    `json:"quantity" binding:"required,min=1"`
+}
+
 type CustomerInfo struct {
 	Name  string `json:"name" binding:"required"`
 	Email string `json:"email" binding:"required,email"`
+	CEP   string `json:"cep"`
 }
 
 type Order struct {
-	ID          int64        `json:"id"`
+	ID          int64        `json:"id" gorm:"primaryKey"`
 	Total       float64      `json:"total"`
+	PaymentType PaymentType  `json:"payment_type" gorm:"type:varchar(20)"`
+	Customer    CustomerInfo `json:"customer" gorm:"embedded;embeddedPrefix:customer_"`
+	CustomerID  *uint        `json:"customer_id,omitempty" gorm:"index"`
+	Lines       []OrderLine  `json:"lines"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+// OrderLine is a product sold in an order, shipped from WarehouseID. An
+// order split between warehouses has one line per warehouse and product.
+type OrderLine struct {
+	ID          int64   `json:"id" gorm:"primaryKey"`
+	OrderID     int64   `json:"-" gorm:"not null;index"`
+	ProductID   int64   `json:"product_id" gorm:"not null;index"`
+	VariantID   *int64  `json:"variant_id" gorm:"index"`
+	SellerID    *uint   `json:"seller_id,omitempty" gorm:"index"`
+	WarehouseID *int64  `json:"warehouse_id,omitempty" gorm:"index"`
+	SKU         string  `json:"sku,omitempty"`
+	Name        string  `json:"name"`
+	Quantity    int     `json:"quantity"`
+	UnitPrice   float64 `json:"unit_price"`
+	Total       float64 `json:"total"`
+
+	// UnitCost is the average cost of the goods when they were sold, kept
+	// for margin reports and never shown to customers.
+	UnitCost float64 `json:"-"`
+
+	// Components lists what a bundle line took out of stock.
+	Components []OrderLineComponent `json:"components,omitempty"`
+}
+
+// OrderLineComponent is a component product consumed by a bundle line, with
+// the quantity for the whole line.
+type OrderLineComponent struct {
+	ID          int64  `json:"-" gorm:"primaryKey"`
+	OrderLineID int64  `json:"-" gorm:"not null;index"`
+	ProductID   int64  `json:"product_id" gorm:"not null;index"`
+	VariantID   *int64 `json:"variant_id" gorm:"index"`
+	Quantity    int    `json:"quantity"`
+}
+
+// SellerOrder is the part of an order that concerns a single seller: only
+// the lines of their products and the subtotal of those lines.
+type SellerOrder struct {
+	ID          int64        `json:"id"`
 	PaymentType PaymentType  `json:"payment_type"`
 	Customer    CustomerInfo `json:"customer"`
+	Lines       []OrderLine  `json:"lines"`
+	Subtotal    float64      `json:"subtotal"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+// ForSeller returns the view of the order for the given seller.
+func (o Order) ForSeller(sellerID uint) SellerOrder {
+	view := SellerOrder{
+		ID:          o.ID,
+		PaymentType: o.PaymentType,
+		Customer:    o.Customer,
+		Lines:       []OrderLine{},
+		CreatedAt:   o.CreatedAt,
+	}
+	for _, line := range o.Lines {
+		if line.SellerID != nil && *line.SellerID == sellerID {
+			view.Lines = append(view.Lines, line)
+			view.Subtotal += line.Total
+		}
+	}
+	return view
 }
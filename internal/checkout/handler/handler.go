@@ -1,11 +1,14 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rkweber-max/checkout-backend/internal/checkout/domain"
 	"github.com/rkweber-max/checkout-backend/internal/checkout/service"
+	"github.com/rkweber-max/checkout-backend/internal/middleware"
 )
 
 type CheckoutHandler struct {
@@ -23,8 +26,60 @@ func (h *CheckoutHandler) Checkout(c *gin.Context) {
 		return
 	}
 	
-	order, err := h.service.ProcessOrder(request)
+	// Orders placed by customers are linked to their account; counter
+	// sales rung up by employees are not.
+	var customerID *uint
+	if middleware.HasRole(c, "customer") {
+		if userID, ok := middleware.CurrentUserID(c); ok {
+			customerID = &userID
+		}
+	}
+
+	order, err := h.service.ProcessOrder(c.Request.Context(), request, customerID)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidItem):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		case errors.Is(err, domain.ErrInsufficientStock):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, domain.ErrEmailNotVerified):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// GetSellerOrders lists the orders containing the authenticated seller's
+// products, showing only their lines.
+func (h *CheckoutHandler) GetSellerOrders(c *gin.Context) {
+	sellerID, _ := middleware.CurrentUserID(c)
+	orders, err := h.service.SellerOrders(c.Request.Context(), sellerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, orders)
+}
+
+func (h *CheckoutHandler) GetSellerOrder(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order ID"})
+		return
+	}
+
+	sellerID, _ := middleware.CurrentUserID(c)
+	order, err := h.service.SellerOrder(c.Request.Context(), sellerID, id)
 	if err != nil {
+		if errors.Is(err, domain.ErrOrderNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rkweber-max/checkout-backend/internal/checkout/domain"
+	inventory "github.com/rkweber-max/checkout-backend/internal/inventory/domain"
+	inventoryRepository "github.com/rkweber-max/checkout-backend/internal/inventory/repository"
+	"github.com/rkweber-max/checkout-backend/internal/product"
+	"gorm.io/gorm"
+)
+
+type OrderRepository interface {
+	Create(ctx context.Context, order *domain.Order) error
+	FindBySeller(ctx context.Context, sellerID uint) ([]domain.Order, error)
+	FindByID(ctx context.Context, id int64) (*domain.Order, error)
+}
+
+type orderRepository struct {
+	db *gorm.DB
+}
+
+func NewOrderRepository(db *gorm.DB) OrderRepository {
+	return &orderRepository{db: db}
+}
+
+// Create stores the order and takes the stock of every variant it
+// references, including the components of bundle lines, out of the
+// warehouse each line ships from. The whole operation fails if any variant
+// runs out of stock.
+func (r *orderRepository) Create(ctx context.Context, order *domain.Order) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(order).Error; err != nil {
+			return err
+		}
+
+		for _, line := range order.Lines {
+			if line.VariantID != nil {
+				if err := takeStock(tx, order.ID, line.WarehouseID, *line.VariantID, line.Quantity); err != nil {
+					return fmt.Errorf("%w for variant %s", err, line.SKU)
+				}
+			}
+			for _, component := range line.Components {
+				if component.VariantID == nil {
+					continue
+				}
+				if err := takeStock(tx, order.ID, line.WarehouseID, *component.VariantID, component.Quantity); err != nil {
+					return fmt.Errorf("%w for a component of %s", err, line.Name)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// takeStock records the sale in the stock ledger of the warehouse. Orders
+// placed before any warehouse exists only decrement the variant stock.
+func takeStock(tx *gorm.DB, orderID int64, warehouseID *int64, variantID int64, quantity int) error {
+	if warehouseID != nil {
+		return inventoryRepository.ApplyMovement(tx, &inventory.StockMovement{
+			WarehouseID: *warehouseID,
+			VariantID:   variantID,
+			Quantity:    -quantity,
+			Reason:      inventory.MovementSale,
+			Reference:   fmt.Sprintf("order:%d", orderID),
+		})
+	}
+
+	result := tx.Model(&product.Variant{}).
+		Where("id = ? AND stock >= ?", variantID, quantity).
+		Update("stock", gorm.Expr("stock - ?", quantity))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return domain.ErrInsufficientStock
+	}
+	return nil
+}
+
+// FindBySeller returns the orders containing at least one line of the
+// seller's products, newest first.
+func (r *orderRepository) FindBySeller(ctx context.Context, sellerID uint) ([]domain.Order, error) {
+	var orders []domain.Order
+	err := r.db.WithContext(ctx).
+		Preload("Lines.Components").
+		Where("id IN (?)", r.db.Model(&domain.OrderLine{}).Select("order_id").Where("seller_id = ?", sellerID)).
+		Order("created_at DESC").
+		Find(&orders).Error
+	if err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+func (r *orderRepository) FindByID(ctx context.Context, id int64) (*domain.Order, error) {
+	var order domain.Order
+	if err := r.db.WithContext(ctx).Preload("Lines.Components").First(&order, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &order, nil
+}
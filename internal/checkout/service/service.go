@@ -4,33 +4,92 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/rkweber-max/checkout-backend/internal/checkout/domain"
+	orderRepository "github.com/rkweber-max/checkout-backend/internal/checkout/repository"
+	inventory "github.com/rkweber-max/checkout-backend/internal/inventory/domain"
+	inventoryService "github.com/rkweber-max/checkout-backend/internal/inventory/service"
+	"github.com/rkweber-max/checkout-backend/internal/product"
 	"github.com/rkweber-max/checkout-backend/internal/product/repository"
+	productService "github.com/rkweber-max/checkout-backend/internal/product/service"
+	userRepository "github.com/rkweber-max/checkout-backend/internal/user/repository"
+	"github.com/rkweber-max/checkout-backend/pkg/gtin"
 )
 
 type CheckoutService struct {
-	repo repository.ProductRepository
+	repo        repository.ProductRepository
+	variantRepo repository.VariantRepository
+	orderRepo   orderRepository.OrderRepository
+	prices      productService.PriceService
+	bundles     productService.BundleService
+	inventory   inventoryService.InventoryService
+	costs       inventoryService.PurchasingService
+	users       userRepository.UserRepository
 }
 
-func NewCheckoutService(repo repository.ProductRepository) *CheckoutService {
-	return &CheckoutService{repo: repo}
+func NewCheckoutService(
+	repo repository.ProductRepository,
+	variantRepo repository.VariantRepository,
+	orderRepo orderRepository.OrderRepository,
+	prices productService.PriceService,
+	bundles productService.BundleService,
+	inventory inventoryService.InventoryService,
+	costs inventoryService.PurchasingService,
+	users userRepository.UserRepository,
+) *CheckoutService {
+	return &CheckoutService{
+		repo:        repo,
+		variantRepo: variantRepo,
+		orderRepo:   orderRepo,
+		prices:      prices,
+		bundles:     bundles,
+		inventory:   inventory,
+		costs:       costs,
+		users:       users,
+	}
 }
 
-func (s *CheckoutService) ProcessOrder(order domain.CheckoutRequest) (*domain.Order, error) {
-	if len(order.ProductIDs) == 0 {
+// ProcessOrder prices, sources and stores the order. customerID is the
+// account of the customer placing it, if any; that account must have a
+// verified email.
+func (s *CheckoutService) ProcessOrder(ctx context.Context, order domain.CheckoutRequest, customerID *uint) (*domain.Order, error) {
+	if len(order.Items) == 0 {
 		return nil, errors.New("order must contain at least one item")
 	}
 
-	ctx := context.Background()
+	if customerID != nil {
+		customer, err := s.users.FindByID(*customerID)
+		if err != nil {
+			return nil, err
+		}
+		if customer == nil || !customer.EmailVerified() {
+			return nil, domain.ErrEmailNotVerified
+		}
+	}
+
+	orderedAt := time.Now()
+	var lines []domain.OrderLine
 	var prices []float64
 
-	for _, productID := range order.ProductIDs {
-		product, err := s.repo.FindByID(ctx, int64(productID))
+	for _, item := range order.Items {
+		line, err := s.buildLine(ctx, item, orderedAt)
 		if err != nil {
-			return nil, fmt.Errorf("product with ID %d not found: %w", productID, err)
+			return nil, err
 		}
-		prices = append(prices, product.Price)
+		if line.UnitCost, err = s.unitCost(ctx, *line); err != nil {
+			return nil, err
+		}
+		lines = append(lines, *line)
+	}
+
+	lines, err := s.source(ctx, order.Customer.CEP, lines)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range lines {
+		prices = append(prices, line.Total)
 	}
 
 	total := domain.CalculateTotalPrice(prices, order.PaymentType)
@@ -38,7 +97,259 @@ func (s *CheckoutService) ProcessOrder(order domain.CheckoutRequest) (*domain.Or
 		Total:       total,
 		PaymentType: order.PaymentType,
 		Customer:    order.Customer,
+		CustomerID:  customerID,
+		Lines:       lines,
+		CreatedAt:   orderedAt,
+	}
+
+	if err := s.orderRepo.Create(ctx, newOrder); err != nil {
+		return nil, err
 	}
 
 	return newOrder, nil
 }
+
+// unitCost returns the average cost of one unit of the line. A bundle costs
+// what its components cost.
+func (s *CheckoutService) unitCost(ctx context.Context, line domain.OrderLine) (float64, error) {
+	if len(line.Components) == 0 {
+		cost, err := s.costs.ProductCost(ctx, line.ProductID)
+		if err != nil {
+			return 0, err
+		}
+		return cost.AverageCost, nil
+	}
+
+	var total float64
+	for _, component := range line.Components {
+		cost, err := s.costs.ProductCost(ctx, component.ProductID)
+		if err != nil {
+			return 0, err
+		}
+		total += cost.AverageCost * float64(component.Quantity)
+	}
+	return total / float64(line.Quantity), nil
+}
+
+// source assigns every line to the warehouse it ships from, splitting
+// lines whose quantity comes from more than one warehouse.
+func (s *CheckoutService) source(ctx context.Context, cep string, lines []domain.OrderLine) ([]domain.OrderLine, error) {
+	requests := make([]inventory.SourcingRequest, len(lines))
+	for i, line := range lines {
+		request := inventory.SourcingRequest{Quantity: line.Quantity, Splittable: len(line.Components) == 0}
+		if len(line.Components) == 0 {
+			demand := inventory.Demand{Quantity: line.Quantity}
+			if line.VariantID != nil {
+				demand.VariantID = *line.VariantID
+			}
+			request.Items = []inventory.Demand{demand}
+		}
+		for _, component := range line.Components {
+			demand := inventory.Demand{Quantity: component.Quantity}
+			if component.VariantID != nil {
+				demand.VariantID = *component.VariantID
+			}
+			request.Items = append(request.Items, demand)
+		}
+		requests[i] = request
+	}
+
+	allocations, err := s.inventory.Source(ctx, cep, requests)
+	if err != nil {
+		return nil, err
+	}
+	if allocations == nil {
+		return lines, nil
+	}
+
+	var sourced []domain.OrderLine
+	for i, line := range lines {
+		for _, allocation := range allocations[i] {
+			split := line
+			warehouseID := allocation.WarehouseID
+			split.WarehouseID = &warehouseID
+			split.Quantity = allocation.Quantity
+			split.Total = split.UnitPrice * float64(split.Quantity)
+			sourced = append(sourced, split)
+		}
+	}
+	return sourced, nil
+}
+
+// SellerOrders returns the orders of the seller's products, restricted to
+// the seller's own lines.
+func (s *CheckoutService) SellerOrders(ctx context.Context, sellerID uint) ([]domain.SellerOrder, error) {
+	orders, err := s.orderRepo.FindBySeller(ctx, sellerID)
+	if err != nil {
+		return nil, err
+	}
+
+	views := make([]domain.SellerOrder, 0, len(orders))
+	for _, order := range orders {
+		views = append(views, order.ForSeller(sellerID))
+	}
+	return views, nil
+}
+
+// SellerOrder returns a single order as seen by the seller. Orders without
+// any of the seller's lines are reported as not found.
+func (s *CheckoutService) SellerOrder(ctx context.Context, sellerID uint, orderID int64) (*domain.SellerOrder, error) {
+	order, err := s.orderRepo.FindByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return nil, domain.ErrOrderNotFound
+	}
+
+	view := order.ForSeller(sellerID)
+	if len(view.Lines) == 0 {
+		return nil, domain.ErrOrderNotFound
+	}
+	return &view, nil
+}
+
+// bundleLine prices a bundle as a single line and lists the component
+// quantities it takes out of stock.
+func bundleLine(p product.Product, bundle product.Bundle, item domain.LineItem) (*domain.OrderLine, error) {
+	if item.VariantID != nil {
+		return nil, fmt.Errorf("%w: bundle %d has no variants", domain.ErrInvalidItem, p.ID)
+	}
+	if bundle.Available != nil && *bundle.Available < item.Quantity {
+		return nil, fmt.Errorf("%w for bundle %s", domain.ErrInsufficientStock, p.Name)
+	}
+
+	line := &domain.OrderLine{
+		ProductID: p.ID,
+		SellerID:  p.OwnerID,
+		SKU:       p.SKU,
+		Name:      p.Name,
+		Quantity:  item.Quantity,
+		UnitPrice: bundle.Price,
+		Total:     bundle.Price * float64(item.Quantity),
+	}
+	for _, component := range bundle.Components {
+		line.Components = append(line.Components, domain.OrderLineComponent{
+			ProductID: component.ProductID,
+			VariantID: component.VariantID,
+			Quantity:  component.Quantity * item.Quantity,
+		})
+	}
+	return line, nil
+}
+
+// resolveBarcode fills in the product and variant of a scanned item. The
+// barcode of a variant takes precedence over the GTIN of a product.
+func (s *CheckoutService) resolveBarcode(ctx context.Context, item *domain.LineItem) error {
+	code := strings.TrimSpace(item.Barcode)
+	if code == "" {
+		return fmt.Errorf("%w: product_id or barcode is required", domain.ErrInvalidItem)
+	}
+	normalized, err := gtin.Normalize(code)
+	if err != nil {
+		return fmt.Errorf("%w: %v", domain.ErrInvalidItem, err)
+	}
+
+	variant, err := s.variantRepo.FindByBarcode(ctx, normalized)
+	if err != nil {
+		return err
+	}
+	if variant != nil {
+		item.ProductID = variant.ProductID
+		item.VariantID = &variant.ID
+		return nil
+	}
+
+	p, err := s.repo.FindByGTIN(ctx, normalized)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return fmt.Errorf("%w: no product with barcode %s", domain.ErrInvalidItem, item.Barcode)
+	}
+	item.ProductID = p.ID
+	return nil
+}
+
+// findProduct resolves the product of an item by ID or, when the item was
+// scanned, by barcode.
+func (s *CheckoutService) findProduct(ctx context.Context, item *domain.LineItem) (*product.Product, error) {
+	if item.ProductID == 0 {
+		if err := s.resolveBarcode(ctx, item); err != nil {
+			return nil, err
+		}
+	}
+
+	p, err := s.repo.FindByID(ctx, item.ProductID)
+	if err != nil {
+		return nil, fmt.Errorf("product with ID %d not found: %w", item.ProductID, err)
+	}
+	if p == nil {
+		return nil, fmt.Errorf("%w: product with ID %d not found", domain.ErrInvalidItem, item.ProductID)
+	}
+	return p, nil
+}
+
+// buildLine resolves the product and variant of an item and prices it at
+// the order time. Products that are sold in variants must be bought through
+// one of them.
+func (s *CheckoutService) buildLine(ctx context.Context, item domain.LineItem, at time.Time) (*domain.OrderLine, error) {
+	if item.Quantity <= 0 {
+		return nil, fmt.Errorf("%w: quantity must be positive", domain.ErrInvalidItem)
+	}
+
+	product, err := s.findProduct(ctx, &item)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle, err := s.bundles.Quote(ctx, product.ID, at)
+	if err != nil {
+		return nil, err
+	}
+	if bundle != nil {
+		return bundleLine(*product, *bundle, item)
+	}
+
+	price, err := s.prices.EffectivePrice(ctx, *product, at)
+	if err != nil {
+		return nil, err
+	}
+	product.Price = price
+
+	line := &domain.OrderLine{
+		ProductID: product.ID,
+		SellerID:  product.OwnerID,
+		Name:      product.Name,
+		Quantity:  item.Quantity,
+		UnitPrice: product.Price,
+	}
+
+	if item.VariantID == nil {
+		variants, err := s.variantRepo.CountByProductID(ctx, product.ID)
+		if err != nil {
+			return nil, err
+		}
+		if variants > 0 {
+			return nil, fmt.Errorf("%w: product with ID %d requires a variant", domain.ErrInvalidItem, product.ID)
+		}
+	} else {
+		variant, err := s.variantRepo.FindByID(ctx, *item.VariantID)
+		if err != nil {
+			return nil, err
+		}
+		if variant == nil || variant.ProductID != product.ID {
+			return nil, fmt.Errorf("%w: variant with ID %d not found for product %d", domain.ErrInvalidItem, *item.VariantID, product.ID)
+		}
+		if variant.Stock < item.Quantity {
+			return nil, fmt.Errorf("%w for variant %s", domain.ErrInsufficientStock, variant.SKU)
+		}
+
+		line.VariantID = &variant.ID
+		line.SKU = variant.SKU
+		line.UnitPrice = variant.EffectivePrice(*product)
+	}
+
+	line.Total = line.UnitPrice * float64(line.Quantity)
+	return line, nil
+}
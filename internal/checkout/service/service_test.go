@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rkweber-max/checkout-backend/internal/checkout/domain"
+	inventory "github.com/rkweber-max/checkout-backend/internal/inventory/domain"
+	inventoryService "github.com/rkweber-max/checkout-backend/internal/inventory/service"
+)
+
+// fixedCosts returns a stored average cost per product. Other
+// PurchasingService methods are not expected to be called.
+type fixedCosts struct {
+	inventoryService.PurchasingService
+
+	costs map[int64]float64
+}
+
+func (f fixedCosts) ProductCost(ctx context.Context, productID int64) (*inventory.ProductCost, error) {
+	return &inventory.ProductCost{ProductID: productID, AverageCost: f.costs[productID]}, nil
+}
+
+func TestUnitCost(t *testing.T) {
+	s := &CheckoutService{costs: fixedCosts{costs: map[int64]float64{1: 4.5, 2: 12, 3: 0}}}
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		line domain.OrderLine
+		want float64
+	}{
+		{"product", domain.OrderLine{ProductID: 2, Quantity: 3}, 12},
+		{"never received", domain.OrderLine{ProductID: 3, Quantity: 1}, 0},
+		{
+			// Two kits of 2 mugs and 1 T-shirt: the components are
+			// already multiplied by the number of kits.
+			"bundle",
+			domain.OrderLine{ProductID: 10, Quantity: 2, Components: []domain.OrderLineComponent{
+				{ProductID: 1, Quantity: 4},
+				{ProductID: 2, Quantity: 2},
+			}},
+			21,
+		},
+	}
+	for _, tt := range tests {
+		got, err := s.unitCost(ctx, tt.line)
+		if err != nil {
+			t.Fatalf("%s: unitCost: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: unitCost = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
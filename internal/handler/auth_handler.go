@@ -1,19 +1,27 @@
 package handler
 
 import (
+	"errors"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rkweber-max/checkout-backend/internal/middleware"
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
 	"github.com/rkweber-max/checkout-backend/internal/user/service"
 )
 
 type AuthHandler struct {
-	service service.UserService
+	logins service.LoginService
+	mfa    service.MFAService
+	tokens service.TokenService
+	keys   service.KeyService
 }
 
-func NewAuthHandler(service service.UserService) *AuthHandler {
-	return &AuthHandler{service: service}
+func NewAuthHandler(logins service.LoginService, mfa service.MFAService, tokens service.TokenService, keys service.KeyService) *AuthHandler {
+	return &AuthHandler{logins: logins, mfa: mfa, tokens: tokens, keys: keys}
 }
 
 type LoginRequest struct {
@@ -21,6 +29,14 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req LoginRequest
 
@@ -29,15 +45,107 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	token, err := h.service.Login(req.Email, req.Password)
+	user, err := h.logins.Authenticate(c.Request.Context(), req.Email, req.Password, c.ClientIP())
+	if err != nil {
+		var limited *service.RateLimitError
+		switch {
+		case errors.As(err, &limited):
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(limited.RetryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrInvalidCredentials):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrSSORequired):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			log.Printf("Login error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "login failed"})
+		}
+		return
+	}
+
+	completeLogin(c, h.mfa, h.tokens, user)
+}
+
+// completeLogin answers a successful first login step. Users with
+// two-factor authentication, or whose role requires it, get a challenge
+// to complete at /api/login/mfa instead of tokens.
+func completeLogin(c *gin.Context, mfa service.MFAService, tokens service.TokenService, user *domain.User) {
+	challenge, err := mfa.Challenge(c.Request.Context(), user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if challenge != nil {
+		c.JSON(http.StatusOK, challenge)
+		return
+	}
+
+	issued, err := tokens.Issue(c.Request.Context(), user)
 	if err != nil {
-		log.Printf("Login error: %v", err)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"access_token": token,
-		"message":      "Login successful",
+		"access_token":  issued.AccessToken,
+		"token_type":    issued.TokenType,
+		"expires_in":    issued.ExpiresIn,
+		"refresh_token": issued.RefreshToken,
+		"message":       "Login successful",
 	})
 }
+
+// Refresh exchanges a refresh token for a new access token and a new
+// refresh token. The old refresh token stops working.
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := h.tokens.Refresh(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidRefreshToken) || errors.Is(err, service.ErrRefreshTokenReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout revokes the access token of the request and, when sent, the
+// refresh token of the session.
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req LogoutRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	jti, expiresAt := middleware.CurrentToken(c)
+	if err := h.tokens.Logout(c.Request.Context(), jti, expiresAt, req.RefreshToken); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// JWKS publishes the public keys access tokens are signed with, so other
+// services can verify them without sharing a secret.
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	set, err := h.keys.JWKS(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, set)
+}
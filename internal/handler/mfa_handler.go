@@ -0,0 +1,269 @@
+package handler
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rkweber-max/checkout-backend/internal/middleware"
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
+	"github.com/rkweber-max/checkout-backend/internal/user/service"
+)
+
+type MFAHandler struct {
+	mfa    service.MFAService
+	users  service.UserService
+	tokens service.TokenService
+}
+
+func NewMFAHandler(mfa service.MFAService, users service.UserService, tokens service.TokenService) *MFAHandler {
+	return &MFAHandler{mfa: mfa, users: users, tokens: tokens}
+}
+
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type MFAPolicyRequest struct {
+	Required *bool `json:"required" binding:"required"`
+}
+
+// LoginVerify completes a login that returned an MFA challenge. The code
+// is a TOTP code or a recovery code.
+func (h *MFAHandler) LoginVerify(c *gin.Context) {
+	var req MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mfa_token and code are required"})
+		return
+	}
+
+	user, err := h.mfa.ChallengeUser(c.Request.Context(), req.MFAToken, false)
+	if err != nil {
+		respondMFAError(c, err)
+		return
+	}
+	if err := h.mfa.Verify(c.Request.Context(), user.ID, req.Code); err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	tokens, err := h.tokens.Issue(c.Request.Context(), user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// LoginEnroll starts the TOTP setup a login required before it can
+// complete.
+func (h *MFAHandler) LoginEnroll(c *gin.Context) {
+	var req MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.mfa.ChallengeUser(c.Request.Context(), req.MFAToken, true)
+	if err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	setup, err := h.mfa.Enroll(c.Request.Context(), user)
+	if err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, setup)
+}
+
+// LoginConfirm finishes the setup started by LoginEnroll and completes the
+// login, returning the tokens along with the recovery codes.
+func (h *MFAHandler) LoginConfirm(c *gin.Context) {
+	var req MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mfa_token and code are required"})
+		return
+	}
+
+	user, err := h.mfa.ChallengeUser(c.Request.Context(), req.MFAToken, true)
+	if err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	codes, err := h.mfa.Confirm(c.Request.Context(), user.ID, req.Code)
+	if err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	tokens, err := h.tokens.Issue(c.Request.Context(), user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token":   tokens.AccessToken,
+		"token_type":     tokens.TokenType,
+		"expires_in":     tokens.ExpiresIn,
+		"refresh_token":  tokens.RefreshToken,
+		"recovery_codes": codes,
+	})
+}
+
+// Enroll starts TOTP setup for the authenticated user.
+func (h *MFAHandler) Enroll(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	setup, err := h.mfa.Enroll(c.Request.Context(), user)
+	if err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, setup)
+}
+
+// Confirm activates the authenticated user's pending enrollment.
+func (h *MFAHandler) Confirm(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := middleware.CurrentUserID(c)
+	codes, err := h.mfa.Confirm(c.Request.Context(), userID, req.Code)
+	if err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := middleware.CurrentUserID(c)
+	codes, err := h.mfa.RegenerateRecoveryCodes(c.Request.Context(), userID, req.Code)
+	if err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+func (h *MFAHandler) Disable(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	if err := h.mfa.Disable(c.Request.Context(), user, req.Code); err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Reset lets an admin remove a user's two-factor setup.
+func (h *MFAHandler) Reset(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	if err := h.mfa.Reset(c.Request.Context(), uint(id)); err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *MFAHandler) Policies(c *gin.Context) {
+	policies, err := h.mfa.Policies(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, policies)
+}
+
+func (h *MFAHandler) SetPolicy(c *gin.Context) {
+	var req MFAPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy, err := h.mfa.SetPolicy(c.Request.Context(), domain.Role(c.Param("role")), *req.Required)
+	if err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+func (h *MFAHandler) currentUser(c *gin.Context) (*domain.User, bool) {
+	userID, _ := middleware.CurrentUserID(c)
+	user, err := h.users.GetByID(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": service.ErrUserNotFound.Error()})
+		return nil, false
+	}
+	return user, true
+}
+
+func respondMFAError(c *gin.Context, err error) {
+	var limited *service.RateLimitError
+	switch {
+	case errors.As(err, &limited):
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(limited.RetryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidMFAToken), errors.Is(err, service.ErrInvalidMFACode):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrMFARequiredForRole):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrMFAAlreadyEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrUserNotFound), errors.Is(err, service.ErrRoleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
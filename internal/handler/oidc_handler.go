@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rkweber-max/checkout-backend/internal/user/service"
+)
+
+const (
+	oidcStateCookie = "oidc_state"
+	oidcCookiePath  = "/api/login/oidc"
+)
+
+type OIDCHandler struct {
+	oidc   service.OIDCService
+	mfa    service.MFAService
+	tokens service.TokenService
+}
+
+func NewOIDCHandler(oidc service.OIDCService, mfa service.MFAService, tokens service.TokenService) *OIDCHandler {
+	return &OIDCHandler{oidc: oidc, mfa: mfa, tokens: tokens}
+}
+
+// Start sends the browser to the identity provider. The state also goes
+// into a cookie, so the callback only completes in the browser that
+// started the sign-in.
+func (h *OIDCHandler) Start(c *gin.Context) {
+	login, err := h.oidc.Begin(c.Request.Context())
+	if err != nil {
+		respondOIDCError(c, err)
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, login.State, login.ExpiresIn, oidcCookiePath, "", secureRequest(c), true)
+	c.Redirect(http.StatusFound, login.AuthorizationURL)
+}
+
+// Callback completes the sign-in the identity provider sent the browser
+// back from, answering like POST /api/login.
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	if !h.oidc.Enabled() {
+		respondOIDCError(c, service.ErrOIDCDisabled)
+		return
+	}
+
+	cookie, _ := c.Cookie(oidcStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, "", -1, oidcCookiePath, "", secureRequest(c), true)
+
+	if reason := c.Query("error"); reason != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": reason, "description": c.Query("error_description")})
+		return
+	}
+
+	state, code := c.Query("state"), c.Query("code")
+	if state == "" || code == "" || subtle.ConstantTimeCompare([]byte(state), []byte(cookie)) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": service.ErrInvalidOIDCState.Error()})
+		return
+	}
+
+	user, err := h.oidc.Complete(c.Request.Context(), state, code)
+	if err != nil {
+		respondOIDCError(c, err)
+		return
+	}
+
+	completeLogin(c, h.mfa, h.tokens, user)
+}
+
+func secureRequest(c *gin.Context) bool {
+	return c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+}
+
+func respondOIDCError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrOIDCDisabled):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidOIDCState):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrOIDCNoRole), errors.Is(err, service.ErrOIDCEmailNotVerified):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrOIDCFailed):
+		// The provider's answer may say more than the user should see.
+		log.Printf("OIDC sign-in failed: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": service.ErrOIDCFailed.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
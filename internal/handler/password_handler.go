@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rkweber-max/checkout-backend/internal/user/service"
+)
+
+type PasswordHandler struct {
+	service service.PasswordResetService
+}
+
+func NewPasswordHandler(service service.PasswordResetService) *PasswordHandler {
+	return &PasswordHandler{service: service}
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+// Forgot always answers 202 so it can't be used to find out which
+// addresses are registered.
+func (h *PasswordHandler) Forgot(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.Forgot(c.Request.Context(), req.Email, c.ClientIP()); err != nil {
+		respondPasswordError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "If the address belongs to an account, a password reset link is on its way.",
+	})
+}
+
+// Reset chooses a new password with the token from the reset link. Every
+// session of the account is logged out.
+func (h *PasswordHandler) Reset(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.Reset(c.Request.Context(), req.Token, req.Password, c.ClientIP()); err != nil {
+		respondPasswordError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password updated. Log in with your new password."})
+}
+
+func respondPasswordError(c *gin.Context, err error) {
+	var limited *service.RateLimitError
+	switch {
+	case errors.As(err, &limited):
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(limited.RetryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidResetToken):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
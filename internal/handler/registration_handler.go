@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
+	"github.com/rkweber-max/checkout-backend/internal/user/service"
+)
+
+type RegistrationHandler struct {
+	service service.RegistrationService
+}
+
+func NewRegistrationHandler(service service.RegistrationService) *RegistrationHandler {
+	return &RegistrationHandler{service: service}
+}
+
+type RegisterRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// Register creates a customer account. The customer can log in right away
+// but must verify their email before checking out.
+func (h *RegistrationHandler) Register(c *gin.Context) {
+	var req RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := &domain.User{
+		Name:     req.Name,
+		Email:    req.Email,
+		Password: req.Password,
+	}
+	if err := h.service.Register(c.Request.Context(), user); err != nil {
+		if errors.Is(err, service.ErrEmailInUse) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"id":      user.ID,
+		"message": "Registration successful. Check your email to verify your address.",
+	})
+}
+
+// Verify confirms the email address from the link sent on registration.
+func (h *RegistrationHandler) Verify(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing token"})
+		return
+	}
+
+	user, err := h.service.Verify(c.Request.Context(), token)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidVerificationToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":           "Email verified",
+		"email_verified_at": user.EmailVerifiedAt,
+	})
+}
+
+// ResendVerification always answers 202 so it can't be used to find out
+// which addresses are registered.
+func (h *RegistrationHandler) ResendVerification(c *gin.Context) {
+	var req ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.ResendVerification(c.Request.Context(), req.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "If the address belongs to an unverified account, a new link is on its way.",
+	})
+}
@@ -0,0 +1,19 @@
+package domain
+
+import "errors"
+
+var (
+	ErrWarehouseNotFound  = errors.New("warehouse not found")
+	ErrTransferNotFound   = errors.New("transfer not found")
+	ErrTransferNotPending = errors.New("only transfers in transit can be received or cancelled")
+	ErrInsufficientStock  = errors.New("insufficient stock")
+
+	ErrSupplierNotFound         = errors.New("supplier not found")
+	ErrPurchaseOrderNotFound    = errors.New("purchase order not found")
+	ErrPurchaseOrderClosed      = errors.New("purchase order is no longer open")
+	ErrPurchaseOrderNotDraft    = errors.New("only draft purchase orders can be submitted")
+	ErrPurchaseOrderHasReceipts = errors.New("purchase order already has receipts")
+	ErrOverReceipt              = errors.New("received quantity exceeds the outstanding quantity")
+
+	ErrReorderRuleNotFound = errors.New("reorder rule not found")
+)
@@ -0,0 +1,85 @@
+package domain
+
+import "time"
+
+// Warehouse is a location stock is kept and shipped from. CEP is the
+// Brazilian postal code of the location, used to pick the warehouse
+// closest to a customer.
+type Warehouse struct {
+	ID        int64     `json:"id" gorm:"primaryKey"`
+	Code      string    `json:"code" gorm:"uniqueIndex;not null"`
+	Name      string    `json:"name" gorm:"not null"`
+	CEP       string    `json:"cep" gorm:"type:varchar(8)"`
+	Active    bool      `json:"active" gorm:"not null;default:true"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// StockLevel is the stock of a variant in one warehouse. OnHand can be
+// sold; Incoming is on its way from another warehouse and cannot be sold
+// until the transfer is received. The variant stock is the sum of OnHand
+// across warehouses.
+type StockLevel struct {
+	WarehouseID int64     `json:"warehouse_id" gorm:"primaryKey;autoIncrement:false"`
+	VariantID   int64     `json:"variant_id" gorm:"primaryKey;autoIncrement:false;index"`
+	OnHand      int       `json:"on_hand" gorm:"not null;default:0"`
+	Incoming    int       `json:"incoming" gorm:"not null;default:0"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type MovementReason string
+
+const (
+	MovementOpeningBalance MovementReason = "opening_balance"
+	MovementAdjustment     MovementReason = "adjustment"
+	MovementSale           MovementReason = "sale"
+	MovementTransferOut    MovementReason = "transfer_out"
+	MovementTransferIn     MovementReason = "transfer_in"
+	MovementTransferCancel MovementReason = "transfer_cancel"
+	MovementReceipt        MovementReason = "receipt"
+)
+
+// StockMovement is an entry of the stock ledger. Every change to the on-hand
+// stock of a warehouse is recorded as a movement, positive when stock comes
+// in and negative when it goes out. Reference points to the document that
+// caused it, such as "order:12" or "transfer:3".
+type StockMovement struct {
+	ID          int64          `json:"id" gorm:"primaryKey"`
+	WarehouseID int64          `json:"warehouse_id" gorm:"not null;index"`
+	VariantID   int64          `json:"variant_id" gorm:"not null;index"`
+	Quantity    int            `json:"quantity" gorm:"not null"`
+	Reason      MovementReason `json:"reason" gorm:"type:varchar(30);not null"`
+	Reference   string         `json:"reference,omitempty"`
+	Note        string         `json:"note,omitempty"`
+	CreatedBy   *uint          `json:"created_by,omitempty"`
+	CreatedAt   time.Time      `json:"created_at" gorm:"index"`
+}
+
+type TransferStatus string
+
+const (
+	TransferInTransit TransferStatus = "in_transit"
+	TransferReceived  TransferStatus = "received"
+	TransferCancelled TransferStatus = "cancelled"
+)
+
+// Transfer moves stock between warehouses. Stock leaves the source when the
+// transfer is created and only reaches the destination when it is
+// received; in between it is counted as incoming at the destination.
+type Transfer struct {
+	ID              int64          `json:"id" gorm:"primaryKey"`
+	FromWarehouseID int64          `json:"from_warehouse_id" gorm:"not null;index"`
+	ToWarehouseID   int64          `json:"to_warehouse_id" gorm:"not null;index"`
+	Status          TransferStatus `json:"status" gorm:"type:varchar(20);not null;index"`
+	Lines           []TransferLine `json:"lines" gorm:"constraint:OnDelete:CASCADE"`
+	CreatedBy       *uint          `json:"created_by,omitempty"`
+	CreatedAt       time.Time      `json:"created_at"`
+	ReceivedAt      *time.Time     `json:"received_at,omitempty"`
+}
+
+type TransferLine struct {
+	ID         int64 `json:"id" gorm:"primaryKey"`
+	TransferID int64 `json:"-" gorm:"not null;index"`
+	VariantID  int64 `json:"variant_id" gorm:"not null;index"`
+	Quantity   int   `json:"quantity" gorm:"not null"`
+}
@@ -0,0 +1,139 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+type Supplier struct {
+	ID        int64     `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"not null"`
+	Document  string    `json:"document,omitempty" gorm:"uniqueIndex:idx_suppliers_document,where:document <> ''"`
+	Email     string    `json:"email,omitempty"`
+	Phone     string    `json:"phone,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type PurchaseOrderStatus string
+
+const (
+	PurchaseOrderDraft             PurchaseOrderStatus = "draft"
+	PurchaseOrderOpen              PurchaseOrderStatus = "open"
+	PurchaseOrderPartiallyReceived PurchaseOrderStatus = "partially_received"
+	PurchaseOrderReceived          PurchaseOrderStatus = "received"
+	PurchaseOrderCancelled         PurchaseOrderStatus = "cancelled"
+)
+
+// Receivable reports whether goods can still be received against the
+// order.
+func (s PurchaseOrderStatus) Receivable() bool {
+	return s == PurchaseOrderOpen || s == PurchaseOrderPartiallyReceived
+}
+
+// PurchaseOrder is an order placed with a supplier for delivery into a
+// warehouse. Goods may arrive in several deliveries; each one is booked
+// with Receive and increments the stock through the ledger. Drafts, such as
+// those suggested by the low-stock job, must be submitted before goods can
+// be received.
+type PurchaseOrder struct {
+	ID          int64               `json:"id" gorm:"primaryKey"`
+	SupplierID  int64               `json:"supplier_id" gorm:"not null;index"`
+	WarehouseID int64               `json:"warehouse_id" gorm:"not null;index"`
+	Status      PurchaseOrderStatus `json:"status" gorm:"type:varchar(20);not null;index"`
+	ExpectedAt  *time.Time          `json:"expected_at,omitempty"`
+	Notes       string              `json:"notes,omitempty"`
+	Lines       []PurchaseOrderLine `json:"lines" gorm:"constraint:OnDelete:CASCADE"`
+	CreatedBy   *uint               `json:"created_by,omitempty"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+}
+
+// Receive adds a delivery to the received quantities of the lines and
+// updates the status: received once nothing is outstanding, partially
+// received otherwise. The order is left unchanged if the delivery names an
+// unknown line or exceeds what is outstanding on one.
+func (po *PurchaseOrder) Receive(receipts []ReceiptLine) error {
+	if !po.Status.Receivable() {
+		return ErrPurchaseOrderClosed
+	}
+
+	received := make(map[int64]int, len(receipts))
+	for _, receipt := range receipts {
+		received[receipt.LineID] += receipt.Quantity
+	}
+	for lineID, quantity := range received {
+		line := po.Line(lineID)
+		if line == nil {
+			return fmt.Errorf("purchase order %d has no line %d", po.ID, lineID)
+		}
+		if quantity > line.Outstanding() {
+			return fmt.Errorf("%w on line %d (%d outstanding)", ErrOverReceipt, line.ID, line.Outstanding())
+		}
+	}
+
+	po.Status = PurchaseOrderReceived
+	for i := range po.Lines {
+		line := &po.Lines[i]
+		line.ReceivedQuantity += received[line.ID]
+		if line.Outstanding() > 0 {
+			po.Status = PurchaseOrderPartiallyReceived
+		}
+	}
+	return nil
+}
+
+// Line returns the line with the given ID, or nil.
+func (po *PurchaseOrder) Line(id int64) *PurchaseOrderLine {
+	for i := range po.Lines {
+		if po.Lines[i].ID == id {
+			return &po.Lines[i]
+		}
+	}
+	return nil
+}
+
+// PurchaseOrderLine is a variant ordered from the supplier at the expected
+// unit cost.
+type PurchaseOrderLine struct {
+	ID               int64   `json:"id" gorm:"primaryKey"`
+	PurchaseOrderID  int64   `json:"-" gorm:"not null;index"`
+	VariantID        int64   `json:"variant_id" gorm:"not null;index"`
+	Quantity         int     `json:"quantity" gorm:"not null"`
+	ReceivedQuantity int     `json:"received_quantity" gorm:"not null;default:0"`
+	UnitCost         float64 `json:"unit_cost"`
+}
+
+// Outstanding is the quantity still to be delivered.
+func (l PurchaseOrderLine) Outstanding() int {
+	return l.Quantity - l.ReceivedQuantity
+}
+
+// ReceiptLine is a quantity of a purchase order line delivered by the
+// supplier. A nil UnitCost means the cost agreed on the line.
+type ReceiptLine struct {
+	LineID   int64
+	Quantity int
+	UnitCost *float64
+}
+
+// ProductCost is the moving weighted average cost of a product, updated on
+// every receipt: the stock on hand keeps its cost and the received units
+// enter at their purchase cost.
+type ProductCost struct {
+	ProductID   int64     `json:"product_id" gorm:"primaryKey;autoIncrement:false"`
+	AverageCost float64   `json:"average_cost" gorm:"not null;default:0"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// NextAverageCost returns the average cost after receiving quantity units at
+// unitCost on top of onHand units at the current average.
+func (c ProductCost) NextAverageCost(onHand, quantity int, unitCost float64) float64 {
+	if onHand < 0 {
+		onHand = 0
+	}
+	if onHand+quantity <= 0 {
+		return c.AverageCost
+	}
+	return (float64(onHand)*c.AverageCost + float64(quantity)*unitCost) / float64(onHand+quantity)
+}
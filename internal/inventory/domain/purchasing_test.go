@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestNextAverageCost(t *testing.T) {
+	tests := []struct {
+		name     string
+		average  float64
+		onHand   int
+		quantity int
+		unitCost float64
+		want     float64
+	}{
+		{"first receipt", 0, 0, 10, 12.5, 12.5},
+		{"weighted by stock on hand", 10, 30, 10, 14, 11},
+		{"same cost", 8, 5, 5, 8, 8},
+		{"free goods lower the average", 9, 9, 3, 0, 6.75},
+		{"oversold stock counts as none", 10, -4, 4, 16, 16},
+		{"nothing received", 10, 0, 0, 99, 10},
+	}
+	for _, tt := range tests {
+		got := ProductCost{AverageCost: tt.average}.NextAverageCost(tt.onHand, tt.quantity, tt.unitCost)
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: NextAverageCost = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func newPurchaseOrder() *PurchaseOrder {
+	return &PurchaseOrder{
+		ID:     1,
+		Status: PurchaseOrderOpen,
+		Lines: []PurchaseOrderLine{
+			{ID: 11, VariantID: 100, Quantity: 10, UnitCost: 5},
+			{ID: 12, VariantID: 200, Quantity: 4, UnitCost: 8},
+		},
+	}
+}
+
+func TestPurchaseOrderReceiveInSeveralDeliveries(t *testing.T) {
+	po := newPurchaseOrder()
+
+	if err := po.Receive([]ReceiptLine{{LineID: 11, Quantity: 6}}); err != nil {
+		t.Fatalf("first delivery: %v", err)
+	}
+	if po.Status != PurchaseOrderPartiallyReceived || po.Line(11).Outstanding() != 4 || po.Line(12).Outstanding() != 4 {
+		t.Fatalf("after the first delivery: status %q, outstanding %d and %d", po.Status, po.Line(11).Outstanding(), po.Line(12).Outstanding())
+	}
+
+	// The same line may appear more than once in a delivery.
+	if err := po.Receive([]ReceiptLine{{LineID: 11, Quantity: 1}, {LineID: 12, Quantity: 4}, {LineID: 11, Quantity: 3}}); err != nil {
+		t.Fatalf("second delivery: %v", err)
+	}
+	if po.Status != PurchaseOrderReceived || po.Line(11).ReceivedQuantity != 10 || po.Line(12).ReceivedQuantity != 4 {
+		t.Errorf("after the second delivery: status %q, received %d and %d", po.Status, po.Line(11).ReceivedQuantity, po.Line(12).ReceivedQuantity)
+	}
+
+	if err := po.Receive([]ReceiptLine{{LineID: 11, Quantity: 1}}); !errors.Is(err, ErrPurchaseOrderClosed) {
+		t.Errorf("receipt on a received order error = %v, want ErrPurchaseOrderClosed", err)
+	}
+}
+
+func TestPurchaseOrderReceiveRejectsBadDeliveries(t *testing.T) {
+	tests := []struct {
+		name     string
+		receipts []ReceiptLine
+		want     error
+	}{
+		{"over the ordered quantity", []ReceiptLine{{LineID: 12, Quantity: 5}}, ErrOverReceipt},
+		{"over across duplicate lines", []ReceiptLine{{LineID: 12, Quantity: 3}, {LineID: 12, Quantity: 2}}, ErrOverReceipt},
+		{"unknown line", []ReceiptLine{{LineID: 11, Quantity: 1}, {LineID: 99, Quantity: 1}}, nil},
+	}
+	for _, tt := range tests {
+		po := newPurchaseOrder()
+		err := po.Receive(tt.receipts)
+		if err == nil || (tt.want != nil && !errors.Is(err, tt.want)) {
+			t.Errorf("%s: Receive error = %v, want %v", tt.name, err, tt.want)
+		}
+		if po.Status != PurchaseOrderOpen || po.Line(11).ReceivedQuantity != 0 || po.Line(12).ReceivedQuantity != 0 {
+			t.Errorf("%s: rejected delivery changed the order", tt.name)
+		}
+	}
+
+	for _, status := range []PurchaseOrderStatus{PurchaseOrderDraft, PurchaseOrderCancelled} {
+		po := newPurchaseOrder()
+		po.Status = status
+		if err := po.Receive([]ReceiptLine{{LineID: 11, Quantity: 1}}); !errors.Is(err, ErrPurchaseOrderClosed) {
+			t.Errorf("receipt on a %s order error = %v, want ErrPurchaseOrderClosed", status, err)
+		}
+	}
+}
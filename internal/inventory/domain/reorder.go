@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"math"
+	"time"
+)
+
+// ReorderRule sets the stock level below which a product must be
+// replenished. When AutoDraft is set and a supplier and warehouse are
+// known, the low-stock job also drafts a purchase order for it.
+// MinimumQuantity is the smallest quantity per variant worth ordering.
+type ReorderRule struct {
+	ProductID       int64      `json:"product_id" gorm:"primaryKey;autoIncrement:false"`
+	ReorderPoint    int        `json:"reorder_point" gorm:"not null"`
+	MinimumQuantity int        `json:"minimum_quantity" gorm:"not null;default:0"`
+	SupplierID      *int64     `json:"supplier_id,omitempty"`
+	WarehouseID     *int64     `json:"warehouse_id,omitempty"`
+	AutoDraft       bool       `json:"auto_draft" gorm:"not null;default:false"`
+	AlertedAt       *time.Time `json:"alerted_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// LowStock is a product whose stock is at or below its reorder point.
+type LowStock struct {
+	ProductID    int64  `json:"product_id"`
+	Name         string `json:"name"`
+	SKU          string `json:"sku,omitempty"`
+	Stock        int    `json:"stock"`
+	ReorderPoint int    `json:"reorder_point"`
+}
+
+// SuggestedQuantity returns how many units of a variant to order so that
+// stock covers coverDays of sales at the given daily velocity, and at
+// least minimum when anything is needed at all.
+func SuggestedQuantity(velocity float64, coverDays, stock, incoming, minimum int) int {
+	needed := int(math.Ceil(velocity*float64(coverDays))) - stock - incoming
+	if needed <= 0 {
+		return 0
+	}
+	if needed < minimum {
+		return minimum
+	}
+	return needed
+}
@@ -0,0 +1,28 @@
+package domain
+
+import "testing"
+
+func TestSuggestedQuantity(t *testing.T) {
+	tests := []struct {
+		name                       string
+		velocity                   float64
+		coverDays, stock, incoming int
+		minimum                    int
+		want                       int
+	}{
+		{"covers the period", 2, 30, 10, 0, 0, 50},
+		{"net of what is on order", 2, 30, 10, 20, 0, 30},
+		{"rounds demand up", 0.1, 15, 0, 0, 0, 2},
+		{"raised to the minimum", 0.5, 30, 10, 0, 12, 12},
+		{"above the minimum", 2, 30, 0, 0, 12, 60},
+		{"enough stock", 1, 30, 30, 0, 12, 0},
+		{"enough on order", 1, 30, 5, 25, 12, 0},
+		{"no sales", 0, 30, 0, 0, 12, 0},
+		{"oversold", 1, 10, -5, 0, 0, 15},
+	}
+	for _, tt := range tests {
+		if got := SuggestedQuantity(tt.velocity, tt.coverDays, tt.stock, tt.incoming, tt.minimum); got != tt.want {
+			t.Errorf("%s: SuggestedQuantity = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Demand is a quantity of a variant an order needs. A zero VariantID stands
+// for a product that is not stock-tracked; it can ship from any warehouse.
+type Demand struct {
+	VariantID int64
+	Quantity  int
+}
+
+// SourcingRequest is one order line to source. Quantity is the quantity of
+// the line and Items the total quantity of each variant it consumes: a
+// single variant for a plain line, or the components of a bundle. Lines that
+// cannot be split, such as bundles, must ship whole from a single warehouse.
+type SourcingRequest struct {
+	Quantity   int
+	Items      []Demand
+	Splittable bool
+}
+
+// Allocation assigns Quantity units of a line to a warehouse. Lines that are
+// not splittable get exactly one allocation.
+type Allocation struct {
+	WarehouseID int64
+	Quantity    int
+}
+
+// Levels holds the on-hand stock per warehouse and variant.
+type Levels map[int64]map[int64]int
+
+func (l Levels) take(warehouseID, variantID int64, quantity int) {
+	l[warehouseID][variantID] -= quantity
+}
+
+func (l Levels) has(warehouseID int64, items []Demand) bool {
+	for _, item := range items {
+		if item.VariantID == 0 {
+			continue
+		}
+		if l[warehouseID][item.VariantID] < item.Quantity {
+			return false
+		}
+	}
+	return true
+}
+
+// ByDistance orders warehouses from the closest to the farthest from the
+// customer CEP. Without a usable CEP the original order is kept.
+func ByDistance(warehouses []Warehouse, cep string) []Warehouse {
+	sorted := append([]Warehouse(nil), warehouses...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return CEPDistance(sorted[i].CEP, cep) < CEPDistance(sorted[j].CEP, cep)
+	})
+	return sorted
+}
+
+// CEPDistance approximates how far apart two CEPs are. CEPs are assigned
+// geographically, region first, so the difference between their five-digit
+// prefixes grows with distance. Invalid CEPs are as far as possible.
+func CEPDistance(a, b string) int {
+	pa, okA := cepPrefix(a)
+	pb, okB := cepPrefix(b)
+	if !okA || !okB {
+		return 100000
+	}
+	if pa > pb {
+		return pa - pb
+	}
+	return pb - pa
+}
+
+func cepPrefix(cep string) (int, bool) {
+	cep = strings.ReplaceAll(strings.TrimSpace(cep), "-", "")
+	if len(cep) != 8 {
+		return 0, false
+	}
+	prefix, err := strconv.Atoi(cep[:5])
+	return prefix, err == nil
+}
+
+// Source picks the warehouses an order ships from. Warehouses must be
+// sorted by preference (see ByDistance). The closest warehouse able to ship
+// the whole order is preferred; when none can, the order is split, taking
+// each line from the closest warehouses that have it. Levels is consumed as
+// stock is allocated.
+func Source(warehouses []Warehouse, levels Levels, requests []SourcingRequest) ([][]Allocation, error) {
+	if len(warehouses) == 0 {
+		return nil, ErrWarehouseNotFound
+	}
+	for _, w := range warehouses {
+		if levels[w.ID] == nil {
+			levels[w.ID] = make(map[int64]int)
+		}
+	}
+
+	if single := sourceSingle(warehouses, levels, requests); single != nil {
+		return single, nil
+	}
+
+	allocations := make([][]Allocation, len(requests))
+	for i, request := range requests {
+		if !request.Splittable {
+			w, ok := firstWith(warehouses, levels, request.Items)
+			if !ok {
+				return nil, fmt.Errorf("%w to ship line %d from a single warehouse", ErrInsufficientStock, i+1)
+			}
+			for _, item := range request.Items {
+				levels.take(w.ID, item.VariantID, item.Quantity)
+			}
+			allocations[i] = []Allocation{{WarehouseID: w.ID, Quantity: request.Quantity}}
+			continue
+		}
+
+		item := request.Items[0]
+		if item.VariantID == 0 {
+			allocations[i] = []Allocation{{WarehouseID: warehouses[0].ID, Quantity: request.Quantity}}
+			continue
+		}
+
+		remaining := request.Quantity
+		for _, w := range warehouses {
+			available := levels[w.ID][item.VariantID]
+			if available <= 0 {
+				continue
+			}
+			take := min(available, remaining)
+			levels.take(w.ID, item.VariantID, take)
+			allocations[i] = append(allocations[i], Allocation{WarehouseID: w.ID, Quantity: take})
+			remaining -= take
+			if remaining == 0 {
+				break
+			}
+		}
+		if remaining > 0 {
+			return nil, fmt.Errorf("%w for line %d", ErrInsufficientStock, i+1)
+		}
+	}
+
+	return allocations, nil
+}
+
+// sourceSingle returns the allocations shipping every request from the
+// first warehouse that has all of them, or nil.
+func sourceSingle(warehouses []Warehouse, levels Levels, requests []SourcingRequest) [][]Allocation {
+	needed := make(map[int64]int)
+	for _, request := range requests {
+		for _, item := range request.Items {
+			if item.VariantID != 0 {
+				needed[item.VariantID] += item.Quantity
+			}
+		}
+	}
+
+	for _, w := range warehouses {
+		fits := true
+		for variantID, quantity := range needed {
+			if levels[w.ID][variantID] < quantity {
+				fits = false
+				break
+			}
+		}
+		if !fits {
+			continue
+		}
+
+		allocations := make([][]Allocation, len(requests))
+		for i, request := range requests {
+			allocations[i] = []Allocation{{WarehouseID: w.ID, Quantity: request.Quantity}}
+		}
+		for variantID, quantity := range needed {
+			levels.take(w.ID, variantID, quantity)
+		}
+		return allocations
+	}
+
+	return nil
+}
+
+func firstWith(warehouses []Warehouse, levels Levels, items []Demand) (Warehouse, bool) {
+	for _, w := range warehouses {
+		if levels.has(w.ID, items) {
+			return w, true
+		}
+	}
+	return Warehouse{}, false
+}
@@ -0,0 +1,143 @@
+package domain
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var (
+	saoPaulo = Warehouse{ID: 1, Code: "SP", CEP: "01310-100"}
+	curitiba = Warehouse{ID: 2, Code: "CWB", CEP: "80010000"}
+	recife   = Warehouse{ID: 3, Code: "REC", CEP: "50010000"}
+)
+
+func line(variantID int64, quantity int) SourcingRequest {
+	return SourcingRequest{
+		Quantity:   quantity,
+		Items:      []Demand{{VariantID: variantID, Quantity: quantity}},
+		Splittable: true,
+	}
+}
+
+func TestSourcePrefersClosestWarehouseWithWholeOrder(t *testing.T) {
+	levels := Levels{
+		1: {10: 5},
+		2: {10: 5, 20: 1},
+		3: {10: 5, 20: 5},
+	}
+
+	got, err := Source([]Warehouse{saoPaulo, curitiba, recife}, levels, []SourcingRequest{line(10, 2), line(20, 1)})
+	if err != nil {
+		t.Fatalf("Source: %v", err)
+	}
+
+	want := [][]Allocation{{{WarehouseID: 2, Quantity: 2}}, {{WarehouseID: 2, Quantity: 1}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("allocations = %v, want %v", got, want)
+	}
+	if levels[2][10] != 3 || levels[2][20] != 0 || levels[1][10] != 5 {
+		t.Errorf("levels not consumed from the chosen warehouse: %v", levels)
+	}
+}
+
+func TestSourceSplitsWhenNoWarehouseHasEverything(t *testing.T) {
+	levels := Levels{
+		1: {10: 2},
+		2: {10: 1, 20: 3},
+		3: {10: 4},
+	}
+
+	got, err := Source([]Warehouse{saoPaulo, curitiba, recife}, levels, []SourcingRequest{line(10, 5), line(20, 1)})
+	if err != nil {
+		t.Fatalf("Source: %v", err)
+	}
+
+	want := [][]Allocation{
+		{{WarehouseID: 1, Quantity: 2}, {WarehouseID: 2, Quantity: 1}, {WarehouseID: 3, Quantity: 2}},
+		{{WarehouseID: 2, Quantity: 1}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("allocations = %v, want %v", got, want)
+	}
+}
+
+func TestSourceShipsBundlesWhole(t *testing.T) {
+	bundle := SourcingRequest{
+		Quantity: 2,
+		Items:    []Demand{{VariantID: 10, Quantity: 2}, {VariantID: 20, Quantity: 4}},
+	}
+	levels := Levels{
+		1: {10: 2, 20: 3},
+		2: {10: 2, 20: 4},
+		3: {30: 1},
+	}
+
+	got, err := Source([]Warehouse{saoPaulo, curitiba, recife}, levels, []SourcingRequest{bundle, line(30, 1)})
+	if err != nil {
+		t.Fatalf("Source: %v", err)
+	}
+
+	want := [][]Allocation{{{WarehouseID: 2, Quantity: 2}}, {{WarehouseID: 3, Quantity: 1}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("allocations = %v, want %v", got, want)
+	}
+
+	levels = Levels{1: {10: 2, 20: 3}, 2: {20: 4}}
+	_, err = Source([]Warehouse{saoPaulo, curitiba}, levels, []SourcingRequest{bundle})
+	if !errors.Is(err, ErrInsufficientStock) {
+		t.Errorf("bundle split across warehouses: error = %v, want ErrInsufficientStock", err)
+	}
+}
+
+func TestSourceUntrackedProducts(t *testing.T) {
+	untracked := SourcingRequest{Quantity: 3, Items: []Demand{{Quantity: 3}}, Splittable: true}
+
+	got, err := Source([]Warehouse{saoPaulo, curitiba}, Levels{}, []SourcingRequest{untracked})
+	if err != nil {
+		t.Fatalf("Source: %v", err)
+	}
+	if want := [][]Allocation{{{WarehouseID: 1, Quantity: 3}}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("allocations = %v, want %v", got, want)
+	}
+}
+
+func TestSourceInsufficientStock(t *testing.T) {
+	levels := Levels{1: {10: 1}, 2: {10: 1}}
+	if _, err := Source([]Warehouse{saoPaulo, curitiba}, levels, []SourcingRequest{line(10, 3)}); !errors.Is(err, ErrInsufficientStock) {
+		t.Errorf("error = %v, want ErrInsufficientStock", err)
+	}
+
+	if _, err := Source(nil, Levels{}, []SourcingRequest{line(10, 1)}); !errors.Is(err, ErrWarehouseNotFound) {
+		t.Errorf("error without warehouses = %v, want ErrWarehouseNotFound", err)
+	}
+}
+
+func TestByDistance(t *testing.T) {
+	warehouses := []Warehouse{recife, saoPaulo, curitiba}
+
+	got := ByDistance(warehouses, "04538-133")
+	if codes := []string{got[0].Code, got[1].Code, got[2].Code}; !reflect.DeepEqual(codes, []string{"SP", "REC", "CWB"}) {
+		t.Errorf("order from São Paulo = %v", codes)
+	}
+
+	got = ByDistance(warehouses, "")
+	if !reflect.DeepEqual(got, warehouses) {
+		t.Errorf("order without a CEP = %v, want the original order", got)
+	}
+	if &got[0] == &warehouses[0] {
+		t.Error("ByDistance sorted the caller's slice")
+	}
+}
+
+func TestCEPDistance(t *testing.T) {
+	if d := CEPDistance("01310-100", "01310100"); d != 0 {
+		t.Errorf("distance between the same CEP = %d", d)
+	}
+	if near, far := CEPDistance("01310100", "04538133"), CEPDistance("01310100", "80010000"); near >= far {
+		t.Errorf("distance within São Paulo %d not below distance to Curitiba %d", near, far)
+	}
+	if d := CEPDistance("invalid", "01310100"); d != 100000 {
+		t.Errorf("distance to an invalid CEP = %d", d)
+	}
+}
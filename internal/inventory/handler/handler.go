@@ -0,0 +1,296 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rkweber-max/checkout-backend/internal/inventory/domain"
+	"github.com/rkweber-max/checkout-backend/internal/inventory/repository"
+	"github.com/rkweber-max/checkout-backend/internal/inventory/service"
+)
+
+const defaultMovementLimit = 100
+
+type InventoryHandler struct {
+	service service.InventoryService
+}
+
+func NewInventoryHandler(service service.InventoryService) *InventoryHandler {
+	return &InventoryHandler{service: service}
+}
+
+type WarehouseRequest struct {
+	Code   string `json:"code" binding:"required"`
+	Name   string `json:"name" binding:"required"`
+	CEP    string `json:"cep"`
+	Active *bool  `json:"active"`
+}
+
+type AdjustmentRequest struct {
+	VariantID int64  `json:"variant_id" binding:"required"`
+	Quantity  int    `json:"quantity" binding:"required"`
+	Note      string `json:"note"`
+}
+
+type TransferLineRequest struct {
+	VariantID int64 `json:"variant_id" binding:"required"`
+	Quantity  int   `json:"quantity" binding:"required,min=1"`
+}
+
+type TransferRequest struct {
+	FromWarehouseID int64                 `json:"from_warehouse_id" binding:"required"`
+	ToWarehouseID   int64                 `json:"to_warehouse_id" binding:"required"`
+	Lines           []TransferLineRequest `json:"lines" binding:"required,min=1,dive"`
+}
+
+func (h *InventoryHandler) CreateWarehouse(c *gin.Context) {
+	var req WarehouseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	w := &domain.Warehouse{Code: req.Code, Name: req.Name, CEP: req.CEP}
+	if err := h.service.CreateWarehouse(c.Request.Context(), w); err != nil {
+		respondInventoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, w)
+}
+
+func (h *InventoryHandler) ListWarehouses(c *gin.Context) {
+	warehouses, err := h.service.ListWarehouses(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, warehouses)
+}
+
+func (h *InventoryHandler) GetWarehouse(c *gin.Context) {
+	id, ok := parseID(c, "id", "invalid warehouse ID")
+	if !ok {
+		return
+	}
+
+	w, err := h.service.GetWarehouse(c.Request.Context(), id)
+	if err != nil {
+		respondInventoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, w)
+}
+
+func (h *InventoryHandler) UpdateWarehouse(c *gin.Context) {
+	id, ok := parseID(c, "id", "invalid warehouse ID")
+	if !ok {
+		return
+	}
+
+	var req WarehouseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	w := &domain.Warehouse{ID: id, Code: req.Code, Name: req.Name, CEP: req.CEP, Active: true}
+	if req.Active != nil {
+		w.Active = *req.Active
+	}
+	if err := h.service.UpdateWarehouse(c.Request.Context(), w); err != nil {
+		respondInventoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, w)
+}
+
+func (h *InventoryHandler) WarehouseStock(c *gin.Context) {
+	id, ok := parseID(c, "id", "invalid warehouse ID")
+	if !ok {
+		return
+	}
+
+	levels, err := h.service.WarehouseStock(c.Request.Context(), id)
+	if err != nil {
+		respondInventoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, levels)
+}
+
+func (h *InventoryHandler) VariantStock(c *gin.Context) {
+	id, ok := parseID(c, "variantId", "invalid variant ID")
+	if !ok {
+		return
+	}
+
+	levels, err := h.service.VariantStock(c.Request.Context(), id)
+	if err != nil {
+		respondInventoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, levels)
+}
+
+// Adjust books a manual correction of the stock of a warehouse. A negative
+// quantity takes stock out.
+func (h *InventoryHandler) Adjust(c *gin.Context) {
+	id, ok := parseID(c, "id", "invalid warehouse ID")
+	if !ok {
+		return
+	}
+
+	var req AdjustmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	movement, err := h.service.Adjust(c.Request.Context(), id, req.VariantID, req.Quantity, req.Note)
+	if err != nil {
+		respondInventoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, movement)
+}
+
+func (h *InventoryHandler) CreateTransfer(c *gin.Context) {
+	var req TransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	input := service.TransferInput{FromWarehouseID: req.FromWarehouseID, ToWarehouseID: req.ToWarehouseID}
+	for _, line := range req.Lines {
+		input.Lines = append(input.Lines, domain.TransferLine{VariantID: line.VariantID, Quantity: line.Quantity})
+	}
+
+	transfer, err := h.service.CreateTransfer(c.Request.Context(), input)
+	if err != nil {
+		respondInventoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, transfer)
+}
+
+func (h *InventoryHandler) ListTransfers(c *gin.Context) {
+	status := domain.TransferStatus(c.Query("status"))
+	transfers, err := h.service.ListTransfers(c.Request.Context(), status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, transfers)
+}
+
+func (h *InventoryHandler) GetTransfer(c *gin.Context) {
+	id, ok := parseID(c, "id", "invalid transfer ID")
+	if !ok {
+		return
+	}
+
+	transfer, err := h.service.GetTransfer(c.Request.Context(), id)
+	if err != nil {
+		respondInventoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, transfer)
+}
+
+func (h *InventoryHandler) ReceiveTransfer(c *gin.Context) {
+	id, ok := parseID(c, "id", "invalid transfer ID")
+	if !ok {
+		return
+	}
+
+	transfer, err := h.service.ReceiveTransfer(c.Request.Context(), id)
+	if err != nil {
+		respondInventoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, transfer)
+}
+
+func (h *InventoryHandler) CancelTransfer(c *gin.Context) {
+	id, ok := parseID(c, "id", "invalid transfer ID")
+	if !ok {
+		return
+	}
+
+	transfer, err := h.service.CancelTransfer(c.Request.Context(), id)
+	if err != nil {
+		respondInventoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, transfer)
+}
+
+// Movements lists the stock ledger, newest first, optionally filtered by
+// warehouse_id and variant_id.
+func (h *InventoryHandler) Movements(c *gin.Context) {
+	filter := repository.MovementFilter{Limit: defaultMovementLimit}
+	for param, target := range map[string]*int64{"warehouse_id": &filter.WarehouseID, "variant_id": &filter.VariantID} {
+		if value := c.Query(param); value != "" {
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + param})
+				return
+			}
+			*target = id
+		}
+	}
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	movements, err := h.service.Movements(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, movements)
+}
+
+func parseID(c *gin.Context, param, message string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(param), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return 0, false
+	}
+	return id, true
+}
+
+func respondInventoryError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrWarehouseNotFound),
+		errors.Is(err, domain.ErrTransferNotFound),
+		errors.Is(err, service.ErrVariantNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrInsufficientStock),
+		errors.Is(err, domain.ErrTransferNotPending):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,259 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rkweber-max/checkout-backend/internal/inventory/domain"
+	"github.com/rkweber-max/checkout-backend/internal/inventory/service"
+)
+
+type PurchasingHandler struct {
+	service service.PurchasingService
+}
+
+func NewPurchasingHandler(service service.PurchasingService) *PurchasingHandler {
+	return &PurchasingHandler{service: service}
+}
+
+type SupplierRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Document string `json:"document"`
+	Email    string `json:"email" binding:"omitempty,email"`
+	Phone    string `json:"phone"`
+}
+
+type PurchaseOrderLineRequest struct {
+	VariantID int64   `json:"variant_id" binding:"required"`
+	Quantity  int     `json:"quantity" binding:"required,min=1"`
+	UnitCost  float64 `json:"unit_cost" binding:"min=0"`
+}
+
+type PurchaseOrderRequest struct {
+	SupplierID  int64                      `json:"supplier_id" binding:"required"`
+	WarehouseID int64                      `json:"warehouse_id" binding:"required"`
+	ExpectedAt  *time.Time                 `json:"expected_at"`
+	Notes       string                     `json:"notes"`
+	Lines       []PurchaseOrderLineRequest `json:"lines" binding:"required,min=1,dive"`
+}
+
+type ReceiptLineRequest struct {
+	LineID   int64    `json:"line_id" binding:"required"`
+	Quantity int      `json:"quantity" binding:"required,min=1"`
+	UnitCost *float64 `json:"unit_cost"`
+}
+
+type ReceiptRequest struct {
+	WarehouseID int64                `json:"warehouse_id"`
+	Lines       []ReceiptLineRequest `json:"lines" binding:"required,min=1,dive"`
+}
+
+func (h *PurchasingHandler) CreateSupplier(c *gin.Context) {
+	var req SupplierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	supplier := &domain.Supplier{Name: req.Name, Document: req.Document, Email: req.Email, Phone: req.Phone}
+	if err := h.service.CreateSupplier(c.Request.Context(), supplier); err != nil {
+		respondPurchasingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, supplier)
+}
+
+func (h *PurchasingHandler) ListSuppliers(c *gin.Context) {
+	suppliers, err := h.service.ListSuppliers(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, suppliers)
+}
+
+func (h *PurchasingHandler) GetSupplier(c *gin.Context) {
+	id, ok := parseID(c, "id", "invalid supplier ID")
+	if !ok {
+		return
+	}
+
+	supplier, err := h.service.GetSupplier(c.Request.Context(), id)
+	if err != nil {
+		respondPurchasingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, supplier)
+}
+
+func (h *PurchasingHandler) UpdateSupplier(c *gin.Context) {
+	id, ok := parseID(c, "id", "invalid supplier ID")
+	if !ok {
+		return
+	}
+
+	var req SupplierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	supplier := &domain.Supplier{ID: id, Name: req.Name, Document: req.Document, Email: req.Email, Phone: req.Phone}
+	if err := h.service.UpdateSupplier(c.Request.Context(), supplier); err != nil {
+		respondPurchasingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, supplier)
+}
+
+func (h *PurchasingHandler) CreatePurchaseOrder(c *gin.Context) {
+	var req PurchaseOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	input := service.PurchaseOrderInput{
+		SupplierID:  req.SupplierID,
+		WarehouseID: req.WarehouseID,
+		ExpectedAt:  req.ExpectedAt,
+		Notes:       req.Notes,
+	}
+	for _, line := range req.Lines {
+		input.Lines = append(input.Lines, domain.PurchaseOrderLine{
+			VariantID: line.VariantID,
+			Quantity:  line.Quantity,
+			UnitCost:  line.UnitCost,
+		})
+	}
+
+	po, err := h.service.CreatePurchaseOrder(c.Request.Context(), input)
+	if err != nil {
+		respondPurchasingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, po)
+}
+
+func (h *PurchasingHandler) ListPurchaseOrders(c *gin.Context) {
+	status := domain.PurchaseOrderStatus(c.Query("status"))
+	orders, err := h.service.ListPurchaseOrders(c.Request.Context(), status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, orders)
+}
+
+func (h *PurchasingHandler) GetPurchaseOrder(c *gin.Context) {
+	id, ok := parseID(c, "id", "invalid purchase order ID")
+	if !ok {
+		return
+	}
+
+	po, err := h.service.GetPurchaseOrder(c.Request.Context(), id)
+	if err != nil {
+		respondPurchasingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, po)
+}
+
+func (h *PurchasingHandler) SubmitPurchaseOrder(c *gin.Context) {
+	id, ok := parseID(c, "id", "invalid purchase order ID")
+	if !ok {
+		return
+	}
+
+	po, err := h.service.SubmitPurchaseOrder(c.Request.Context(), id)
+	if err != nil {
+		respondPurchasingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, po)
+}
+
+func (h *PurchasingHandler) CancelPurchaseOrder(c *gin.Context) {
+	id, ok := parseID(c, "id", "invalid purchase order ID")
+	if !ok {
+		return
+	}
+
+	if err := h.service.CancelPurchaseOrder(c.Request.Context(), id); err != nil {
+		respondPurchasingError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Receive books goods delivered by the supplier. Only the delivered lines
+// and quantities are sent; the rest stays outstanding.
+func (h *PurchasingHandler) Receive(c *gin.Context) {
+	id, ok := parseID(c, "id", "invalid purchase order ID")
+	if !ok {
+		return
+	}
+
+	var req ReceiptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	lines := make([]domain.ReceiptLine, 0, len(req.Lines))
+	for _, line := range req.Lines {
+		lines = append(lines, domain.ReceiptLine{LineID: line.LineID, Quantity: line.Quantity, UnitCost: line.UnitCost})
+	}
+
+	po, err := h.service.Receive(c.Request.Context(), id, req.WarehouseID, lines)
+	if err != nil {
+		respondPurchasingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, po)
+}
+
+func (h *PurchasingHandler) ProductCost(c *gin.Context) {
+	id, ok := parseID(c, "id", "invalid product ID")
+	if !ok {
+		return
+	}
+
+	cost, err := h.service.ProductCost(c.Request.Context(), id)
+	if err != nil {
+		respondPurchasingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, cost)
+}
+
+func respondPurchasingError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrSupplierNotFound),
+		errors.Is(err, domain.ErrPurchaseOrderNotFound),
+		errors.Is(err, domain.ErrWarehouseNotFound),
+		errors.Is(err, service.ErrVariantNotFound),
+		errors.Is(err, service.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrPurchaseOrderClosed),
+		errors.Is(err, domain.ErrPurchaseOrderHasReceipts),
+		errors.Is(err, domain.ErrPurchaseOrderNotDraft),
+		errors.Is(err, domain.ErrOverReceipt):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rkweber-max/checkout-backend/internal/inventory/domain"
+	"github.com/rkweber-max/checkout-backend/internal/inventory/service"
+)
+
+type ReorderHandler struct {
+	service service.ReorderService
+}
+
+func NewReorderHandler(service service.ReorderService) *ReorderHandler {
+	return &ReorderHandler{service: service}
+}
+
+type ReorderRuleRequest struct {
+	ReorderPoint    int    `json:"reorder_point" binding:"min=0"`
+	MinimumQuantity int    `json:"minimum_quantity" binding:"min=0"`
+	SupplierID      *int64 `json:"supplier_id"`
+	WarehouseID     *int64 `json:"warehouse_id"`
+	AutoDraft       bool   `json:"auto_draft"`
+}
+
+func (h *ReorderHandler) SetRule(c *gin.Context) {
+	id, ok := parseID(c, "id", "invalid product ID")
+	if !ok {
+		return
+	}
+
+	var req ReorderRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule := &domain.ReorderRule{
+		ProductID:       id,
+		ReorderPoint:    req.ReorderPoint,
+		MinimumQuantity: req.MinimumQuantity,
+		SupplierID:      req.SupplierID,
+		WarehouseID:     req.WarehouseID,
+		AutoDraft:       req.AutoDraft,
+	}
+	if err := h.service.SetRule(c.Request.Context(), rule); err != nil {
+		respondReorderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+func (h *ReorderHandler) GetRule(c *gin.Context) {
+	id, ok := parseID(c, "id", "invalid product ID")
+	if !ok {
+		return
+	}
+
+	rule, err := h.service.GetRule(c.Request.Context(), id)
+	if err != nil {
+		respondReorderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+func (h *ReorderHandler) DeleteRule(c *gin.Context) {
+	id, ok := parseID(c, "id", "invalid product ID")
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteRule(c.Request.Context(), id); err != nil {
+		respondReorderError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ReorderHandler) LowStock(c *gin.Context) {
+	items, err := h.service.LowStock(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, items)
+}
+
+func respondReorderError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrReorderRuleNotFound),
+		errors.Is(err, domain.ErrSupplierNotFound),
+		errors.Is(err, domain.ErrWarehouseNotFound),
+		errors.Is(err, service.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/rkweber-max/checkout-backend/internal/inventory/domain"
+	"github.com/rkweber-max/checkout-backend/internal/product"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ApplyMovement records a stock movement and applies it to the on-hand
+// stock of the warehouse and to the variant total, inside the caller's
+// transaction. Outgoing movements fail with ErrInsufficientStock instead of
+// taking the warehouse below zero.
+func ApplyMovement(tx *gorm.DB, m *domain.StockMovement) error {
+	if m.Quantity == 0 {
+		return nil
+	}
+
+	if m.Quantity > 0 {
+		level := domain.StockLevel{WarehouseID: m.WarehouseID, VariantID: m.VariantID, OnHand: m.Quantity}
+		err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "warehouse_id"}, {Name: "variant_id"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"on_hand":    gorm.Expr("stock_levels.on_hand + ?", m.Quantity),
+				"updated_at": gorm.Expr("NOW()"),
+			}),
+		}).Create(&level).Error
+		if err != nil {
+			return err
+		}
+	} else {
+		result := tx.Model(&domain.StockLevel{}).
+			Where("warehouse_id = ? AND variant_id = ? AND on_hand >= ?", m.WarehouseID, m.VariantID, -m.Quantity).
+			Update("on_hand", gorm.Expr("on_hand + ?", m.Quantity))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w of variant %d in warehouse %d", domain.ErrInsufficientStock, m.VariantID, m.WarehouseID)
+		}
+	}
+
+	err := tx.Model(&product.Variant{}).
+		Where("id = ?", m.VariantID).
+		Update("stock", gorm.Expr("stock + ?", m.Quantity)).Error
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(m).Error
+}
+
+// addIncoming changes the stock expected at a warehouse from transfers.
+func addIncoming(tx *gorm.DB, warehouseID, variantID int64, quantity int) error {
+	level := domain.StockLevel{WarehouseID: warehouseID, VariantID: variantID, Incoming: quantity}
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "warehouse_id"}, {Name: "variant_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"incoming":   gorm.Expr("stock_levels.incoming + ?", quantity),
+			"updated_at": gorm.Expr("NOW()"),
+		}),
+	}).Create(&level).Error
+}
@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rkweber-max/checkout-backend/internal/inventory/domain"
+	"github.com/rkweber-max/checkout-backend/internal/product"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PurchasingRepository interface {
+	CreateSupplier(ctx context.Context, s *domain.Supplier) error
+	FindSuppliers(ctx context.Context) ([]domain.Supplier, error)
+	FindSupplierByID(ctx context.Context, id int64) (*domain.Supplier, error)
+	UpdateSupplier(ctx context.Context, s *domain.Supplier) error
+	CreatePurchaseOrder(ctx context.Context, po *domain.PurchaseOrder) error
+	FindPurchaseOrders(ctx context.Context, status domain.PurchaseOrderStatus) ([]domain.PurchaseOrder, error)
+	FindPurchaseOrderByID(ctx context.Context, id int64) (*domain.PurchaseOrder, error)
+	SubmitPurchaseOrder(ctx context.Context, id int64) error
+	CancelPurchaseOrder(ctx context.Context, id int64) error
+	Receive(ctx context.Context, id int64, warehouseID int64, lines []domain.ReceiptLine, actor *uint) (*domain.PurchaseOrder, error)
+	FindProductCost(ctx context.Context, productID int64) (*domain.ProductCost, error)
+}
+
+type purchasingRepository struct {
+	db *gorm.DB
+}
+
+func NewPurchasingRepository(db *gorm.DB) PurchasingRepository {
+	return &purchasingRepository{db: db}
+}
+
+func (r *purchasingRepository) CreateSupplier(ctx context.Context, s *domain.Supplier) error {
+	return r.db.WithContext(ctx).Create(s).Error
+}
+
+func (r *purchasingRepository) FindSuppliers(ctx context.Context) ([]domain.Supplier, error) {
+	var suppliers []domain.Supplier
+	if err := r.db.WithContext(ctx).Order("name").Find(&suppliers).Error; err != nil {
+		return nil, err
+	}
+	return suppliers, nil
+}
+
+func (r *purchasingRepository) FindSupplierByID(ctx context.Context, id int64) (*domain.Supplier, error) {
+	var s domain.Supplier
+	err := r.db.WithContext(ctx).First(&s, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *purchasingRepository) UpdateSupplier(ctx context.Context, s *domain.Supplier) error {
+	return r.db.WithContext(ctx).
+		Model(s).
+		Select("name", "document", "email", "phone").
+		Updates(s).Error
+}
+
+func (r *purchasingRepository) CreatePurchaseOrder(ctx context.Context, po *domain.PurchaseOrder) error {
+	return r.db.WithContext(ctx).Create(po).Error
+}
+
+func (r *purchasingRepository) FindPurchaseOrders(ctx context.Context, status domain.PurchaseOrderStatus) ([]domain.PurchaseOrder, error) {
+	var orders []domain.PurchaseOrder
+	query := r.db.WithContext(ctx).Preload("Lines").Order("created_at DESC")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Find(&orders).Error; err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+func (r *purchasingRepository) FindPurchaseOrderByID(ctx context.Context, id int64) (*domain.PurchaseOrder, error) {
+	return findPurchaseOrder(r.db.WithContext(ctx), id)
+}
+
+// SubmitPurchaseOrder places a draft order with the supplier.
+func (r *purchasingRepository) SubmitPurchaseOrder(ctx context.Context, id int64) error {
+	result := r.db.WithContext(ctx).
+		Model(&domain.PurchaseOrder{}).
+		Where("id = ? AND status = ?", id, domain.PurchaseOrderDraft).
+		Update("status", domain.PurchaseOrderOpen)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return domain.ErrPurchaseOrderNotDraft
+	}
+	return nil
+}
+
+// CancelPurchaseOrder cancels a draft or open order that nothing was
+// received against yet.
+func (r *purchasingRepository) CancelPurchaseOrder(ctx context.Context, id int64) error {
+	result := r.db.WithContext(ctx).
+		Model(&domain.PurchaseOrder{}).
+		Where("id = ? AND status IN ?", id, []domain.PurchaseOrderStatus{domain.PurchaseOrderDraft, domain.PurchaseOrderOpen}).
+		Update("status", domain.PurchaseOrderCancelled)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return domain.ErrPurchaseOrderHasReceipts
+	}
+	return nil
+}
+
+// Receive books a delivery against the purchase order. The order is locked
+// while the quantities are checked, so concurrent receipts cannot exceed
+// what was ordered. Each line updates the average cost of its product
+// before the stock enters the warehouse through the ledger.
+func (r *purchasingRepository) Receive(
+	ctx context.Context,
+	id int64,
+	warehouseID int64,
+	lines []domain.ReceiptLine,
+	actor *uint,
+) (*domain.PurchaseOrder, error) {
+	var po *domain.PurchaseOrder
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		po, err = findPurchaseOrder(tx.Clauses(clause.Locking{Strength: "UPDATE"}), id)
+		if err != nil {
+			return err
+		}
+		if po == nil {
+			return domain.ErrPurchaseOrderNotFound
+		}
+		if err := po.Receive(lines); err != nil {
+			return err
+		}
+
+		for _, receipt := range lines {
+			line := po.Line(receipt.LineID)
+			unitCost := line.UnitCost
+			if receipt.UnitCost != nil {
+				unitCost = *receipt.UnitCost
+			}
+			if err := updateAverageCost(tx, line.VariantID, receipt.Quantity, unitCost); err != nil {
+				return err
+			}
+
+			err := ApplyMovement(tx, &domain.StockMovement{
+				WarehouseID: warehouseID,
+				VariantID:   line.VariantID,
+				Quantity:    receipt.Quantity,
+				Reason:      domain.MovementReceipt,
+				Reference:   fmt.Sprintf("purchase_order:%d", po.ID),
+				CreatedBy:   actor,
+			})
+			if err != nil {
+				return err
+			}
+
+			if err := tx.Model(line).Update("received_quantity", line.ReceivedQuantity).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(po).Update("status", po.Status).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return po, nil
+}
+
+func (r *purchasingRepository) FindProductCost(ctx context.Context, productID int64) (*domain.ProductCost, error) {
+	var cost domain.ProductCost
+	err := r.db.WithContext(ctx).First(&cost, "product_id = ?", productID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cost, nil
+}
+
+func findPurchaseOrder(db *gorm.DB, id int64) (*domain.PurchaseOrder, error) {
+	var po domain.PurchaseOrder
+	err := db.Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).First(&po, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &po, nil
+}
+
+// updateAverageCost folds quantity units at unitCost into the average cost
+// of the variant's product, weighted by the product's stock on hand.
+func updateAverageCost(tx *gorm.DB, variantID int64, quantity int, unitCost float64) error {
+	var variant product.Variant
+	if err := tx.Select("id", "product_id").First(&variant, variantID).Error; err != nil {
+		return err
+	}
+
+	var onHand int
+	err := tx.Model(&product.Variant{}).
+		Where("product_id = ?", variant.ProductID).
+		Select("COALESCE(SUM(stock), 0)").
+		Scan(&onHand).Error
+	if err != nil {
+		return err
+	}
+
+	cost := domain.ProductCost{ProductID: variant.ProductID}
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&cost, "product_id = ?", variant.ProductID).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	cost.AverageCost = cost.NextAverageCost(onHand, quantity, unitCost)
+	return tx.Save(&cost).Error
+}
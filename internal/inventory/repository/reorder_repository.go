@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/inventory/domain"
+	"gorm.io/gorm"
+)
+
+type ReorderRepository interface {
+	SaveRule(ctx context.Context, rule *domain.ReorderRule) error
+	FindRule(ctx context.Context, productID int64) (*domain.ReorderRule, error)
+	FindRules(ctx context.Context) ([]domain.ReorderRule, error)
+	DeleteRule(ctx context.Context, productID int64) error
+	SetAlertedAt(ctx context.Context, productID int64, at *time.Time) error
+	UnitsSoldSince(ctx context.Context, variantIDs []int64, since time.Time) (map[int64]int, error)
+	OutstandingPurchases(ctx context.Context, variantIDs []int64) (map[int64]int, error)
+}
+
+type reorderRepository struct {
+	db *gorm.DB
+}
+
+func NewReorderRepository(db *gorm.DB) ReorderRepository {
+	return &reorderRepository{db: db}
+}
+
+func (r *reorderRepository) SaveRule(ctx context.Context, rule *domain.ReorderRule) error {
+	return r.db.WithContext(ctx).Save(rule).Error
+}
+
+func (r *reorderRepository) FindRule(ctx context.Context, productID int64) (*domain.ReorderRule, error) {
+	var rule domain.ReorderRule
+	err := r.db.WithContext(ctx).First(&rule, "product_id = ?", productID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *reorderRepository) FindRules(ctx context.Context) ([]domain.ReorderRule, error) {
+	var rules []domain.ReorderRule
+	if err := r.db.WithContext(ctx).Order("product_id").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (r *reorderRepository) DeleteRule(ctx context.Context, productID int64) error {
+	return r.db.WithContext(ctx).Delete(&domain.ReorderRule{}, "product_id = ?", productID).Error
+}
+
+func (r *reorderRepository) SetAlertedAt(ctx context.Context, productID int64, at *time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&domain.ReorderRule{}).
+		Where("product_id = ?", productID).
+		Update("alerted_at", at).Error
+}
+
+// UnitsSoldSince sums the units of each variant sold since the given time,
+// whether sold directly or as a bundle component.
+func (r *reorderRepository) UnitsSoldSince(ctx context.Context, variantIDs []int64, since time.Time) (map[int64]int, error) {
+	sold := make(map[int64]int)
+	if len(variantIDs) == 0 {
+		return sold, nil
+	}
+
+	var rows []struct {
+		VariantID int64
+		Units     int
+	}
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT variant_id, SUM(quantity) AS units FROM (
+			SELECT ol.variant_id, ol.quantity
+			FROM order_lines ol JOIN orders o ON o.id = ol.order_id
+			WHERE ol.variant_id IN ? AND o.created_at >= ?
+			UNION ALL
+			SELECT olc.variant_id, olc.quantity
+			FROM order_line_components olc
+			JOIN order_lines ol ON ol.id = olc.order_line_id
+			JOIN orders o ON o.id = ol.order_id
+			WHERE olc.variant_id IN ? AND o.created_at >= ?
+		) sales
+		GROUP BY variant_id`, variantIDs, since, variantIDs, since).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		sold[row.VariantID] = row.Units
+	}
+	return sold, nil
+}
+
+// OutstandingPurchases sums, per variant, the quantity ordered from
+// suppliers and not yet received, drafts included.
+func (r *reorderRepository) OutstandingPurchases(ctx context.Context, variantIDs []int64) (map[int64]int, error) {
+	outstanding := make(map[int64]int)
+	if len(variantIDs) == 0 {
+		return outstanding, nil
+	}
+
+	var rows []struct {
+		VariantID int64
+		Units     int
+	}
+	err := r.db.WithContext(ctx).
+		Table("purchase_order_lines pol").
+		Select("pol.variant_id, SUM(pol.quantity - pol.received_quantity) AS units").
+		Joins("JOIN purchase_orders po ON po.id = pol.purchase_order_id").
+		Where("pol.variant_id IN ?", variantIDs).
+		Where("po.status IN ?", []domain.PurchaseOrderStatus{
+			domain.PurchaseOrderDraft,
+			domain.PurchaseOrderOpen,
+			domain.PurchaseOrderPartiallyReceived,
+		}).
+		Group("pol.variant_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		outstanding[row.VariantID] = row.Units
+	}
+	return outstanding, nil
+}
@@ -0,0 +1,296 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/inventory/domain"
+	"github.com/rkweber-max/checkout-backend/internal/product"
+	"gorm.io/gorm"
+)
+
+// MovementFilter narrows the stock ledger. Zero values match everything.
+type MovementFilter struct {
+	WarehouseID int64
+	VariantID   int64
+	Limit       int
+}
+
+type InventoryRepository interface {
+	CreateWarehouse(ctx context.Context, w *domain.Warehouse, openingBalance bool) error
+	FindWarehouses(ctx context.Context, activeOnly bool) ([]domain.Warehouse, error)
+	FindWarehouseByID(ctx context.Context, id int64) (*domain.Warehouse, error)
+	UpdateWarehouse(ctx context.Context, w *domain.Warehouse) error
+	CountWarehouses(ctx context.Context) (int64, error)
+	FindLevelsByWarehouse(ctx context.Context, warehouseID int64) ([]domain.StockLevel, error)
+	FindLevelsByVariants(ctx context.Context, variantIDs []int64) ([]domain.StockLevel, error)
+	Adjust(ctx context.Context, m *domain.StockMovement) error
+	CreateTransfer(ctx context.Context, t *domain.Transfer) error
+	FindTransfers(ctx context.Context, status domain.TransferStatus) ([]domain.Transfer, error)
+	FindTransferByID(ctx context.Context, id int64) (*domain.Transfer, error)
+	ReceiveTransfer(ctx context.Context, t *domain.Transfer, actor *uint) error
+	CancelTransfer(ctx context.Context, t *domain.Transfer, actor *uint) error
+	FindMovements(ctx context.Context, filter MovementFilter) ([]domain.StockMovement, error)
+}
+
+type inventoryRepository struct {
+	db *gorm.DB
+}
+
+func NewInventoryRepository(db *gorm.DB) InventoryRepository {
+	return &inventoryRepository{db: db}
+}
+
+// CreateWarehouse stores the warehouse. With openingBalance set, the stock
+// variants already have is booked into it, which is how stock kept before
+// warehouses existed gets a location.
+func (r *inventoryRepository) CreateWarehouse(ctx context.Context, w *domain.Warehouse, openingBalance bool) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(w).Error; err != nil {
+			return err
+		}
+		if !openingBalance {
+			return nil
+		}
+
+		var variants []product.Variant
+		if err := tx.Where("stock > 0").Find(&variants).Error; err != nil {
+			return err
+		}
+		for _, v := range variants {
+			level := domain.StockLevel{WarehouseID: w.ID, VariantID: v.ID, OnHand: v.Stock}
+			if err := tx.Create(&level).Error; err != nil {
+				return err
+			}
+			movement := domain.StockMovement{
+				WarehouseID: w.ID,
+				VariantID:   v.ID,
+				Quantity:    v.Stock,
+				Reason:      domain.MovementOpeningBalance,
+			}
+			if err := tx.Create(&movement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *inventoryRepository) FindWarehouses(ctx context.Context, activeOnly bool) ([]domain.Warehouse, error) {
+	var warehouses []domain.Warehouse
+	query := r.db.WithContext(ctx).Order("id")
+	if activeOnly {
+		query = query.Where("active = ?", true)
+	}
+	if err := query.Find(&warehouses).Error; err != nil {
+		return nil, err
+	}
+	return warehouses, nil
+}
+
+func (r *inventoryRepository) FindWarehouseByID(ctx context.Context, id int64) (*domain.Warehouse, error) {
+	var w domain.Warehouse
+	err := r.db.WithContext(ctx).First(&w, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+func (r *inventoryRepository) UpdateWarehouse(ctx context.Context, w *domain.Warehouse) error {
+	return r.db.WithContext(ctx).
+		Model(w).
+		Select("code", "name", "cep", "active").
+		Updates(w).Error
+}
+
+func (r *inventoryRepository) CountWarehouses(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&domain.Warehouse{}).Count(&count).Error
+	return count, err
+}
+
+func (r *inventoryRepository) FindLevelsByWarehouse(ctx context.Context, warehouseID int64) ([]domain.StockLevel, error) {
+	var levels []domain.StockLevel
+	err := r.db.WithContext(ctx).
+		Where("warehouse_id = ?", warehouseID).
+		Order("variant_id").
+		Find(&levels).Error
+	if err != nil {
+		return nil, err
+	}
+	return levels, nil
+}
+
+func (r *inventoryRepository) FindLevelsByVariants(ctx context.Context, variantIDs []int64) ([]domain.StockLevel, error) {
+	var levels []domain.StockLevel
+	if len(variantIDs) == 0 {
+		return levels, nil
+	}
+	err := r.db.WithContext(ctx).
+		Where("variant_id IN ?", variantIDs).
+		Order("variant_id, warehouse_id").
+		Find(&levels).Error
+	if err != nil {
+		return nil, err
+	}
+	return levels, nil
+}
+
+func (r *inventoryRepository) Adjust(ctx context.Context, m *domain.StockMovement) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return ApplyMovement(tx, m)
+	})
+}
+
+// CreateTransfer ships the transfer: stock leaves the source warehouse and
+// is counted as incoming at the destination.
+func (r *inventoryRepository) CreateTransfer(ctx context.Context, t *domain.Transfer) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(t).Error; err != nil {
+			return err
+		}
+
+		for _, line := range t.Lines {
+			err := ApplyMovement(tx, &domain.StockMovement{
+				WarehouseID: t.FromWarehouseID,
+				VariantID:   line.VariantID,
+				Quantity:    -line.Quantity,
+				Reason:      domain.MovementTransferOut,
+				Reference:   transferReference(t.ID),
+				CreatedBy:   t.CreatedBy,
+			})
+			if err != nil {
+				return err
+			}
+			if err := addIncoming(tx, t.ToWarehouseID, line.VariantID, line.Quantity); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *inventoryRepository) FindTransfers(ctx context.Context, status domain.TransferStatus) ([]domain.Transfer, error) {
+	var transfers []domain.Transfer
+	query := r.db.WithContext(ctx).Preload("Lines").Order("created_at DESC")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Find(&transfers).Error; err != nil {
+		return nil, err
+	}
+	return transfers, nil
+}
+
+func (r *inventoryRepository) FindTransferByID(ctx context.Context, id int64) (*domain.Transfer, error) {
+	var t domain.Transfer
+	err := r.db.WithContext(ctx).Preload("Lines").First(&t, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ReceiveTransfer books the transfer into the destination warehouse.
+func (r *inventoryRepository) ReceiveTransfer(ctx context.Context, t *domain.Transfer, actor *uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := closeTransfer(tx, t, domain.TransferReceived, &now); err != nil {
+			return err
+		}
+
+		for _, line := range t.Lines {
+			if err := addIncoming(tx, t.ToWarehouseID, line.VariantID, -line.Quantity); err != nil {
+				return err
+			}
+			err := ApplyMovement(tx, &domain.StockMovement{
+				WarehouseID: t.ToWarehouseID,
+				VariantID:   line.VariantID,
+				Quantity:    line.Quantity,
+				Reason:      domain.MovementTransferIn,
+				Reference:   transferReference(t.ID),
+				CreatedBy:   actor,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CancelTransfer returns the stock of a transfer in transit to its source.
+func (r *inventoryRepository) CancelTransfer(ctx context.Context, t *domain.Transfer, actor *uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := closeTransfer(tx, t, domain.TransferCancelled, nil); err != nil {
+			return err
+		}
+
+		for _, line := range t.Lines {
+			if err := addIncoming(tx, t.ToWarehouseID, line.VariantID, -line.Quantity); err != nil {
+				return err
+			}
+			err := ApplyMovement(tx, &domain.StockMovement{
+				WarehouseID: t.FromWarehouseID,
+				VariantID:   line.VariantID,
+				Quantity:    line.Quantity,
+				Reason:      domain.MovementTransferCancel,
+				Reference:   transferReference(t.ID),
+				CreatedBy:   actor,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *inventoryRepository) FindMovements(ctx context.Context, filter MovementFilter) ([]domain.StockMovement, error) {
+	var movements []domain.StockMovement
+	query := r.db.WithContext(ctx).Order("created_at DESC, id DESC")
+	if filter.WarehouseID != 0 {
+		query = query.Where("warehouse_id = ?", filter.WarehouseID)
+	}
+	if filter.VariantID != 0 {
+		query = query.Where("variant_id = ?", filter.VariantID)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	if err := query.Find(&movements).Error; err != nil {
+		return nil, err
+	}
+	return movements, nil
+}
+
+// closeTransfer moves a transfer out of transit. The status check is part
+// of the update so that a transfer cannot be received or cancelled twice.
+func closeTransfer(tx *gorm.DB, t *domain.Transfer, status domain.TransferStatus, receivedAt *time.Time) error {
+	result := tx.Model(&domain.Transfer{}).
+		Where("id = ? AND status = ?", t.ID, domain.TransferInTransit).
+		Updates(map[string]interface{}{"status": status, "received_at": receivedAt})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return domain.ErrTransferNotPending
+	}
+
+	t.Status = status
+	t.ReceivedAt = receivedAt
+	return nil
+}
+
+func transferReference(id int64) string {
+	return fmt.Sprintf("transfer:%d", id)
+}
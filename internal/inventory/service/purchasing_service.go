@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/auth"
+	"github.com/rkweber-max/checkout-backend/internal/inventory/domain"
+	"github.com/rkweber-max/checkout-backend/internal/inventory/repository"
+	productRepository "github.com/rkweber-max/checkout-backend/internal/product/repository"
+)
+
+// PurchaseOrderInput describes a purchase order to place with a supplier.
+type PurchaseOrderInput struct {
+	SupplierID  int64
+	WarehouseID int64
+	ExpectedAt  *time.Time
+	Notes       string
+	Lines       []domain.PurchaseOrderLine
+}
+
+type PurchasingService interface {
+	CreateSupplier(ctx context.Context, s *domain.Supplier) error
+	ListSuppliers(ctx context.Context) ([]domain.Supplier, error)
+	GetSupplier(ctx context.Context, id int64) (*domain.Supplier, error)
+	UpdateSupplier(ctx context.Context, s *domain.Supplier) error
+	CreatePurchaseOrder(ctx context.Context, input PurchaseOrderInput) (*domain.PurchaseOrder, error)
+	ListPurchaseOrders(ctx context.Context, status domain.PurchaseOrderStatus) ([]domain.PurchaseOrder, error)
+	GetPurchaseOrder(ctx context.Context, id int64) (*domain.PurchaseOrder, error)
+	SubmitPurchaseOrder(ctx context.Context, id int64) (*domain.PurchaseOrder, error)
+	CancelPurchaseOrder(ctx context.Context, id int64) error
+	Receive(ctx context.Context, id int64, warehouseID int64, lines []domain.ReceiptLine) (*domain.PurchaseOrder, error)
+	ProductCost(ctx context.Context, productID int64) (*domain.ProductCost, error)
+}
+
+type purchasingService struct {
+	repo        repository.PurchasingRepository
+	inventory   repository.InventoryRepository
+	variantRepo productRepository.VariantRepository
+	productRepo productRepository.ProductRepository
+}
+
+func NewPurchasingService(
+	repo repository.PurchasingRepository,
+	inventory repository.InventoryRepository,
+	variantRepo productRepository.VariantRepository,
+	productRepo productRepository.ProductRepository,
+) PurchasingService {
+	return &purchasingService{repo: repo, inventory: inventory, variantRepo: variantRepo, productRepo: productRepo}
+}
+
+func (s *purchasingService) CreateSupplier(ctx context.Context, supplier *domain.Supplier) error {
+	if err := validateSupplier(supplier); err != nil {
+		return err
+	}
+	return s.repo.CreateSupplier(ctx, supplier)
+}
+
+func (s *purchasingService) ListSuppliers(ctx context.Context) ([]domain.Supplier, error) {
+	return s.repo.FindSuppliers(ctx)
+}
+
+func (s *purchasingService) GetSupplier(ctx context.Context, id int64) (*domain.Supplier, error) {
+	supplier, err := s.repo.FindSupplierByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if supplier == nil {
+		return nil, domain.ErrSupplierNotFound
+	}
+	return supplier, nil
+}
+
+func (s *purchasingService) UpdateSupplier(ctx context.Context, supplier *domain.Supplier) error {
+	if err := validateSupplier(supplier); err != nil {
+		return err
+	}
+	if _, err := s.GetSupplier(ctx, supplier.ID); err != nil {
+		return err
+	}
+	return s.repo.UpdateSupplier(ctx, supplier)
+}
+
+func (s *purchasingService) CreatePurchaseOrder(ctx context.Context, input PurchaseOrderInput) (*domain.PurchaseOrder, error) {
+	if _, err := s.GetSupplier(ctx, input.SupplierID); err != nil {
+		return nil, err
+	}
+	if err := s.ensureWarehouse(ctx, input.WarehouseID); err != nil {
+		return nil, err
+	}
+
+	if len(input.Lines) == 0 {
+		return nil, errors.New("purchase order must contain at least one line")
+	}
+	seen := make(map[int64]bool)
+	for _, line := range input.Lines {
+		if line.Quantity <= 0 {
+			return nil, errors.New("ordered quantity must be positive")
+		}
+		if line.UnitCost < 0 {
+			return nil, errors.New("unit cost cannot be negative")
+		}
+		if seen[line.VariantID] {
+			return nil, fmt.Errorf("variant %d appears more than once", line.VariantID)
+		}
+		seen[line.VariantID] = true
+
+		variant, err := s.variantRepo.FindByID(ctx, line.VariantID)
+		if err != nil {
+			return nil, err
+		}
+		if variant == nil {
+			return nil, fmt.Errorf("%w: %d", ErrVariantNotFound, line.VariantID)
+		}
+	}
+
+	po := &domain.PurchaseOrder{
+		SupplierID:  input.SupplierID,
+		WarehouseID: input.WarehouseID,
+		Status:      domain.PurchaseOrderOpen,
+		ExpectedAt:  input.ExpectedAt,
+		Notes:       strings.TrimSpace(input.Notes),
+		Lines:       input.Lines,
+		CreatedBy:   auth.ActorFromContext(ctx),
+	}
+	if err := s.repo.CreatePurchaseOrder(ctx, po); err != nil {
+		return nil, err
+	}
+
+	return po, nil
+}
+
+func (s *purchasingService) ListPurchaseOrders(ctx context.Context, status domain.PurchaseOrderStatus) ([]domain.PurchaseOrder, error) {
+	return s.repo.FindPurchaseOrders(ctx, status)
+}
+
+func (s *purchasingService) GetPurchaseOrder(ctx context.Context, id int64) (*domain.PurchaseOrder, error) {
+	po, err := s.repo.FindPurchaseOrderByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if po == nil {
+		return nil, domain.ErrPurchaseOrderNotFound
+	}
+	return po, nil
+}
+
+func (s *purchasingService) SubmitPurchaseOrder(ctx context.Context, id int64) (*domain.PurchaseOrder, error) {
+	if _, err := s.GetPurchaseOrder(ctx, id); err != nil {
+		return nil, err
+	}
+	if err := s.repo.SubmitPurchaseOrder(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.GetPurchaseOrder(ctx, id)
+}
+
+func (s *purchasingService) CancelPurchaseOrder(ctx context.Context, id int64) error {
+	po, err := s.GetPurchaseOrder(ctx, id)
+	if err != nil {
+		return err
+	}
+	if po.Status != domain.PurchaseOrderDraft && !po.Status.Receivable() {
+		return domain.ErrPurchaseOrderClosed
+	}
+	return s.repo.CancelPurchaseOrder(ctx, id)
+}
+
+// Receive books a delivery into the purchase order's warehouse, or into
+// warehouseID when it is set. Lines may be received partially and over
+// several deliveries.
+func (s *purchasingService) Receive(ctx context.Context, id int64, warehouseID int64, lines []domain.ReceiptLine) (*domain.PurchaseOrder, error) {
+	po, err := s.GetPurchaseOrder(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if warehouseID == 0 {
+		warehouseID = po.WarehouseID
+	}
+	if err := s.ensureWarehouse(ctx, warehouseID); err != nil {
+		return nil, err
+	}
+
+	if len(lines) == 0 {
+		return nil, errors.New("receipt must contain at least one line")
+	}
+	for _, line := range lines {
+		if line.Quantity <= 0 {
+			return nil, errors.New("received quantity must be positive")
+		}
+		if line.UnitCost != nil && *line.UnitCost < 0 {
+			return nil, errors.New("unit cost cannot be negative")
+		}
+	}
+
+	return s.repo.Receive(ctx, id, warehouseID, lines, auth.ActorFromContext(ctx))
+}
+
+// ProductCost returns the average cost of the product, zero if it was never
+// received through a purchase order.
+func (s *purchasingService) ProductCost(ctx context.Context, productID int64) (*domain.ProductCost, error) {
+	p, err := s.productRepo.FindByID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrProductNotFound
+	}
+
+	cost, err := s.repo.FindProductCost(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if cost == nil {
+		cost = &domain.ProductCost{ProductID: productID}
+	}
+	return cost, nil
+}
+
+func (s *purchasingService) ensureWarehouse(ctx context.Context, id int64) error {
+	w, err := s.inventory.FindWarehouseByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if w == nil {
+		return domain.ErrWarehouseNotFound
+	}
+	if !w.Active {
+		return fmt.Errorf("warehouse %s is inactive", w.Code)
+	}
+	return nil
+}
+
+func validateSupplier(s *domain.Supplier) error {
+	s.Name = strings.TrimSpace(s.Name)
+	s.Document = strings.TrimSpace(s.Document)
+	s.Email = strings.TrimSpace(s.Email)
+	s.Phone = strings.TrimSpace(s.Phone)
+
+	if s.Name == "" {
+		return errors.New("supplier name cannot be empty")
+	}
+	return nil
+}
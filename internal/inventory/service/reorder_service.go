@@ -0,0 +1,315 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/inventory/domain"
+	"github.com/rkweber-max/checkout-backend/internal/inventory/repository"
+	"github.com/rkweber-max/checkout-backend/internal/product"
+	productRepository "github.com/rkweber-max/checkout-backend/internal/product/repository"
+	"github.com/rkweber-max/checkout-backend/pkg/config"
+	"github.com/rkweber-max/checkout-backend/pkg/notify"
+)
+
+const (
+	defaultReorderVelocityDays = 30
+	defaultReorderCoverDays    = 30
+)
+
+type ReorderService interface {
+	SetRule(ctx context.Context, rule *domain.ReorderRule) error
+	GetRule(ctx context.Context, productID int64) (*domain.ReorderRule, error)
+	DeleteRule(ctx context.Context, productID int64) error
+	LowStock(ctx context.Context) ([]domain.LowStock, error)
+	CheckLowStock(ctx context.Context) error
+}
+
+type reorderService struct {
+	repo         repository.ReorderRepository
+	purchasing   repository.PurchasingRepository
+	inventory    repository.InventoryRepository
+	productRepo  productRepository.ProductRepository
+	variantRepo  productRepository.VariantRepository
+	notifier     notify.Notifier
+	velocityDays int
+	coverDays    int
+}
+
+func NewReorderService(
+	repo repository.ReorderRepository,
+	purchasing repository.PurchasingRepository,
+	inventory repository.InventoryRepository,
+	productRepo productRepository.ProductRepository,
+	variantRepo productRepository.VariantRepository,
+	notifier notify.Notifier,
+	cfg *config.Config,
+) ReorderService {
+	velocityDays := cfg.ReorderVelocityDays
+	if velocityDays <= 0 {
+		velocityDays = defaultReorderVelocityDays
+	}
+	coverDays := cfg.ReorderCoverDays
+	if coverDays <= 0 {
+		coverDays = defaultReorderCoverDays
+	}
+
+	return &reorderService{
+		repo:         repo,
+		purchasing:   purchasing,
+		inventory:    inventory,
+		productRepo:  productRepo,
+		variantRepo:  variantRepo,
+		notifier:     notifier,
+		velocityDays: velocityDays,
+		coverDays:    coverDays,
+	}
+}
+
+// SetRule creates or replaces the reorder rule of a product. An alert
+// already sent for the product is kept so it is not repeated.
+func (s *reorderService) SetRule(ctx context.Context, rule *domain.ReorderRule) error {
+	if rule.ReorderPoint < 0 {
+		return errors.New("reorder point cannot be negative")
+	}
+	if rule.MinimumQuantity < 0 {
+		return errors.New("minimum quantity cannot be negative")
+	}
+	if rule.AutoDraft && (rule.SupplierID == nil || rule.WarehouseID == nil) {
+		return errors.New("automatic drafts need a supplier and a warehouse")
+	}
+
+	p, err := s.productRepo.FindByID(ctx, rule.ProductID)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return ErrProductNotFound
+	}
+
+	if rule.SupplierID != nil {
+		supplier, err := s.purchasing.FindSupplierByID(ctx, *rule.SupplierID)
+		if err != nil {
+			return err
+		}
+		if supplier == nil {
+			return domain.ErrSupplierNotFound
+		}
+	}
+	if rule.WarehouseID != nil {
+		w, err := s.inventory.FindWarehouseByID(ctx, *rule.WarehouseID)
+		if err != nil {
+			return err
+		}
+		if w == nil {
+			return domain.ErrWarehouseNotFound
+		}
+	}
+
+	existing, err := s.repo.FindRule(ctx, rule.ProductID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		rule.AlertedAt = existing.AlertedAt
+		rule.CreatedAt = existing.CreatedAt
+	}
+
+	return s.repo.SaveRule(ctx, rule)
+}
+
+func (s *reorderService) GetRule(ctx context.Context, productID int64) (*domain.ReorderRule, error) {
+	rule, err := s.repo.FindRule(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil {
+		return nil, domain.ErrReorderRuleNotFound
+	}
+	return rule, nil
+}
+
+func (s *reorderService) DeleteRule(ctx context.Context, productID int64) error {
+	if _, err := s.GetRule(ctx, productID); err != nil {
+		return err
+	}
+	return s.repo.DeleteRule(ctx, productID)
+}
+
+// LowStock lists the products whose stock, summed over their variants, is
+// at or below their reorder point.
+func (s *reorderService) LowStock(ctx context.Context) ([]domain.LowStock, error) {
+	rules, err := s.repo.FindRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	low := make([]domain.LowStock, 0)
+	for _, rule := range rules {
+		item, _, err := s.check(ctx, rule)
+		if err != nil {
+			return nil, err
+		}
+		if item != nil {
+			low = append(low, *item)
+		}
+	}
+	return low, nil
+}
+
+// CheckLowStock sends one alert for each product that fell to its reorder
+// point and, when the rule asks for it, drafts a purchase order sized on
+// recent sales. The alert is sent again only after the stock went back
+// above the reorder point.
+func (s *reorderService) CheckLowStock(ctx context.Context) error {
+	rules, err := s.repo.FindRules(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		if err := s.checkRule(ctx, rule); err != nil {
+			log.Printf("Failed to check stock of product %d: %v", rule.ProductID, err)
+		}
+	}
+	return nil
+}
+
+func (s *reorderService) checkRule(ctx context.Context, rule domain.ReorderRule) error {
+	item, variants, err := s.check(ctx, rule)
+	if err != nil {
+		return err
+	}
+
+	if item == nil {
+		if rule.AlertedAt != nil {
+			return s.repo.SetAlertedAt(ctx, rule.ProductID, nil)
+		}
+		return nil
+	}
+	if rule.AlertedAt != nil {
+		return nil
+	}
+
+	data := map[string]interface{}{
+		"product_id":    item.ProductID,
+		"sku":           item.SKU,
+		"stock":         item.Stock,
+		"reorder_point": item.ReorderPoint,
+	}
+	if rule.AutoDraft && rule.SupplierID != nil && rule.WarehouseID != nil {
+		po, err := s.draft(ctx, rule, variants)
+		if err != nil {
+			return err
+		}
+		if po != nil {
+			data["purchase_order_id"] = po.ID
+		}
+	}
+
+	now := time.Now()
+	err = s.notifier.Notify(ctx, notify.Notification{
+		Kind:    "low_stock",
+		Message: fmt.Sprintf("%s is low on stock: %d left, reorder point %d", item.Name, item.Stock, item.ReorderPoint),
+		Data:    data,
+		At:      now,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.repo.SetAlertedAt(ctx, rule.ProductID, &now)
+}
+
+// check returns the low-stock entry for the rule, or nil when the product
+// is above its reorder point, has no variants or no longer exists.
+func (s *reorderService) check(ctx context.Context, rule domain.ReorderRule) (*domain.LowStock, []product.Variant, error) {
+	p, err := s.productRepo.FindByID(ctx, rule.ProductID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if p == nil {
+		return nil, nil, nil
+	}
+
+	variants, err := s.variantRepo.FindByProductID(ctx, rule.ProductID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(variants) == 0 {
+		return nil, nil, nil
+	}
+
+	stock := 0
+	for _, v := range variants {
+		stock += v.Stock
+	}
+	if stock > rule.ReorderPoint {
+		return nil, variants, nil
+	}
+
+	return &domain.LowStock{
+		ProductID:    p.ID,
+		Name:         p.Name,
+		SKU:          p.SKU,
+		Stock:        stock,
+		ReorderPoint: rule.ReorderPoint,
+	}, variants, nil
+}
+
+// draft creates a draft purchase order covering coverDays of sales at the
+// velocity observed over the last velocityDays, net of what is already on
+// order. It returns nil when nothing needs ordering.
+func (s *reorderService) draft(ctx context.Context, rule domain.ReorderRule, variants []product.Variant) (*domain.PurchaseOrder, error) {
+	ids := make([]int64, 0, len(variants))
+	for _, v := range variants {
+		ids = append(ids, v.ID)
+	}
+
+	since := time.Now().AddDate(0, 0, -s.velocityDays)
+	sold, err := s.repo.UnitsSoldSince(ctx, ids, since)
+	if err != nil {
+		return nil, err
+	}
+	outstanding, err := s.repo.OutstandingPurchases(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	cost, err := s.purchasing.FindProductCost(ctx, rule.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	unitCost := 0.0
+	if cost != nil {
+		unitCost = cost.AverageCost
+	}
+
+	var lines []domain.PurchaseOrderLine
+	for _, v := range variants {
+		velocity := float64(sold[v.ID]) / float64(s.velocityDays)
+		quantity := domain.SuggestedQuantity(velocity, s.coverDays, v.Stock, outstanding[v.ID], rule.MinimumQuantity)
+		if quantity == 0 {
+			continue
+		}
+		lines = append(lines, domain.PurchaseOrderLine{VariantID: v.ID, Quantity: quantity, UnitCost: unitCost})
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	po := &domain.PurchaseOrder{
+		SupplierID:  *rule.SupplierID,
+		WarehouseID: *rule.WarehouseID,
+		Status:      domain.PurchaseOrderDraft,
+		Notes:       "Drafted by the low-stock job",
+		Lines:       lines,
+	}
+	if err := s.purchasing.CreatePurchaseOrder(ctx, po); err != nil {
+		return nil, err
+	}
+	return po, nil
+}
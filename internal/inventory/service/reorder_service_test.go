@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/inventory/domain"
+	"github.com/rkweber-max/checkout-backend/internal/inventory/repository"
+	"github.com/rkweber-max/checkout-backend/internal/product"
+	productRepository "github.com/rkweber-max/checkout-backend/internal/product/repository"
+	"github.com/rkweber-max/checkout-backend/pkg/config"
+	"github.com/rkweber-max/checkout-backend/pkg/notify"
+)
+
+var _ repository.ReorderRepository = (*fakeReorder)(nil)
+
+// fakeReorder is an in-memory repository.ReorderRepository. Sales and
+// open purchases are set per variant by the test.
+type fakeReorder struct {
+	rules       map[int64]domain.ReorderRule
+	sold        map[int64]int
+	outstanding map[int64]int
+}
+
+func (f *fakeReorder) SaveRule(ctx context.Context, rule *domain.ReorderRule) error {
+	f.rules[rule.ProductID] = *rule
+	return nil
+}
+
+func (f *fakeReorder) FindRule(ctx context.Context, productID int64) (*domain.ReorderRule, error) {
+	if rule, ok := f.rules[productID]; ok {
+		return &rule, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeReorder) FindRules(ctx context.Context) ([]domain.ReorderRule, error) {
+	var rules []domain.ReorderRule
+	for _, rule := range f.rules {
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (f *fakeReorder) DeleteRule(ctx context.Context, productID int64) error {
+	delete(f.rules, productID)
+	return nil
+}
+
+func (f *fakeReorder) SetAlertedAt(ctx context.Context, productID int64, at *time.Time) error {
+	rule := f.rules[productID]
+	rule.AlertedAt = at
+	f.rules[productID] = rule
+	return nil
+}
+
+func (f *fakeReorder) UnitsSoldSince(ctx context.Context, variantIDs []int64, since time.Time) (map[int64]int, error) {
+	return f.sold, nil
+}
+
+func (f *fakeReorder) OutstandingPurchases(ctx context.Context, variantIDs []int64) (map[int64]int, error) {
+	return f.outstanding, nil
+}
+
+// fakePurchasing records drafted purchase orders and returns a fixed
+// average cost.
+type fakePurchasing struct {
+	repository.PurchasingRepository
+
+	cost   *domain.ProductCost
+	orders []domain.PurchaseOrder
+}
+
+func (f *fakePurchasing) CreatePurchaseOrder(ctx context.Context, po *domain.PurchaseOrder) error {
+	po.ID = int64(len(f.orders) + 1)
+	f.orders = append(f.orders, *po)
+	return nil
+}
+
+func (f *fakePurchasing) FindProductCost(ctx context.Context, productID int64) (*domain.ProductCost, error) {
+	return f.cost, nil
+}
+
+type fakeProducts struct {
+	productRepository.ProductRepository
+
+	products map[int64]product.Product
+}
+
+func (f *fakeProducts) FindByID(ctx context.Context, id int64) (*product.Product, error) {
+	if p, ok := f.products[id]; ok {
+		return &p, nil
+	}
+	return nil, nil
+}
+
+type fakeVariants struct {
+	productRepository.VariantRepository
+
+	variants []product.Variant
+}
+
+func (f *fakeVariants) FindByProductID(ctx context.Context, productID int64) ([]product.Variant, error) {
+	var variants []product.Variant
+	for _, v := range f.variants {
+		if v.ProductID == productID {
+			variants = append(variants, v)
+		}
+	}
+	return variants, nil
+}
+
+func (f *fakeVariants) setStock(variantID int64, stock int) {
+	for i := range f.variants {
+		if f.variants[i].ID == variantID {
+			f.variants[i].Stock = stock
+		}
+	}
+}
+
+type recordingNotifier struct {
+	sent []notify.Notification
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, notification notify.Notification) error {
+	n.sent = append(n.sent, notification)
+	return nil
+}
+
+type reorderFixture struct {
+	rules      *fakeReorder
+	purchasing *fakePurchasing
+	products   *fakeProducts
+	variants   *fakeVariants
+	notifier   *recordingNotifier
+	service    ReorderService
+}
+
+// newReorderFixture watches a T-shirt sold in two sizes, reordered from
+// supplier 3 into warehouse 4 once 10 or fewer are left.
+func newReorderFixture(autoDraft bool) *reorderFixture {
+	supplier, warehouse := int64(3), int64(4)
+	f := &reorderFixture{
+		rules: &fakeReorder{
+			rules: map[int64]domain.ReorderRule{
+				1: {ProductID: 1, ReorderPoint: 10, MinimumQuantity: 6, SupplierID: &supplier, WarehouseID: &warehouse, AutoDraft: autoDraft},
+			},
+			sold:        map[int64]int{},
+			outstanding: map[int64]int{},
+		},
+		purchasing: &fakePurchasing{cost: &domain.ProductCost{ProductID: 1, AverageCost: 18.5}},
+		products:   &fakeProducts{products: map[int64]product.Product{1: {ID: 1, Name: "Camiseta", SKU: "CAM"}}},
+		variants: &fakeVariants{
+			variants: []product.Variant{
+				{ID: 11, ProductID: 1, SKU: "CAM-M", Stock: 20},
+				{ID: 12, ProductID: 1, SKU: "CAM-G", Stock: 5},
+			},
+		},
+		notifier: &recordingNotifier{},
+	}
+	cfg := &config.Config{ReorderVelocityDays: 30, ReorderCoverDays: 15}
+	f.service = NewReorderService(f.rules, f.purchasing, nil, f.products, f.variants, f.notifier, cfg)
+	return f
+}
+
+func TestCheckLowStockAlertsOnce(t *testing.T) {
+	f := newReorderFixture(false)
+	ctx := context.Background()
+
+	f.service.CheckLowStock(ctx)
+	if len(f.notifier.sent) != 0 {
+		t.Fatalf("alert sent with 25 in stock")
+	}
+
+	f.variants.setStock(11, 4)
+	f.service.CheckLowStock(ctx)
+	f.service.CheckLowStock(ctx)
+	if len(f.notifier.sent) != 1 {
+		t.Fatalf("sent %d alerts, want 1", len(f.notifier.sent))
+	}
+	if got := f.notifier.sent[0].Data["stock"]; got != 9 {
+		t.Errorf("alert stock = %v, want 9", got)
+	}
+	if len(f.purchasing.orders) != 0 {
+		t.Error("purchase order drafted without auto draft")
+	}
+
+	// Once restocked the product can alert again.
+	f.variants.setStock(11, 20)
+	f.service.CheckLowStock(ctx)
+	if f.rules.rules[1].AlertedAt != nil {
+		t.Fatal("alert not cleared after restocking")
+	}
+	f.variants.setStock(11, 0)
+	f.service.CheckLowStock(ctx)
+	if len(f.notifier.sent) != 2 {
+		t.Errorf("sent %d alerts, want 2", len(f.notifier.sent))
+	}
+}
+
+func TestCheckLowStockDraftsPurchaseOrder(t *testing.T) {
+	f := newReorderFixture(true)
+	f.variants.setStock(11, 4)
+	f.variants.setStock(12, 0)
+	// Size M sold 60 in 30 days, 2 a day, with 10 already on order; size
+	// G sold 3 and is out, so 2 are needed for 15 days but the minimum is 6.
+	f.rules.sold = map[int64]int{11: 60, 12: 3}
+	f.rules.outstanding = map[int64]int{11: 10}
+
+	f.service.CheckLowStock(context.Background())
+
+	if len(f.purchasing.orders) != 1 {
+		t.Fatalf("drafted %d purchase orders, want 1", len(f.purchasing.orders))
+	}
+	po := f.purchasing.orders[0]
+	if po.Status != domain.PurchaseOrderDraft || po.SupplierID != 3 || po.WarehouseID != 4 {
+		t.Errorf("purchase order = %+v", po)
+	}
+	want := map[int64]int{11: 16, 12: 6}
+	if len(po.Lines) != len(want) {
+		t.Fatalf("lines = %+v, want %v", po.Lines, want)
+	}
+	for _, line := range po.Lines {
+		if line.Quantity != want[line.VariantID] || line.UnitCost != 18.5 {
+			t.Errorf("line for variant %d: %d at %v, want %d at 18.5", line.VariantID, line.Quantity, line.UnitCost, want[line.VariantID])
+		}
+	}
+	if got := f.notifier.sent[0].Data["purchase_order_id"]; got != po.ID {
+		t.Errorf("alert purchase order = %v, want %d", got, po.ID)
+	}
+}
+
+func TestCheckLowStockSkipsDraftWhenNothingIsNeeded(t *testing.T) {
+	f := newReorderFixture(true)
+	f.variants.setStock(11, 4)
+	f.rules.outstanding = map[int64]int{11: 50, 12: 50}
+	f.rules.sold = map[int64]int{11: 30, 12: 30}
+
+	f.service.CheckLowStock(context.Background())
+
+	if len(f.purchasing.orders) != 0 {
+		t.Errorf("drafted %+v with enough on order", f.purchasing.orders)
+	}
+	if len(f.notifier.sent) != 1 {
+		t.Fatalf("sent %d alerts, want 1", len(f.notifier.sent))
+	}
+	if _, ok := f.notifier.sent[0].Data["purchase_order_id"]; ok {
+		t.Error("alert names a purchase order")
+	}
+}
+
+func TestLowStockIgnoresProductsWithoutVariantsOrInTrash(t *testing.T) {
+	f := newReorderFixture(false)
+	f.variants.setStock(11, 0)
+	f.rules.rules[2] = domain.ReorderRule{ProductID: 2, ReorderPoint: 100}
+	f.products.products[2] = product.Product{ID: 2, Name: "Caneca"}
+	f.rules.rules[3] = domain.ReorderRule{ProductID: 3, ReorderPoint: 100}
+
+	low, err := f.service.LowStock(context.Background())
+	if err != nil {
+		t.Fatalf("LowStock: %v", err)
+	}
+	if len(low) != 1 || low[0].ProductID != 1 || low[0].Stock != 5 {
+		t.Errorf("LowStock = %+v, want only product 1 with 5", low)
+	}
+}
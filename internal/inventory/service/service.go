@@ -0,0 +1,297 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rkweber-max/checkout-backend/internal/auth"
+	"github.com/rkweber-max/checkout-backend/internal/inventory/domain"
+	"github.com/rkweber-max/checkout-backend/internal/inventory/repository"
+	productRepository "github.com/rkweber-max/checkout-backend/internal/product/repository"
+)
+
+var (
+	ErrVariantNotFound = errors.New("variant not found")
+	ErrProductNotFound = errors.New("product not found")
+)
+
+// TransferInput describes the stock to move between two warehouses.
+type TransferInput struct {
+	FromWarehouseID int64
+	ToWarehouseID   int64
+	Lines           []domain.TransferLine
+}
+
+type InventoryService interface {
+	CreateWarehouse(ctx context.Context, w *domain.Warehouse) error
+	ListWarehouses(ctx context.Context) ([]domain.Warehouse, error)
+	GetWarehouse(ctx context.Context, id int64) (*domain.Warehouse, error)
+	UpdateWarehouse(ctx context.Context, w *domain.Warehouse) error
+	WarehouseStock(ctx context.Context, warehouseID int64) ([]domain.StockLevel, error)
+	VariantStock(ctx context.Context, variantID int64) ([]domain.StockLevel, error)
+	Adjust(ctx context.Context, warehouseID, variantID int64, quantity int, note string) (*domain.StockMovement, error)
+	CreateTransfer(ctx context.Context, input TransferInput) (*domain.Transfer, error)
+	ListTransfers(ctx context.Context, status domain.TransferStatus) ([]domain.Transfer, error)
+	GetTransfer(ctx context.Context, id int64) (*domain.Transfer, error)
+	ReceiveTransfer(ctx context.Context, id int64) (*domain.Transfer, error)
+	CancelTransfer(ctx context.Context, id int64) (*domain.Transfer, error)
+	Movements(ctx context.Context, filter repository.MovementFilter) ([]domain.StockMovement, error)
+	Source(ctx context.Context, cep string, requests []domain.SourcingRequest) ([][]domain.Allocation, error)
+}
+
+type inventoryService struct {
+	repo        repository.InventoryRepository
+	variantRepo productRepository.VariantRepository
+}
+
+func NewInventoryService(repo repository.InventoryRepository, variantRepo productRepository.VariantRepository) InventoryService {
+	return &inventoryService{repo: repo, variantRepo: variantRepo}
+}
+
+// CreateWarehouse adds a warehouse. The first warehouse receives the stock
+// recorded on variants before warehouses existed.
+func (s *inventoryService) CreateWarehouse(ctx context.Context, w *domain.Warehouse) error {
+	if err := validateWarehouse(w); err != nil {
+		return err
+	}
+
+	count, err := s.repo.CountWarehouses(ctx)
+	if err != nil {
+		return err
+	}
+
+	w.Active = true
+	return s.repo.CreateWarehouse(ctx, w, count == 0)
+}
+
+func (s *inventoryService) ListWarehouses(ctx context.Context) ([]domain.Warehouse, error) {
+	return s.repo.FindWarehouses(ctx, false)
+}
+
+func (s *inventoryService) GetWarehouse(ctx context.Context, id int64) (*domain.Warehouse, error) {
+	w, err := s.repo.FindWarehouseByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if w == nil {
+		return nil, domain.ErrWarehouseNotFound
+	}
+	return w, nil
+}
+
+func (s *inventoryService) UpdateWarehouse(ctx context.Context, w *domain.Warehouse) error {
+	if err := validateWarehouse(w); err != nil {
+		return err
+	}
+	if _, err := s.GetWarehouse(ctx, w.ID); err != nil {
+		return err
+	}
+
+	return s.repo.UpdateWarehouse(ctx, w)
+}
+
+func (s *inventoryService) WarehouseStock(ctx context.Context, warehouseID int64) ([]domain.StockLevel, error) {
+	if _, err := s.GetWarehouse(ctx, warehouseID); err != nil {
+		return nil, err
+	}
+	return s.repo.FindLevelsByWarehouse(ctx, warehouseID)
+}
+
+func (s *inventoryService) VariantStock(ctx context.Context, variantID int64) ([]domain.StockLevel, error) {
+	if err := s.ensureVariant(ctx, variantID); err != nil {
+		return nil, err
+	}
+	return s.repo.FindLevelsByVariants(ctx, []int64{variantID})
+}
+
+// Adjust corrects the on-hand stock of a variant in a warehouse, for
+// example after a stock count. Quantity is the change, not the new level.
+func (s *inventoryService) Adjust(ctx context.Context, warehouseID, variantID int64, quantity int, note string) (*domain.StockMovement, error) {
+	if quantity == 0 {
+		return nil, errors.New("adjustment quantity cannot be zero")
+	}
+	if _, err := s.GetWarehouse(ctx, warehouseID); err != nil {
+		return nil, err
+	}
+	if err := s.ensureVariant(ctx, variantID); err != nil {
+		return nil, err
+	}
+
+	movement := &domain.StockMovement{
+		WarehouseID: warehouseID,
+		VariantID:   variantID,
+		Quantity:    quantity,
+		Reason:      domain.MovementAdjustment,
+		Note:        strings.TrimSpace(note),
+		CreatedBy:   auth.ActorFromContext(ctx),
+	}
+	if err := s.repo.Adjust(ctx, movement); err != nil {
+		return nil, err
+	}
+
+	return movement, nil
+}
+
+func (s *inventoryService) CreateTransfer(ctx context.Context, input TransferInput) (*domain.Transfer, error) {
+	if input.FromWarehouseID == input.ToWarehouseID {
+		return nil, errors.New("source and destination warehouses must differ")
+	}
+	for _, id := range []int64{input.FromWarehouseID, input.ToWarehouseID} {
+		w, err := s.GetWarehouse(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if !w.Active {
+			return nil, fmt.Errorf("warehouse %s is inactive", w.Code)
+		}
+	}
+
+	if len(input.Lines) == 0 {
+		return nil, errors.New("transfer must contain at least one line")
+	}
+	seen := make(map[int64]bool)
+	for _, line := range input.Lines {
+		if line.Quantity <= 0 {
+			return nil, errors.New("transfer quantity must be positive")
+		}
+		if seen[line.VariantID] {
+			return nil, fmt.Errorf("variant %d appears more than once", line.VariantID)
+		}
+		seen[line.VariantID] = true
+		if err := s.ensureVariant(ctx, line.VariantID); err != nil {
+			return nil, err
+		}
+	}
+
+	transfer := &domain.Transfer{
+		FromWarehouseID: input.FromWarehouseID,
+		ToWarehouseID:   input.ToWarehouseID,
+		Status:          domain.TransferInTransit,
+		Lines:           input.Lines,
+		CreatedBy:       auth.ActorFromContext(ctx),
+	}
+	if err := s.repo.CreateTransfer(ctx, transfer); err != nil {
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+func (s *inventoryService) ListTransfers(ctx context.Context, status domain.TransferStatus) ([]domain.Transfer, error) {
+	return s.repo.FindTransfers(ctx, status)
+}
+
+func (s *inventoryService) GetTransfer(ctx context.Context, id int64) (*domain.Transfer, error) {
+	t, err := s.repo.FindTransferByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, domain.ErrTransferNotFound
+	}
+	return t, nil
+}
+
+func (s *inventoryService) ReceiveTransfer(ctx context.Context, id int64) (*domain.Transfer, error) {
+	t, err := s.GetTransfer(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.ReceiveTransfer(ctx, t, auth.ActorFromContext(ctx)); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (s *inventoryService) CancelTransfer(ctx context.Context, id int64) (*domain.Transfer, error) {
+	t, err := s.GetTransfer(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.CancelTransfer(ctx, t, auth.ActorFromContext(ctx)); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (s *inventoryService) Movements(ctx context.Context, filter repository.MovementFilter) ([]domain.StockMovement, error) {
+	return s.repo.FindMovements(ctx, filter)
+}
+
+// Source chooses the warehouses that ship an order to the given CEP, see
+// domain.Source. Without any active warehouse it returns nil allocations
+// and stock is only tracked on the variants.
+func (s *inventoryService) Source(ctx context.Context, cep string, requests []domain.SourcingRequest) ([][]domain.Allocation, error) {
+	warehouses, err := s.repo.FindWarehouses(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(warehouses) == 0 {
+		return nil, nil
+	}
+
+	var variantIDs []int64
+	for _, request := range requests {
+		for _, item := range request.Items {
+			if item.VariantID != 0 {
+				variantIDs = append(variantIDs, item.VariantID)
+			}
+		}
+	}
+
+	stock, err := s.repo.FindLevelsByVariants(ctx, variantIDs)
+	if err != nil {
+		return nil, err
+	}
+	levels := make(domain.Levels)
+	for _, level := range stock {
+		if levels[level.WarehouseID] == nil {
+			levels[level.WarehouseID] = make(map[int64]int)
+		}
+		levels[level.WarehouseID][level.VariantID] = level.OnHand
+	}
+
+	return domain.Source(domain.ByDistance(warehouses, cep), levels, requests)
+}
+
+func (s *inventoryService) ensureVariant(ctx context.Context, variantID int64) error {
+	v, err := s.variantRepo.FindByID(ctx, variantID)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		return ErrVariantNotFound
+	}
+	return nil
+}
+
+func validateWarehouse(w *domain.Warehouse) error {
+	w.Code = strings.ToUpper(strings.TrimSpace(w.Code))
+	w.Name = strings.TrimSpace(w.Name)
+	w.CEP = strings.ReplaceAll(strings.TrimSpace(w.CEP), "-", "")
+
+	if w.Code == "" {
+		return errors.New("warehouse code cannot be empty")
+	}
+	if w.Name == "" {
+		return errors.New("warehouse name cannot be empty")
+	}
+	if w.CEP != "" && !isCEP(w.CEP) {
+		return errors.New("warehouse CEP must have 8 digits")
+	}
+	return nil
+}
+
+func isCEP(cep string) bool {
+	if len(cep) != 8 {
+		return false
+	}
+	for _, r := range cep {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
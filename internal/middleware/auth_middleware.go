@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rkweber-max/checkout-backend/internal/auth"
+)
+
+// AuthMiddleware authenticates the request with either an API key in the
+// X-API-Key header or, without one, a bearer access token as
+// JWTAuthMiddleware does. Both set the same context values, so
+// RequirePermission and the handlers don't need to know which was used.
+func AuthMiddleware(keys auth.KeyProvider, opts auth.TokenOptions, denylist auth.Denylist, apiKeys auth.APIKeyVerifier) gin.HandlerFunc {
+	bearer := JWTAuthMiddleware(keys, opts, denylist)
+
+	return func(c *gin.Context) {
+		key := c.GetHeader("X-API-Key")
+		if key == "" {
+			bearer(c)
+			return
+		}
+
+		subject, err := apiKeys.VerifyAPIKey(c.Request.Context(), key, c.ClientIP())
+		if errors.Is(err, auth.ErrInvalidAPIKey) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		roles := make([]string, len(subject.Roles))
+		for i, r := range subject.Roles {
+			roles[i] = string(r)
+		}
+		permissions := make([]string, len(subject.Permissions))
+		for i, p := range subject.Permissions {
+			permissions[i] = string(p)
+		}
+		setPrincipal(c, subject.UserID, string(subject.Role), roles, permissions)
+
+		c.Next()
+	}
+}
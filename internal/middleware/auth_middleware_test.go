@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rkweber-max/checkout-backend/internal/auth"
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
+)
+
+// staticAPIKeys accepts a single key.
+type staticAPIKeys struct {
+	key     string
+	subject auth.Subject
+	ip      string
+}
+
+func (k *staticAPIKeys) VerifyAPIKey(ctx context.Context, key, ip string) (*auth.Subject, error) {
+	if key != k.key {
+		return nil, auth.ErrInvalidAPIKey
+	}
+	k.ip = ip
+	subject := k.subject
+	return &subject, nil
+}
+
+func TestAuthMiddlewareAcceptsAPIKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	keys := newStaticKeys(t)
+	apiKeys := &staticAPIKeys{
+		key:     "ck_erp.secret",
+		subject: auth.Subject{UserID: 7, Role: domain.RoleEmployee, Roles: []domain.Role{domain.RoleEmployee}, Permissions: []domain.Permission{domain.PermPurchasingRead}},
+	}
+
+	var userID uint
+	var role string
+	router := gin.New()
+	router.GET("/purchase-orders",
+		AuthMiddleware(keys, testTokenOptions, noDenylist{}, apiKeys),
+		RequirePermission(domain.PermPurchasingRead),
+		func(c *gin.Context) {
+			userID, _ = CurrentUserID(c)
+			role = CurrentRole(c)
+			c.Status(http.StatusOK)
+		})
+
+	serve := func(header, value string) int {
+		req := httptest.NewRequest(http.MethodGet, "/purchase-orders", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if got := serve("X-API-Key", "ck_erp.secret"); got != http.StatusOK {
+		t.Fatalf("status = %d, want %d", got, http.StatusOK)
+	}
+	if userID != 7 || role != string(domain.RoleEmployee) || apiKeys.ip == "" {
+		t.Errorf("user %d with role %q from %q, want the key's user", userID, role, apiKeys.ip)
+	}
+
+	if got := serve("X-API-Key", "ck_erp.wrong"); got != http.StatusUnauthorized {
+		t.Errorf("wrong key: status = %d, want %d", got, http.StatusUnauthorized)
+	}
+	if got := serve("", ""); got != http.StatusUnauthorized {
+		t.Errorf("no credentials: status = %d, want %d", got, http.StatusUnauthorized)
+	}
+
+	// Without a key the bearer token is used, and its permissions apply.
+	token, err := auth.GenerateToken(keys.key, testTokenOptions, auth.Subject{UserID: 8, Role: domain.RoleCustomer})
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	if got := serve("Authorization", "Bearer "+token.Token); got != http.StatusForbidden {
+		t.Errorf("bearer without permission: status = %d, want %d", got, http.StatusForbidden)
+	}
+}
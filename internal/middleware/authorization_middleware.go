@@ -1,41 +1,66 @@
 package middleware
 
 import (
-	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
 )
 
-func AuthorizationRole(allowedRoles ...string) gin.HandlerFunc {
+// RequirePermission lets the request through when the user holds every
+// one of the permissions.
+func RequirePermission(permissions ...domain.Permission) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		roleValue, exists := c.Get("role")
-		if !exists {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
-				"forbidden": "role not found",
-			})
-			return
-		}
-
-		userRole, ok := roleValue.(string)
-		if !ok {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
-				"error": "invalid role tyoe",
-			})
-			return
+		for _, p := range permissions {
+			if !HasPermission(c, p) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+					"error": "missing permission " + string(p),
+				})
+				return
+			}
 		}
+		c.Next()
+	}
+}
 
-		for _, role := range allowedRoles {
-			if userRole == role {
-				log.Printf("User %s has permission to access this resource", userRole)
-				log.Println("Successfully authorized")
+// RequireAnyPermission lets the request through when the user holds at
+// least one of the permissions, e.g. when handlers narrow what a weaker
+// permission allows.
+func RequireAnyPermission(permissions ...domain.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, p := range permissions {
+			if HasPermission(c, p) {
 				c.Next()
 				return
 			}
 		}
-
 		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
 			"error": "permission denied",
 		})
 	}
 }
+
+// HasPermission reports whether the authenticated user holds the
+// permission.
+func HasPermission(c *gin.Context, permission domain.Permission) bool {
+	for _, p := range c.GetStringSlice("permissions") {
+		if p == string(permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRole reports whether the authenticated user holds the role, as their
+// primary role or otherwise.
+func HasRole(c *gin.Context, role string) bool {
+	if CurrentRole(c) == role {
+		return true
+	}
+	for _, r := range c.GetStringSlice("roles") {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
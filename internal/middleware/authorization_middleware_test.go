@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rkweber-max/checkout-backend/internal/auth"
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
+)
+
+// staticKeys signs and verifies with a single key.
+type staticKeys struct {
+	key *auth.Key
+}
+
+func newStaticKeys(t *testing.T) *staticKeys {
+	t.Helper()
+
+	private, err := auth.GenerateKey(auth.AlgorithmEdDSA)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	return &staticKeys{key: &auth.Key{ID: "test", Algorithm: auth.AlgorithmEdDSA, Private: private}}
+}
+
+func (k *staticKeys) SigningKey(ctx context.Context) (*auth.Key, error) {
+	return k.key, nil
+}
+
+func (k *staticKeys) VerificationKey(ctx context.Context, kid string) (*auth.Key, error) {
+	if kid == k.key.ID {
+		return k.key, nil
+	}
+	return nil, nil
+}
+
+type noDenylist struct{}
+
+func (noDenylist) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	return false, nil
+}
+
+var testTokenOptions = auth.TokenOptions{Issuer: "test-issuer", Audience: "test-api", TTL: time.Minute}
+
+// newAuthorizedRouter serves GET /products behind the bearer token and
+// the given authorization middleware.
+func newAuthorizedRouter(keys *staticKeys, authorize gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/products", JWTAuthMiddleware(keys, testTokenOptions, noDenylist{}), authorize, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func get(t *testing.T, router *gin.Engine, keys *staticKeys, subject auth.Subject) int {
+	t.Helper()
+
+	token, err := auth.GenerateToken(keys.key, testTokenOptions, subject)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/products", nil)
+	req.Header.Set("Authorization", "Bearer "+token.Token)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestRequirePermission(t *testing.T) {
+	keys := newStaticKeys(t)
+	router := newAuthorizedRouter(keys, RequirePermission(domain.PermProductsWrite, domain.PermCategoriesWrite))
+
+	tests := []struct {
+		name        string
+		permissions []domain.Permission
+		want        int
+	}{
+		{"every permission", []domain.Permission{domain.PermCategoriesWrite, domain.PermProductsWrite}, http.StatusOK},
+		{"one of them", []domain.Permission{domain.PermProductsWrite}, http.StatusForbidden},
+		{"only the own-products permission", []domain.Permission{domain.PermProductsWriteOwn, domain.PermCategoriesWrite}, http.StatusForbidden},
+		{"none", nil, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subject := auth.Subject{UserID: 1, Role: domain.RoleEmployee, Permissions: tt.permissions}
+			if got := get(t, router, keys, subject); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireAnyPermission(t *testing.T) {
+	keys := newStaticKeys(t)
+	router := newAuthorizedRouter(keys, RequireAnyPermission(domain.PermProductsWrite, domain.PermProductsWriteOwn))
+
+	tests := []struct {
+		name        string
+		permissions []domain.Permission
+		want        int
+	}{
+		{"any product", []domain.Permission{domain.PermProductsWrite}, http.StatusOK},
+		{"own products", []domain.Permission{domain.PermProductsWriteOwn}, http.StatusOK},
+		{"read only", []domain.Permission{domain.PermProductsRead}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subject := auth.Subject{UserID: 1, Role: domain.RoleSeller, Permissions: tt.permissions}
+			if got := get(t, router, keys, subject); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasRoleChecksEveryRole(t *testing.T) {
+	keys := newStaticKeys(t)
+	var seller, admin bool
+	router := newAuthorizedRouter(keys, func(c *gin.Context) {
+		seller = HasRole(c, string(domain.RoleSeller))
+		admin = HasRole(c, string(domain.RoleAdmin))
+	})
+
+	subject := auth.Subject{UserID: 1, Role: domain.RoleCustomer, Roles: []domain.Role{domain.RoleCustomer, domain.RoleSeller}}
+	if got := get(t, router, keys, subject); got != http.StatusOK {
+		t.Fatalf("status = %d", got)
+	}
+	if !seller || admin {
+		t.Errorf("seller = %v, admin = %v, want true and false", seller, admin)
+	}
+}
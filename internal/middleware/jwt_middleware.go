@@ -3,22 +3,18 @@ package middleware
 import (
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/rkweber-max/checkout-backend/pkg/config"
+	"github.com/rkweber-max/checkout-backend/internal/auth"
 )
 
-func JWTAuthMiddleware(cfg *config.Config) gin.HandlerFunc {
+// JWTAuthMiddleware authenticates the request with the bearer access token.
+// The token must be signed by one of the keys, carry the expected issuer
+// and audience, and not have been revoked before its expiry, e.g. on
+// logout.
+func JWTAuthMiddleware(keys auth.KeyProvider, opts auth.TokenOptions, denylist auth.Denylist) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		secret := cfg.JWTSecret
-		if secret == "" {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-				"error": "JWT secret not configured",
-			})
-			return
-		}
-
 		authHeader := c.GetHeader("Authorization")
 
 		if authHeader == "" {
@@ -30,28 +26,91 @@ func JWTAuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 
-		token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
-			return []byte(secret), nil
-		})
-
-		if err != nil || !token.Valid {
+		claims, err := auth.ParseToken(c.Request.Context(), keys, opts, tokenString)
+		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 				"error": "invalid or expired token",
 			})
 			return
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "invalid token claims",
-			})
-			return
+		jti, _ := claims["jti"].(string)
+		if jti != "" {
+			revoked, err := denylist.IsRevoked(c.Request.Context(), jti)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			if revoked {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+					"error": "token has been revoked",
+				})
+				return
+			}
 		}
 
-		c.Set("user_id", claims["sub"])
-		c.Set("role", claims["role"])
+		sub, _ := claims["sub"].(float64)
+		role, _ := claims["role"].(string)
+		setPrincipal(c, uint(sub), role, claimStrings(claims["roles"]), claimStrings(claims["perms"]))
+		c.Set("jti", jti)
+		if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+			c.Set("token_expires_at", exp.Time)
+		}
 
 		c.Next()
 	}
 }
+
+// setPrincipal records who is making the request, where handlers and the
+// authorization middleware look for it.
+func setPrincipal(c *gin.Context, userID uint, role string, roles, permissions []string) {
+	c.Set("user_id", userID)
+	c.Set("role", role)
+	c.Set("roles", roles)
+	c.Set("permissions", permissions)
+	if userID != 0 {
+		c.Request = c.Request.WithContext(auth.WithActor(c.Request.Context(), userID))
+	}
+}
+
+// CurrentUserID returns the authenticated user's ID set by
+// JWTAuthMiddleware.
+func CurrentUserID(c *gin.Context) (uint, bool) {
+	id := auth.ActorFromContext(c.Request.Context())
+	if id == nil {
+		return 0, false
+	}
+	return *id, true
+}
+
+// CurrentRole returns the authenticated user's role set by
+// JWTAuthMiddleware, or an empty string.
+func CurrentRole(c *gin.Context) string {
+	role, _ := c.Get("role")
+	value, _ := role.(string)
+	return value
+}
+
+// claimStrings converts a JSON array claim to strings, skipping anything
+// that isn't one.
+func claimStrings(claim interface{}) []string {
+	values, _ := claim.([]interface{})
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// CurrentToken returns the ID and expiry of the access token used for the
+// request.
+func CurrentToken(c *gin.Context) (string, time.Time) {
+	jti := c.GetString("jti")
+	expiresAt, _ := c.Get("token_expires_at")
+	at, _ := expiresAt.(time.Time)
+	return jti, at
+}
@@ -0,0 +1,156 @@
+package product
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+type AttributeType string
+
+const (
+	AttributeString  AttributeType = "string"
+	AttributeNumber  AttributeType = "number"
+	AttributeInteger AttributeType = "integer"
+	AttributeBoolean AttributeType = "boolean"
+	AttributeEnum    AttributeType = "enum"
+)
+
+var attributeKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// ValidAttributeKey reports whether key can name an attribute. Keys are
+// used in query strings (attr.<key>=...) and as JSON object keys.
+func ValidAttributeKey(key string) bool {
+	return attributeKeyPattern.MatchString(key)
+}
+
+// AttributeDefinition declares a field of the spec sheet of the products in
+// a category and its subcategories. A key has the same type wherever it is
+// defined, so listings can filter on it across categories.
+type AttributeDefinition struct {
+	ID         int64         `json:"id" gorm:"primaryKey"`
+	CategoryID int64         `json:"category_id" gorm:"not null;uniqueIndex:idx_attribute_definitions_category_key"`
+	Key        string        `json:"key" gorm:"not null;uniqueIndex:idx_attribute_definitions_category_key"`
+	Label      string        `json:"label" gorm:"not null"`
+	Type       AttributeType `json:"type" gorm:"type:varchar(20);not null"`
+	Required   bool          `json:"required" gorm:"not null;default:false"`
+	Options    StringList    `json:"options,omitempty" gorm:"type:jsonb"`
+	Min        *float64      `json:"min,omitempty"`
+	Max        *float64      `json:"max,omitempty"`
+	Unit       string        `json:"unit,omitempty"`
+	CreatedAt  time.Time     `json:"created_at"`
+	UpdatedAt  time.Time     `json:"updated_at"`
+}
+
+// Check validates a value decoded from JSON against the definition.
+func (d AttributeDefinition) Check(value interface{}) error {
+	switch d.Type {
+	case AttributeString:
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("attribute %q must be a string", d.Key)
+		}
+	case AttributeEnum:
+		s, ok := value.(string)
+		if !ok || !d.Options.Contains(s) {
+			return fmt.Errorf("attribute %q must be one of %v", d.Key, []string(d.Options))
+		}
+	case AttributeBoolean:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("attribute %q must be a boolean", d.Key)
+		}
+	case AttributeNumber, AttributeInteger:
+		n, ok := value.(float64)
+		if !ok {
+			return fmt.Errorf("attribute %q must be a number", d.Key)
+		}
+		if d.Type == AttributeInteger && n != math.Trunc(n) {
+			return fmt.Errorf("attribute %q must be an integer", d.Key)
+		}
+		if d.Min != nil && n < *d.Min {
+			return fmt.Errorf("attribute %q must be at least %v", d.Key, *d.Min)
+		}
+		if d.Max != nil && n > *d.Max {
+			return fmt.Errorf("attribute %q must be at most %v", d.Key, *d.Max)
+		}
+	default:
+		return fmt.Errorf("attribute %q has unknown type %q", d.Key, d.Type)
+	}
+	return nil
+}
+
+// Parse converts a query string value into the JSON value the attribute
+// would hold, so it can be compared with stored values.
+func (t AttributeType) Parse(raw string) (interface{}, error) {
+	switch t {
+	case AttributeNumber, AttributeInteger:
+		return strconv.ParseFloat(raw, 64)
+	case AttributeBoolean:
+		return strconv.ParseBool(raw)
+	default:
+		return raw, nil
+	}
+}
+
+// Attributes holds the attribute values of a product, keyed by attribute
+// key. It is stored as a JSONB object.
+type Attributes map[string]interface{}
+
+func (a Attributes) Value() (driver.Value, error) {
+	if a == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (a *Attributes) Scan(value interface{}) error {
+	return scanJSON(value, a)
+}
+
+// StringList is a list of strings stored as a JSONB array.
+type StringList []string
+
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (l *StringList) Scan(value interface{}) error {
+	return scanJSON(value, l)
+}
+
+func (l StringList) Contains(s string) bool {
+	for _, item := range l {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func scanJSON(value interface{}, dest interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, dest)
+	case string:
+		return json.Unmarshal([]byte(v), dest)
+	default:
+		return errors.New("unsupported JSON column value")
+	}
+}
@@ -0,0 +1,45 @@
+package product
+
+import "time"
+
+// BundlePricing selects how the price of a bundle is set.
+type BundlePricing string
+
+const (
+	// BundlePricingFixed sells the bundle at the price of its own product.
+	BundlePricingFixed BundlePricing = "fixed"
+	// BundlePricingDiscount sells the bundle at the sum of its components
+	// less DiscountPercent.
+	BundlePricingDiscount BundlePricing = "discount"
+)
+
+func (p BundlePricing) IsValid() bool {
+	return p == BundlePricingFixed || p == BundlePricingDiscount
+}
+
+// Bundle turns a product into a kit of other products. The bundle has no
+// stock of its own: it is available as long as its components are, and
+// selling it takes the components out of stock.
+type Bundle struct {
+	ProductID       int64             `json:"product_id" gorm:"primaryKey;autoIncrement:false"`
+	Pricing         BundlePricing     `json:"pricing" gorm:"type:varchar(20);not null"`
+	DiscountPercent float64           `json:"discount_percent"`
+	Components      []BundleComponent `json:"components" gorm:"foreignKey:BundleID;references:ProductID;constraint:OnDelete:CASCADE"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+
+	// Price and Available are computed when the bundle is read. A nil
+	// Available means no component has limited stock.
+	Price     float64 `json:"price" gorm:"-"`
+	Available *int    `json:"available" gorm:"-"`
+}
+
+// BundleComponent is a quantity of a product, or of one of its variants,
+// included in a bundle.
+type BundleComponent struct {
+	ID        int64  `json:"id" gorm:"primaryKey"`
+	BundleID  int64  `json:"-" gorm:"not null;index"`
+	ProductID int64  `json:"product_id" gorm:"not null;index"`
+	VariantID *int64 `json:"variant_id" gorm:"index"`
+	Quantity  int    `json:"quantity" gorm:"not null"`
+}
@@ -0,0 +1,63 @@
+package product
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Category is a node in the catalogue tree. ParentID keeps the adjacency
+// list while Path stores the materialized path of ancestor IDs
+// (e.g. "/1/4/9/") so descendants can be found with a single prefix query.
+type Category struct {
+	ID        int64     `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"not null"`
+	Slug      string    `json:"slug" gorm:"uniqueIndex;not null"`
+	ParentID  *int64    `json:"parent_id" gorm:"index"`
+	Path      string    `json:"path" gorm:"index;not null"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ProductCategory is the join table between products and categories.
+type ProductCategory struct {
+	ProductID  int64 `json:"product_id" gorm:"primaryKey"`
+	CategoryID int64 `json:"category_id" gorm:"primaryKey;index"`
+}
+
+// CategoryNode is a category with its children, used to render the tree.
+type CategoryNode struct {
+	Category
+	Children []*CategoryNode `json:"children"`
+}
+
+// Breadcrumb is a single step from the root to a category.
+type Breadcrumb struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// AncestorIDs returns the IDs in the path, from the root down to and
+// including the category itself.
+func (c Category) AncestorIDs() []int64 {
+	var ids []int64
+	for _, part := range strings.Split(strings.Trim(c.Path, "/"), "/") {
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// ChildPath builds the path of a category with the given ID placed under c.
+func (c Category) ChildPath(id int64) string {
+	return c.Path + strconv.FormatInt(id, 10) + "/"
+}
+
+// RootPath builds the path of a top-level category.
+func RootPath(id int64) string {
+	return "/" + strconv.FormatInt(id, 10) + "/"
+}
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rkweber-max/checkout-backend/internal/product"
+	"github.com/rkweber-max/checkout-backend/internal/product/service"
+)
+
+type AttributeHandler struct {
+	service service.AttributeService
+}
+
+func NewAttributeHandler(service service.AttributeService) *AttributeHandler {
+	return &AttributeHandler{service: service}
+}
+
+type AttributeDefinitionRequest struct {
+	Key      string                `json:"key"`
+	Label    string                `json:"label"`
+	Type     product.AttributeType `json:"type"`
+	Required bool                  `json:"required"`
+	Options  []string              `json:"options"`
+	Min      *float64              `json:"min"`
+	Max      *float64              `json:"max"`
+	Unit     string                `json:"unit"`
+}
+
+func (r AttributeDefinitionRequest) definition(categoryID int64) *product.AttributeDefinition {
+	return &product.AttributeDefinition{
+		CategoryID: categoryID,
+		Key:        r.Key,
+		Label:      r.Label,
+		Type:       r.Type,
+		Required:   r.Required,
+		Options:    r.Options,
+		Min:        r.Min,
+		Max:        r.Max,
+		Unit:       r.Unit,
+	}
+}
+
+func (h *AttributeHandler) Define(c *gin.Context) {
+	categoryID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category ID"})
+		return
+	}
+
+	var req AttributeDefinitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	d := req.definition(categoryID)
+	if err := h.service.Define(c.Request.Context(), d); err != nil {
+		respondAttributeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, d)
+}
+
+// List returns the attributes of the category, inherited ones included.
+func (h *AttributeHandler) List(c *gin.Context) {
+	categoryID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category ID"})
+		return
+	}
+
+	definitions, err := h.service.List(c.Request.Context(), categoryID)
+	if err != nil {
+		respondAttributeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, definitions)
+}
+
+func (h *AttributeHandler) Update(c *gin.Context) {
+	categoryID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category ID"})
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("attributeId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid attribute ID"})
+		return
+	}
+
+	var req AttributeDefinitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	d := req.definition(categoryID)
+	d.ID = id
+	updated, err := h.service.Update(c.Request.Context(), d)
+	if err != nil {
+		respondAttributeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+func (h *AttributeHandler) Delete(c *gin.Context) {
+	categoryID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category ID"})
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("attributeId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid attribute ID"})
+		return
+	}
+
+	if err := h.service.Delete(c.Request.Context(), categoryID, id); err != nil {
+		respondAttributeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// SetProductAttributes replaces the spec sheet of the product. A null
+// value removes the attribute.
+func (h *AttributeHandler) SetProductAttributes(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	var attrs product.Attributes
+	if err := c.ShouldBindJSON(&attrs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	p, err := h.service.SetProductAttributes(c.Request.Context(), id, attrs)
+	if err != nil {
+		respondAttributeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, p)
+}
+
+func respondAttributeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrAttributeNotFound),
+		errors.Is(err, service.ErrCategoryNotFound),
+		errors.Is(err, service.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrDuplicateAttribute):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rkweber-max/checkout-backend/internal/product"
+	"github.com/rkweber-max/checkout-backend/internal/product/service"
+)
+
+type BundleHandler struct {
+	service service.BundleService
+}
+
+func NewBundleHandler(service service.BundleService) *BundleHandler {
+	return &BundleHandler{service: service}
+}
+
+type BundleComponentRequest struct {
+	ProductID int64  `json:"product_id" binding:"required"`
+	VariantID *int64 `json:"variant_id"`
+	Quantity  int    `json:"quantity" binding:"required,min=1"`
+}
+
+type BundleRequest struct {
+	Pricing         product.BundlePricing    `json:"pricing" binding:"required"`
+	DiscountPercent float64                  `json:"discount_percent"`
+	Components      []BundleComponentRequest `json:"components" binding:"required,min=1,dive"`
+}
+
+// Set makes the product a bundle of the given components, replacing any
+// previous composition.
+func (h *BundleHandler) Set(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	var req BundleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	input := service.BundleInput{Pricing: req.Pricing, DiscountPercent: req.DiscountPercent}
+	for _, component := range req.Components {
+		input.Components = append(input.Components, product.BundleComponent{
+			ProductID: component.ProductID,
+			VariantID: component.VariantID,
+			Quantity:  component.Quantity,
+		})
+	}
+
+	bundle, err := h.service.Set(c.Request.Context(), productID, input)
+	if err != nil {
+		respondBundleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, bundle)
+}
+
+func (h *BundleHandler) Get(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	bundle, err := h.service.Get(c.Request.Context(), productID)
+	if err != nil {
+		respondBundleError(c, err)
+		return
+	}
+	if bundle == nil {
+		respondBundleError(c, service.ErrBundleNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, bundle)
+}
+
+func (h *BundleHandler) Delete(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	if err := h.service.Delete(c.Request.Context(), productID); err != nil {
+		respondBundleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func respondBundleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrProductNotFound),
+		errors.Is(err, service.ErrBundleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidBundle):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rkweber-max/checkout-backend/internal/product"
+	"github.com/rkweber-max/checkout-backend/internal/product/service"
+)
+
+type CategoryHandler struct {
+	service service.CategoryService
+}
+
+func NewCategoryHandler(service service.CategoryService) *CategoryHandler {
+	return &CategoryHandler{service: service}
+}
+
+type CategoryRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Slug     string `json:"slug"`
+	ParentID *int64 `json:"parent_id"`
+}
+
+type MoveCategoryRequest struct {
+	ParentID *int64 `json:"parent_id"`
+}
+
+type AssignCategoriesRequest struct {
+	CategoryIDs []int64 `json:"category_ids"`
+}
+
+func (h *CategoryHandler) Create(c *gin.Context) {
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	category := product.Category{Name: req.Name, Slug: req.Slug, ParentID: req.ParentID}
+	if err := h.service.Create(c.Request.Context(), &category); err != nil {
+		respondCategoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, category)
+}
+
+func (h *CategoryHandler) GetTree(c *gin.Context) {
+	tree, err := h.service.GetTree(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tree)
+}
+
+func (h *CategoryHandler) GetByID(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category ID"})
+		return
+	}
+
+	category, err := h.service.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if category == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+func (h *CategoryHandler) Update(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category ID"})
+		return
+	}
+
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	category := product.Category{ID: id, Name: req.Name, Slug: req.Slug}
+	if err := h.service.Update(c.Request.Context(), &category); err != nil {
+		respondCategoryError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *CategoryHandler) Move(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category ID"})
+		return
+	}
+
+	var req MoveCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	category, err := h.service.Move(c.Request.Context(), id, req.ParentID)
+	if err != nil {
+		respondCategoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+func (h *CategoryHandler) Delete(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category ID"})
+		return
+	}
+
+	if err := h.service.Delete(c.Request.Context(), id); err != nil {
+		respondCategoryError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *CategoryHandler) ListProducts(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category ID"})
+		return
+	}
+
+	products, err := h.service.ListProducts(c.Request.Context(), id)
+	if err != nil {
+		respondCategoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, products)
+}
+
+func (h *CategoryHandler) AssignProduct(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	var req AssignCategoriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.AssignProduct(c.Request.Context(), id, req.CategoryIDs); err != nil {
+		respondCategoryError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func respondCategoryError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrCategoryNotFound), errors.Is(err, service.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrCategoryHasChildren), errors.Is(err, service.ErrInvalidCategoryMove):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
@@ -1,20 +1,48 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rkweber-max/checkout-backend/internal/middleware"
 	"github.com/rkweber-max/checkout-backend/internal/product"
 	"github.com/rkweber-max/checkout-backend/internal/product/service"
+	userDomain "github.com/rkweber-max/checkout-backend/internal/user/domain"
+	"github.com/rkweber-max/checkout-backend/pkg/etag"
+	"github.com/rkweber-max/checkout-backend/pkg/gtin"
 )
 
 type ProductHandler struct {
-	service service.ProductService
+	service    service.ProductService
+	categories service.CategoryService
+	variants   service.VariantService
+	images     service.ImageService
+	bundles    service.BundleService
+	attributes service.AttributeService
+	reviews    service.ReviewService
 }
 
-func NewProductHandler(service service.ProductService) *ProductHandler {
-	return &ProductHandler{service: service}
+func NewProductHandler(
+	service service.ProductService,
+	categories service.CategoryService,
+	variants service.VariantService,
+	images service.ImageService,
+	bundles service.BundleService,
+	attributes service.AttributeService,
+	reviews service.ReviewService,
+) *ProductHandler {
+	return &ProductHandler{
+		service:    service,
+		categories: categories,
+		variants:   variants,
+		images:     images,
+		bundles:    bundles,
+		attributes: attributes,
+		reviews:    reviews,
+	}
 }
 
 func (h *ProductHandler) CreateProduct(c *gin.Context) {
@@ -24,16 +52,49 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 		return
 	}
 
+	// Sellers always own what they create; users who may manage any
+	// product may assign an owner or leave the product to the platform.
+	if !middleware.HasPermission(c, userDomain.PermProductsWrite) {
+		userID, _ := middleware.CurrentUserID(c)
+		p.OwnerID = &userID
+	}
+
 	id, err := h.service.Create(c.Request.Context(), p)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		switch {
+		case errors.Is(err, gtin.ErrInvalid):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrDuplicateGTIN):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{"id": id})
 }
 
+// GetAllProducts lists products, optionally filtered by attribute values
+// with attr.<key>=<value> query parameters.
 func (h *ProductHandler) GetAllProducts(c *gin.Context) {
+	filters := make(map[string]string)
+	for key, values := range c.Request.URL.Query() {
+		if name, ok := strings.CutPrefix(key, "attr."); ok && len(values) > 0 {
+			filters[name] = values[0]
+		}
+	}
+
+	if len(filters) > 0 {
+		products, err := h.attributes.ListProducts(c.Request.Context(), filters)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, products)
+		return
+	}
+
 	products, err := h.service.GetAll(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -43,6 +104,74 @@ func (h *ProductHandler) GetAllProducts(c *gin.Context) {
 	c.JSON(http.StatusOK, products)
 }
 
+// GetMyProducts lists the products owned by the authenticated seller.
+func (h *ProductHandler) GetMyProducts(c *gin.Context) {
+	userID, _ := middleware.CurrentUserID(c)
+	products, err := h.service.GetByOwner(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, products)
+}
+
+// RequireOwner only lets the request through when the product in the :id
+// parameter belongs to the authenticated seller. Users with
+// products:write may act on any product.
+func (h *ProductHandler) RequireOwner() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if middleware.HasPermission(c, userDomain.PermProductsWrite) {
+			c.Next()
+			return
+		}
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+			return
+		}
+
+		p, err := h.service.GetByID(c.Request.Context(), id)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if p == nil {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "product not found"})
+			return
+		}
+
+		userID, _ := middleware.CurrentUserID(c)
+		if p.OwnerID == nil || *p.OwnerID != userID {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "product belongs to another seller"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// GetProductByBarcode looks up a product by the GTIN read by a barcode
+// scanner.
+func (h *ProductHandler) GetProductByBarcode(c *gin.Context) {
+	p, err := h.service.GetByGTIN(c.Request.Context(), c.Param("gtin"))
+	if err != nil {
+		if errors.Is(err, gtin.ErrInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if p == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, p)
+}
+
 func (h *ProductHandler) GetProductByID(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.ParseInt(idParam, 10, 64)
@@ -61,12 +190,63 @@ func (h *ProductHandler) GetProductByID(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, p)
+	breadcrumbs, err := h.categories.Breadcrumbs(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	options, err := h.variants.ListOptionTypes(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	variants, err := h.variants.ListVariants(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	images, err := h.images.List(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	bundle, err := h.bundles.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	rating, err := h.reviews.Summary(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("ETag", etag.Format(p.Version))
+	c.JSON(http.StatusOK, product.ProductDetail{
+		Product:     *p,
+		Breadcrumbs: breadcrumbs,
+		Options:     options,
+		Variants:    variants,
+		Images:      images,
+		Bundle:      bundle,
+		Rating:      rating,
+	})
 }
 
+// UpdateProduct replaces the product. The caller must say which version it
+// is replacing, either with If-Match or with the version field of the body.
 func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 	var p product.Product
-	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
 
 	if err := c.ShouldBindJSON(&p); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -75,15 +255,63 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 
 	p.ID = id
 
-	err := h.service.Update(c.Request.Context(), p)
+	version, present, err := etag.IfMatch(c.Request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if present {
+		p.Version = version
+	}
+	if p.Version == 0 {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header or version field is required"})
 		return
 	}
 
+	if err := h.service.Update(c.Request.Context(), &p); err != nil {
+		respondProductWriteError(c, err)
+		return
+	}
+
+	c.Header("ETag", etag.Format(p.Version))
 	c.Status(http.StatusNoContent)
 }
 
+// PatchProduct applies a JSON Merge Patch (RFC 7396), so fields absent from
+// the body keep their current value. If-Match is honoured when present.
+func (h *ProductHandler) PatchProduct(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	version, present, err := etag.IfMatch(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var expected *int64
+	if present {
+		expected = &version
+	}
+
+	patch, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	p, err := h.service.Patch(c.Request.Context(), id, expected, patch)
+	if err != nil {
+		respondProductWriteError(c, err)
+		return
+	}
+
+	c.Header("ETag", etag.Format(p.Version))
+	c.JSON(http.StatusOK, p)
+}
+
 func (h *ProductHandler) DeleteProduct(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.ParseInt(idParam, 10, 64)
@@ -92,7 +320,27 @@ func (h *ProductHandler) DeleteProduct(c *gin.Context) {
 		return
 	}
 
-	err = h.service.Delete(c.Request.Context(), id)
+	if err := h.service.Delete(c.Request.Context(), id); err != nil {
+		if errors.Is(err, service.ErrProductNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	c.Status(http.StatusNoContent)
 }
+
+func respondProductWriteError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrVersionConflict):
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrDuplicateGTIN):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
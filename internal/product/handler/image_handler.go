@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rkweber-max/checkout-backend/internal/product/service"
+	"github.com/rkweber-max/checkout-backend/pkg/imaging"
+)
+
+// multipartOverhead is the room left in an upload body for the multipart
+// boundaries and headers around the image itself.
+const multipartOverhead = 1 << 20
+
+type ImageHandler struct {
+	service service.ImageService
+}
+
+func NewImageHandler(service service.ImageService) *ImageHandler {
+	return &ImageHandler{service: service}
+}
+
+type ReorderImagesRequest struct {
+	ImageIDs []int64 `json:"image_ids" binding:"required"`
+}
+
+// Upload stores the image sent in the "image" field of a multipart form.
+func (h *ImageHandler) Upload(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	// Bound the body before the multipart form is parsed, which would
+	// otherwise buffer all of it.
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.service.MaxSize()+multipartOverhead)
+
+	header, err := c.FormFile("image")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondImageError(c, service.ErrImageTooLarge)
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "image is required"})
+		return
+	}
+	if header.Size > h.service.MaxSize() {
+		respondImageError(c, service.ErrImageTooLarge)
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	// Read one byte past the limit so oversized bodies are detected even
+	// if the declared size was wrong.
+	data, err := io.ReadAll(io.LimitReader(file, h.service.MaxSize()+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	image, err := h.service.Upload(c.Request.Context(), productID, data)
+	if err != nil {
+		respondImageError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, image)
+}
+
+func (h *ImageHandler) List(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	images, err := h.service.List(c.Request.Context(), productID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, images)
+}
+
+func (h *ImageHandler) Reorder(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	var req ReorderImagesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	images, err := h.service.Reorder(c.Request.Context(), productID, req.ImageIDs)
+	if err != nil {
+		respondImageError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, images)
+}
+
+func (h *ImageHandler) SetPrimary(c *gin.Context) {
+	productID, imageID, ok := parseImageParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.SetPrimary(c.Request.Context(), productID, imageID); err != nil {
+		respondImageError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ImageHandler) Delete(c *gin.Context) {
+	productID, imageID, ok := parseImageParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Delete(c.Request.Context(), productID, imageID); err != nil {
+		respondImageError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func parseImageParams(c *gin.Context) (int64, int64, bool) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return 0, 0, false
+	}
+
+	imageID, err := strconv.ParseInt(c.Param("imageId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid image ID"})
+		return 0, 0, false
+	}
+
+	return productID, imageID, true
+}
+
+func respondImageError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrProductNotFound), errors.Is(err, service.ErrImageNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrImageTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, imaging.ErrUnsupportedImage):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	case errors.Is(err, imaging.ErrInvalidImage), errors.Is(err, service.ErrInvalidOrder):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rkweber-max/checkout-backend/internal/product/service"
+	"github.com/rkweber-max/checkout-backend/pkg/spreadsheet"
+)
+
+const maxImportFileSize = 20 << 20
+
+type ImportHandler struct {
+	service service.ImportService
+}
+
+func NewImportHandler(service service.ImportService) *ImportHandler {
+	return &ImportHandler{service: service}
+}
+
+type ImportRequest struct {
+	Mapping string `form:"mapping"`
+	DryRun  bool   `form:"dry_run"`
+	Async   bool   `form:"async"`
+}
+
+// Import accepts a multipart upload with the spreadsheet in the "file"
+// field. Small files are imported inline; large ones, or any file when
+// async=true, are processed in the background and answered with 202.
+func (h *ImportHandler) Import(c *gin.Context) {
+	var req ImportRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	opts := service.ImportOptions{DryRun: req.DryRun}
+	if req.Mapping != "" {
+		if err := json.Unmarshal([]byte(req.Mapping), &opts.Mapping); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mapping must be a JSON object of field to column header"})
+			return
+		}
+	}
+
+	rows, err := readUploadedSheet(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Async || len(rows)-1 > service.BackgroundImportThreshold {
+		job, err := h.service.StartImport(c.Request.Context(), rows, opts)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.Header("Location", "/api/admin/products/import/jobs/"+job.ID)
+		c.JSON(http.StatusAccepted, job)
+		return
+	}
+
+	report, err := h.service.Import(c.Request.Context(), rows, opts)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func (h *ImportHandler) GetJob(c *gin.Context) {
+	job := h.service.GetJob(c.Param("jobId"))
+	if job == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "import job not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// Export streams the catalogue as CSV (default) or XLSX, selected with the
+// format query parameter.
+func (h *ImportHandler) Export(c *gin.Context) {
+	format, err := spreadsheet.ParseFormat(c.DefaultQuery("format", string(spreadsheet.FormatCSV)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="products.%s"`, format))
+	c.Status(http.StatusOK)
+
+	w, err := spreadsheet.NewWriter(c.Writer, format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Headers are already sent, so a failure can only be logged.
+	if err := h.service.Export(c.Request.Context(), w); err != nil {
+		log.Printf("Product export failed: %v", err)
+	}
+}
+
+func readUploadedSheet(c *gin.Context) ([][]string, error) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, errors.New("file is required")
+	}
+	if header.Size > maxImportFileSize {
+		return nil, fmt.Errorf("file exceeds the %d MB limit", maxImportFileSize>>20)
+	}
+
+	format, err := spreadsheet.FormatFromFilename(header.Filename)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxImportFileSize))
+	if err != nil {
+		return nil, err
+	}
+
+	return spreadsheet.ReadAll(bytes.NewReader(data), int64(len(data)), format)
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rkweber-max/checkout-backend/internal/product/service"
+)
+
+type PriceHandler struct {
+	service service.PriceService
+}
+
+func NewPriceHandler(service service.PriceService) *PriceHandler {
+	return &PriceHandler{service: service}
+}
+
+type SchedulePriceRequest struct {
+	Price    float64    `json:"price" binding:"min=0"`
+	StartsAt time.Time  `json:"starts_at" binding:"required"`
+	EndsAt   *time.Time `json:"ends_at"`
+}
+
+func (h *PriceHandler) History(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	history, err := h.service.History(c.Request.Context(), productID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+func (h *PriceHandler) Schedule(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	var req SchedulePriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	schedule, err := h.service.Schedule(c.Request.Context(), productID, service.ScheduleInput{
+		Price:    req.Price,
+		StartsAt: req.StartsAt,
+		EndsAt:   req.EndsAt,
+	})
+	if err != nil {
+		respondPriceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, schedule)
+}
+
+func (h *PriceHandler) ListSchedules(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	schedules, err := h.service.ListSchedules(c.Request.Context(), productID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedules)
+}
+
+func (h *PriceHandler) CancelSchedule(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	scheduleID, err := strconv.ParseInt(c.Param("scheduleId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid scheduled price ID"})
+		return
+	}
+
+	if err := h.service.CancelSchedule(c.Request.Context(), productID, scheduleID); err != nil {
+		respondPriceError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func respondPriceError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrProductNotFound), errors.Is(err, service.ErrScheduleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrScheduleNotCancellable), errors.Is(err, service.ErrScheduleOverlap):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rkweber-max/checkout-backend/internal/middleware"
+	"github.com/rkweber-max/checkout-backend/internal/product"
+	"github.com/rkweber-max/checkout-backend/internal/product/service"
+)
+
+type ReviewHandler struct {
+	service service.ReviewService
+}
+
+func NewReviewHandler(service service.ReviewService) *ReviewHandler {
+	return &ReviewHandler{service: service}
+}
+
+type ReviewRequest struct {
+	Rating int    `json:"rating" binding:"required,min=1,max=5"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+}
+
+type RejectReviewRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (h *ReviewHandler) Create(c *gin.Context) {
+	review, ok := h.bindReview(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Create(c.Request.Context(), review); err != nil {
+		respondReviewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, review)
+}
+
+// UpdateMine edits the authenticated customer's review of the product.
+func (h *ReviewHandler) UpdateMine(c *gin.Context) {
+	review, ok := h.bindReview(c)
+	if !ok {
+		return
+	}
+
+	updated, err := h.service.UpdateOwn(c.Request.Context(), review)
+	if err != nil {
+		respondReviewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+func (h *ReviewHandler) DeleteMine(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	userID, _ := middleware.CurrentUserID(c)
+	if err := h.service.DeleteOwn(c.Request.Context(), productID, userID); err != nil {
+		respondReviewError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// List returns the approved reviews of the product, newest first.
+func (h *ReviewHandler) List(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	reviews, err := h.service.ListApproved(c.Request.Context(), productID)
+	if err != nil {
+		respondReviewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reviews)
+}
+
+func (h *ReviewHandler) Pending(c *gin.Context) {
+	reviews, err := h.service.Pending(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, reviews)
+}
+
+func (h *ReviewHandler) Approve(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("reviewId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid review ID"})
+		return
+	}
+
+	review, err := h.service.Approve(c.Request.Context(), id)
+	if err != nil {
+		respondReviewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, review)
+}
+
+func (h *ReviewHandler) Reject(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("reviewId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid review ID"})
+		return
+	}
+
+	var req RejectReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	review, err := h.service.Reject(c.Request.Context(), id, req.Reason)
+	if err != nil {
+		respondReviewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, review)
+}
+
+// bindReview reads the review of the authenticated customer for the
+// product in the :id parameter.
+func (h *ReviewHandler) bindReview(c *gin.Context) (*product.Review, bool) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return nil, false
+	}
+
+	var req ReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	userID, _ := middleware.CurrentUserID(c)
+	return &product.Review{
+		ProductID: productID,
+		UserID:    userID,
+		Rating:    req.Rating,
+		Title:     req.Title,
+		Body:      req.Body,
+	}, true
+}
+
+func respondReviewError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrReviewNotFound), errors.Is(err, service.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrDuplicateReview):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
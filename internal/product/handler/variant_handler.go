@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rkweber-max/checkout-backend/internal/product"
+	"github.com/rkweber-max/checkout-backend/internal/product/service"
+)
+
+type VariantHandler struct {
+	service service.VariantService
+}
+
+func NewVariantHandler(service service.VariantService) *VariantHandler {
+	return &VariantHandler{service: service}
+}
+
+type OptionTypeRequest struct {
+	Name   string   `json:"name" binding:"required"`
+	Values []string `json:"values" binding:"required,min=1"`
+}
+
+type GenerateVariantsRequest struct {
+	Price *float64 `json:"price"`
+}
+
+type UpdateVariantRequest struct {
+	SKU     string   `json:"sku" binding:"required"`
+	Price   *float64 `json:"price"`
+	Barcode string   `json:"barcode"`
+}
+
+func (h *VariantHandler) AddOptionType(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	var req OptionTypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	option, err := h.service.AddOptionType(c.Request.Context(), productID, req.Name, req.Values)
+	if err != nil {
+		respondVariantError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, option)
+}
+
+func (h *VariantHandler) ListOptionTypes(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	options, err := h.service.ListOptionTypes(c.Request.Context(), productID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, options)
+}
+
+func (h *VariantHandler) DeleteOptionType(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	optionTypeID, err := strconv.ParseInt(c.Param("optionId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid option type ID"})
+		return
+	}
+
+	if err := h.service.DeleteOptionType(c.Request.Context(), productID, optionTypeID); err != nil {
+		respondVariantError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *VariantHandler) ListVariants(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	variants, err := h.service.ListVariants(c.Request.Context(), productID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, variants)
+}
+
+func (h *VariantHandler) GenerateVariants(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	var req GenerateVariantsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	variants, err := h.service.GenerateVariants(c.Request.Context(), productID, service.GenerateVariantsInput{
+		Price: req.Price,
+	})
+	if err != nil {
+		respondVariantError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, variants)
+}
+
+func (h *VariantHandler) UpdateVariant(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	variantID, err := strconv.ParseInt(c.Param("variantId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid variant ID"})
+		return
+	}
+
+	var req UpdateVariantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	variant := product.Variant{
+		ID:        variantID,
+		ProductID: productID,
+		SKU:       req.SKU,
+		Price:     req.Price,
+		Barcode:   req.Barcode,
+	}
+	if err := h.service.UpdateVariant(c.Request.Context(), &variant); err != nil {
+		respondVariantError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *VariantHandler) DeleteVariant(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	variantID, err := strconv.ParseInt(c.Param("variantId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid variant ID"})
+		return
+	}
+
+	if err := h.service.DeleteVariant(c.Request.Context(), productID, variantID); err != nil {
+		respondVariantError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func respondVariantError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrProductNotFound),
+		errors.Is(err, service.ErrVariantNotFound),
+		errors.Is(err, service.ErrOptionTypeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrNoOptionTypes),
+		errors.Is(err, service.ErrDuplicateBarcode):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
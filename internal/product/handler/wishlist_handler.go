@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rkweber-max/checkout-backend/internal/middleware"
+	"github.com/rkweber-max/checkout-backend/internal/product/service"
+)
+
+type WishlistHandler struct {
+	service service.WishlistService
+}
+
+func NewWishlistHandler(service service.WishlistService) *WishlistHandler {
+	return &WishlistHandler{service: service}
+}
+
+type NotifyMeRequest struct {
+	VariantID *int64 `json:"variant_id"`
+}
+
+func (h *WishlistHandler) List(c *gin.Context) {
+	userID, _ := middleware.CurrentUserID(c)
+	items, err := h.service.List(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, items)
+}
+
+func (h *WishlistHandler) Add(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("productId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	userID, _ := middleware.CurrentUserID(c)
+	if err := h.service.Add(c.Request.Context(), userID, productID); err != nil {
+		respondWishlistError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *WishlistHandler) Remove(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("productId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	userID, _ := middleware.CurrentUserID(c)
+	if err := h.service.Remove(c.Request.Context(), userID, productID); err != nil {
+		respondWishlistError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Subscribe registers a "notify me" request for an out-of-stock product or
+// variant.
+func (h *WishlistHandler) Subscribe(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	var req NotifyMeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := middleware.CurrentUserID(c)
+	subscription, err := h.service.Subscribe(c.Request.Context(), userID, productID, req.VariantID)
+	if err != nil {
+		respondWishlistError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, subscription)
+}
+
+// Unsubscribe cancels a "notify me" request. The variant, if any, is given
+// in the variant_id query parameter.
+func (h *WishlistHandler) Unsubscribe(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	var variantID *int64
+	if raw := c.Query("variant_id"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid variant ID"})
+			return
+		}
+		variantID = &id
+	}
+
+	userID, _ := middleware.CurrentUserID(c)
+	if err := h.service.Unsubscribe(c.Request.Context(), userID, productID, variantID); err != nil {
+		respondWishlistError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *WishlistHandler) Subscriptions(c *gin.Context) {
+	userID, _ := middleware.CurrentUserID(c)
+	subscriptions, err := h.service.Subscriptions(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, subscriptions)
+}
+
+func respondWishlistError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrProductNotFound),
+		errors.Is(err, service.ErrVariantNotFound),
+		errors.Is(err, service.ErrWishlistItemNotFound),
+		errors.Is(err, service.ErrSubscriptionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInStock):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,28 @@
+package product
+
+import "time"
+
+// ThumbnailSizes maps each thumbnail name to the maximum length of its
+// longest side, in pixels.
+var ThumbnailSizes = map[string]int{
+	"small":  150,
+	"medium": 400,
+	"large":  800,
+}
+
+// Image is a picture of a product. StorageKey is the prefix under which the
+// original and its thumbnails are stored.
+type Image struct {
+	ID          int64             `json:"id" gorm:"primaryKey"`
+	ProductID   int64             `json:"product_id" gorm:"not null;index"`
+	StorageKey  string            `json:"-" gorm:"not null"`
+	ContentType string            `json:"content_type"`
+	Width       int               `json:"width"`
+	Height      int               `json:"height"`
+	SizeBytes   int64             `json:"size_bytes"`
+	URL         string            `json:"url"`
+	Thumbnails  map[string]string `json:"thumbnails" gorm:"serializer:json"`
+	Position    int               `json:"position"`
+	IsPrimary   bool              `json:"is_primary"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
@@ -0,0 +1,47 @@
+package product
+
+import "time"
+
+// ImportColumns lists the product fields that can be mapped to spreadsheet
+// columns, in export order.
+var ImportColumns = []string{"sku", "gtin", "name", "description", "price"}
+
+// RowError reports why a spreadsheet row was rejected. Row is the 1-based
+// row number as shown by spreadsheet software, header included.
+type RowError struct {
+	Row    int      `json:"row"`
+	SKU    string   `json:"sku,omitempty"`
+	Errors []string `json:"errors"`
+}
+
+// ImportReport summarises an import. In dry-run mode Created and Updated
+// count what would have happened.
+type ImportReport struct {
+	DryRun    bool       `json:"dry_run"`
+	TotalRows int        `json:"total_rows"`
+	Created   int        `json:"created"`
+	Updated   int        `json:"updated"`
+	Failed    int        `json:"failed"`
+	Errors    []RowError `json:"errors"`
+}
+
+type ImportJobStatus string
+
+const (
+	ImportJobQueued    ImportJobStatus = "queued"
+	ImportJobRunning   ImportJobStatus = "running"
+	ImportJobCompleted ImportJobStatus = "completed"
+	ImportJobFailed    ImportJobStatus = "failed"
+)
+
+// ImportJob tracks an import running in the background.
+type ImportJob struct {
+	ID         string          `json:"id"`
+	Status     ImportJobStatus `json:"status"`
+	Processed  int             `json:"processed"`
+	Total      int             `json:"total"`
+	Report     *ImportReport   `json:"report,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
@@ -1,8 +1,27 @@
 package product
 
+import "gorm.io/gorm"
+
 type Product struct {
-	ID          int64   `json:"id"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
+	ID          int64          `json:"id"`
+	SKU         string         `json:"sku" gorm:"uniqueIndex:idx_products_sku,where:sku <> ''"`
+	GTIN        string         `json:"gtin,omitempty" gorm:"column:gtin;uniqueIndex:idx_products_gtin,where:gtin <> ''"`
+	OwnerID     *uint          `json:"owner_id" gorm:"index"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Price       float64        `json:"price"`
+	Attributes  Attributes     `json:"attributes" gorm:"type:jsonb;not null;default:'{}';index:idx_products_attributes,type:gin"`
+	Version     int64          `json:"version" gorm:"not null;default:1"`
+	DeletedAt   gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
+}
+
+// ProductDetail is the product representation returned by GET /products/:id.
+type ProductDetail struct {
+	Product
+	Breadcrumbs [][]Breadcrumb `json:"breadcrumbs"`
+	Options     []OptionType   `json:"options"`
+	Variants    []Variant      `json:"variants"`
+	Images      []Image        `json:"images"`
+	Bundle      *Bundle        `json:"bundle,omitempty"`
+	Rating      RatingSummary  `json:"rating"`
 }
@@ -0,0 +1,89 @@
+package product
+
+import (
+	"context"
+	"time"
+)
+
+type PriceSource string
+
+const (
+	PriceSourceManual         PriceSource = "manual"
+	PriceSourceImport         PriceSource = "import"
+	PriceSourceSchedule       PriceSource = "schedule"
+	PriceSourceScheduleRevert PriceSource = "schedule_revert"
+)
+
+// PriceChange records a change of a product's base price.
+type PriceChange struct {
+	ID        int64       `json:"id" gorm:"primaryKey"`
+	ProductID int64       `json:"product_id" gorm:"not null;index"`
+	OldPrice  float64     `json:"old_price"`
+	NewPrice  float64     `json:"new_price"`
+	Source    PriceSource `json:"source" gorm:"type:varchar(20);not null"`
+	ChangedBy *uint       `json:"changed_by"`
+	ChangedAt time.Time   `json:"changed_at" gorm:"not null;index"`
+}
+
+type ScheduleStatus string
+
+const (
+	SchedulePending   ScheduleStatus = "pending"
+	ScheduleActive    ScheduleStatus = "active"
+	ScheduleCompleted ScheduleStatus = "completed"
+	ScheduleCancelled ScheduleStatus = "cancelled"
+)
+
+// ScheduledPrice is a future price for a product. When EndsAt is set the
+// price is a temporary sale and the previous price is restored at that time.
+type ScheduledPrice struct {
+	ID            int64          `json:"id" gorm:"primaryKey"`
+	ProductID     int64          `json:"product_id" gorm:"not null;index"`
+	Price         float64        `json:"price"`
+	StartsAt      time.Time      `json:"starts_at" gorm:"not null;index"`
+	EndsAt        *time.Time     `json:"ends_at"`
+	PreviousPrice *float64       `json:"previous_price"`
+	Status        ScheduleStatus `json:"status" gorm:"type:varchar(20);not null;index"`
+	CreatedBy     *uint          `json:"created_by"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+// Covers reports whether the schedule sets the price at the given time.
+func (s ScheduledPrice) Covers(at time.Time) bool {
+	if s.Status != SchedulePending && s.Status != ScheduleActive {
+		return false
+	}
+	if at.Before(s.StartsAt) {
+		return false
+	}
+	return s.EndsAt == nil || at.Before(*s.EndsAt)
+}
+
+// Overlaps reports whether both schedules would set the price at the same
+// time. A permanent change only claims its start time: once applied, the
+// price it sets is the product's own and a later sale restores it.
+func (s ScheduledPrice) Overlaps(other ScheduledPrice) bool {
+	return s.claims(other.StartsAt) || other.claims(s.StartsAt)
+}
+
+func (s ScheduledPrice) claims(at time.Time) bool {
+	if s.EndsAt == nil {
+		return at.Equal(s.StartsAt)
+	}
+	return !at.Before(s.StartsAt) && at.Before(*s.EndsAt)
+}
+
+type priceSourceKey struct{}
+
+// WithPriceSource tags the context so price changes made with it are
+// recorded with the given source. Untagged changes are manual.
+func WithPriceSource(ctx context.Context, source PriceSource) context.Context {
+	return context.WithValue(ctx, priceSourceKey{}, source)
+}
+
+func PriceSourceFromContext(ctx context.Context) PriceSource {
+	if source, ok := ctx.Value(priceSourceKey{}).(PriceSource); ok {
+		return source
+	}
+	return PriceSourceManual
+}
@@ -0,0 +1,89 @@
+package product
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+var scheduleBase = time.Date(2026, 11, 27, 0, 0, 0, 0, time.UTC)
+
+func at(hours int) time.Time {
+	return scheduleBase.Add(time.Duration(hours) * time.Hour)
+}
+
+func sale(start, end int) ScheduledPrice {
+	ends := at(end)
+	return ScheduledPrice{StartsAt: at(start), EndsAt: &ends, Status: SchedulePending}
+}
+
+func change(start int) ScheduledPrice {
+	return ScheduledPrice{StartsAt: at(start), Status: SchedulePending}
+}
+
+func TestScheduledPriceCovers(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule ScheduledPrice
+		at       time.Time
+		want     bool
+	}{
+		{"before a sale", sale(10, 20), at(9), false},
+		{"at the start of a sale", sale(10, 20), at(10), true},
+		{"during a sale", sale(10, 20), at(15), true},
+		{"at the end of a sale", sale(10, 20), at(20), false},
+		{"after a permanent change", change(10), at(1000), true},
+		{"before a permanent change", change(10), at(5), false},
+	}
+	for _, tt := range tests {
+		if got := tt.schedule.Covers(tt.at); got != tt.want {
+			t.Errorf("%s: Covers = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	for _, status := range []ScheduleStatus{ScheduleCompleted, ScheduleCancelled} {
+		s := sale(10, 20)
+		s.Status = status
+		if s.Covers(at(15)) {
+			t.Errorf("%s schedule covers a time in its window", status)
+		}
+	}
+}
+
+func TestScheduledPriceOverlaps(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b ScheduledPrice
+		want bool
+	}{
+		{"sales apart", sale(0, 10), sale(20, 30), false},
+		{"sales back to back", sale(0, 10), sale(10, 20), false},
+		{"sales crossing", sale(0, 10), sale(5, 15), true},
+		{"sale inside another", sale(0, 30), sale(10, 20), true},
+		{"same window", sale(0, 10), sale(0, 10), true},
+		{"change during a sale", sale(0, 10), change(5), true},
+		{"change when a sale starts", sale(0, 10), change(0), true},
+		{"change when a sale ends", sale(0, 10), change(10), false},
+		{"sale after a change", change(0), sale(5, 10), false},
+		{"changes at different times", change(0), change(5), false},
+		{"changes at the same time", change(5), change(5), true},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Overlaps(tt.b); got != tt.want {
+			t.Errorf("%s: Overlaps = %v, want %v", tt.name, got, tt.want)
+		}
+		if got := tt.b.Overlaps(tt.a); got != tt.want {
+			t.Errorf("%s (reversed): Overlaps = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPriceSourceFromContext(t *testing.T) {
+	ctx := context.Background()
+	if got := PriceSourceFromContext(ctx); got != PriceSourceManual {
+		t.Errorf("untagged source = %q, want manual", got)
+	}
+	if got := PriceSourceFromContext(WithPriceSource(ctx, PriceSourceImport)); got != PriceSourceImport {
+		t.Errorf("tagged source = %q, want import", got)
+	}
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rkweber-max/checkout-backend/internal/product"
+	"gorm.io/gorm"
+)
+
+type AttributeRepository interface {
+	Create(ctx context.Context, d *product.AttributeDefinition) error
+	FindByID(ctx context.Context, id int64) (*product.AttributeDefinition, error)
+	FindByCategoryIDs(ctx context.Context, categoryIDs []int64) ([]product.AttributeDefinition, error)
+	FindByKey(ctx context.Context, key string) ([]product.AttributeDefinition, error)
+	Update(ctx context.Context, d *product.AttributeDefinition) error
+	Delete(ctx context.Context, id int64) error
+}
+
+type attributeRepository struct {
+	db *gorm.DB
+}
+
+func NewAttributeRepository(db *gorm.DB) AttributeRepository {
+	return &attributeRepository{db: db}
+}
+
+func (r *attributeRepository) Create(ctx context.Context, d *product.AttributeDefinition) error {
+	return r.db.WithContext(ctx).Create(d).Error
+}
+
+func (r *attributeRepository) FindByID(ctx context.Context, id int64) (*product.AttributeDefinition, error) {
+	var d product.AttributeDefinition
+	err := r.db.WithContext(ctx).First(&d, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *attributeRepository) FindByCategoryIDs(ctx context.Context, categoryIDs []int64) ([]product.AttributeDefinition, error) {
+	var definitions []product.AttributeDefinition
+	if len(categoryIDs) == 0 {
+		return definitions, nil
+	}
+	err := r.db.WithContext(ctx).
+		Where("category_id IN ?", categoryIDs).
+		Order("category_id, key").
+		Find(&definitions).Error
+	if err != nil {
+		return nil, err
+	}
+	return definitions, nil
+}
+
+func (r *attributeRepository) FindByKey(ctx context.Context, key string) ([]product.AttributeDefinition, error) {
+	var definitions []product.AttributeDefinition
+	if err := r.db.WithContext(ctx).Where("key = ?", key).Find(&definitions).Error; err != nil {
+		return nil, err
+	}
+	return definitions, nil
+}
+
+func (r *attributeRepository) Update(ctx context.Context, d *product.AttributeDefinition) error {
+	return r.db.WithContext(ctx).
+		Model(d).
+		Select("label", "required", "options", "min", "max", "unit").
+		Updates(d).Error
+}
+
+func (r *attributeRepository) Delete(ctx context.Context, id int64) error {
+	return r.db.WithContext(ctx).Delete(&product.AttributeDefinition{}, id).Error
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rkweber-max/checkout-backend/internal/product"
+	"gorm.io/gorm"
+)
+
+type BundleRepository interface {
+	Save(ctx context.Context, b *product.Bundle) error
+	FindByProductID(ctx context.Context, productID int64) (*product.Bundle, error)
+	Delete(ctx context.Context, productID int64) error
+	IsComponent(ctx context.Context, productID int64) (bool, error)
+}
+
+type bundleRepository struct {
+	db *gorm.DB
+}
+
+func NewBundleRepository(db *gorm.DB) BundleRepository {
+	return &bundleRepository{db: db}
+}
+
+// Save creates or replaces the bundle together with its components.
+func (r *bundleRepository) Save(ctx context.Context, b *product.Bundle) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("bundle_id = ?", b.ProductID).Delete(&product.BundleComponent{}).Error; err != nil {
+			return err
+		}
+		for i := range b.Components {
+			b.Components[i].ID = 0
+			b.Components[i].BundleID = b.ProductID
+		}
+		return tx.Save(b).Error
+	})
+}
+
+func (r *bundleRepository) FindByProductID(ctx context.Context, productID int64) (*product.Bundle, error) {
+	var b product.Bundle
+	err := r.db.WithContext(ctx).
+		Preload("Components", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		First(&b, "product_id = ?", productID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (r *bundleRepository) Delete(ctx context.Context, productID int64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("bundle_id = ?", productID).Delete(&product.BundleComponent{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&product.Bundle{}, "product_id = ?", productID).Error
+	})
+}
+
+// IsComponent reports whether the product is part of any bundle.
+func (r *bundleRepository) IsComponent(ctx context.Context, productID int64) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&product.BundleComponent{}).
+		Where("product_id = ?", productID).
+		Count(&count).Error
+	return count > 0, err
+}
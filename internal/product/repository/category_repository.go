@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rkweber-max/checkout-backend/internal/product"
+	"gorm.io/gorm"
+)
+
+type CategoryRepository interface {
+	Create(ctx context.Context, c *product.Category) error
+	FindAll(ctx context.Context) ([]product.Category, error)
+	FindByID(ctx context.Context, id int64) (*product.Category, error)
+	FindByIDs(ctx context.Context, ids []int64) ([]product.Category, error)
+	FindByProductID(ctx context.Context, productID int64) ([]product.Category, error)
+	FindDescendantIDs(ctx context.Context, c product.Category) ([]int64, error)
+	HasChildren(ctx context.Context, id int64) (bool, error)
+	Update(ctx context.Context, c *product.Category) error
+	Move(ctx context.Context, c *product.Category, newParentID *int64, newPath string) error
+	Delete(ctx context.Context, id int64) error
+	SetProductCategories(ctx context.Context, productID int64, categoryIDs []int64) error
+}
+
+type categoryRepository struct {
+	db *gorm.DB
+}
+
+func NewCategoryRepository(db *gorm.DB) CategoryRepository {
+	return &categoryRepository{db: db}
+}
+
+// Create inserts the category and fills in its materialized path, which
+// depends on the generated ID.
+func (r *categoryRepository) Create(ctx context.Context, c *product.Category) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		parentPath := ""
+		if c.ParentID != nil {
+			var parent product.Category
+			if err := tx.First(&parent, *c.ParentID).Error; err != nil {
+				return err
+			}
+			parentPath = parent.Path
+		}
+
+		// Path is NOT NULL, so insert a placeholder until the ID is known.
+		c.Path = parentPath
+		if err := tx.Create(c).Error; err != nil {
+			return err
+		}
+
+		if parentPath == "" {
+			c.Path = product.RootPath(c.ID)
+		} else {
+			c.Path = product.Category{Path: parentPath}.ChildPath(c.ID)
+		}
+
+		return tx.Model(c).Update("path", c.Path).Error
+	})
+}
+
+func (r *categoryRepository) FindAll(ctx context.Context) ([]product.Category, error) {
+	var categories []product.Category
+	if err := r.db.WithContext(ctx).Order("path").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+func (r *categoryRepository) FindByID(ctx context.Context, id int64) (*product.Category, error) {
+	var c product.Category
+	err := r.db.WithContext(ctx).First(&c, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *categoryRepository) FindByIDs(ctx context.Context, ids []int64) ([]product.Category, error) {
+	var categories []product.Category
+	if len(ids) == 0 {
+		return categories, nil
+	}
+	if err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+func (r *categoryRepository) FindByProductID(ctx context.Context, productID int64) ([]product.Category, error) {
+	var categories []product.Category
+	err := r.db.WithContext(ctx).
+		Joins("JOIN product_categories pc ON pc.category_id = categories.id").
+		Where("pc.product_id = ?", productID).
+		Order("categories.path").
+		Find(&categories).Error
+	if err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+// FindDescendantIDs returns the ID of the category and of every category
+// below it.
+func (r *categoryRepository) FindDescendantIDs(ctx context.Context, c product.Category) ([]int64, error) {
+	var ids []int64
+	err := r.db.WithContext(ctx).
+		Model(&product.Category{}).
+		Where("path LIKE ?", c.Path+"%").
+		Pluck("id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r *categoryRepository) HasChildren(ctx context.Context, id int64) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&product.Category{}).
+		Where("parent_id = ?", id).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *categoryRepository) Update(ctx context.Context, c *product.Category) error {
+	return r.db.WithContext(ctx).
+		Model(c).
+		Updates(map[string]interface{}{"name": c.Name, "slug": c.Slug}).Error
+}
+
+// Move re-parents the category and rewrites the path prefix of the whole
+// subtree in one transaction.
+func (r *categoryRepository) Move(ctx context.Context, c *product.Category, newParentID *int64, newPath string) error {
+	oldPath := c.Path
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(c).Update("parent_id", newParentID).Error; err != nil {
+			return err
+		}
+
+		err := tx.Model(&product.Category{}).
+			Where("path LIKE ?", oldPath+"%").
+			Update("path", gorm.Expr("? || substring(path from ?)", newPath, len(oldPath)+1)).Error
+		if err != nil {
+			return err
+		}
+
+		c.ParentID = newParentID
+		c.Path = newPath
+		return nil
+	})
+}
+
+func (r *categoryRepository) Delete(ctx context.Context, id int64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("category_id = ?", id).Delete(&product.ProductCategory{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("category_id = ?", id).Delete(&product.AttributeDefinition{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&product.Category{}, id).Error
+	})
+}
+
+// SetProductCategories replaces the category assignment of a product.
+func (r *categoryRepository) SetProductCategories(ctx context.Context, productID int64, categoryIDs []int64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("product_id = ?", productID).Delete(&product.ProductCategory{}).Error; err != nil {
+			return err
+		}
+
+		if len(categoryIDs) == 0 {
+			return nil
+		}
+
+		links := make([]product.ProductCategory, 0, len(categoryIDs))
+		for _, id := range categoryIDs {
+			links = append(links, product.ProductCategory{ProductID: productID, CategoryID: id})
+		}
+		return tx.Create(&links).Error
+	})
+}
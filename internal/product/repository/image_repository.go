@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rkweber-max/checkout-backend/internal/product"
+	"gorm.io/gorm"
+)
+
+type ImageRepository interface {
+	Create(ctx context.Context, img *product.Image) error
+	FindByProductID(ctx context.Context, productID int64) ([]product.Image, error)
+	FindByID(ctx context.Context, id int64) (*product.Image, error)
+	UpdatePositions(ctx context.Context, productID int64, orderedIDs []int64) error
+	SetPrimary(ctx context.Context, productID, imageID int64) error
+	Delete(ctx context.Context, id int64) error
+}
+
+type imageRepository struct {
+	db *gorm.DB
+}
+
+func NewImageRepository(db *gorm.DB) ImageRepository {
+	return &imageRepository{db: db}
+}
+
+func (r *imageRepository) Create(ctx context.Context, img *product.Image) error {
+	return r.db.WithContext(ctx).Create(img).Error
+}
+
+func (r *imageRepository) FindByProductID(ctx context.Context, productID int64) ([]product.Image, error) {
+	var images []product.Image
+	err := r.db.WithContext(ctx).
+		Where("product_id = ?", productID).
+		Order("position, id").
+		Find(&images).Error
+	if err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+func (r *imageRepository) FindByID(ctx context.Context, id int64) (*product.Image, error) {
+	var img product.Image
+	err := r.db.WithContext(ctx).First(&img, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &img, nil
+}
+
+func (r *imageRepository) UpdatePositions(ctx context.Context, productID int64, orderedIDs []int64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for position, id := range orderedIDs {
+			err := tx.Model(&product.Image{}).
+				Where("id = ? AND product_id = ?", id, productID).
+				Update("position", position).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SetPrimary flags the image as primary and clears the flag on every other
+// image of the product.
+func (r *imageRepository) SetPrimary(ctx context.Context, productID, imageID int64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&product.Image{}).
+			Where("product_id = ? AND id <> ?", productID, imageID).
+			Update("is_primary", false).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&product.Image{}).
+			Where("product_id = ? AND id = ?", productID, imageID).
+			Update("is_primary", true).Error
+	})
+}
+
+func (r *imageRepository) Delete(ctx context.Context, id int64) error {
+	return r.db.WithContext(ctx).Delete(&product.Image{}, id).Error
+}
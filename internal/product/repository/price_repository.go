@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/product"
+	"gorm.io/gorm"
+)
+
+type PriceRepository interface {
+	FindHistory(ctx context.Context, productID int64) ([]product.PriceChange, error)
+	CreateSchedule(ctx context.Context, s *product.ScheduledPrice) error
+	FindSchedules(ctx context.Context, productID int64) ([]product.ScheduledPrice, error)
+	FindScheduleByID(ctx context.Context, id int64) (*product.ScheduledPrice, error)
+	FindDueSchedules(ctx context.Context, now time.Time) ([]product.ScheduledPrice, error)
+	FindCoveringSchedule(ctx context.Context, productID int64, at time.Time) (*product.ScheduledPrice, error)
+	UpdateSchedule(ctx context.Context, s *product.ScheduledPrice) error
+}
+
+type priceRepository struct {
+	db *gorm.DB
+}
+
+func NewPriceRepository(db *gorm.DB) PriceRepository {
+	return &priceRepository{db: db}
+}
+
+func (r *priceRepository) FindHistory(ctx context.Context, productID int64) ([]product.PriceChange, error) {
+	var changes []product.PriceChange
+	err := r.db.WithContext(ctx).
+		Where("product_id = ?", productID).
+		Order("changed_at DESC, id DESC").
+		Find(&changes).Error
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func (r *priceRepository) CreateSchedule(ctx context.Context, s *product.ScheduledPrice) error {
+	return r.db.WithContext(ctx).Create(s).Error
+}
+
+func (r *priceRepository) FindSchedules(ctx context.Context, productID int64) ([]product.ScheduledPrice, error) {
+	var schedules []product.ScheduledPrice
+	err := r.db.WithContext(ctx).
+		Where("product_id = ?", productID).
+		Order("starts_at DESC").
+		Find(&schedules).Error
+	if err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func (r *priceRepository) FindScheduleByID(ctx context.Context, id int64) (*product.ScheduledPrice, error) {
+	var s product.ScheduledPrice
+	err := r.db.WithContext(ctx).First(&s, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// FindDueSchedules returns pending schedules that should have started and
+// active sales that should have ended, oldest first.
+func (r *priceRepository) FindDueSchedules(ctx context.Context, now time.Time) ([]product.ScheduledPrice, error) {
+	var schedules []product.ScheduledPrice
+	err := r.db.WithContext(ctx).
+		Where("(status = ? AND starts_at <= ?) OR (status = ? AND ends_at <= ?)",
+			product.SchedulePending, now, product.ScheduleActive, now).
+		Order("starts_at, id").
+		Find(&schedules).Error
+	if err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// FindCoveringSchedule returns the most recently started schedule whose
+// window includes the given time, or nil.
+func (r *priceRepository) FindCoveringSchedule(ctx context.Context, productID int64, at time.Time) (*product.ScheduledPrice, error) {
+	var s product.ScheduledPrice
+	err := r.db.WithContext(ctx).
+		Where("product_id = ? AND status IN ? AND starts_at <= ? AND (ends_at IS NULL OR ends_at > ?)",
+			productID, []product.ScheduleStatus{product.SchedulePending, product.ScheduleActive}, at, at).
+		Order("starts_at DESC, id DESC").
+		First(&s).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *priceRepository) UpdateSchedule(ctx context.Context, s *product.ScheduledPrice) error {
+	return r.db.WithContext(ctx).
+		Model(s).
+		Select("previous_price", "status").
+		Updates(s).Error
+}
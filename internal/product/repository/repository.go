@@ -2,19 +2,42 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
 
+	"github.com/rkweber-max/checkout-backend/internal/auth"
 	"github.com/rkweber-max/checkout-backend/internal/product"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ProductRepository interface {
 	Create(ctx context.Context, p product.Product) (int64, error)
 	FindAll(ctx context.Context) ([]product.Product, error)
 	FindByID(ctx context.Context, id int64) (*product.Product, error)
-	Update(ctx context.Context, p product.Product) error
+	FindBySKU(ctx context.Context, sku string) (*product.Product, error)
+	FindByGTIN(ctx context.Context, gtin string) (*product.Product, error)
+	FindByOwner(ctx context.Context, ownerID uint) ([]product.Product, error)
+	FindByCategoryIDs(ctx context.Context, categoryIDs []int64) ([]product.Product, error)
+	FindByAttributes(ctx context.Context, attrs product.Attributes) ([]product.Product, error)
+	FindInBatches(ctx context.Context, batchSize int, fn func([]product.Product) error) error
+	Update(ctx context.Context, p *product.Product) error
+	UpdatePrice(ctx context.Context, id int64, price float64) error
+	UpdateScheduledPrice(ctx context.Context, s *product.ScheduledPrice, price float64) error
+	SetAttributes(ctx context.Context, id int64, attrs product.Attributes) error
 	Delete(ctx context.Context, id int64) error
+	FindDeleted(ctx context.Context) ([]product.Product, error)
+	FindDeletedBySKU(ctx context.Context, sku string) (*product.Product, error)
+	FindDeletedByGTIN(ctx context.Context, gtin string) (*product.Product, error)
+	Restore(ctx context.Context, id int64) (bool, error)
+	FindPurgeable(ctx context.Context, deletedBefore time.Time) ([]product.Product, error)
+	Purge(ctx context.Context, id int64) error
 }
 
+// ErrVersionConflict is returned when a product was modified since the
+// version the caller based its update on.
+var ErrVersionConflict = errors.New("product was modified by someone else")
+
 type productRepository struct {
 	db *gorm.DB
 }
@@ -24,6 +47,7 @@ func NewProductRepository(db *gorm.DB) ProductRepository {
 }
 
 func (r *productRepository) Create(ctx context.Context, p product.Product) (int64, error) {
+	p.Version = 1
 	if err := r.db.WithContext(ctx).Create(&p).Error; err != nil {
 		return 0, err
 	}
@@ -40,16 +64,278 @@ func (r *productRepository) FindAll(ctx context.Context) ([]product.Product, err
 
 func (r *productRepository) FindByID(ctx context.Context, id int64) (*product.Product, error) {
 	var p product.Product
-	if err := r.db.WithContext(ctx).First(&p, id).Error; err != nil {
+	err := r.db.WithContext(ctx).First(&p, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *productRepository) FindBySKU(ctx context.Context, sku string) (*product.Product, error) {
+	var p product.Product
+	err := r.db.WithContext(ctx).Where("sku = ?", sku).First(&p).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *productRepository) FindByGTIN(ctx context.Context, gtin string) (*product.Product, error) {
+	var p product.Product
+	err := r.db.WithContext(ctx).Where("gtin = ?", gtin).First(&p).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
 		return nil, err
 	}
 	return &p, nil
 }
 
-func (r *productRepository) Update(ctx context.Context, p product.Product) error {
-	return r.db.WithContext(ctx).Save(&p).Error
+func (r *productRepository) FindByOwner(ctx context.Context, ownerID uint) ([]product.Product, error) {
+	var products []product.Product
+	if err := r.db.WithContext(ctx).Where("owner_id = ?", ownerID).Find(&products).Error; err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+func (r *productRepository) FindByCategoryIDs(ctx context.Context, categoryIDs []int64) ([]product.Product, error) {
+	var products []product.Product
+	db := r.db.WithContext(ctx)
+	err := db.
+		Where("id IN (?)", db.Model(&product.ProductCategory{}).Select("product_id").Where("category_id IN ?", categoryIDs)).
+		Find(&products).Error
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// FindByAttributes returns the products whose attributes contain every
+// given key and value.
+func (r *productRepository) FindByAttributes(ctx context.Context, attrs product.Attributes) ([]product.Product, error) {
+	var products []product.Product
+	if err := r.db.WithContext(ctx).Where("attributes @> ?::jsonb", attrs).Find(&products).Error; err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// FindInBatches walks every product ordered by ID without loading the whole
+// table at once.
+func (r *productRepository) FindInBatches(ctx context.Context, batchSize int, fn func([]product.Product) error) error {
+	var batch []product.Product
+	return r.db.WithContext(ctx).
+		Order("id").
+		FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+			return fn(batch)
+		}).Error
+}
+
+// Update saves the product if it is still at p.Version, bumping the version
+// on success. When the price changed, the change is recorded in the price
+// history within the same transaction.
+func (r *productRepository) Update(ctx context.Context, p *product.Product) error {
+	return r.withPriceHistory(ctx, p.ID, p.Price, func(tx *gorm.DB, current product.Product) error {
+		if current.Version != p.Version {
+			return ErrVersionConflict
+		}
+
+		p.Version++
+		return tx.Model(p).
+			Select("sku", "gtin", "name", "description", "price", "version").
+			Updates(p).Error
+	})
+}
+
+func (r *productRepository) UpdatePrice(ctx context.Context, id int64, price float64) error {
+	return r.withPriceHistory(ctx, id, price, func(tx *gorm.DB, _ product.Product) error {
+		return tx.Model(&product.Product{}).
+			Where("id = ?", id).
+			Updates(map[string]interface{}{"price": price, "version": gorm.Expr("version + 1")}).Error
+	})
+}
+
+// UpdateScheduledPrice sets the price of the schedule's product and saves
+// the schedule's status and previous price in the same transaction, so a
+// failed write can't leave a price applied by a schedule still pending.
+func (r *productRepository) UpdateScheduledPrice(ctx context.Context, s *product.ScheduledPrice, price float64) error {
+	return r.withPriceHistory(ctx, s.ProductID, price, func(tx *gorm.DB, _ product.Product) error {
+		err := tx.Model(&product.Product{}).
+			Where("id = ?", s.ProductID).
+			Updates(map[string]interface{}{"price": price, "version": gorm.Expr("version + 1")}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(s).Select("previous_price", "status").Updates(s).Error
+	})
+}
+
+// SetAttributes replaces the attribute values of the product.
+func (r *productRepository) SetAttributes(ctx context.Context, id int64, attrs product.Attributes) error {
+	return r.db.WithContext(ctx).
+		Model(&product.Product{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"attributes": attrs, "version": gorm.Expr("version + 1")}).Error
+}
+
+// withPriceHistory locks the product row, runs write and records a price
+// change if newPrice differs from the stored price.
+func (r *productRepository) withPriceHistory(
+	ctx context.Context,
+	id int64,
+	newPrice float64,
+	write func(tx *gorm.DB, current product.Product) error,
+) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var current product.Product
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "price", "version").
+			First(&current, id).Error
+		if err != nil {
+			return err
+		}
+
+		if err := write(tx, current); err != nil {
+			return err
+		}
+
+		if current.Price == newPrice {
+			return nil
+		}
+
+		return tx.Create(&product.PriceChange{
+			ProductID: id,
+			OldPrice:  current.Price,
+			NewPrice:  newPrice,
+			Source:    product.PriceSourceFromContext(ctx),
+			ChangedBy: auth.ActorFromContext(ctx),
+			ChangedAt: time.Now(),
+		}).Error
+	})
 }
 
 func (r *productRepository) Delete(ctx context.Context, id int64) error {
 	return r.db.WithContext(ctx).Delete(&product.Product{}, id).Error
 }
+
+// FindDeleted lists the products in the trash, most recently deleted first.
+func (r *productRepository) FindDeleted(ctx context.Context) ([]product.Product, error) {
+	var products []product.Product
+	err := r.db.WithContext(ctx).
+		Unscoped().
+		Where("deleted_at IS NOT NULL").
+		Order("deleted_at DESC").
+		Find(&products).Error
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// FindDeletedBySKU returns the product in the trash with the given SKU, or
+// nil. Trashed products keep their SKU, so it cannot be reused.
+func (r *productRepository) FindDeletedBySKU(ctx context.Context, sku string) (*product.Product, error) {
+	var p product.Product
+	err := r.db.WithContext(ctx).
+		Unscoped().
+		Where("sku = ? AND deleted_at IS NOT NULL", sku).
+		First(&p).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// FindDeletedByGTIN returns the product in the trash with the given GTIN,
+// or nil. Like SKUs, the GTINs of trashed products stay taken.
+func (r *productRepository) FindDeletedByGTIN(ctx context.Context, gtin string) (*product.Product, error) {
+	var p product.Product
+	err := r.db.WithContext(ctx).
+		Unscoped().
+		Where("gtin = ? AND deleted_at IS NOT NULL", gtin).
+		First(&p).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Restore takes a product out of the trash. It reports false if no trashed
+// product has that ID.
+func (r *productRepository) Restore(ctx context.Context, id int64) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Unscoped().
+		Model(&product.Product{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Update("deleted_at", nil)
+	return result.RowsAffected > 0, result.Error
+}
+
+// FindPurgeable returns trashed products deleted before the given time that
+// no order line, bundle, stock transfer or purchase order references.
+func (r *productRepository) FindPurgeable(ctx context.Context, deletedBefore time.Time) ([]product.Product, error) {
+	var products []product.Product
+	err := r.db.WithContext(ctx).
+		Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", deletedBefore).
+		Where("NOT EXISTS (SELECT 1 FROM order_lines ol WHERE ol.product_id = products.id)").
+		Where("NOT EXISTS (SELECT 1 FROM order_line_components olc WHERE olc.product_id = products.id)").
+		Where("NOT EXISTS (SELECT 1 FROM bundle_components bc WHERE bc.product_id = products.id)").
+		Where("NOT EXISTS (SELECT 1 FROM transfer_lines tl JOIN variants v ON v.id = tl.variant_id WHERE v.product_id = products.id)").
+		Where("NOT EXISTS (SELECT 1 FROM purchase_order_lines pol JOIN variants v ON v.id = pol.variant_id WHERE v.product_id = products.id)").
+		Find(&products).Error
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// Purge permanently removes a product together with the rows that only
+// make sense with it. Image files must be removed by the caller beforehand.
+func (r *productRepository) Purge(ctx context.Context, id int64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		optionTypeIDs := tx.Model(&product.OptionType{}).Select("id").Where("product_id = ?", id)
+		variantIDs := tx.Model(&product.Variant{}).Select("id").Where("product_id = ?", id)
+
+		steps := []func() error{
+			func() error { return tx.Exec("DELETE FROM variant_option_values WHERE variant_id IN (?)", variantIDs).Error },
+			func() error { return tx.Exec("DELETE FROM stock_levels WHERE variant_id IN (?)", variantIDs).Error },
+			func() error { return tx.Exec("DELETE FROM stock_movements WHERE variant_id IN (?)", variantIDs).Error },
+			func() error { return tx.Where("product_id = ?", id).Delete(&product.Variant{}).Error },
+			func() error { return tx.Where("option_type_id IN (?)", optionTypeIDs).Delete(&product.OptionValue{}).Error },
+			func() error { return tx.Where("product_id = ?", id).Delete(&product.OptionType{}).Error },
+			func() error { return tx.Where("product_id = ?", id).Delete(&product.ProductCategory{}).Error },
+			func() error { return tx.Where("product_id = ?", id).Delete(&product.Image{}).Error },
+			func() error { return tx.Where("product_id = ?", id).Delete(&product.PriceChange{}).Error },
+			func() error { return tx.Where("product_id = ?", id).Delete(&product.ScheduledPrice{}).Error },
+			func() error { return tx.Where("bundle_id = ?", id).Delete(&product.BundleComponent{}).Error },
+			func() error { return tx.Where("product_id = ?", id).Delete(&product.Bundle{}).Error },
+			func() error { return tx.Exec("DELETE FROM reorder_rules WHERE product_id = ?", id).Error },
+			func() error { return tx.Exec("DELETE FROM product_costs WHERE product_id = ?", id).Error },
+			func() error { return tx.Where("product_id = ?", id).Delete(&product.Review{}).Error },
+			func() error { return tx.Where("product_id = ?", id).Delete(&product.WishlistItem{}).Error },
+			func() error { return tx.Where("product_id = ?", id).Delete(&product.StockSubscription{}).Error },
+			func() error { return tx.Unscoped().Delete(&product.Product{}, id).Error },
+		}
+		for _, step := range steps {
+			if err := step(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
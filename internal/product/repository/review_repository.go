@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rkweber-max/checkout-backend/internal/product"
+	"gorm.io/gorm"
+)
+
+type ReviewRepository interface {
+	Create(ctx context.Context, r *product.Review) error
+	FindByID(ctx context.Context, id int64) (*product.Review, error)
+	FindByProductAndUser(ctx context.Context, productID int64, userID uint) (*product.Review, error)
+	FindByProduct(ctx context.Context, productID int64, status product.ReviewStatus) ([]product.Review, error)
+	FindByStatus(ctx context.Context, status product.ReviewStatus) ([]product.Review, error)
+	Update(ctx context.Context, r *product.Review) error
+	Delete(ctx context.Context, id int64) error
+	RatingCounts(ctx context.Context, productID int64) (map[int]int, error)
+	HasPurchased(ctx context.Context, userID uint, productID int64) (bool, error)
+}
+
+type reviewRepository struct {
+	db *gorm.DB
+}
+
+func NewReviewRepository(db *gorm.DB) ReviewRepository {
+	return &reviewRepository{db: db}
+}
+
+func (r *reviewRepository) Create(ctx context.Context, review *product.Review) error {
+	return r.db.WithContext(ctx).Create(review).Error
+}
+
+func (r *reviewRepository) FindByID(ctx context.Context, id int64) (*product.Review, error) {
+	var review product.Review
+	err := r.db.WithContext(ctx).First(&review, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+func (r *reviewRepository) FindByProductAndUser(ctx context.Context, productID int64, userID uint) (*product.Review, error) {
+	var review product.Review
+	err := r.db.WithContext(ctx).Where("product_id = ? AND user_id = ?", productID, userID).First(&review).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+func (r *reviewRepository) FindByProduct(ctx context.Context, productID int64, status product.ReviewStatus) ([]product.Review, error) {
+	var reviews []product.Review
+	err := r.db.WithContext(ctx).
+		Where("product_id = ? AND status = ?", productID, status).
+		Order("created_at DESC").
+		Find(&reviews).Error
+	if err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
+
+// FindByStatus lists reviews in the given status, oldest first so the
+// moderation queue is worked in order of arrival.
+func (r *reviewRepository) FindByStatus(ctx context.Context, status product.ReviewStatus) ([]product.Review, error) {
+	var reviews []product.Review
+	if err := r.db.WithContext(ctx).Where("status = ?", status).Order("created_at").Find(&reviews).Error; err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
+
+func (r *reviewRepository) Update(ctx context.Context, review *product.Review) error {
+	return r.db.WithContext(ctx).
+		Model(review).
+		Select("rating", "title", "body", "verified", "status", "rejection_reason", "moderated_by", "moderated_at").
+		Updates(review).Error
+}
+
+func (r *reviewRepository) Delete(ctx context.Context, id int64) error {
+	return r.db.WithContext(ctx).Delete(&product.Review{}, id).Error
+}
+
+// RatingCounts returns the number of approved reviews of the product per
+// star rating.
+func (r *reviewRepository) RatingCounts(ctx context.Context, productID int64) (map[int]int, error) {
+	var rows []struct {
+		Rating int
+		Count  int
+	}
+	err := r.db.WithContext(ctx).
+		Model(&product.Review{}).
+		Select("rating, COUNT(*) AS count").
+		Where("product_id = ? AND status = ?", productID, product.ReviewApproved).
+		Group("rating").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[int]int, len(rows))
+	for _, row := range rows {
+		counts[row.Rating] = row.Count
+	}
+	return counts, nil
+}
+
+// HasPurchased reports whether the customer ordered the product, on its
+// own or as part of a bundle.
+func (r *reviewRepository) HasPurchased(ctx context.Context, userID uint, productID int64) (bool, error) {
+	var purchased bool
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT EXISTS (
+			SELECT 1 FROM orders o
+			JOIN order_lines ol ON ol.order_id = o.id
+			LEFT JOIN order_line_components olc ON olc.order_line_id = ol.id
+			WHERE o.customer_id = ? AND (ol.product_id = ? OR olc.product_id = ?)
+		)`, userID, productID, productID).Scan(&purchased).Error
+	if err != nil {
+		return false, err
+	}
+	return purchased, nil
+}
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rkweber-max/checkout-backend/internal/product"
+	"gorm.io/gorm"
+)
+
+type VariantRepository interface {
+	CreateOptionType(ctx context.Context, o *product.OptionType) error
+	FindOptionTypes(ctx context.Context, productID int64) ([]product.OptionType, error)
+	DeleteOptionType(ctx context.Context, productID, optionTypeID int64) error
+	Create(ctx context.Context, variants []product.Variant) error
+	FindByProductID(ctx context.Context, productID int64) ([]product.Variant, error)
+	FindByID(ctx context.Context, id int64) (*product.Variant, error)
+	FindByBarcode(ctx context.Context, barcode string) (*product.Variant, error)
+	CountByProductID(ctx context.Context, productID int64) (int64, error)
+	Update(ctx context.Context, v *product.Variant) error
+	Delete(ctx context.Context, productID, variantID int64) error
+}
+
+type variantRepository struct {
+	db *gorm.DB
+}
+
+func NewVariantRepository(db *gorm.DB) VariantRepository {
+	return &variantRepository{db: db}
+}
+
+func (r *variantRepository) CreateOptionType(ctx context.Context, o *product.OptionType) error {
+	return r.db.WithContext(ctx).Create(o).Error
+}
+
+func (r *variantRepository) FindOptionTypes(ctx context.Context, productID int64) ([]product.OptionType, error) {
+	var options []product.OptionType
+	err := r.db.WithContext(ctx).
+		Preload("Values", func(db *gorm.DB) *gorm.DB { return db.Order("position, id") }).
+		Where("product_id = ?", productID).
+		Order("position, id").
+		Find(&options).Error
+	if err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+// DeleteOptionType removes the option type, its values and every variant
+// built from them, with their stock levels, since those combinations no
+// longer exist.
+func (r *variantRepository) DeleteOptionType(ctx context.Context, productID, optionTypeID int64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var valueIDs []int64
+		err := tx.Model(&product.OptionValue{}).
+			Where("option_type_id = ?", optionTypeID).
+			Pluck("id", &valueIDs).Error
+		if err != nil {
+			return err
+		}
+
+		var variantIDs []int64
+		if len(valueIDs) > 0 {
+			err = tx.Table("variant_option_values").
+				Where("option_value_id IN ?", valueIDs).
+				Distinct().
+				Pluck("variant_id", &variantIDs).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(variantIDs) > 0 {
+			if err := tx.Exec("DELETE FROM variant_option_values WHERE variant_id IN ?", variantIDs).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec("DELETE FROM stock_levels WHERE variant_id IN ?", variantIDs).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("id IN ?", variantIDs).Delete(&product.Variant{}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("option_type_id = ?", optionTypeID).Delete(&product.OptionValue{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("product_id = ?", productID).Delete(&product.OptionType{}, optionTypeID).Error
+	})
+}
+
+func (r *variantRepository) Create(ctx context.Context, variants []product.Variant) error {
+	if len(variants) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).
+		Omit("OptionValues.*").
+		Create(&variants).Error
+}
+
+func (r *variantRepository) FindByProductID(ctx context.Context, productID int64) ([]product.Variant, error) {
+	var variants []product.Variant
+	err := r.db.WithContext(ctx).
+		Preload("OptionValues").
+		Where("product_id = ?", productID).
+		Order("id").
+		Find(&variants).Error
+	if err != nil {
+		return nil, err
+	}
+	return variants, nil
+}
+
+func (r *variantRepository) FindByID(ctx context.Context, id int64) (*product.Variant, error) {
+	var v product.Variant
+	err := r.db.WithContext(ctx).Preload("OptionValues").First(&v, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (r *variantRepository) FindByBarcode(ctx context.Context, barcode string) (*product.Variant, error) {
+	var v product.Variant
+	err := r.db.WithContext(ctx).Where("barcode = ?", barcode).First(&v).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (r *variantRepository) CountByProductID(ctx context.Context, productID int64) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&product.Variant{}).
+		Where("product_id = ?", productID).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *variantRepository) Update(ctx context.Context, v *product.Variant) error {
+	return r.db.WithContext(ctx).
+		Model(v).
+		Select("sku", "price", "barcode").
+		Updates(v).Error
+}
+
+func (r *variantRepository) Delete(ctx context.Context, productID, variantID int64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM variant_option_values WHERE variant_id = ?", variantID).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM stock_levels WHERE variant_id = ?", variantID).Error; err != nil {
+			return err
+		}
+		return tx.Where("product_id = ?", productID).Delete(&product.Variant{}, variantID).Error
+	})
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rkweber-max/checkout-backend/internal/product"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WishlistRepository interface {
+	AddItem(ctx context.Context, item *product.WishlistItem) error
+	RemoveItem(ctx context.Context, userID uint, productID int64) (bool, error)
+	FindItems(ctx context.Context, userID uint) ([]product.WishlistItem, error)
+	CreateSubscription(ctx context.Context, s *product.StockSubscription) error
+	FindSubscription(ctx context.Context, userID uint, productID int64, variantID *int64) (*product.StockSubscription, error)
+	FindSubscriptionsByUser(ctx context.Context, userID uint) ([]product.StockSubscription, error)
+	FindAllSubscriptions(ctx context.Context) ([]product.StockSubscription, error)
+	DeleteSubscription(ctx context.Context, id int64) error
+}
+
+type wishlistRepository struct {
+	db *gorm.DB
+}
+
+func NewWishlistRepository(db *gorm.DB) WishlistRepository {
+	return &wishlistRepository{db: db}
+}
+
+// AddItem saves the product to the wishlist. Adding a product that is
+// already there is a no-op.
+func (r *wishlistRepository) AddItem(ctx context.Context, item *product.WishlistItem) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(item).Error
+}
+
+func (r *wishlistRepository) RemoveItem(ctx context.Context, userID uint, productID int64) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Where("user_id = ? AND product_id = ?", userID, productID).
+		Delete(&product.WishlistItem{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// FindItems returns the wishlist, most recently added first. Products in
+// the trash are left out of Product.
+func (r *wishlistRepository) FindItems(ctx context.Context, userID uint) ([]product.WishlistItem, error) {
+	var items []product.WishlistItem
+	err := r.db.WithContext(ctx).
+		Preload("Product").
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *wishlistRepository) CreateSubscription(ctx context.Context, s *product.StockSubscription) error {
+	return r.db.WithContext(ctx).Create(s).Error
+}
+
+func (r *wishlistRepository) FindSubscription(ctx context.Context, userID uint, productID int64, variantID *int64) (*product.StockSubscription, error) {
+	query := r.db.WithContext(ctx).Where("user_id = ? AND product_id = ?", userID, productID)
+	if variantID == nil {
+		query = query.Where("variant_id IS NULL")
+	} else {
+		query = query.Where("variant_id = ?", *variantID)
+	}
+
+	var s product.StockSubscription
+	err := query.First(&s).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *wishlistRepository) FindSubscriptionsByUser(ctx context.Context, userID uint) ([]product.StockSubscription, error) {
+	var subscriptions []product.StockSubscription
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&subscriptions).Error; err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+func (r *wishlistRepository) FindAllSubscriptions(ctx context.Context) ([]product.StockSubscription, error) {
+	var subscriptions []product.StockSubscription
+	if err := r.db.WithContext(ctx).Order("product_id, created_at").Find(&subscriptions).Error; err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+func (r *wishlistRepository) DeleteSubscription(ctx context.Context, id int64) error {
+	return r.db.WithContext(ctx).Delete(&product.StockSubscription{}, id).Error
+}
@@ -0,0 +1,56 @@
+package product
+
+import "time"
+
+type ReviewStatus string
+
+const (
+	ReviewPending  ReviewStatus = "pending"
+	ReviewApproved ReviewStatus = "approved"
+	ReviewRejected ReviewStatus = "rejected"
+)
+
+// Review is a customer's rating of a product. Reviews wait in the
+// moderation queue until an employee approves them; only approved reviews
+// are shown and counted in the rating. Verified is set when the customer
+// has ordered the product.
+type Review struct {
+	ID              int64        `json:"id" gorm:"primaryKey"`
+	ProductID       int64        `json:"product_id" gorm:"not null;uniqueIndex:idx_reviews_product_user"`
+	UserID          uint         `json:"user_id" gorm:"not null;uniqueIndex:idx_reviews_product_user"`
+	Rating          int          `json:"rating" gorm:"not null"`
+	Title           string       `json:"title,omitempty"`
+	Body            string       `json:"body,omitempty"`
+	Verified        bool         `json:"verified" gorm:"not null;default:false"`
+	Status          ReviewStatus `json:"status" gorm:"type:varchar(20);not null;index"`
+	RejectionReason string       `json:"rejection_reason,omitempty"`
+	ModeratedBy     *uint        `json:"moderated_by,omitempty"`
+	ModeratedAt     *time.Time   `json:"moderated_at,omitempty"`
+	CreatedAt       time.Time    `json:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at"`
+}
+
+// RatingSummary aggregates the approved reviews of a product. Histogram
+// counts the reviews for each number of stars, from 1 to 5.
+type RatingSummary struct {
+	Average   float64     `json:"average"`
+	Count     int         `json:"count"`
+	Histogram map[int]int `json:"histogram"`
+}
+
+// NewRatingSummary builds the summary from the number of reviews per star
+// rating.
+func NewRatingSummary(counts map[int]int) RatingSummary {
+	summary := RatingSummary{Histogram: make(map[int]int, 5)}
+	sum := 0
+	for stars := 1; stars <= 5; stars++ {
+		n := counts[stars]
+		summary.Histogram[stars] = n
+		summary.Count += n
+		sum += stars * n
+	}
+	if summary.Count > 0 {
+		summary.Average = float64(sum) / float64(summary.Count)
+	}
+	return summary
+}
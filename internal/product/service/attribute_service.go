@@ -0,0 +1,257 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rkweber-max/checkout-backend/internal/product"
+	"github.com/rkweber-max/checkout-backend/internal/product/repository"
+)
+
+var (
+	ErrAttributeNotFound  = errors.New("attribute definition not found")
+	ErrDuplicateAttribute = errors.New("category already defines this attribute")
+)
+
+type AttributeService interface {
+	Define(ctx context.Context, d *product.AttributeDefinition) error
+	List(ctx context.Context, categoryID int64) ([]product.AttributeDefinition, error)
+	Update(ctx context.Context, d *product.AttributeDefinition) (*product.AttributeDefinition, error)
+	Delete(ctx context.Context, categoryID, id int64) error
+	SetProductAttributes(ctx context.Context, productID int64, attrs product.Attributes) (*product.Product, error)
+	ListProducts(ctx context.Context, filters map[string]string) ([]product.Product, error)
+}
+
+type attributeService struct {
+	repo        repository.AttributeRepository
+	categories  repository.CategoryRepository
+	productRepo repository.ProductRepository
+}
+
+func NewAttributeService(
+	repo repository.AttributeRepository,
+	categories repository.CategoryRepository,
+	productRepo repository.ProductRepository,
+) AttributeService {
+	return &attributeService{repo: repo, categories: categories, productRepo: productRepo}
+}
+
+// Define adds an attribute to the spec sheet of a category. The attribute
+// also applies to every subcategory.
+func (s *attributeService) Define(ctx context.Context, d *product.AttributeDefinition) error {
+	c, err := s.categories.FindByID(ctx, d.CategoryID)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return ErrCategoryNotFound
+	}
+
+	d.Key = strings.TrimSpace(d.Key)
+	if !product.ValidAttributeKey(d.Key) {
+		return errors.New("attribute key must start with a letter and contain only lowercase letters, digits and underscores")
+	}
+	if err := validateDefinition(d); err != nil {
+		return err
+	}
+
+	existing, err := s.repo.FindByKey(ctx, d.Key)
+	if err != nil {
+		return err
+	}
+	for _, e := range existing {
+		if e.CategoryID == d.CategoryID {
+			return ErrDuplicateAttribute
+		}
+		if e.Type != d.Type {
+			return fmt.Errorf("attribute %q is already defined as %s", d.Key, e.Type)
+		}
+	}
+
+	return s.repo.Create(ctx, d)
+}
+
+// List returns the attributes that apply to products in the category: its
+// own and those inherited from its ancestors.
+func (s *attributeService) List(ctx context.Context, categoryID int64) ([]product.AttributeDefinition, error) {
+	c, err := s.categories.FindByID(ctx, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, ErrCategoryNotFound
+	}
+
+	return s.repo.FindByCategoryIDs(ctx, c.AncestorIDs())
+}
+
+// Update changes the label and constraints of an attribute. Its key and
+// type are fixed once defined since product values depend on them.
+func (s *attributeService) Update(ctx context.Context, d *product.AttributeDefinition) (*product.AttributeDefinition, error) {
+	existing, err := s.repo.FindByID(ctx, d.ID)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil || existing.CategoryID != d.CategoryID {
+		return nil, ErrAttributeNotFound
+	}
+
+	existing.Label = d.Label
+	existing.Required = d.Required
+	existing.Options = d.Options
+	existing.Min = d.Min
+	existing.Max = d.Max
+	existing.Unit = d.Unit
+	if err := validateDefinition(existing); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Update(ctx, existing); err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+func (s *attributeService) Delete(ctx context.Context, categoryID, id int64) error {
+	existing, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if existing == nil || existing.CategoryID != categoryID {
+		return ErrAttributeNotFound
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+// SetProductAttributes replaces the attribute values of a product. Every
+// key must be defined for one of the product's categories or their
+// ancestors, and every required attribute must be present.
+func (s *attributeService) SetProductAttributes(ctx context.Context, productID int64, attrs product.Attributes) (*product.Product, error) {
+	p, err := s.productRepo.FindByID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrProductNotFound
+	}
+
+	definitions, err := s.productDefinitions(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	clean := make(product.Attributes, len(attrs))
+	for key, value := range attrs {
+		if value == nil {
+			continue
+		}
+		d, ok := definitions[key]
+		if !ok {
+			return nil, fmt.Errorf("attribute %q is not defined for the product's categories", key)
+		}
+		if err := d.Check(value); err != nil {
+			return nil, err
+		}
+		clean[key] = value
+	}
+	for key, d := range definitions {
+		if _, ok := clean[key]; d.Required && !ok {
+			return nil, fmt.Errorf("attribute %q is required", key)
+		}
+	}
+
+	if err := s.productRepo.SetAttributes(ctx, productID, clean); err != nil {
+		return nil, err
+	}
+	return s.productRepo.FindByID(ctx, productID)
+}
+
+// ListProducts returns the products matching every attr.<key>=<value>
+// filter. Values are compared with the type of the attribute, so
+// voltage=220 matches a stored 220.0.
+func (s *attributeService) ListProducts(ctx context.Context, filters map[string]string) ([]product.Product, error) {
+	attrs := make(product.Attributes, len(filters))
+	for key, raw := range filters {
+		definitions, err := s.repo.FindByKey(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if len(definitions) == 0 {
+			return nil, fmt.Errorf("unknown attribute %q", key)
+		}
+
+		value, err := definitions[0].Type.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for attribute %q: %w", key, err)
+		}
+		attrs[key] = value
+	}
+
+	return s.productRepo.FindByAttributes(ctx, attrs)
+}
+
+// productDefinitions returns the attributes applying to the product, keyed
+// by attribute key. When a key is defined at several levels, the deepest
+// category wins.
+func (s *attributeService) productDefinitions(ctx context.Context, productID int64) (map[string]product.AttributeDefinition, error) {
+	categories, err := s.categories.FindByProductID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	depth := make(map[int64]int)
+	for _, c := range categories {
+		for i, id := range c.AncestorIDs() {
+			if i+1 > depth[id] {
+				depth[id] = i + 1
+			}
+		}
+	}
+	ids := make([]int64, 0, len(depth))
+	for id := range depth {
+		ids = append(ids, id)
+	}
+
+	all, err := s.repo.FindByCategoryIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	definitions := make(map[string]product.AttributeDefinition, len(all))
+	for _, d := range all {
+		current, ok := definitions[d.Key]
+		if !ok || depth[d.CategoryID] > depth[current.CategoryID] {
+			definitions[d.Key] = d
+		}
+	}
+	return definitions, nil
+}
+
+func validateDefinition(d *product.AttributeDefinition) error {
+	d.Label = strings.TrimSpace(d.Label)
+	d.Unit = strings.TrimSpace(d.Unit)
+	if d.Label == "" {
+		d.Label = d.Key
+	}
+
+	switch d.Type {
+	case product.AttributeEnum:
+		if len(d.Options) == 0 {
+			return errors.New("enum attributes need at least one option")
+		}
+	case product.AttributeString, product.AttributeNumber, product.AttributeInteger, product.AttributeBoolean:
+		d.Options = nil
+	default:
+		return fmt.Errorf("unknown attribute type %q", d.Type)
+	}
+
+	if d.Type != product.AttributeNumber && d.Type != product.AttributeInteger {
+		d.Min, d.Max = nil, nil
+	}
+	if d.Min != nil && d.Max != nil && *d.Min > *d.Max {
+		return errors.New("attribute minimum cannot exceed its maximum")
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/product"
+	"github.com/rkweber-max/checkout-backend/internal/product/repository"
+)
+
+var (
+	ErrBundleNotFound = errors.New("product is not a bundle")
+	ErrInvalidBundle  = errors.New("invalid bundle")
+)
+
+// BundleInput describes the composition and pricing of a bundle.
+type BundleInput struct {
+	Pricing         product.BundlePricing
+	DiscountPercent float64
+	Components      []product.BundleComponent
+}
+
+type BundleService interface {
+	Set(ctx context.Context, productID int64, input BundleInput) (*product.Bundle, error)
+	Get(ctx context.Context, productID int64) (*product.Bundle, error)
+	Quote(ctx context.Context, productID int64, at time.Time) (*product.Bundle, error)
+	Delete(ctx context.Context, productID int64) error
+}
+
+type bundleService struct {
+	repo        repository.BundleRepository
+	productRepo repository.ProductRepository
+	variantRepo repository.VariantRepository
+	prices      PriceService
+}
+
+func NewBundleService(
+	repo repository.BundleRepository,
+	productRepo repository.ProductRepository,
+	variantRepo repository.VariantRepository,
+	prices PriceService,
+) BundleService {
+	return &bundleService{repo: repo, productRepo: productRepo, variantRepo: variantRepo, prices: prices}
+}
+
+// Set turns the product into a bundle, or replaces the composition of an
+// existing one.
+func (s *bundleService) Set(ctx context.Context, productID int64, input BundleInput) (*product.Bundle, error) {
+	p, err := s.productRepo.FindByID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrProductNotFound
+	}
+
+	if err := s.validate(ctx, *p, input); err != nil {
+		return nil, err
+	}
+
+	bundle := &product.Bundle{
+		ProductID:       productID,
+		Pricing:         input.Pricing,
+		DiscountPercent: input.DiscountPercent,
+		Components:      input.Components,
+	}
+	if err := s.repo.Save(ctx, bundle); err != nil {
+		return nil, err
+	}
+
+	return s.Get(ctx, productID)
+}
+
+// Get returns the bundle priced at the current time, or nil if the product
+// is not a bundle.
+func (s *bundleService) Get(ctx context.Context, productID int64) (*product.Bundle, error) {
+	return s.Quote(ctx, productID, time.Now())
+}
+
+// Quote returns the bundle with its price at the given time and the number
+// of bundles its components' stock allows, or nil if the product is not a
+// bundle.
+func (s *bundleService) Quote(ctx context.Context, productID int64, at time.Time) (*product.Bundle, error) {
+	bundle, err := s.repo.FindByProductID(ctx, productID)
+	if err != nil || bundle == nil {
+		return nil, err
+	}
+
+	if bundle.Pricing == product.BundlePricingFixed {
+		p, err := s.productRepo.FindByID(ctx, productID)
+		if err != nil {
+			return nil, err
+		}
+		if p == nil {
+			return nil, ErrProductNotFound
+		}
+		if bundle.Price, err = s.prices.EffectivePrice(ctx, *p, at); err != nil {
+			return nil, err
+		}
+	}
+
+	var total float64
+	for _, component := range bundle.Components {
+		p, err := s.productRepo.FindByID(ctx, component.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		if p == nil {
+			// A component in the trash makes the bundle unsellable.
+			none := 0
+			bundle.Available = &none
+			continue
+		}
+
+		price, err := s.prices.EffectivePrice(ctx, *p, at)
+		if err != nil {
+			return nil, err
+		}
+		p.Price = price
+
+		if component.VariantID != nil {
+			variant, err := s.variantRepo.FindByID(ctx, *component.VariantID)
+			if err != nil {
+				return nil, err
+			}
+			stock := 0
+			if variant != nil {
+				price = variant.EffectivePrice(*p)
+				stock = variant.Stock
+			}
+			if available := stock / component.Quantity; bundle.Available == nil || available < *bundle.Available {
+				bundle.Available = &available
+			}
+		}
+
+		total += price * float64(component.Quantity)
+	}
+
+	if bundle.Pricing == product.BundlePricingDiscount {
+		bundle.Price = math.Round(total*(100-bundle.DiscountPercent)) / 100
+	}
+
+	return bundle, nil
+}
+
+func (s *bundleService) Delete(ctx context.Context, productID int64) error {
+	bundle, err := s.repo.FindByProductID(ctx, productID)
+	if err != nil {
+		return err
+	}
+	if bundle == nil {
+		return ErrBundleNotFound
+	}
+
+	return s.repo.Delete(ctx, productID)
+}
+
+// validate checks that the components exist, are not bundles themselves,
+// belong to the same seller as the bundle and name a variant whenever the
+// component is sold in variants.
+func (s *bundleService) validate(ctx context.Context, bundle product.Product, input BundleInput) error {
+	if !input.Pricing.IsValid() {
+		return fmt.Errorf("%w: pricing must be 'fixed' or 'discount'", ErrInvalidBundle)
+	}
+	if input.Pricing == product.BundlePricingDiscount && (input.DiscountPercent <= 0 || input.DiscountPercent >= 100) {
+		return fmt.Errorf("%w: discount percent must be between 0 and 100", ErrInvalidBundle)
+	}
+	if input.Pricing == product.BundlePricingFixed && input.DiscountPercent != 0 {
+		return fmt.Errorf("%w: fixed-price bundles cannot have a discount", ErrInvalidBundle)
+	}
+	if len(input.Components) == 0 {
+		return fmt.Errorf("%w: a bundle needs at least one component", ErrInvalidBundle)
+	}
+
+	variants, err := s.variantRepo.CountByProductID(ctx, bundle.ID)
+	if err != nil {
+		return err
+	}
+	if variants > 0 {
+		return fmt.Errorf("%w: products sold in variants cannot be bundles", ErrInvalidBundle)
+	}
+	isComponent, err := s.repo.IsComponent(ctx, bundle.ID)
+	if err != nil {
+		return err
+	}
+	if isComponent {
+		return fmt.Errorf("%w: product is a component of another bundle", ErrInvalidBundle)
+	}
+
+	for _, component := range input.Components {
+		if component.Quantity <= 0 {
+			return fmt.Errorf("%w: component quantity must be positive", ErrInvalidBundle)
+		}
+		if component.ProductID == bundle.ID {
+			return fmt.Errorf("%w: a bundle cannot contain itself", ErrInvalidBundle)
+		}
+
+		p, err := s.productRepo.FindByID(ctx, component.ProductID)
+		if err != nil {
+			return err
+		}
+		if p == nil {
+			return fmt.Errorf("%w: product with ID %d not found", ErrInvalidBundle, component.ProductID)
+		}
+		if !sameOwner(bundle.OwnerID, p.OwnerID) {
+			return fmt.Errorf("%w: product with ID %d belongs to another seller", ErrInvalidBundle, p.ID)
+		}
+
+		nested, err := s.repo.FindByProductID(ctx, p.ID)
+		if err != nil {
+			return err
+		}
+		if nested != nil {
+			return fmt.Errorf("%w: product with ID %d is itself a bundle", ErrInvalidBundle, p.ID)
+		}
+
+		if component.VariantID == nil {
+			count, err := s.variantRepo.CountByProductID(ctx, p.ID)
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("%w: product with ID %d requires a variant", ErrInvalidBundle, p.ID)
+			}
+			continue
+		}
+
+		variant, err := s.variantRepo.FindByID(ctx, *component.VariantID)
+		if err != nil {
+			return err
+		}
+		if variant == nil || variant.ProductID != p.ID {
+			return fmt.Errorf("%w: variant with ID %d not found for product %d", ErrInvalidBundle, *component.VariantID, p.ID)
+		}
+	}
+
+	return nil
+}
+
+func sameOwner(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
@@ -0,0 +1,271 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/product"
+	"github.com/rkweber-max/checkout-backend/internal/product/repository"
+)
+
+var _ repository.BundleRepository = (*fakeBundles)(nil)
+
+// fakeBundles is an in-memory repository.BundleRepository.
+type fakeBundles struct {
+	bundles map[int64]product.Bundle
+}
+
+func (f *fakeBundles) Save(ctx context.Context, b *product.Bundle) error {
+	f.bundles[b.ProductID] = *b
+	return nil
+}
+
+func (f *fakeBundles) FindByProductID(ctx context.Context, productID int64) (*product.Bundle, error) {
+	if b, ok := f.bundles[productID]; ok {
+		b.Components = append([]product.BundleComponent(nil), b.Components...)
+		return &b, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeBundles) Delete(ctx context.Context, productID int64) error {
+	delete(f.bundles, productID)
+	return nil
+}
+
+func (f *fakeBundles) IsComponent(ctx context.Context, productID int64) (bool, error) {
+	for _, b := range f.bundles {
+		for _, c := range b.Components {
+			if c.ProductID == productID {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// fakeVariants keeps variants in memory. Only the lookups used by bundles
+// and checkout are implemented.
+type fakeVariants struct {
+	repository.VariantRepository
+
+	variants map[int64]product.Variant
+}
+
+func (f *fakeVariants) FindByID(ctx context.Context, id int64) (*product.Variant, error) {
+	if v, ok := f.variants[id]; ok {
+		return &v, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeVariants) CountByProductID(ctx context.Context, productID int64) (int64, error) {
+	var count int64
+	for _, v := range f.variants {
+		if v.ProductID == productID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+type bundleFixture struct {
+	*priceFixture
+	bundles  *fakeBundles
+	variants *fakeVariants
+	service  BundleService
+}
+
+// newBundleFixture sells a kit (product 10) of a mug (product 1, 100.00)
+// and a T-shirt (product 2, 50.00) whose size M variant costs 30.00.
+func newBundleFixture() *bundleFixture {
+	seller := uint(7)
+	prices := newFakePrices()
+	products := newFakeProducts(
+		product.Product{ID: 1, Name: "Caneca", Price: 100, OwnerID: &seller},
+		product.Product{ID: 2, Name: "Camiseta", Price: 50, OwnerID: &seller},
+		product.Product{ID: 3, Name: "Boné", Price: 40},
+		product.Product{ID: 10, Name: "Kit", Price: 150, OwnerID: &seller},
+	)
+	products.prices = prices
+	variantPrice := 30.0
+
+	f := &bundleFixture{
+		priceFixture: &priceFixture{prices: prices, products: products, service: NewPriceService(prices, products)},
+		bundles:      &fakeBundles{bundles: make(map[int64]product.Bundle)},
+		variants: &fakeVariants{variants: map[int64]product.Variant{
+			21: {ID: 21, ProductID: 2, SKU: "CAM-M", Price: &variantPrice, Stock: 7},
+			22: {ID: 22, ProductID: 2, SKU: "CAM-G", Stock: 1},
+		}},
+	}
+	f.service = NewBundleService(f.bundles, products, f.variants, f.priceFixture.service)
+	return f
+}
+
+func variantID(id int64) *int64 {
+	return &id
+}
+
+func (f *bundleFixture) set(t *testing.T, input BundleInput) *product.Bundle {
+	t.Helper()
+
+	bundle, err := f.service.Set(context.Background(), 10, input)
+	if err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	return bundle
+}
+
+func TestBundleDiscountPricing(t *testing.T) {
+	f := newBundleFixture()
+	bundle := f.set(t, BundleInput{
+		Pricing:         product.BundlePricingDiscount,
+		DiscountPercent: 10,
+		Components: []product.BundleComponent{
+			{ProductID: 1, Quantity: 2},
+			{ProductID: 2, VariantID: variantID(21), Quantity: 3},
+		},
+	})
+
+	// (2 × 100.00 + 3 × 30.00) less 10%.
+	if bundle.Price != 261 {
+		t.Errorf("price = %v, want 261", bundle.Price)
+	}
+	// Only the variant's stock is tracked: 7 T-shirts make 2 kits.
+	if bundle.Available == nil || *bundle.Available != 2 {
+		t.Errorf("available = %v, want 2", bundle.Available)
+	}
+}
+
+func TestBundleDiscountRoundsToCents(t *testing.T) {
+	f := newBundleFixture()
+	f.products.products[1].Price = 9.99
+	bundle := f.set(t, BundleInput{
+		Pricing:         product.BundlePricingDiscount,
+		DiscountPercent: 15,
+		Components:      []product.BundleComponent{{ProductID: 1, Quantity: 3}},
+	})
+
+	// 29.97 less 15% is 25.4745.
+	if bundle.Price != 25.47 {
+		t.Errorf("price = %v, want 25.47", bundle.Price)
+	}
+	if bundle.Available != nil {
+		t.Errorf("available = %v, want unlimited", *bundle.Available)
+	}
+}
+
+func TestBundleQuoteUsesScheduledPrices(t *testing.T) {
+	f := newBundleFixture()
+	ctx := context.Background()
+	f.set(t, BundleInput{
+		Pricing:         product.BundlePricingDiscount,
+		DiscountPercent: 50,
+		Components:      []product.BundleComponent{{ProductID: 1, Quantity: 1}},
+	})
+
+	now := time.Now()
+	f.addSale(80, now.Add(time.Hour), now.Add(2*time.Hour))
+
+	tests := map[time.Duration]float64{0: 50, 90 * time.Minute: 40, 3 * time.Hour: 50}
+	for offset, want := range tests {
+		bundle, err := f.service.Quote(ctx, 10, now.Add(offset))
+		if err != nil {
+			t.Fatalf("Quote: %v", err)
+		}
+		if bundle.Price != want {
+			t.Errorf("price at %v = %v, want %v", offset, bundle.Price, want)
+		}
+	}
+}
+
+func TestBundleFixedPricing(t *testing.T) {
+	f := newBundleFixture()
+	ctx := context.Background()
+	f.set(t, BundleInput{
+		Pricing:    product.BundlePricingFixed,
+		Components: []product.BundleComponent{{ProductID: 1, Quantity: 1}, {ProductID: 2, VariantID: variantID(22), Quantity: 2}},
+	})
+
+	now := time.Now()
+	sale := &product.ScheduledPrice{ProductID: 10, Price: 120, StartsAt: now.Add(-time.Hour), Status: product.SchedulePending}
+	f.prices.CreateSchedule(ctx, sale)
+
+	bundle, err := f.service.Quote(ctx, 10, now)
+	if err != nil {
+		t.Fatalf("Quote: %v", err)
+	}
+	if bundle.Price != 120 {
+		t.Errorf("price = %v, want the scheduled 120 of the kit itself", bundle.Price)
+	}
+	// One size G T-shirt is not enough for a kit of two.
+	if bundle.Available == nil || *bundle.Available != 0 {
+		t.Errorf("available = %v, want 0", bundle.Available)
+	}
+}
+
+func TestBundleWithTrashedComponentIsUnavailable(t *testing.T) {
+	f := newBundleFixture()
+	f.set(t, BundleInput{
+		Pricing:         product.BundlePricingDiscount,
+		DiscountPercent: 10,
+		Components:      []product.BundleComponent{{ProductID: 1, Quantity: 1}, {ProductID: 2, VariantID: variantID(21), Quantity: 1}},
+	})
+	delete(f.products.products, 1)
+
+	bundle, err := f.service.Get(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if bundle.Available == nil || *bundle.Available != 0 {
+		t.Errorf("available = %v, want 0", bundle.Available)
+	}
+}
+
+func TestBundleValidation(t *testing.T) {
+	mug := []product.BundleComponent{{ProductID: 1, Quantity: 1}}
+	tests := []struct {
+		name  string
+		input BundleInput
+	}{
+		{"unknown pricing", BundleInput{Pricing: "free", Components: mug}},
+		{"no discount", BundleInput{Pricing: product.BundlePricingDiscount, Components: mug}},
+		{"whole discount", BundleInput{Pricing: product.BundlePricingDiscount, DiscountPercent: 100, Components: mug}},
+		{"fixed with discount", BundleInput{Pricing: product.BundlePricingFixed, DiscountPercent: 10, Components: mug}},
+		{"empty", BundleInput{Pricing: product.BundlePricingFixed}},
+		{"zero quantity", BundleInput{Pricing: product.BundlePricingFixed, Components: []product.BundleComponent{{ProductID: 1}}}},
+		{"itself", BundleInput{Pricing: product.BundlePricingFixed, Components: []product.BundleComponent{{ProductID: 10, Quantity: 1}}}},
+		{"unknown product", BundleInput{Pricing: product.BundlePricingFixed, Components: []product.BundleComponent{{ProductID: 99, Quantity: 1}}}},
+		{"other seller", BundleInput{Pricing: product.BundlePricingFixed, Components: []product.BundleComponent{{ProductID: 3, Quantity: 1}}}},
+		{"missing variant", BundleInput{Pricing: product.BundlePricingFixed, Components: []product.BundleComponent{{ProductID: 2, Quantity: 1}}}},
+		{"variant of another product", BundleInput{Pricing: product.BundlePricingFixed, Components: []product.BundleComponent{{ProductID: 1, VariantID: variantID(21), Quantity: 1}}}},
+	}
+	for _, tt := range tests {
+		f := newBundleFixture()
+		if _, err := f.service.Set(context.Background(), 10, tt.input); !errors.Is(err, ErrInvalidBundle) {
+			t.Errorf("%s: Set error = %v, want ErrInvalidBundle", tt.name, err)
+		}
+	}
+}
+
+func TestBundleCannotNest(t *testing.T) {
+	f := newBundleFixture()
+	ctx := context.Background()
+	f.set(t, BundleInput{Pricing: product.BundlePricingFixed, Components: []product.BundleComponent{{ProductID: 1, Quantity: 1}}})
+
+	// The kit can't go into another bundle...
+	seller := uint(7)
+	f.products.products[11] = &product.Product{ID: 11, Name: "Kit duplo", Price: 250, OwnerID: &seller}
+	_, err := f.service.Set(ctx, 11, BundleInput{Pricing: product.BundlePricingFixed, Components: []product.BundleComponent{{ProductID: 10, Quantity: 2}}})
+	if !errors.Is(err, ErrInvalidBundle) {
+		t.Errorf("bundle of bundles error = %v, want ErrInvalidBundle", err)
+	}
+
+	// ...and a component can't become a bundle.
+	_, err = f.service.Set(ctx, 1, BundleInput{Pricing: product.BundlePricingFixed, Components: []product.BundleComponent{{ProductID: 2, VariantID: variantID(21), Quantity: 1}}})
+	if !errors.Is(err, ErrInvalidBundle) {
+		t.Errorf("component turned bundle error = %v, want ErrInvalidBundle", err)
+	}
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/rkweber-max/checkout-backend/internal/product"
+	"github.com/rkweber-max/checkout-backend/internal/product/repository"
+)
+
+var (
+	ErrCategoryNotFound    = errors.New("category not found")
+	ErrCategoryHasChildren = errors.New("category has subcategories")
+	ErrInvalidCategoryMove = errors.New("category cannot be moved under itself or its descendants")
+)
+
+var slugInvalidChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+type CategoryService interface {
+	Create(ctx context.Context, c *product.Category) error
+	GetTree(ctx context.Context) ([]*product.CategoryNode, error)
+	GetByID(ctx context.Context, id int64) (*product.Category, error)
+	Update(ctx context.Context, c *product.Category) error
+	Move(ctx context.Context, id int64, newParentID *int64) (*product.Category, error)
+	Delete(ctx context.Context, id int64) error
+	AssignProduct(ctx context.Context, productID int64, categoryIDs []int64) error
+	ListProducts(ctx context.Context, categoryID int64) ([]product.Product, error)
+	Breadcrumbs(ctx context.Context, productID int64) ([][]product.Breadcrumb, error)
+}
+
+type categoryService struct {
+	repo        repository.CategoryRepository
+	productRepo repository.ProductRepository
+}
+
+func NewCategoryService(repo repository.CategoryRepository, productRepo repository.ProductRepository) CategoryService {
+	return &categoryService{repo: repo, productRepo: productRepo}
+}
+
+func (s *categoryService) Create(ctx context.Context, c *product.Category) error {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return errors.New("category name cannot be empty")
+	}
+	c.Slug = slugify(c.Slug, c.Name)
+
+	if c.ParentID != nil {
+		parent, err := s.repo.FindByID(ctx, *c.ParentID)
+		if err != nil {
+			return err
+		}
+		if parent == nil {
+			return ErrCategoryNotFound
+		}
+	}
+
+	return s.repo.Create(ctx, c)
+}
+
+// GetTree returns every category arranged under its parent.
+func (s *categoryService) GetTree(ctx context.Context) ([]*product.CategoryNode, error) {
+	categories, err := s.repo.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[int64]*product.CategoryNode, len(categories))
+	for _, c := range categories {
+		nodes[c.ID] = &product.CategoryNode{Category: c, Children: []*product.CategoryNode{}}
+	}
+
+	// Categories are ordered by path, so parents are always seen first.
+	roots := []*product.CategoryNode{}
+	for _, c := range categories {
+		node := nodes[c.ID]
+		if c.ParentID == nil {
+			roots = append(roots, node)
+			continue
+		}
+		if parent, ok := nodes[*c.ParentID]; ok {
+			parent.Children = append(parent.Children, node)
+		}
+	}
+
+	return roots, nil
+}
+
+func (s *categoryService) GetByID(ctx context.Context, id int64) (*product.Category, error) {
+	return s.repo.FindByID(ctx, id)
+}
+
+func (s *categoryService) Update(ctx context.Context, c *product.Category) error {
+	existing, err := s.repo.FindByID(ctx, c.ID)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return ErrCategoryNotFound
+	}
+
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return errors.New("category name cannot be empty")
+	}
+	c.Slug = slugify(c.Slug, c.Name)
+
+	return s.repo.Update(ctx, c)
+}
+
+// Move re-parents a category together with its whole subtree. A nil
+// newParentID moves it to the top level.
+func (s *categoryService) Move(ctx context.Context, id int64, newParentID *int64) (*product.Category, error) {
+	c, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, ErrCategoryNotFound
+	}
+
+	newPath := product.RootPath(c.ID)
+	if newParentID != nil {
+		parent, err := s.repo.FindByID(ctx, *newParentID)
+		if err != nil {
+			return nil, err
+		}
+		if parent == nil {
+			return nil, ErrCategoryNotFound
+		}
+		if strings.HasPrefix(parent.Path, c.Path) {
+			return nil, ErrInvalidCategoryMove
+		}
+		newPath = parent.ChildPath(c.ID)
+	}
+
+	if newPath == c.Path {
+		return c, nil
+	}
+
+	if err := s.repo.Move(ctx, c, newParentID, newPath); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (s *categoryService) Delete(ctx context.Context, id int64) error {
+	c, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return ErrCategoryNotFound
+	}
+
+	hasChildren, err := s.repo.HasChildren(ctx, id)
+	if err != nil {
+		return err
+	}
+	if hasChildren {
+		return ErrCategoryHasChildren
+	}
+
+	return s.repo.Delete(ctx, id)
+}
+
+func (s *categoryService) AssignProduct(ctx context.Context, productID int64, categoryIDs []int64) error {
+	p, err := s.productRepo.FindByID(ctx, productID)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return ErrProductNotFound
+	}
+
+	categoryIDs = uniqueIDs(categoryIDs)
+	categories, err := s.repo.FindByIDs(ctx, categoryIDs)
+	if err != nil {
+		return err
+	}
+	if len(categories) != len(categoryIDs) {
+		return ErrCategoryNotFound
+	}
+
+	return s.repo.SetProductCategories(ctx, productID, categoryIDs)
+}
+
+// ListProducts returns the products assigned to the category or to any of
+// its descendants.
+func (s *categoryService) ListProducts(ctx context.Context, categoryID int64) ([]product.Product, error) {
+	c, err := s.repo.FindByID(ctx, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, ErrCategoryNotFound
+	}
+
+	ids, err := s.repo.FindDescendantIDs(ctx, *c)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.productRepo.FindByCategoryIDs(ctx, ids)
+}
+
+// Breadcrumbs returns one root-to-leaf trail per category the product is
+// assigned to.
+func (s *categoryService) Breadcrumbs(ctx context.Context, productID int64) ([][]product.Breadcrumb, error) {
+	categories, err := s.repo.FindByProductID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	var ancestorIDs []int64
+	for _, c := range categories {
+		ancestorIDs = append(ancestorIDs, c.AncestorIDs()...)
+	}
+
+	ancestors, err := s.repo.FindByIDs(ctx, uniqueIDs(ancestorIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int64]product.Category, len(ancestors))
+	for _, a := range ancestors {
+		byID[a.ID] = a
+	}
+
+	trails := [][]product.Breadcrumb{}
+	for _, c := range categories {
+		var trail []product.Breadcrumb
+		for _, id := range c.AncestorIDs() {
+			a, ok := byID[id]
+			if !ok {
+				continue
+			}
+			trail = append(trail, product.Breadcrumb{ID: a.ID, Name: a.Name, Slug: a.Slug})
+		}
+		trails = append(trails, trail)
+	}
+
+	return trails, nil
+}
+
+func slugify(slug, fallback string) string {
+	if strings.TrimSpace(slug) == "" {
+		slug = fallback
+	}
+	slug = slugInvalidChars.ReplaceAllString(strings.ToLower(slug), "-")
+	return strings.Trim(slug, "-")
+}
+
+func uniqueIDs(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	var unique []int64
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		unique = append(unique, id)
+	}
+	return unique
+}
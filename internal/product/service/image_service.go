@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/rkweber-max/checkout-backend/internal/product"
+	"github.com/rkweber-max/checkout-backend/internal/product/repository"
+	"github.com/rkweber-max/checkout-backend/pkg/config"
+	"github.com/rkweber-max/checkout-backend/pkg/imaging"
+	"github.com/rkweber-max/checkout-backend/pkg/storage"
+)
+
+const defaultImageMaxSizeMB = 5
+
+var (
+	ErrImageNotFound = errors.New("image not found")
+	ErrImageTooLarge = errors.New("image exceeds the maximum upload size")
+	ErrInvalidOrder  = errors.New("image order must list every image of the product exactly once")
+)
+
+type ImageService interface {
+	Upload(ctx context.Context, productID int64, data []byte) (*product.Image, error)
+	List(ctx context.Context, productID int64) ([]product.Image, error)
+	Reorder(ctx context.Context, productID int64, orderedIDs []int64) ([]product.Image, error)
+	SetPrimary(ctx context.Context, productID, imageID int64) error
+	Delete(ctx context.Context, productID, imageID int64) error
+	MaxSize() int64
+}
+
+type imageService struct {
+	repo        repository.ImageRepository
+	productRepo repository.ProductRepository
+	storage     storage.Storage
+	maxSize     int64
+}
+
+func NewImageService(
+	repo repository.ImageRepository,
+	productRepo repository.ProductRepository,
+	store storage.Storage,
+	cfg *config.Config,
+) ImageService {
+	maxSizeMB := cfg.ImageMaxSizeMB
+	if maxSizeMB <= 0 {
+		maxSizeMB = defaultImageMaxSizeMB
+	}
+
+	return &imageService{
+		repo:        repo,
+		productRepo: productRepo,
+		storage:     store,
+		maxSize:     int64(maxSizeMB) << 20,
+	}
+}
+
+func (s *imageService) MaxSize() int64 {
+	return s.maxSize
+}
+
+// Upload validates the image, stores the original and its thumbnails and
+// records it. The first image of a product becomes its primary image.
+func (s *imageService) Upload(ctx context.Context, productID int64, data []byte) (*product.Image, error) {
+	if int64(len(data)) > s.maxSize {
+		return nil, ErrImageTooLarge
+	}
+
+	p, err := s.productRepo.FindByID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrProductNotFound
+	}
+
+	img, info, err := imaging.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.repo.FindByProductID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	suffix, err := newImageKey()
+	if err != nil {
+		return nil, err
+	}
+	prefix := fmt.Sprintf("products/%d/%s", productID, suffix)
+
+	var stored []string
+	cleanup := func() {
+		for _, key := range stored {
+			if err := s.storage.Delete(context.Background(), key); err != nil {
+				log.Printf("Failed to remove %s after aborted upload: %v", key, err)
+			}
+		}
+	}
+
+	original := originalKey(prefix, info.ContentType)
+	if err := s.storage.Put(ctx, original, data, info.ContentType); err != nil {
+		return nil, err
+	}
+	stored = append(stored, original)
+
+	thumbnailType := imaging.ThumbnailContentType(info.ContentType)
+	thumbnails := make(map[string]string, len(product.ThumbnailSizes))
+	for name, side := range product.ThumbnailSizes {
+		encoded, err := imaging.EncodeThumbnail(imaging.Thumbnail(img, side), info.ContentType)
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+
+		key := thumbnailKey(prefix, name, info.ContentType)
+		if err := s.storage.Put(ctx, key, encoded, thumbnailType); err != nil {
+			cleanup()
+			return nil, err
+		}
+		stored = append(stored, key)
+		thumbnails[name] = s.storage.URL(key)
+	}
+
+	image := &product.Image{
+		ProductID:   productID,
+		StorageKey:  prefix,
+		ContentType: info.ContentType,
+		Width:       info.Width,
+		Height:      info.Height,
+		SizeBytes:   int64(len(data)),
+		URL:         s.storage.URL(original),
+		Thumbnails:  thumbnails,
+		Position:    len(existing),
+		IsPrimary:   len(existing) == 0,
+	}
+	if err := s.repo.Create(ctx, image); err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	return image, nil
+}
+
+func (s *imageService) List(ctx context.Context, productID int64) ([]product.Image, error) {
+	return s.repo.FindByProductID(ctx, productID)
+}
+
+// Reorder sets image positions to follow orderedIDs, which must contain
+// every image of the product.
+func (s *imageService) Reorder(ctx context.Context, productID int64, orderedIDs []int64) ([]product.Image, error) {
+	images, err := s.repo.FindByProductID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := uniqueIDs(orderedIDs)
+	if len(ids) != len(orderedIDs) || len(ids) != len(images) {
+		return nil, ErrInvalidOrder
+	}
+
+	known := make(map[int64]bool, len(images))
+	for _, img := range images {
+		known[img.ID] = true
+	}
+	for _, id := range ids {
+		if !known[id] {
+			return nil, ErrInvalidOrder
+		}
+	}
+
+	if err := s.repo.UpdatePositions(ctx, productID, ids); err != nil {
+		return nil, err
+	}
+
+	return s.repo.FindByProductID(ctx, productID)
+}
+
+func (s *imageService) SetPrimary(ctx context.Context, productID, imageID int64) error {
+	if _, err := s.findImage(ctx, productID, imageID); err != nil {
+		return err
+	}
+
+	return s.repo.SetPrimary(ctx, productID, imageID)
+}
+
+// Delete removes the image and its files. If it was the primary image, the
+// next image in order takes its place.
+func (s *imageService) Delete(ctx context.Context, productID, imageID int64) error {
+	img, err := s.findImage(ctx, productID, imageID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(ctx, imageID); err != nil {
+		return err
+	}
+
+	keys := []string{originalKey(img.StorageKey, img.ContentType)}
+	for name := range product.ThumbnailSizes {
+		keys = append(keys, thumbnailKey(img.StorageKey, name, img.ContentType))
+	}
+	for _, key := range keys {
+		if err := s.storage.Delete(ctx, key); err != nil {
+			log.Printf("Failed to remove image file %s: %v", key, err)
+		}
+	}
+
+	if !img.IsPrimary {
+		return nil
+	}
+
+	remaining, err := s.repo.FindByProductID(ctx, productID)
+	if err != nil || len(remaining) == 0 {
+		return err
+	}
+	return s.repo.SetPrimary(ctx, productID, remaining[0].ID)
+}
+
+func (s *imageService) findImage(ctx context.Context, productID, imageID int64) (*product.Image, error) {
+	img, err := s.repo.FindByID(ctx, imageID)
+	if err != nil {
+		return nil, err
+	}
+	if img == nil || img.ProductID != productID {
+		return nil, ErrImageNotFound
+	}
+	return img, nil
+}
+
+// newImageKey returns a random name for the storage folder of an upload, so
+// uploads never overwrite each other's files.
+func newImageKey() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func originalKey(prefix, contentType string) string {
+	return fmt.Sprintf("%s/original.%s", prefix, imaging.Extension(contentType))
+}
+
+func thumbnailKey(prefix, name, sourceType string) string {
+	return fmt.Sprintf("%s/%s.%s", prefix, name, imaging.Extension(imaging.ThumbnailContentType(sourceType)))
+}
@@ -0,0 +1,366 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/product"
+	"github.com/rkweber-max/checkout-backend/internal/product/repository"
+	"github.com/rkweber-max/checkout-backend/pkg/spreadsheet"
+)
+
+// BackgroundImportThreshold is the number of data rows above which imports
+// are always run as a background job.
+const BackgroundImportThreshold = 500
+
+const (
+	exportBatchSize   = 500
+	importJobLifetime = 24 * time.Hour
+)
+
+var ErrEmptyImport = errors.New("spreadsheet has no header row")
+
+// ImportOptions controls how a spreadsheet is imported. Mapping maps a
+// product field (see product.ImportColumns) to the header of the column
+// holding it; unmapped fields default to a column named after the field.
+type ImportOptions struct {
+	Mapping map[string]string
+	DryRun  bool
+}
+
+type ImportService interface {
+	Import(ctx context.Context, rows [][]string, opts ImportOptions) (*product.ImportReport, error)
+	StartImport(ctx context.Context, rows [][]string, opts ImportOptions) (*product.ImportJob, error)
+	GetJob(id string) *product.ImportJob
+	Export(ctx context.Context, w spreadsheet.Writer) error
+}
+
+type importService struct {
+	repo repository.ProductRepository
+
+	mu   sync.Mutex
+	jobs map[string]*product.ImportJob
+}
+
+func NewImportService(repo repository.ProductRepository) ImportService {
+	return &importService{repo: repo, jobs: make(map[string]*product.ImportJob)}
+}
+
+func (s *importService) Import(ctx context.Context, rows [][]string, opts ImportOptions) (*product.ImportReport, error) {
+	return s.run(ctx, rows, opts, func(int) {})
+}
+
+// StartImport validates the header synchronously and processes the rows in
+// a background goroutine. Progress is available through GetJob.
+func (s *importService) StartImport(ctx context.Context, rows [][]string, opts ImportOptions) (*product.ImportJob, error) {
+	if _, err := resolveColumns(rows, opts.Mapping); err != nil {
+		return nil, err
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+
+	job := &product.ImportJob{
+		ID:        id,
+		Status:    product.ImportJobQueued,
+		Total:     len(rows) - 1,
+		CreatedAt: time.Now(),
+	}
+
+	s.mu.Lock()
+	s.pruneJobs()
+	s.jobs[id] = job
+	s.mu.Unlock()
+
+	go func() {
+		s.updateJob(id, func(j *product.ImportJob) { j.Status = product.ImportJobRunning })
+
+		// Keep the request values (such as the acting user) but not its
+		// cancellation, since the job outlives the request.
+		report, err := s.run(context.WithoutCancel(ctx), rows, opts, func(processed int) {
+			s.updateJob(id, func(j *product.ImportJob) { j.Processed = processed })
+		})
+
+		s.updateJob(id, func(j *product.ImportJob) {
+			now := time.Now()
+			j.FinishedAt = &now
+			if err != nil {
+				log.Printf("Product import %s failed: %v", id, err)
+				j.Status = product.ImportJobFailed
+				j.Error = err.Error()
+				return
+			}
+			j.Status = product.ImportJobCompleted
+			j.Report = report
+		})
+	}()
+
+	return s.GetJob(id), nil
+}
+
+// GetJob returns a snapshot of the job, or nil if it is unknown.
+func (s *importService) GetJob(id string) *product.ImportJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil
+	}
+	snapshot := *job
+	return &snapshot
+}
+
+// Export writes a header row followed by every product.
+func (s *importService) Export(ctx context.Context, w spreadsheet.Writer) error {
+	if err := w.WriteRow(product.ImportColumns); err != nil {
+		return err
+	}
+
+	err := s.repo.FindInBatches(ctx, exportBatchSize, func(products []product.Product) error {
+		for _, p := range products {
+			row := []string{p.SKU, p.GTIN, p.Name, p.Description, strconv.FormatFloat(p.Price, 'f', -1, 64)}
+			if err := w.WriteRow(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return w.Close()
+}
+
+func (s *importService) run(ctx context.Context, rows [][]string, opts ImportOptions, progress func(int)) (*product.ImportReport, error) {
+	columns, err := resolveColumns(rows, opts.Mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = product.WithPriceSource(ctx, product.PriceSourceImport)
+	report := &product.ImportReport{DryRun: opts.DryRun, Errors: []product.RowError{}}
+	seen := importSeen{skus: make(map[string]int), gtins: make(map[string]int)}
+
+	for i, row := range rows[1:] {
+		rowNumber := i + 2
+		if isBlankRow(row) {
+			progress(i + 1)
+			continue
+		}
+		report.TotalRows++
+
+		created, rowErrs := s.importRow(ctx, row, columns, opts.DryRun, rowNumber, seen)
+		if len(rowErrs) > 0 {
+			report.Failed++
+			report.Errors = append(report.Errors, product.RowError{
+				Row:    rowNumber,
+				SKU:    cell(row, columns["sku"]),
+				Errors: rowErrs,
+			})
+		} else if created {
+			report.Created++
+		} else {
+			report.Updated++
+		}
+
+		progress(i + 1)
+	}
+
+	return report, nil
+}
+
+// importSeen records the row on which each SKU and GTIN first appeared, so
+// that duplicates within the file are reported before they reach the
+// database.
+type importSeen struct {
+	skus  map[string]int
+	gtins map[string]int
+}
+
+// importRow validates a single row and, unless in dry-run mode, upserts it
+// by SKU. It reports whether the row creates a new product.
+func (s *importService) importRow(
+	ctx context.Context,
+	row []string,
+	columns map[string]int,
+	dryRun bool,
+	rowNumber int,
+	seen importSeen,
+) (bool, []string) {
+	var errs []string
+
+	sku := cell(row, columns["sku"])
+	if sku == "" {
+		return false, []string{"sku is required"}
+	}
+	if first, ok := seen.skus[sku]; ok {
+		return false, []string{fmt.Sprintf("duplicate sku, already used on row %d", first)}
+	}
+	seen.skus[sku] = rowNumber
+
+	existing, err := s.repo.FindBySKU(ctx, sku)
+	if err != nil {
+		return false, []string{err.Error()}
+	}
+	if existing == nil {
+		trashed, err := s.repo.FindDeletedBySKU(ctx, sku)
+		if err != nil {
+			return false, []string{err.Error()}
+		}
+		if trashed != nil {
+			return false, []string{fmt.Sprintf("sku belongs to product %d in the trash; restore it first", trashed.ID)}
+		}
+	}
+
+	p := product.Product{SKU: sku}
+	if existing != nil {
+		p = *existing
+	}
+
+	if idx, ok := columns["gtin"]; ok {
+		p.GTIN = cell(row, idx)
+	}
+	if idx, ok := columns["name"]; ok {
+		p.Name = cell(row, idx)
+	}
+	if idx, ok := columns["description"]; ok {
+		p.Description = cell(row, idx)
+	}
+	if idx, ok := columns["price"]; ok {
+		price, err := strconv.ParseFloat(strings.ReplaceAll(cell(row, idx), ",", "."), 64)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("invalid price %q", cell(row, idx)))
+		}
+		p.Price = price
+	}
+
+	if err := validateProduct(&p); err != nil {
+		errs = append(errs, err.Error())
+	} else if p.GTIN != "" {
+		if first, ok := seen.gtins[p.GTIN]; ok {
+			errs = append(errs, fmt.Sprintf("duplicate gtin, already used on row %d", first))
+		} else {
+			seen.gtins[p.GTIN] = rowNumber
+			if err := checkGTIN(ctx, s.repo, p); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+	if len(errs) > 0 || dryRun {
+		return existing == nil, errs
+	}
+
+	if existing == nil {
+		_, err = s.repo.Create(ctx, p)
+	} else {
+		err = s.repo.Update(ctx, &p)
+	}
+	if err != nil {
+		return false, []string{err.Error()}
+	}
+
+	return existing == nil, nil
+}
+
+func (s *importService) updateJob(id string, fn func(*product.ImportJob)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job, ok := s.jobs[id]; ok {
+		fn(job)
+	}
+}
+
+// pruneJobs forgets finished jobs older than importJobLifetime. The caller
+// must hold s.mu.
+func (s *importService) pruneJobs() {
+	cutoff := time.Now().Add(-importJobLifetime)
+	for id, job := range s.jobs {
+		if job.FinishedAt != nil && job.FinishedAt.Before(cutoff) {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// resolveColumns maps each product field to its column index using the
+// header row. SKU, name and price are required.
+func resolveColumns(rows [][]string, mapping map[string]string) (map[string]int, error) {
+	if len(rows) == 0 {
+		return nil, ErrEmptyImport
+	}
+
+	for field := range mapping {
+		if !isImportColumn(field) {
+			return nil, fmt.Errorf("unknown field %q in column mapping", field)
+		}
+	}
+
+	headers := make(map[string]int, len(rows[0]))
+	for i, h := range rows[0] {
+		headers[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+
+	columns := make(map[string]int)
+	for _, field := range product.ImportColumns {
+		header := field
+		if mapped, ok := mapping[field]; ok {
+			header = mapped
+		}
+		if idx, ok := headers[strings.ToLower(strings.TrimSpace(header))]; ok {
+			columns[field] = idx
+		}
+	}
+
+	for _, required := range []string{"sku", "name", "price"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing column for required field %q", required)
+		}
+	}
+
+	return columns, nil
+}
+
+func isImportColumn(field string) bool {
+	for _, c := range product.ImportColumns {
+		if c == field {
+			return true
+		}
+	}
+	return false
+}
+
+func cell(row []string, idx int) string {
+	if idx < 0 || idx >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[idx])
+}
+
+func isBlankRow(row []string) bool {
+	for _, c := range row {
+		if strings.TrimSpace(c) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/auth"
+	"github.com/rkweber-max/checkout-backend/internal/product"
+	"github.com/rkweber-max/checkout-backend/internal/product/repository"
+)
+
+var (
+	ErrScheduleNotFound       = errors.New("scheduled price not found")
+	ErrScheduleNotCancellable = errors.New("only pending or active scheduled prices can be cancelled")
+	ErrScheduleOverlap        = errors.New("scheduled price overlaps another scheduled price of this product")
+)
+
+type ScheduleInput struct {
+	Price    float64
+	StartsAt time.Time
+	EndsAt   *time.Time
+}
+
+type PriceService interface {
+	History(ctx context.Context, productID int64) ([]product.PriceChange, error)
+	Schedule(ctx context.Context, productID int64, input ScheduleInput) (*product.ScheduledPrice, error)
+	ListSchedules(ctx context.Context, productID int64) ([]product.ScheduledPrice, error)
+	CancelSchedule(ctx context.Context, productID, scheduleID int64) error
+	EffectivePrice(ctx context.Context, p product.Product, at time.Time) (float64, error)
+	ApplyDueSchedules(ctx context.Context) error
+}
+
+type priceService struct {
+	repo        repository.PriceRepository
+	productRepo repository.ProductRepository
+}
+
+func NewPriceService(repo repository.PriceRepository, productRepo repository.ProductRepository) PriceService {
+	return &priceService{repo: repo, productRepo: productRepo}
+}
+
+func (s *priceService) History(ctx context.Context, productID int64) ([]product.PriceChange, error) {
+	return s.repo.FindHistory(ctx, productID)
+}
+
+func (s *priceService) Schedule(ctx context.Context, productID int64, input ScheduleInput) (*product.ScheduledPrice, error) {
+	p, err := s.productRepo.FindByID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrProductNotFound
+	}
+
+	if input.Price < 0 {
+		return nil, errors.New("product price cannot be negative")
+	}
+	if input.StartsAt.Before(time.Now()) {
+		return nil, errors.New("scheduled price must start in the future")
+	}
+	if input.EndsAt != nil && !input.EndsAt.After(input.StartsAt) {
+		return nil, errors.New("revert date must be after the start date")
+	}
+
+	schedule := &product.ScheduledPrice{
+		ProductID: productID,
+		Price:     input.Price,
+		StartsAt:  input.StartsAt,
+		EndsAt:    input.EndsAt,
+		Status:    product.SchedulePending,
+		CreatedBy: auth.ActorFromContext(ctx),
+	}
+
+	// Overlapping sales would each restore the price the other one set.
+	existing, err := s.repo.FindSchedules(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	for _, other := range existing {
+		if other.Status != product.SchedulePending && other.Status != product.ScheduleActive {
+			continue
+		}
+		if schedule.Overlaps(other) {
+			return nil, ErrScheduleOverlap
+		}
+	}
+
+	if err := s.repo.CreateSchedule(ctx, schedule); err != nil {
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+func (s *priceService) ListSchedules(ctx context.Context, productID int64) ([]product.ScheduledPrice, error) {
+	return s.repo.FindSchedules(ctx, productID)
+}
+
+// CancelSchedule cancels a pending schedule or ends an active sale early,
+// restoring the price it replaced.
+func (s *priceService) CancelSchedule(ctx context.Context, productID, scheduleID int64) error {
+	schedule, err := s.repo.FindScheduleByID(ctx, scheduleID)
+	if err != nil {
+		return err
+	}
+	if schedule == nil || schedule.ProductID != productID {
+		return ErrScheduleNotFound
+	}
+	switch schedule.Status {
+	case product.SchedulePending:
+		schedule.Status = product.ScheduleCancelled
+		return s.repo.UpdateSchedule(ctx, schedule)
+	case product.ScheduleActive:
+		return s.endSale(ctx, schedule, product.ScheduleCancelled)
+	default:
+		return ErrScheduleNotCancellable
+	}
+}
+
+// EffectivePrice returns the price of the product at the given time. A
+// schedule whose window covers that time wins over the stored price, so the
+// result is correct even if the scheduler has not caught up yet.
+func (s *priceService) EffectivePrice(ctx context.Context, p product.Product, at time.Time) (float64, error) {
+	schedule, err := s.repo.FindCoveringSchedule(ctx, p.ID, at)
+	if err != nil {
+		return 0, err
+	}
+	if schedule != nil {
+		return schedule.Price, nil
+	}
+	return p.Price, nil
+}
+
+// ApplyDueSchedules starts pending schedules whose start time has passed and
+// reverts sales whose end time has passed. It is run periodically.
+func (s *priceService) ApplyDueSchedules(ctx context.Context) error {
+	now := time.Now()
+	schedules, err := s.repo.FindDueSchedules(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	for i := range schedules {
+		schedule := &schedules[i]
+		if err := s.applySchedule(ctx, schedule, now); err != nil {
+			log.Printf("Failed to apply scheduled price %d for product %d: %v", schedule.ID, schedule.ProductID, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *priceService) applySchedule(ctx context.Context, schedule *product.ScheduledPrice, now time.Time) error {
+	if schedule.Status == product.SchedulePending {
+		p, err := s.productRepo.FindByID(ctx, schedule.ProductID)
+		if err != nil {
+			return err
+		}
+		if p == nil {
+			schedule.Status = product.ScheduleCancelled
+			return s.repo.UpdateSchedule(ctx, schedule)
+		}
+
+		previous := p.Price
+		schedule.PreviousPrice = &previous
+		schedule.Status = product.ScheduleActive
+		if schedule.EndsAt == nil {
+			schedule.Status = product.ScheduleCompleted
+		}
+		err = s.productRepo.UpdateScheduledPrice(product.WithPriceSource(ctx, product.PriceSourceSchedule), schedule, schedule.Price)
+		if err != nil {
+			return err
+		}
+
+		// A sale that already ended while the scheduler was down is
+		// reverted right away.
+		if schedule.EndsAt == nil || schedule.EndsAt.After(now) {
+			return nil
+		}
+	}
+
+	return s.endSale(ctx, schedule, product.ScheduleCompleted)
+}
+
+// endSale restores the price a sale replaced and closes the schedule with
+// the given status. The price is left alone if it no longer is the sale
+// price, since it was changed by hand during the sale.
+func (s *priceService) endSale(ctx context.Context, schedule *product.ScheduledPrice, status product.ScheduleStatus) error {
+	schedule.Status = status
+	if schedule.PreviousPrice == nil {
+		return s.repo.UpdateSchedule(ctx, schedule)
+	}
+
+	p, err := s.productRepo.FindByID(ctx, schedule.ProductID)
+	if err != nil {
+		return err
+	}
+	if p == nil || p.Price != schedule.Price {
+		return s.repo.UpdateSchedule(ctx, schedule)
+	}
+	ctx = product.WithPriceSource(ctx, product.PriceSourceScheduleRevert)
+	return s.productRepo.UpdateScheduledPrice(ctx, schedule, *schedule.PreviousPrice)
+}
@@ -0,0 +1,375 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/product"
+	"github.com/rkweber-max/checkout-backend/internal/product/repository"
+)
+
+var _ repository.PriceRepository = (*fakePrices)(nil)
+
+// fakePrices is an in-memory repository.PriceRepository.
+type fakePrices struct {
+	schedules map[int64]*product.ScheduledPrice
+	nextID    int64
+}
+
+func newFakePrices() *fakePrices {
+	return &fakePrices{schedules: make(map[int64]*product.ScheduledPrice)}
+}
+
+func (f *fakePrices) FindHistory(ctx context.Context, productID int64) ([]product.PriceChange, error) {
+	return nil, nil
+}
+
+func (f *fakePrices) CreateSchedule(ctx context.Context, s *product.ScheduledPrice) error {
+	f.nextID++
+	s.ID = f.nextID
+	stored := *s
+	f.schedules[s.ID] = &stored
+	return nil
+}
+
+func (f *fakePrices) FindSchedules(ctx context.Context, productID int64) ([]product.ScheduledPrice, error) {
+	var schedules []product.ScheduledPrice
+	for _, s := range f.schedules {
+		if s.ProductID == productID {
+			schedules = append(schedules, *s)
+		}
+	}
+	return schedules, nil
+}
+
+func (f *fakePrices) FindScheduleByID(ctx context.Context, id int64) (*product.ScheduledPrice, error) {
+	if s, ok := f.schedules[id]; ok {
+		found := *s
+		return &found, nil
+	}
+	return nil, nil
+}
+
+func (f *fakePrices) FindDueSchedules(ctx context.Context, now time.Time) ([]product.ScheduledPrice, error) {
+	var due []product.ScheduledPrice
+	for _, s := range f.schedules {
+		pending := s.Status == product.SchedulePending && !s.StartsAt.After(now)
+		ending := s.Status == product.ScheduleActive && s.EndsAt != nil && !s.EndsAt.After(now)
+		if pending || ending {
+			due = append(due, *s)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].StartsAt.Before(due[j].StartsAt) })
+	return due, nil
+}
+
+func (f *fakePrices) FindCoveringSchedule(ctx context.Context, productID int64, at time.Time) (*product.ScheduledPrice, error) {
+	var covering *product.ScheduledPrice
+	for _, s := range f.schedules {
+		if s.ProductID == productID && s.Covers(at) && (covering == nil || s.StartsAt.After(covering.StartsAt)) {
+			found := *s
+			covering = &found
+		}
+	}
+	return covering, nil
+}
+
+func (f *fakePrices) UpdateSchedule(ctx context.Context, s *product.ScheduledPrice) error {
+	stored := f.schedules[s.ID]
+	stored.PreviousPrice = s.PreviousPrice
+	stored.Status = s.Status
+	return nil
+}
+
+// fakeProducts keeps products in memory and records the source of every
+// price change. Only the methods the price service uses are implemented.
+// Scheduled price changes are saved to prices; with fail set they fail
+// without writing anything, like a rolled back transaction.
+type fakeProducts struct {
+	repository.ProductRepository
+
+	products map[int64]*product.Product
+	sources  []product.PriceSource
+	prices   *fakePrices
+	fail     bool
+}
+
+func newFakeProducts(products ...product.Product) *fakeProducts {
+	f := &fakeProducts{products: make(map[int64]*product.Product)}
+	for i := range products {
+		f.products[products[i].ID] = &products[i]
+	}
+	return f
+}
+
+func (f *fakeProducts) FindByID(ctx context.Context, id int64) (*product.Product, error) {
+	if p, ok := f.products[id]; ok {
+		found := *p
+		return &found, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeProducts) UpdatePrice(ctx context.Context, id int64, price float64) error {
+	f.products[id].Price = price
+	f.sources = append(f.sources, product.PriceSourceFromContext(ctx))
+	return nil
+}
+
+func (f *fakeProducts) UpdateScheduledPrice(ctx context.Context, s *product.ScheduledPrice, price float64) error {
+	if f.fail {
+		return errors.New("connection reset")
+	}
+	f.prices.UpdateSchedule(ctx, s)
+	return f.UpdatePrice(ctx, s.ProductID, price)
+}
+
+func (f *fakeProducts) price(id int64) float64 {
+	return f.products[id].Price
+}
+
+type priceFixture struct {
+	prices   *fakePrices
+	products *fakeProducts
+	service  PriceService
+}
+
+func newPriceFixture() *priceFixture {
+	f := &priceFixture{
+		prices:   newFakePrices(),
+		products: newFakeProducts(product.Product{ID: 1, Name: "Caneca", Price: 100}),
+	}
+	f.products.prices = f.prices
+	f.service = NewPriceService(f.prices, f.products)
+	return f
+}
+
+// addSale stores a pending sale of product 1 directly, since Schedule only
+// accepts start times in the future.
+func (f *priceFixture) addSale(price float64, starts, ends time.Time) *product.ScheduledPrice {
+	s := &product.ScheduledPrice{ProductID: 1, Price: price, StartsAt: starts, EndsAt: &ends, Status: product.SchedulePending}
+	f.prices.CreateSchedule(context.Background(), s)
+	return s
+}
+
+func (f *priceFixture) status(id int64) product.ScheduleStatus {
+	return f.prices.schedules[id].Status
+}
+
+func TestApplyDueSchedulesStartsAndEndsSale(t *testing.T) {
+	f := newPriceFixture()
+	ctx := context.Background()
+	now := time.Now()
+	sale := f.addSale(80, now.Add(-time.Minute), now.Add(time.Hour))
+
+	f.service.ApplyDueSchedules(ctx)
+	if got := f.products.price(1); got != 80 {
+		t.Fatalf("price during the sale = %v, want 80", got)
+	}
+	if got := f.status(sale.ID); got != product.ScheduleActive {
+		t.Fatalf("status = %q, want active", got)
+	}
+	if prev := f.prices.schedules[sale.ID].PreviousPrice; prev == nil || *prev != 100 {
+		t.Fatalf("previous price = %v, want 100", prev)
+	}
+
+	ended := now.Add(-time.Second)
+	f.prices.schedules[sale.ID].EndsAt = &ended
+	f.service.ApplyDueSchedules(ctx)
+
+	if got := f.products.price(1); got != 100 {
+		t.Errorf("price after the sale = %v, want 100", got)
+	}
+	if got := f.status(sale.ID); got != product.ScheduleCompleted {
+		t.Errorf("status = %q, want completed", got)
+	}
+	want := []product.PriceSource{product.PriceSourceSchedule, product.PriceSourceScheduleRevert}
+	if len(f.products.sources) != 2 || f.products.sources[0] != want[0] || f.products.sources[1] != want[1] {
+		t.Errorf("price change sources = %v, want %v", f.products.sources, want)
+	}
+}
+
+func TestApplyDueSchedulesRevertsMissedSale(t *testing.T) {
+	f := newPriceFixture()
+	now := time.Now()
+	sale := f.addSale(80, now.Add(-2*time.Hour), now.Add(-time.Hour))
+
+	f.service.ApplyDueSchedules(context.Background())
+
+	if got := f.products.price(1); got != 100 {
+		t.Errorf("price = %v, want 100", got)
+	}
+	if got := f.status(sale.ID); got != product.ScheduleCompleted {
+		t.Errorf("status = %q, want completed", got)
+	}
+}
+
+func TestApplyDueSchedulesKeepsManualChangeMadeDuringSale(t *testing.T) {
+	f := newPriceFixture()
+	ctx := context.Background()
+	now := time.Now()
+	sale := f.addSale(80, now.Add(-time.Minute), now.Add(time.Hour))
+	f.service.ApplyDueSchedules(ctx)
+
+	f.products.UpdatePrice(ctx, 1, 90)
+
+	ended := now.Add(-time.Second)
+	f.prices.schedules[sale.ID].EndsAt = &ended
+	f.service.ApplyDueSchedules(ctx)
+
+	if got := f.products.price(1); got != 90 {
+		t.Errorf("price = %v, want the manual 90", got)
+	}
+	if got := f.status(sale.ID); got != product.ScheduleCompleted {
+		t.Errorf("status = %q, want completed", got)
+	}
+}
+
+func TestApplyDueSchedulesRetriesFailedWrite(t *testing.T) {
+	f := newPriceFixture()
+	ctx := context.Background()
+	now := time.Now()
+	sale := f.addSale(80, now.Add(-time.Minute), now.Add(time.Hour))
+
+	f.products.fail = true
+	f.service.ApplyDueSchedules(ctx)
+	if got := f.products.price(1); got != 100 {
+		t.Fatalf("price after a failed start = %v, want 100", got)
+	}
+	if got := f.status(sale.ID); got != product.SchedulePending {
+		t.Fatalf("status after a failed start = %q, want pending", got)
+	}
+
+	f.products.fail = false
+	f.service.ApplyDueSchedules(ctx)
+	if got := f.products.price(1); got != 80 {
+		t.Fatalf("price after the retry = %v, want 80", got)
+	}
+
+	ended := now.Add(-time.Second)
+	f.prices.schedules[sale.ID].EndsAt = &ended
+	f.products.fail = true
+	f.service.ApplyDueSchedules(ctx)
+	if got := f.status(sale.ID); got != product.ScheduleActive {
+		t.Fatalf("status after a failed revert = %q, want active", got)
+	}
+
+	f.products.fail = false
+	f.service.ApplyDueSchedules(ctx)
+	if got := f.products.price(1); got != 100 {
+		t.Errorf("price after the retried revert = %v, want 100", got)
+	}
+	if got := f.status(sale.ID); got != product.ScheduleCompleted {
+		t.Errorf("status = %q, want completed", got)
+	}
+}
+
+func TestApplyDueSchedulesPermanentChange(t *testing.T) {
+	f := newPriceFixture()
+	s := &product.ScheduledPrice{ProductID: 1, Price: 120, StartsAt: time.Now().Add(-time.Minute), Status: product.SchedulePending}
+	f.prices.CreateSchedule(context.Background(), s)
+
+	f.service.ApplyDueSchedules(context.Background())
+
+	if got := f.products.price(1); got != 120 {
+		t.Errorf("price = %v, want 120", got)
+	}
+	if got := f.status(s.ID); got != product.ScheduleCompleted {
+		t.Errorf("status = %q, want completed", got)
+	}
+}
+
+func TestScheduleRejectsOverlappingSales(t *testing.T) {
+	f := newPriceFixture()
+	ctx := context.Background()
+	start := time.Now().Add(24 * time.Hour)
+	end := start.Add(48 * time.Hour)
+
+	first, err := f.service.Schedule(ctx, 1, ScheduleInput{Price: 80, StartsAt: start, EndsAt: &end})
+	if err != nil {
+		t.Fatalf("Schedule: %v", err)
+	}
+
+	overlapEnd := end.Add(time.Hour)
+	_, err = f.service.Schedule(ctx, 1, ScheduleInput{Price: 70, StartsAt: start.Add(time.Hour), EndsAt: &overlapEnd})
+	if !errors.Is(err, ErrScheduleOverlap) {
+		t.Fatalf("overlapping Schedule error = %v, want ErrScheduleOverlap", err)
+	}
+
+	laterEnd := end.Add(24 * time.Hour)
+	if _, err := f.service.Schedule(ctx, 1, ScheduleInput{Price: 70, StartsAt: end, EndsAt: &laterEnd}); err != nil {
+		t.Fatalf("back-to-back Schedule: %v", err)
+	}
+
+	if err := f.service.CancelSchedule(ctx, 1, first.ID); err != nil {
+		t.Fatalf("CancelSchedule: %v", err)
+	}
+	if _, err := f.service.Schedule(ctx, 1, ScheduleInput{Price: 70, StartsAt: start.Add(time.Hour), EndsAt: &overlapEnd}); !errors.Is(err, ErrScheduleOverlap) {
+		t.Fatalf("Schedule overlapping the back-to-back sale error = %v, want ErrScheduleOverlap", err)
+	}
+	shortEnd := end.Add(-time.Hour)
+	if _, err := f.service.Schedule(ctx, 1, ScheduleInput{Price: 70, StartsAt: start, EndsAt: &shortEnd}); err != nil {
+		t.Fatalf("Schedule over a cancelled sale: %v", err)
+	}
+}
+
+func TestCancelSchedule(t *testing.T) {
+	f := newPriceFixture()
+	ctx := context.Background()
+	now := time.Now()
+
+	pending := f.addSale(70, now.Add(time.Hour), now.Add(2*time.Hour))
+	if err := f.service.CancelSchedule(ctx, 1, pending.ID); err != nil {
+		t.Fatalf("cancel pending: %v", err)
+	}
+	if got := f.status(pending.ID); got != product.ScheduleCancelled {
+		t.Errorf("pending status = %q, want cancelled", got)
+	}
+
+	active := f.addSale(80, now.Add(-time.Minute), now.Add(time.Hour))
+	f.service.ApplyDueSchedules(ctx)
+	if err := f.service.CancelSchedule(ctx, 1, active.ID); err != nil {
+		t.Fatalf("cancel active: %v", err)
+	}
+	if got := f.products.price(1); got != 100 {
+		t.Errorf("price after ending the sale early = %v, want 100", got)
+	}
+	if got := f.status(active.ID); got != product.ScheduleCancelled {
+		t.Errorf("active status = %q, want cancelled", got)
+	}
+
+	if err := f.service.CancelSchedule(ctx, 1, active.ID); !errors.Is(err, ErrScheduleNotCancellable) {
+		t.Errorf("cancel twice error = %v, want ErrScheduleNotCancellable", err)
+	}
+	if err := f.service.CancelSchedule(ctx, 2, pending.ID); !errors.Is(err, ErrScheduleNotFound) {
+		t.Errorf("cancel through another product error = %v, want ErrScheduleNotFound", err)
+	}
+}
+
+func TestEffectivePrice(t *testing.T) {
+	f := newPriceFixture()
+	ctx := context.Background()
+	now := time.Now()
+	f.addSale(80, now.Add(time.Hour), now.Add(2*time.Hour))
+	p := *f.products.products[1]
+
+	tests := map[time.Time]float64{
+		now:                            100,
+		now.Add(90 * time.Minute):      80,
+		now.Add(3 * time.Hour):         100,
+		now.Add(time.Hour).Add(-1):     100,
+		now.Add(2 * time.Hour).Add(-1): 80,
+	}
+	for at, want := range tests {
+		got, err := f.service.EffectivePrice(ctx, p, at)
+		if err != nil {
+			t.Fatalf("EffectivePrice: %v", err)
+		}
+		if got != want {
+			t.Errorf("EffectivePrice at %v = %v, want %v", at.Sub(now), got, want)
+		}
+	}
+}
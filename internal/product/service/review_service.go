@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/auth"
+	"github.com/rkweber-max/checkout-backend/internal/product"
+	"github.com/rkweber-max/checkout-backend/internal/product/repository"
+)
+
+var (
+	ErrReviewNotFound  = errors.New("review not found")
+	ErrDuplicateReview = errors.New("customer already reviewed this product")
+)
+
+const maxReviewBodyLength = 5000
+
+type ReviewService interface {
+	Create(ctx context.Context, r *product.Review) error
+	UpdateOwn(ctx context.Context, r *product.Review) (*product.Review, error)
+	DeleteOwn(ctx context.Context, productID int64, userID uint) error
+	ListApproved(ctx context.Context, productID int64) ([]product.Review, error)
+	Pending(ctx context.Context) ([]product.Review, error)
+	Approve(ctx context.Context, id int64) (*product.Review, error)
+	Reject(ctx context.Context, id int64, reason string) (*product.Review, error)
+	Summary(ctx context.Context, productID int64) (product.RatingSummary, error)
+}
+
+type reviewService struct {
+	repo        repository.ReviewRepository
+	productRepo repository.ProductRepository
+}
+
+func NewReviewService(repo repository.ReviewRepository, productRepo repository.ProductRepository) ReviewService {
+	return &reviewService{repo: repo, productRepo: productRepo}
+}
+
+// Create submits the customer's review of a product for moderation. A
+// customer reviews each product once; later changes go through UpdateOwn.
+func (s *reviewService) Create(ctx context.Context, r *product.Review) error {
+	if err := validateReview(r); err != nil {
+		return err
+	}
+
+	p, err := s.productRepo.FindByID(ctx, r.ProductID)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return ErrProductNotFound
+	}
+
+	existing, err := s.repo.FindByProductAndUser(ctx, r.ProductID, r.UserID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return ErrDuplicateReview
+	}
+
+	if r.Verified, err = s.repo.HasPurchased(ctx, r.UserID, r.ProductID); err != nil {
+		return err
+	}
+	r.Status = product.ReviewPending
+
+	return s.repo.Create(ctx, r)
+}
+
+// UpdateOwn replaces the rating and text of the customer's review. The
+// edited review goes back to the moderation queue.
+func (s *reviewService) UpdateOwn(ctx context.Context, r *product.Review) (*product.Review, error) {
+	if err := validateReview(r); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.repo.FindByProductAndUser(ctx, r.ProductID, r.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, ErrReviewNotFound
+	}
+
+	existing.Rating = r.Rating
+	existing.Title = r.Title
+	existing.Body = r.Body
+	if existing.Verified, err = s.repo.HasPurchased(ctx, r.UserID, r.ProductID); err != nil {
+		return nil, err
+	}
+	existing.Status = product.ReviewPending
+	existing.RejectionReason = ""
+	existing.ModeratedBy = nil
+	existing.ModeratedAt = nil
+
+	if err := s.repo.Update(ctx, existing); err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+func (s *reviewService) DeleteOwn(ctx context.Context, productID int64, userID uint) error {
+	existing, err := s.repo.FindByProductAndUser(ctx, productID, userID)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return ErrReviewNotFound
+	}
+	return s.repo.Delete(ctx, existing.ID)
+}
+
+func (s *reviewService) ListApproved(ctx context.Context, productID int64) ([]product.Review, error) {
+	p, err := s.productRepo.FindByID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrProductNotFound
+	}
+
+	return s.repo.FindByProduct(ctx, productID, product.ReviewApproved)
+}
+
+// Pending returns the moderation queue.
+func (s *reviewService) Pending(ctx context.Context) ([]product.Review, error) {
+	return s.repo.FindByStatus(ctx, product.ReviewPending)
+}
+
+func (s *reviewService) Approve(ctx context.Context, id int64) (*product.Review, error) {
+	return s.moderate(ctx, id, product.ReviewApproved, "")
+}
+
+func (s *reviewService) Reject(ctx context.Context, id int64, reason string) (*product.Review, error) {
+	return s.moderate(ctx, id, product.ReviewRejected, strings.TrimSpace(reason))
+}
+
+func (s *reviewService) Summary(ctx context.Context, productID int64) (product.RatingSummary, error) {
+	counts, err := s.repo.RatingCounts(ctx, productID)
+	if err != nil {
+		return product.RatingSummary{}, err
+	}
+	return product.NewRatingSummary(counts), nil
+}
+
+// moderate records the decision of the employee acting in ctx. A decision
+// may be revisited, e.g. to take down a review approved by mistake.
+func (s *reviewService) moderate(ctx context.Context, id int64, status product.ReviewStatus, reason string) (*product.Review, error) {
+	review, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if review == nil {
+		return nil, ErrReviewNotFound
+	}
+
+	now := time.Now()
+	review.Status = status
+	review.RejectionReason = reason
+	review.ModeratedBy = auth.ActorFromContext(ctx)
+	review.ModeratedAt = &now
+
+	if err := s.repo.Update(ctx, review); err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+func validateReview(r *product.Review) error {
+	r.Title = strings.TrimSpace(r.Title)
+	r.Body = strings.TrimSpace(r.Body)
+
+	if r.Rating < 1 || r.Rating > 5 {
+		return errors.New("rating must be between 1 and 5 stars")
+	}
+	if len(r.Body) > maxReviewBodyLength {
+		return errors.New("review text is too long")
+	}
+	return nil
+}
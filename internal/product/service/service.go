@@ -2,17 +2,30 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 
 	"github.com/rkweber-max/checkout-backend/internal/product"
 	"github.com/rkweber-max/checkout-backend/internal/product/repository"
+	"github.com/rkweber-max/checkout-backend/pkg/gtin"
+	"github.com/rkweber-max/checkout-backend/pkg/mergepatch"
+)
+
+var (
+	ErrProductNotFound = errors.New("product not found")
+	ErrDuplicateGTIN   = errors.New("another product already uses this GTIN")
+	ErrVersionConflict = repository.ErrVersionConflict
 )
 
 type ProductService interface {
 	Create(ctx context.Context, p product.Product) (int64, error)
 	GetAll(ctx context.Context) ([]product.Product, error)
 	GetByID(ctx context.Context, id int64) (*product.Product, error)
-	Update(ctx context.Context, p product.Product) error
+	GetByOwner(ctx context.Context, ownerID uint) ([]product.Product, error)
+	GetByGTIN(ctx context.Context, code string) (*product.Product, error)
+	Update(ctx context.Context, p *product.Product) error
+	Patch(ctx context.Context, id int64, expectedVersion *int64, patch []byte) (*product.Product, error)
 	Delete(ctx context.Context, id int64) error
 }
 
@@ -25,14 +38,17 @@ func NewProductService(repo repository.ProductRepository) ProductService {
 }
 
 func (s *productService) Create(ctx context.Context, p product.Product) (int64, error) {
-	if p.Name == "" {
-		return 0, errors.New("product name cannot be empty")
+	if err := validateProduct(&p); err != nil {
+		return 0, err
 	}
-
-	if p.Price < 0 {
-		return 0, errors.New("product price cannot be negative")
+	if err := checkGTIN(ctx, s.repo, p); err != nil {
+		return 0, err
 	}
 
+	// Attributes are validated against the product's categories, which
+	// are assigned after creation, so they are set separately.
+	p.Attributes = nil
+
 	return s.repo.Create(ctx, p)
 }
 
@@ -44,18 +60,147 @@ func (s *productService) GetByID(ctx context.Context, id int64) (*product.Produc
 	return s.repo.FindByID(ctx, id)
 }
 
-func (s *productService) Update(ctx context.Context, p product.Product) error {
-	if p.Name == "" {
-		return errors.New("product name cannot be empty")
+// GetByGTIN looks a product up by a scanned barcode.
+func (s *productService) GetByGTIN(ctx context.Context, code string) (*product.Product, error) {
+	normalized, err := gtin.Normalize(code)
+	if err != nil {
+		return nil, err
 	}
+	return s.repo.FindByGTIN(ctx, normalized)
+}
 
-	if p.Price < 0 {
-		return errors.New("product price cannot be negative")
+func (s *productService) GetByOwner(ctx context.Context, ownerID uint) ([]product.Product, error) {
+	return s.repo.FindByOwner(ctx, ownerID)
+}
+
+// Update replaces the product. p.Version must be the version the caller
+// read; it is incremented on success.
+func (s *productService) Update(ctx context.Context, p *product.Product) error {
+	if err := validateProduct(p); err != nil {
+		return err
+	}
+	if err := checkGTIN(ctx, s.repo, *p); err != nil {
+		return err
+	}
+
+	existing, err := s.repo.FindByID(ctx, p.ID)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return ErrProductNotFound
 	}
 
 	return s.repo.Update(ctx, p)
 }
 
+// Patch applies a JSON Merge Patch to the product. When expectedVersion is
+// set the patch is rejected if the product has moved on since.
+func (s *productService) Patch(ctx context.Context, id int64, expectedVersion *int64, patch []byte) (*product.Product, error) {
+	existing, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, ErrProductNotFound
+	}
+	if expectedVersion != nil && *expectedVersion != existing.Version {
+		return nil, ErrVersionConflict
+	}
+
+	original, err := json.Marshal(existing)
+	if err != nil {
+		return nil, err
+	}
+
+	merged, err := mergepatch.Apply(original, patch)
+	if err != nil {
+		return nil, err
+	}
+
+	var updated product.Product
+	if err := json.Unmarshal(merged, &updated); err != nil {
+		return nil, err
+	}
+
+	// Identity and version are not client-editable, and attributes have
+	// their own endpoint.
+	updated.ID = existing.ID
+	updated.Version = existing.Version
+	updated.Attributes = existing.Attributes
+
+	if err := validateProduct(&updated); err != nil {
+		return nil, err
+	}
+	if err := checkGTIN(ctx, s.repo, updated); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Update(ctx, &updated); err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+// Delete moves the product to the trash. It disappears from listings and
+// checkout but can be restored until it is purged.
 func (s *productService) Delete(ctx context.Context, id int64) error {
+	existing, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return ErrProductNotFound
+	}
+
 	return s.repo.Delete(ctx, id)
 }
+
+// checkGTIN rejects a GTIN that is already used by another product,
+// including one in the trash.
+func checkGTIN(ctx context.Context, repo repository.ProductRepository, p product.Product) error {
+	if p.GTIN == "" {
+		return nil
+	}
+
+	existing, err := repo.FindByGTIN(ctx, p.GTIN)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.ID != p.ID {
+		return ErrDuplicateGTIN
+	}
+
+	trashed, err := repo.FindDeletedByGTIN(ctx, p.GTIN)
+	if err != nil {
+		return err
+	}
+	if trashed != nil && trashed.ID != p.ID {
+		return fmt.Errorf("%w: product %d in the trash; restore it first", ErrDuplicateGTIN, trashed.ID)
+	}
+	return nil
+}
+
+// validateProduct checks the product fields and normalizes its GTIN. It
+// holds the rules every product write must satisfy, whether it comes from
+// the API or from a spreadsheet import.
+func validateProduct(p *product.Product) error {
+	if p.Name == "" {
+		return errors.New("product name cannot be empty")
+	}
+
+	if p.Price < 0 {
+		return errors.New("product price cannot be negative")
+	}
+
+	if p.GTIN != "" {
+		normalized, err := gtin.Normalize(p.GTIN)
+		if err != nil {
+			return err
+		}
+		p.GTIN = normalized
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/product"
+	"github.com/rkweber-max/checkout-backend/internal/product/repository"
+	"github.com/rkweber-max/checkout-backend/pkg/config"
+)
+
+const defaultTrashRetentionDays = 30
+
+type TrashService interface {
+	List(ctx context.Context) ([]product.Product, error)
+	Restore(ctx context.Context, id int64) error
+	Purge(ctx context.Context) error
+}
+
+type trashService struct {
+	repo      repository.ProductRepository
+	images    ImageService
+	retention time.Duration
+}
+
+func NewTrashService(repo repository.ProductRepository, images ImageService, cfg *config.Config) TrashService {
+	days := cfg.ProductTrashRetentionDays
+	if days <= 0 {
+		days = defaultTrashRetentionDays
+	}
+
+	return &trashService{
+		repo:      repo,
+		images:    images,
+		retention: time.Duration(days) * 24 * time.Hour,
+	}
+}
+
+func (s *trashService) List(ctx context.Context) ([]product.Product, error) {
+	return s.repo.FindDeleted(ctx)
+}
+
+func (s *trashService) Restore(ctx context.Context, id int64) error {
+	restored, err := s.repo.Restore(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !restored {
+		return ErrProductNotFound
+	}
+	return nil
+}
+
+// Purge permanently removes products that have been in the trash longer
+// than the retention period. Products referenced by any order are kept so
+// order history stays intact.
+func (s *trashService) Purge(ctx context.Context) error {
+	products, err := s.repo.FindPurgeable(ctx, time.Now().Add(-s.retention))
+	if err != nil {
+		return err
+	}
+
+	for _, p := range products {
+		if err := s.purge(ctx, p.ID); err != nil {
+			log.Printf("Failed to purge product %d: %v", p.ID, err)
+			continue
+		}
+		log.Printf("Purged product %d (%s)", p.ID, p.Name)
+	}
+
+	return nil
+}
+
+func (s *trashService) purge(ctx context.Context, id int64) error {
+	images, err := s.images.List(ctx, id)
+	if err != nil {
+		return err
+	}
+	for _, img := range images {
+		if err := s.images.Delete(ctx, id, img.ID); err != nil {
+			return err
+		}
+	}
+
+	return s.repo.Purge(ctx, id)
+}
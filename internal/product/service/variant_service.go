@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rkweber-max/checkout-backend/internal/product"
+	"github.com/rkweber-max/checkout-backend/internal/product/repository"
+	"github.com/rkweber-max/checkout-backend/pkg/gtin"
+)
+
+var (
+	ErrVariantNotFound    = errors.New("variant not found")
+	ErrNoOptionTypes      = errors.New("product has no option types to generate variants from")
+	ErrOptionTypeNotFound = errors.New("option type not found")
+	ErrDuplicateBarcode   = errors.New("another variant or product already uses this barcode")
+)
+
+// GenerateVariantsInput holds the defaults applied to newly generated
+// variants. Variants start without stock; it is booked into warehouses
+// through the inventory endpoints.
+type GenerateVariantsInput struct {
+	Price *float64
+}
+
+type VariantService interface {
+	AddOptionType(ctx context.Context, productID int64, name string, values []string) (*product.OptionType, error)
+	ListOptionTypes(ctx context.Context, productID int64) ([]product.OptionType, error)
+	DeleteOptionType(ctx context.Context, productID, optionTypeID int64) error
+	ListVariants(ctx context.Context, productID int64) ([]product.Variant, error)
+	GenerateVariants(ctx context.Context, productID int64, input GenerateVariantsInput) ([]product.Variant, error)
+	UpdateVariant(ctx context.Context, v *product.Variant) error
+	DeleteVariant(ctx context.Context, productID, variantID int64) error
+}
+
+type variantService struct {
+	repo        repository.VariantRepository
+	productRepo repository.ProductRepository
+}
+
+func NewVariantService(repo repository.VariantRepository, productRepo repository.ProductRepository) VariantService {
+	return &variantService{repo: repo, productRepo: productRepo}
+}
+
+func (s *variantService) AddOptionType(ctx context.Context, productID int64, name string, values []string) (*product.OptionType, error) {
+	if err := s.ensureProduct(ctx, productID); err != nil {
+		return nil, err
+	}
+
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return nil, errors.New("option type name cannot be empty")
+	}
+
+	existing, err := s.repo.FindOptionTypes(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	option := &product.OptionType{ProductID: productID, Name: name, Position: len(existing)}
+	seen := make(map[string]bool)
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" || seen[value] {
+			continue
+		}
+		seen[value] = true
+		option.Values = append(option.Values, product.OptionValue{Value: value, Position: len(option.Values)})
+	}
+	if len(option.Values) == 0 {
+		return nil, errors.New("option type must have at least one value")
+	}
+
+	if err := s.repo.CreateOptionType(ctx, option); err != nil {
+		return nil, err
+	}
+
+	return option, nil
+}
+
+func (s *variantService) ListOptionTypes(ctx context.Context, productID int64) ([]product.OptionType, error) {
+	return s.repo.FindOptionTypes(ctx, productID)
+}
+
+func (s *variantService) DeleteOptionType(ctx context.Context, productID, optionTypeID int64) error {
+	options, err := s.repo.FindOptionTypes(ctx, productID)
+	if err != nil {
+		return err
+	}
+
+	for _, o := range options {
+		if o.ID == optionTypeID {
+			return s.repo.DeleteOptionType(ctx, productID, optionTypeID)
+		}
+	}
+
+	return ErrOptionTypeNotFound
+}
+
+func (s *variantService) ListVariants(ctx context.Context, productID int64) ([]product.Variant, error) {
+	return s.repo.FindByProductID(ctx, productID)
+}
+
+// GenerateVariants creates one variant per combination of option values
+// that does not have a variant yet. Existing variants are left untouched.
+func (s *variantService) GenerateVariants(ctx context.Context, productID int64, input GenerateVariantsInput) ([]product.Variant, error) {
+	if err := s.ensureProduct(ctx, productID); err != nil {
+		return nil, err
+	}
+
+	if input.Price != nil && *input.Price < 0 {
+		return nil, errors.New("variant price cannot be negative")
+	}
+
+	options, err := s.repo.FindOptionTypes(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if len(options) == 0 {
+		return nil, ErrNoOptionTypes
+	}
+
+	existing, err := s.repo.FindByProductID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	taken := make(map[string]bool, len(existing))
+	for _, v := range existing {
+		taken[combinationKey(v.OptionValues)] = true
+	}
+
+	var created []product.Variant
+	for _, combination := range cartesian(options) {
+		if taken[combinationKey(combination)] {
+			continue
+		}
+		created = append(created, product.Variant{
+			ProductID:    productID,
+			SKU:          variantSKU(productID, combination),
+			Price:        input.Price,
+			OptionValues: combination,
+		})
+	}
+
+	if err := s.repo.Create(ctx, created); err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+func (s *variantService) UpdateVariant(ctx context.Context, v *product.Variant) error {
+	existing, err := s.repo.FindByID(ctx, v.ID)
+	if err != nil {
+		return err
+	}
+	if existing == nil || existing.ProductID != v.ProductID {
+		return ErrVariantNotFound
+	}
+
+	v.SKU = strings.TrimSpace(v.SKU)
+	if v.SKU == "" {
+		return errors.New("variant SKU cannot be empty")
+	}
+	if v.Price != nil && *v.Price < 0 {
+		return errors.New("variant price cannot be negative")
+	}
+	if err := s.checkBarcode(ctx, v); err != nil {
+		return err
+	}
+
+	return s.repo.Update(ctx, v)
+}
+
+func (s *variantService) DeleteVariant(ctx context.Context, productID, variantID int64) error {
+	existing, err := s.repo.FindByID(ctx, variantID)
+	if err != nil {
+		return err
+	}
+	if existing == nil || existing.ProductID != productID {
+		return ErrVariantNotFound
+	}
+
+	return s.repo.Delete(ctx, productID, variantID)
+}
+
+// checkBarcode normalizes the variant barcode like a product GTIN and
+// rejects one that a scanner would also read as another variant or as a
+// product, including one in the trash.
+func (s *variantService) checkBarcode(ctx context.Context, v *product.Variant) error {
+	v.Barcode = strings.TrimSpace(v.Barcode)
+	if v.Barcode == "" {
+		return nil
+	}
+
+	normalized, err := gtin.Normalize(v.Barcode)
+	if err != nil {
+		return err
+	}
+	v.Barcode = normalized
+
+	other, err := s.repo.FindByBarcode(ctx, v.Barcode)
+	if err != nil {
+		return err
+	}
+	if other != nil && other.ID != v.ID {
+		return ErrDuplicateBarcode
+	}
+
+	p, err := s.productRepo.FindByGTIN(ctx, v.Barcode)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		p, err = s.productRepo.FindDeletedByGTIN(ctx, v.Barcode)
+		if err != nil {
+			return err
+		}
+	}
+	if p != nil {
+		return ErrDuplicateBarcode
+	}
+	return nil
+}
+
+func (s *variantService) ensureProduct(ctx context.Context, productID int64) error {
+	p, err := s.productRepo.FindByID(ctx, productID)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return ErrProductNotFound
+	}
+	return nil
+}
+
+// cartesian returns every combination picking one value per option type.
+func cartesian(options []product.OptionType) [][]product.OptionValue {
+	combinations := [][]product.OptionValue{{}}
+	for _, o := range options {
+		var next [][]product.OptionValue
+		for _, combination := range combinations {
+			for _, value := range o.Values {
+				extended := append(append([]product.OptionValue{}, combination...), value)
+				next = append(next, extended)
+			}
+		}
+		combinations = next
+	}
+	return combinations
+}
+
+func combinationKey(values []product.OptionValue) string {
+	ids := make([]int64, 0, len(values))
+	for _, v := range values {
+		ids = append(ids, v.ID)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return fmt.Sprint(ids)
+}
+
+func variantSKU(productID int64, values []product.OptionValue) string {
+	parts := []string{fmt.Sprintf("P%d", productID)}
+	for _, v := range values {
+		parts = append(parts, slugify(v.Value, ""))
+	}
+	return strings.ToUpper(strings.Join(parts, "-"))
+}
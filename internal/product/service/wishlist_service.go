@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/product"
+	"github.com/rkweber-max/checkout-backend/internal/product/repository"
+	userRepository "github.com/rkweber-max/checkout-backend/internal/user/repository"
+	"github.com/rkweber-max/checkout-backend/pkg/notify"
+)
+
+var (
+	ErrWishlistItemNotFound = errors.New("product is not in the wishlist")
+	ErrSubscriptionNotFound = errors.New("no back-in-stock subscription for this product")
+	ErrInStock              = errors.New("product is in stock")
+	ErrStockNotTracked      = errors.New("product stock is not tracked")
+)
+
+type WishlistService interface {
+	List(ctx context.Context, userID uint) ([]product.WishlistItem, error)
+	Add(ctx context.Context, userID uint, productID int64) error
+	Remove(ctx context.Context, userID uint, productID int64) error
+	Subscribe(ctx context.Context, userID uint, productID int64, variantID *int64) (*product.StockSubscription, error)
+	Unsubscribe(ctx context.Context, userID uint, productID int64, variantID *int64) error
+	Subscriptions(ctx context.Context, userID uint) ([]product.StockSubscription, error)
+	NotifyBackInStock(ctx context.Context) error
+}
+
+type wishlistService struct {
+	repo        repository.WishlistRepository
+	productRepo repository.ProductRepository
+	variantRepo repository.VariantRepository
+	bundles     BundleService
+	users       userRepository.UserRepository
+	notifier    notify.Notifier
+}
+
+func NewWishlistService(
+	repo repository.WishlistRepository,
+	productRepo repository.ProductRepository,
+	variantRepo repository.VariantRepository,
+	bundles BundleService,
+	users userRepository.UserRepository,
+	notifier notify.Notifier,
+) WishlistService {
+	return &wishlistService{
+		repo:        repo,
+		productRepo: productRepo,
+		variantRepo: variantRepo,
+		bundles:     bundles,
+		users:       users,
+		notifier:    notifier,
+	}
+}
+
+// List returns the customer's wishlist. Products moved to the trash since
+// they were saved are left out.
+func (s *wishlistService) List(ctx context.Context, userID uint) ([]product.WishlistItem, error) {
+	items, err := s.repo.FindItems(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := make([]product.WishlistItem, 0, len(items))
+	for _, item := range items {
+		if item.Product != nil {
+			visible = append(visible, item)
+		}
+	}
+	return visible, nil
+}
+
+func (s *wishlistService) Add(ctx context.Context, userID uint, productID int64) error {
+	p, err := s.productRepo.FindByID(ctx, productID)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return ErrProductNotFound
+	}
+
+	return s.repo.AddItem(ctx, &product.WishlistItem{UserID: userID, ProductID: productID})
+}
+
+func (s *wishlistService) Remove(ctx context.Context, userID uint, productID int64) error {
+	removed, err := s.repo.RemoveItem(ctx, userID, productID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrWishlistItemNotFound
+	}
+	return nil
+}
+
+// Subscribe asks to be notified when the product, or the given variant, is
+// back in stock. Only products that are currently out of stock can be
+// subscribed to; subscribing twice returns the existing subscription.
+func (s *wishlistService) Subscribe(ctx context.Context, userID uint, productID int64, variantID *int64) (*product.StockSubscription, error) {
+	p, err := s.productRepo.FindByID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrProductNotFound
+	}
+
+	stock, err := s.stock(ctx, productID, variantID)
+	if err != nil {
+		return nil, err
+	}
+	if stock > 0 {
+		return nil, ErrInStock
+	}
+
+	existing, err := s.repo.FindSubscription(ctx, userID, productID, variantID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return existing, nil
+	}
+
+	subscription := &product.StockSubscription{UserID: userID, ProductID: productID, VariantID: variantID}
+	if err := s.repo.CreateSubscription(ctx, subscription); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+func (s *wishlistService) Unsubscribe(ctx context.Context, userID uint, productID int64, variantID *int64) error {
+	existing, err := s.repo.FindSubscription(ctx, userID, productID, variantID)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return ErrSubscriptionNotFound
+	}
+	return s.repo.DeleteSubscription(ctx, existing.ID)
+}
+
+func (s *wishlistService) Subscriptions(ctx context.Context, userID uint) ([]product.StockSubscription, error) {
+	return s.repo.FindSubscriptionsByUser(ctx, userID)
+}
+
+// NotifyBackInStock notifies the subscribers of every product that went
+// from out of stock to in stock and removes their subscriptions.
+// Subscriptions are only taken while stock is zero, so positive stock
+// means the product was restocked since.
+func (s *wishlistService) NotifyBackInStock(ctx context.Context) error {
+	subscriptions, err := s.repo.FindAllSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, subscription := range subscriptions {
+		if err := s.notify(ctx, subscription); err != nil {
+			log.Printf("Failed to send back-in-stock notification %d: %v", subscription.ID, err)
+		}
+	}
+	return nil
+}
+
+func (s *wishlistService) notify(ctx context.Context, subscription product.StockSubscription) error {
+	p, err := s.productRepo.FindByID(ctx, subscription.ProductID)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		// The product is in the trash; keep waiting in case it is restored.
+		return nil
+	}
+
+	stock, err := s.stock(ctx, subscription.ProductID, subscription.VariantID)
+	if errors.Is(err, ErrVariantNotFound) || errors.Is(err, ErrStockNotTracked) {
+		return s.repo.DeleteSubscription(ctx, subscription.ID)
+	}
+	if err != nil {
+		return err
+	}
+	if stock <= 0 {
+		return nil
+	}
+
+	user, err := s.users.FindByID(subscription.UserID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return s.repo.DeleteSubscription(ctx, subscription.ID)
+	}
+
+	data := map[string]interface{}{
+		"user_id":    user.ID,
+		"email":      user.Email,
+		"product_id": p.ID,
+	}
+	if subscription.VariantID != nil {
+		data["variant_id"] = *subscription.VariantID
+	}
+
+	err = s.notifier.Notify(ctx, notify.Notification{
+		Kind:    "back_in_stock",
+		Message: fmt.Sprintf("%s is back in stock", p.Name),
+		Data:    data,
+		At:      time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.repo.DeleteSubscription(ctx, subscription.ID)
+}
+
+// stock returns the units available of the variant or, without one, of the
+// whole product: the sum of its variants, or what its components allow
+// for a bundle.
+func (s *wishlistService) stock(ctx context.Context, productID int64, variantID *int64) (int, error) {
+	if variantID != nil {
+		v, err := s.variantRepo.FindByID(ctx, *variantID)
+		if err != nil {
+			return 0, err
+		}
+		if v == nil || v.ProductID != productID {
+			return 0, ErrVariantNotFound
+		}
+		return v.Stock, nil
+	}
+
+	bundle, err := s.bundles.Get(ctx, productID)
+	if err != nil {
+		return 0, err
+	}
+	if bundle != nil {
+		if bundle.Available == nil {
+			return 0, ErrStockNotTracked
+		}
+		return *bundle.Available, nil
+	}
+
+	variants, err := s.variantRepo.FindByProductID(ctx, productID)
+	if err != nil {
+		return 0, err
+	}
+	if len(variants) == 0 {
+		return 0, ErrStockNotTracked
+	}
+
+	total := 0
+	for _, v := range variants {
+		total += v.Stock
+	}
+	return total, nil
+}
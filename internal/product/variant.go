@@ -0,0 +1,45 @@
+package product
+
+import "time"
+
+// OptionType is a dimension a product varies on, such as size or colour.
+type OptionType struct {
+	ID        int64         `json:"id" gorm:"primaryKey"`
+	ProductID int64         `json:"product_id" gorm:"not null;uniqueIndex:idx_option_type_product_name"`
+	Name      string        `json:"name" gorm:"not null;uniqueIndex:idx_option_type_product_name"`
+	Position  int           `json:"position"`
+	Values    []OptionValue `json:"values" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// OptionValue is one choice of an option type, such as "M" or "red".
+type OptionValue struct {
+	ID           int64  `json:"id" gorm:"primaryKey"`
+	OptionTypeID int64  `json:"option_type_id" gorm:"not null;index"`
+	Value        string `json:"value" gorm:"not null"`
+	Position     int    `json:"position"`
+}
+
+// Variant is a sellable combination of option values with its own SKU,
+// barcode and stock. A nil Price means the product price applies. Stock is
+// the total on hand across warehouses; it is kept up to date by the stock
+// ledger and cannot be written directly.
+type Variant struct {
+	ID           int64         `json:"id" gorm:"primaryKey"`
+	ProductID    int64         `json:"product_id" gorm:"not null;index"`
+	SKU          string        `json:"sku" gorm:"uniqueIndex;not null"`
+	Price        *float64      `json:"price"`
+	Barcode      string        `json:"barcode" gorm:"uniqueIndex:idx_variants_barcode,where:barcode <> ''"`
+	Stock        int           `json:"stock" gorm:"not null;default:0"`
+	OptionValues []OptionValue `json:"option_values" gorm:"many2many:variant_option_values"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+}
+
+// EffectivePrice returns the variant price override or, when there is none,
+// the price of its product.
+func (v Variant) EffectivePrice(p Product) float64 {
+	if v.Price != nil {
+		return *v.Price
+	}
+	return p.Price
+}
@@ -0,0 +1,23 @@
+package product
+
+import "time"
+
+// WishlistItem is a product a customer saved for later.
+type WishlistItem struct {
+	ID        int64     `json:"-" gorm:"primaryKey"`
+	UserID    uint      `json:"-" gorm:"not null;uniqueIndex:idx_wishlist_items_user_product"`
+	ProductID int64     `json:"product_id" gorm:"not null;uniqueIndex:idx_wishlist_items_user_product;index"`
+	Product   *Product  `json:"product,omitempty"`
+	CreatedAt time.Time `json:"added_at"`
+}
+
+// StockSubscription asks for a notification when an out-of-stock product,
+// or one of its variants, is back in stock. It is removed once the
+// notification is sent.
+type StockSubscription struct {
+	ID        int64     `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"-" gorm:"not null;index"`
+	ProductID int64     `json:"product_id" gorm:"not null;index"`
+	VariantID *int64    `json:"variant_id,omitempty" gorm:"index"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,35 @@
+package domain
+
+import "time"
+
+// APIKey lets another system, such as the ERP, call the API without a
+// human login. The key acts as UserID, limited to its own permissions.
+// Only the SHA-256 hash of the secret is stored; Prefix is shown in
+// listings and finds the key when it is presented.
+type APIKey struct {
+	ID          uint               `gorm:"primaryKey"`
+	Name        string             `gorm:"type:varchar(100);not null"`
+	Prefix      string             `gorm:"type:varchar(32);not null;uniqueIndex"`
+	SecretHash  string             `gorm:"type:varchar(64);not null"`
+	UserID      uint               `gorm:"not null;index"`
+	Permissions []APIKeyPermission `gorm:"constraint:OnDelete:CASCADE"`
+	ExpiresAt   *time.Time
+	LastUsedAt  *time.Time
+	LastUsedIP  string `gorm:"type:varchar(45)"`
+	RevokedAt   *time.Time
+	CreatedBy   *uint
+	CreatedAt   time.Time
+}
+
+// Usable reports whether the key may still authenticate requests.
+func (k *APIKey) Usable(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+type APIKeyPermission struct {
+	APIKeyID   uint       `gorm:"primaryKey"`
+	Permission Permission `gorm:"primaryKey;type:varchar(100)"`
+}
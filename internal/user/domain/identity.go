@@ -0,0 +1,27 @@
+package domain
+
+import "time"
+
+// UserIdentity links a user to their account at an OpenID Connect
+// provider, identified by the provider's issuer and the subject it gives
+// the account. Users with an identity sign in through the provider only.
+type UserIdentity struct {
+	ID          uint   `gorm:"primaryKey"`
+	UserID      uint   `gorm:"not null;index"`
+	Issuer      string `gorm:"type:varchar(255);not null;uniqueIndex:idx_user_identities_subject"`
+	Subject     string `gorm:"type:varchar(255);not null;uniqueIndex:idx_user_identities_subject"`
+	Email       string `gorm:"type:varchar(255)"`
+	LastLoginAt *time.Time
+	CreatedAt   time.Time
+}
+
+// OIDCLoginState is a login started at the identity provider that hasn't
+// come back yet. State is stored hashed; the nonce and PKCE verifier stay
+// on the server so they never pass through the browser.
+type OIDCLoginState struct {
+	StateHash    string    `gorm:"primaryKey;type:varchar(64)"`
+	Nonce        string    `gorm:"type:varchar(64);not null"`
+	CodeVerifier string    `gorm:"type:varchar(128);not null"`
+	ExpiresAt    time.Time `gorm:"not null;index"`
+	CreatedAt    time.Time
+}
@@ -0,0 +1,39 @@
+package domain
+
+import "time"
+
+// LoginThrottle counts the recent failed logins for an email address,
+// whether or not it belongs to an account, so unknown addresses are
+// throttled exactly like real ones.
+type LoginThrottle struct {
+	Email         string `gorm:"primaryKey;type:varchar(255)"`
+	Failures      int    `gorm:"not null;default:0"`
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
+
+type LoginEventKind string
+
+const (
+	LoginFailed    LoginEventKind = "login_failed"
+	LoginThrottled LoginEventKind = "login_throttled"
+	LoginLocked    LoginEventKind = "account_locked"
+	LoginUnlocked  LoginEventKind = "account_unlocked"
+	MFAFailed      LoginEventKind = "mfa_failed"
+	MFAThrottled   LoginEventKind = "mfa_throttled"
+	MFAReset       LoginEventKind = "mfa_reset"
+)
+
+// LoginEvent is an audit entry for a failed or blocked login, and for
+// lockouts and unlocks. Reason is for operators only and is never shown
+// to the person logging in.
+type LoginEvent struct {
+	ID        int64          `json:"id" gorm:"primaryKey"`
+	Kind      LoginEventKind `json:"kind" gorm:"type:varchar(30);not null"`
+	Email     string         `json:"email" gorm:"type:varchar(255);not null;index"`
+	UserID    *uint          `json:"user_id,omitempty" gorm:"index"`
+	IP        string         `json:"ip" gorm:"type:varchar(64)"`
+	Reason    string         `json:"reason,omitempty"`
+	ActorID   *uint          `json:"actor_id,omitempty"`
+	CreatedAt time.Time      `json:"created_at" gorm:"index"`
+}
@@ -0,0 +1,38 @@
+package domain
+
+import "time"
+
+// MFAEnrollment holds the user's TOTP secret. It only protects logins once
+// confirmed with a first valid code. LastUsedStep is the time step of the
+// last accepted code, so a code can't be replayed.
+type MFAEnrollment struct {
+	UserID       uint   `gorm:"primaryKey"`
+	Secret       string `gorm:"type:text;not null"`
+	ConfirmedAt  *time.Time
+	LastUsedStep int64 `gorm:"not null;default:0"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// Confirmed reports whether the enrollment is active.
+func (e *MFAEnrollment) Confirmed() bool {
+	return e.ConfirmedAt != nil
+}
+
+// RecoveryCode is a single-use code that replaces a TOTP code when the
+// user lost their device. Only its SHA-256 hash is stored.
+type RecoveryCode struct {
+	ID        int64  `gorm:"primaryKey"`
+	UserID    uint   `gorm:"not null;index"`
+	CodeHash  string `gorm:"type:varchar(64);not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// MFAPolicy says whether users with the role must use two-factor
+// authentication.
+type MFAPolicy struct {
+	Role      Role      `json:"role" gorm:"primaryKey;type:varchar(50)"`
+	Required  bool      `json:"required" gorm:"not null"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -15,6 +15,16 @@ type User struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
 	Role      Role           `json:"role" gorm:"type:varchar(50);not null;default:'customer'"`
+	Version   int64          `json:"version" gorm:"not null;default:1"`
+
+	// EmailVerifiedAt is set once the user proves they own Email.
+	// Self-registered customers can't check out before that.
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+}
+
+// EmailVerified reports whether the user confirmed their email address.
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
 }
 
 type Role string
@@ -23,4 +33,10 @@ const (
 	RoleAdmin    Role = "admin"
 	RoleEmployee Role = "employee"
 	RoleCustomer Role = "customer"
+	RoleSeller   Role = "seller"
 )
+
+// IsValid reports whether the role is one of the known roles.
+func (r Role) IsValid() bool {
+	return r == RoleAdmin || r == RoleEmployee || r == RoleCustomer || r == RoleSeller
+}
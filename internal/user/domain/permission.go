@@ -0,0 +1,131 @@
+package domain
+
+import (
+	"regexp"
+	"time"
+)
+
+// Permission allows a kind of action, written as "resource:action".
+type Permission string
+
+const (
+	PermUsersRead         Permission = "users:read"
+	PermUsersWrite        Permission = "users:write"
+	PermUsersSecurity     Permission = "users:security"
+	PermRolesManage       Permission = "roles:manage"
+	PermAPIKeysManage     Permission = "apikeys:manage"
+	PermProductsRead      Permission = "products:read"
+	PermProductsWrite     Permission = "products:write"
+	PermProductsWriteOwn  Permission = "products:write:own"
+	PermCategoriesWrite   Permission = "categories:write"
+	PermReviewsWrite      Permission = "reviews:write"
+	PermReviewsModerate   Permission = "reviews:moderate"
+	PermWishlistManage    Permission = "wishlist:manage"
+	PermOrdersCreate      Permission = "orders:create"
+	PermOrdersReadOwn     Permission = "orders:read:own"
+	PermPOSUse            Permission = "pos:use"
+	PermInventoryRead     Permission = "inventory:read"
+	PermInventoryWrite    Permission = "inventory:write"
+	PermWarehousesManage  Permission = "warehouses:manage"
+	PermPurchasingRead    Permission = "purchasing:read"
+	PermPurchasingWrite   Permission = "purchasing:write"
+	PermPurchasingReceive Permission = "purchasing:receive"
+)
+
+// Permissions lists every permission with what it allows.
+var Permissions = []struct {
+	Name        Permission `json:"name"`
+	Description string     `json:"description"`
+}{
+	{PermUsersRead, "View user accounts"},
+	{PermUsersWrite, "Create, edit and delete user accounts"},
+	{PermUsersSecurity, "Unlock accounts, end sessions, reset two-factor authentication and set MFA policies"},
+	{PermRolesManage, "Define roles and assign them to users"},
+	{PermAPIKeysManage, "Create and revoke API keys for other systems"},
+	{PermProductsRead, "Browse the catalog"},
+	{PermProductsWrite, "Manage any product, its variants, prices and images, and import or export the catalog"},
+	{PermProductsWriteOwn, "Create products and manage the ones you own"},
+	{PermCategoriesWrite, "Manage categories and their attributes"},
+	{PermReviewsWrite, "Review products"},
+	{PermReviewsModerate, "Approve and reject reviews"},
+	{PermWishlistManage, "Keep a wishlist and back-in-stock alerts"},
+	{PermOrdersCreate, "Place orders"},
+	{PermOrdersReadOwn, "View orders containing your products"},
+	{PermPOSUse, "Look up products by barcode at the counter"},
+	{PermInventoryRead, "View warehouses, stock and movements"},
+	{PermInventoryWrite, "Adjust stock and transfer it between warehouses"},
+	{PermWarehousesManage, "Create and edit warehouses"},
+	{PermPurchasingRead, "View suppliers and purchase orders"},
+	{PermPurchasingWrite, "Manage suppliers, purchase orders and reorder rules"},
+	{PermPurchasingReceive, "Receive purchase orders"},
+}
+
+// IsValid reports whether the permission is one of the known permissions.
+func (p Permission) IsValid() bool {
+	for _, known := range Permissions {
+		if known.Name == p {
+			return true
+		}
+	}
+	return false
+}
+
+// AllPermissions returns every known permission.
+func AllPermissions() []Permission {
+	all := make([]Permission, len(Permissions))
+	for i, p := range Permissions {
+		all[i] = p.Name
+	}
+	return all
+}
+
+// DefaultRolePermissions are the permission sets the built-in roles start
+// with, matching what each role could do before permissions existed.
+// Admins always hold every permission.
+var DefaultRolePermissions = map[Role][]Permission{
+	RoleEmployee: {
+		PermProductsRead, PermPOSUse, PermOrdersCreate, PermReviewsModerate,
+		PermInventoryRead, PermInventoryWrite, PermPurchasingRead, PermPurchasingReceive,
+	},
+	RoleSeller: {
+		PermProductsWriteOwn, PermOrdersReadOwn,
+	},
+	RoleCustomer: {
+		PermProductsRead, PermReviewsWrite, PermWishlistManage, PermOrdersCreate,
+	},
+}
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,49}$`)
+
+// ValidRoleName reports whether name can name a role.
+func ValidRoleName(name string) bool {
+	return roleNamePattern.MatchString(name)
+}
+
+// RoleDefinition is a named set of permissions. The built-in roles exist
+// from the start and can't be deleted; the admin role always holds every
+// permission and can't be edited.
+type RoleDefinition struct {
+	Name        Role             `json:"name" gorm:"primaryKey;type:varchar(50)"`
+	Description string           `json:"description"`
+	BuiltIn     bool             `json:"built_in" gorm:"not null"`
+	Permissions []RolePermission `json:"-" gorm:"foreignKey:Role;references:Name;constraint:OnDelete:CASCADE"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+func (RoleDefinition) TableName() string {
+	return "roles"
+}
+
+type RolePermission struct {
+	Role       Role       `gorm:"primaryKey;type:varchar(50)"`
+	Permission Permission `gorm:"primaryKey;type:varchar(100)"`
+}
+
+// UserRole grants a role to a user. Every user holds their primary role,
+// User.Role, and may hold others.
+type UserRole struct {
+	UserID uint `gorm:"primaryKey"`
+	Role   Role `gorm:"primaryKey;type:varchar(50);index"`
+}
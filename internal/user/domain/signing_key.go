@@ -0,0 +1,16 @@
+package domain
+
+import "time"
+
+// SigningKey is a key pair used to sign access tokens. The newest key that
+// is not retired signs new tokens; retired keys are kept, and published,
+// for a grace period so tokens they signed stay verifiable until they
+// expire. PrivateKey is sealed with JWT_KEY_ENCRYPTION_KEY when one is
+// configured.
+type SigningKey struct {
+	KID        string     `gorm:"primaryKey;type:varchar(64)"`
+	Algorithm  string     `gorm:"type:varchar(10);not null"`
+	PrivateKey string     `gorm:"type:text;not null"`
+	CreatedAt  time.Time  `gorm:"not null;index"`
+	RetiredAt  *time.Time `gorm:"index"`
+}
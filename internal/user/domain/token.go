@@ -0,0 +1,38 @@
+package domain
+
+import "time"
+
+// RefreshToken is a long-lived credential exchanged for new access tokens.
+// Only the SHA-256 hash of the token is stored. Every refresh rotates the
+// token: the old one is marked used and a new one is issued in the same
+// family. Presenting a used token again means it leaked, so the whole
+// family is revoked.
+type RefreshToken struct {
+	ID        int64     `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	FamilyID  string    `gorm:"type:varchar(64);not null;index"`
+	TokenHash string    `gorm:"type:varchar(64);not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// RevokedToken denies an access token, identified by its jti claim, until
+// it expires.
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey;type:varchar(64)"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+}
+
+// PasswordResetToken lets a user who forgot their password choose a new
+// one. Only the SHA-256 hash of the token is stored, and a token works
+// once.
+type PasswordResetToken struct {
+	ID        int64     `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	TokenHash string    `gorm:"type:varchar(64);not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
+	"github.com/rkweber-max/checkout-backend/internal/user/service"
+)
+
+type APIKeyHandler struct {
+	service service.APIKeyService
+}
+
+func NewAPIKeyHandler(service service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{service: service}
+}
+
+type CreateAPIKeyRequest struct {
+	Name        string              `json:"name" binding:"required"`
+	UserID      uint                `json:"user_id"`
+	Permissions []domain.Permission `json:"permissions" binding:"required"`
+	ExpiresAt   *time.Time          `json:"expires_at"`
+}
+
+// Create issues a new key. The response is the only time the key itself
+// is shown.
+func (h *APIKeyHandler) Create(c *gin.Context) {
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	key, err := h.service.Create(c.Request.Context(), service.CreateAPIKey{
+		Name:        req.Name,
+		UserID:      req.UserID,
+		Permissions: req.Permissions,
+		ExpiresAt:   req.ExpiresAt,
+	})
+	if err != nil {
+		respondAPIKeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, key)
+}
+
+func (h *APIKeyHandler) List(c *gin.Context) {
+	keys, err := h.service.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+func (h *APIKeyHandler) Get(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid API key id",
+		})
+		return
+	}
+
+	key, err := h.service.Get(c.Request.Context(), uint(id))
+	if err != nil {
+		respondAPIKeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, key)
+}
+
+func (h *APIKeyHandler) Revoke(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid API key id",
+		})
+		return
+	}
+
+	if err := h.service.Revoke(c.Request.Context(), uint(id)); err != nil {
+		respondAPIKeyError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func respondAPIKeyError(c *gin.Context, err error) {
+	status := http.StatusBadRequest
+	if errors.Is(err, service.ErrAPIKeyNotFound) || errors.Is(err, service.ErrUserNotFound) {
+		status = http.StatusNotFound
+	}
+
+	c.JSON(status, gin.H{
+		"error": err.Error(),
+	})
+}
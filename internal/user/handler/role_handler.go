@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
+	"github.com/rkweber-max/checkout-backend/internal/user/service"
+)
+
+type RoleHandler struct {
+	service service.RoleService
+}
+
+func NewRoleHandler(service service.RoleService) *RoleHandler {
+	return &RoleHandler{service: service}
+}
+
+type RoleRequest struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Permissions []domain.Permission `json:"permissions" binding:"required"`
+}
+
+type UserRolesRequest struct {
+	Roles []domain.Role `json:"roles" binding:"required"`
+}
+
+// Permissions lists every permission a role can grant.
+func (h *RoleHandler) Permissions(c *gin.Context) {
+	c.JSON(http.StatusOK, domain.Permissions)
+}
+
+func (h *RoleHandler) List(c *gin.Context) {
+	roles, err := h.service.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+func (h *RoleHandler) Get(c *gin.Context) {
+	role, err := h.service.Get(c.Request.Context(), domain.Role(c.Param("name")))
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+func (h *RoleHandler) Create(c *gin.Context) {
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	role, err := h.service.Create(c.Request.Context(), domain.Role(req.Name), req.Description, req.Permissions)
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, role)
+}
+
+// Update replaces the role's description and permissions.
+func (h *RoleHandler) Update(c *gin.Context) {
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	role, err := h.service.Update(c.Request.Context(), domain.Role(c.Param("name")), req.Description, req.Permissions)
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+func (h *RoleHandler) Delete(c *gin.Context) {
+	if err := h.service.Delete(c.Request.Context(), domain.Role(c.Param("name"))); err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// UserRoles lists every role the user holds, their primary role included.
+func (h *RoleHandler) UserRoles(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return
+	}
+
+	roles, err := h.service.UserRoles(c.Request.Context(), uint(id))
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"roles": roles,
+	})
+}
+
+// SetUserRoles replaces the roles the user holds. The user gets the new
+// permissions with their next access token.
+func (h *RoleHandler) SetUserRoles(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return
+	}
+
+	var req UserRolesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	roles, err := h.service.SetUserRoles(c.Request.Context(), uint(id), req.Roles)
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"roles": roles,
+	})
+}
+
+func respondRoleError(c *gin.Context, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, service.ErrRoleNotFound), errors.Is(err, service.ErrUserNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, service.ErrRoleExists), errors.Is(err, service.ErrRoleInUse):
+		status = http.StatusConflict
+	case errors.Is(err, service.ErrRoleBuiltIn), errors.Is(err, service.ErrRoleNotEditable):
+		status = http.StatusForbidden
+	}
+
+	c.JSON(status, gin.H{
+		"error": err.Error(),
+	})
+}
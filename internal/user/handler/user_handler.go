@@ -1,20 +1,24 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rkweber-max/checkout-backend/internal/user/domain"
 	"github.com/rkweber-max/checkout-backend/internal/user/service"
+	"github.com/rkweber-max/checkout-backend/pkg/etag"
 )
 
 type UserHandler struct {
 	service service.UserService
+	logins  service.LoginService
 }
 
-func NewUserHandler(service service.UserService) *UserHandler {
-	return &UserHandler{service: service}
+func NewUserHandler(service service.UserService, logins service.LoginService) *UserHandler {
+	return &UserHandler{service: service, logins: logins}
 }
 
 type CreateUserRequest struct {
@@ -38,22 +42,31 @@ func (h *UserHandler) Create(c *gin.Context) {
 	if req.Role != "" {
 		role = domain.Role(req.Role)
 
-		if role != domain.RoleAdmin && role != domain.RoleCustomer && role != domain.RoleEmployee {
+		if !role.IsValid() {
 			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "invalid role. Must be 'admin', 'employee', or 'customer'",
+				"error": "invalid role. Must be 'admin', 'employee', 'seller', or 'customer'",
 			})
 			return
 		}
 	}
 
+	// Accounts created by an admin don't go through email verification.
+	verifiedAt := time.Now()
 	user := &domain.User{
-		Name:     req.Name,
-		Email:    req.Email,
-		Password: req.Password,
-		Role:     role,
+		Name:            req.Name,
+		Email:           req.Email,
+		Password:        req.Password,
+		Role:            role,
+		EmailVerifiedAt: &verifiedAt,
 	}
 
 	err := h.service.Create(user)
+	if errors.Is(err, service.ErrEmailInUse) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": err.Error(),
@@ -93,6 +106,7 @@ func (h *UserHandler) GetByID(c *gin.Context) {
 		return
 	}
 
+	c.Header("ETag", etag.Format(user.Version))
 	c.JSON(http.StatusOK, user)
 }
 
@@ -149,19 +163,94 @@ func (h *UserHandler) Update(c *gin.Context) {
 
 	user.ID = uint(id)
 
-	err = h.service.Update(&user)
+	version, present, err := etag.IfMatch(c.Request)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
+	if present {
+		user.Version = version
+	}
+	if user.Version == 0 {
+		c.JSON(http.StatusPreconditionRequired, gin.H{
+			"error": "If-Match header or version field is required",
+		})
+		return
+	}
 
+	err = h.service.Update(&user)
+	if err != nil {
+		respondUpdateError(c, err)
+		return
+	}
+
+	c.Header("ETag", etag.Format(user.Version))
 	c.JSON(http.StatusOK, gin.H{
 		"message": "user updated successfully",
 	})
 }
 
+// Patch applies a JSON Merge Patch (RFC 7396) to the user's profile.
+// If-Match is honoured when present.
+func (h *UserHandler) Patch(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return
+	}
+
+	version, present, err := etag.IfMatch(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	var expected *int64
+	if present {
+		expected = &version
+	}
+
+	patch, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	user, err := h.service.Patch(uint(id), expected, patch)
+	if err != nil {
+		respondUpdateError(c, err)
+		return
+	}
+
+	c.Header("ETag", etag.Format(user.Version))
+	c.JSON(http.StatusOK, user)
+}
+
+func respondUpdateError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, service.ErrVersionConflict):
+		c.JSON(http.StatusPreconditionFailed, gin.H{
+			"error": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+	}
+}
+
 func (h *UserHandler) Delete(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.Atoi(idParam)
@@ -184,3 +273,85 @@ func (h *UserHandler) Delete(c *gin.Context) {
 		"message": "user deleted successfully",
 	})
 }
+
+// RevokeSessions logs the user out everywhere: none of their refresh tokens
+// can be used any more.
+func (h *UserHandler) RevokeSessions(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return
+	}
+
+	if err := h.service.RevokeSessions(uint(id)); err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Unlock lifts a lockout caused by failed logins on the user's account.
+func (h *UserHandler) Unlock(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return
+	}
+
+	if err := h.logins.Unlock(c.Request.Context(), uint(id)); err != nil {
+		respondLoginEventError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "user unlocked successfully",
+	})
+}
+
+// LoginEvents returns the user's recent failed logins, lockouts and
+// unlocks, newest first.
+func (h *UserHandler) LoginEvents(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return
+	}
+
+	events, err := h.logins.Events(c.Request.Context(), uint(id))
+	if err != nil {
+		respondLoginEventError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+func respondLoginEventError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error": err.Error(),
+	})
+}
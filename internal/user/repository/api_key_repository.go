@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
+	"gorm.io/gorm"
+)
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *domain.APIKey) error
+	FindAll(ctx context.Context) ([]domain.APIKey, error)
+	FindByID(ctx context.Context, id uint) (*domain.APIKey, error)
+	FindByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error)
+	Revoke(ctx context.Context, id uint, at time.Time) (bool, error)
+	TouchLastUsed(ctx context.Context, id uint, at time.Time, ip string, staleBefore time.Time) error
+}
+
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+func (r *apiKeyRepository) Create(ctx context.Context, key *domain.APIKey) error {
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+func (r *apiKeyRepository) FindAll(ctx context.Context) ([]domain.APIKey, error) {
+	var keys []domain.APIKey
+	if err := r.db.WithContext(ctx).Preload("Permissions").Order("id").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *apiKeyRepository) FindByID(ctx context.Context, id uint) (*domain.APIKey, error) {
+	return r.find(ctx, "id = ?", id)
+}
+
+func (r *apiKeyRepository) FindByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error) {
+	return r.find(ctx, "prefix = ?", prefix)
+}
+
+func (r *apiKeyRepository) find(ctx context.Context, query string, arg interface{}) (*domain.APIKey, error) {
+	var key domain.APIKey
+	err := r.db.WithContext(ctx).Preload("Permissions").Where(query, arg).First(&key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// Revoke marks the key revoked unless it already was, reporting whether
+// it changed.
+func (r *apiKeyRepository) Revoke(ctx context.Context, id uint, at time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&domain.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", at)
+	return result.RowsAffected > 0, result.Error
+}
+
+// TouchLastUsed records when and from where the key was used. Keys last
+// recorded after staleBefore are left alone, so busy keys don't write on
+// every request.
+func (r *apiKeyRepository) TouchLastUsed(ctx context.Context, id uint, at time.Time, ip string, staleBefore time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&domain.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, staleBefore).
+		Updates(map[string]interface{}{
+			"last_used_at": at,
+			"last_used_ip": ip,
+		}).Error
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdentityRepository interface {
+	FindIdentity(ctx context.Context, issuer, subject string) (*domain.UserIdentity, error)
+	CreateIdentity(ctx context.Context, identity *domain.UserIdentity) error
+	TouchIdentity(ctx context.Context, id uint, email string, at time.Time) error
+	HasIdentity(ctx context.Context, userID uint) (bool, error)
+	CreateState(ctx context.Context, state *domain.OIDCLoginState) error
+	TakeState(ctx context.Context, stateHash string, now time.Time) (*domain.OIDCLoginState, error)
+	DeleteExpiredStates(ctx context.Context, now time.Time) error
+}
+
+type identityRepository struct {
+	db *gorm.DB
+}
+
+func NewIdentityRepository(db *gorm.DB) IdentityRepository {
+	return &identityRepository{db: db}
+}
+
+func (r *identityRepository) FindIdentity(ctx context.Context, issuer, subject string) (*domain.UserIdentity, error) {
+	var identity domain.UserIdentity
+	err := r.db.WithContext(ctx).
+		Where("issuer = ? AND subject = ?", issuer, subject).
+		First(&identity).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *identityRepository) CreateIdentity(ctx context.Context, identity *domain.UserIdentity) error {
+	return r.db.WithContext(ctx).Create(identity).Error
+}
+
+func (r *identityRepository) TouchIdentity(ctx context.Context, id uint, email string, at time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&domain.UserIdentity{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"email":         email,
+			"last_login_at": at,
+		}).Error
+}
+
+func (r *identityRepository) HasIdentity(ctx context.Context, userID uint) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&domain.UserIdentity{}).Where("user_id = ?", userID).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *identityRepository) CreateState(ctx context.Context, state *domain.OIDCLoginState) error {
+	return r.db.WithContext(ctx).Create(state).Error
+}
+
+// TakeState deletes and returns the login state if it hasn't expired, so
+// each state completes at most one login.
+func (r *identityRepository) TakeState(ctx context.Context, stateHash string, now time.Time) (*domain.OIDCLoginState, error) {
+	var states []domain.OIDCLoginState
+	err := r.db.WithContext(ctx).
+		Clauses(clause.Returning{}).
+		Where("state_hash = ? AND expires_at > ?", stateHash, now).
+		Delete(&states).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(states) == 0 {
+		return nil, nil
+	}
+	return &states[0], nil
+}
+
+func (r *identityRepository) DeleteExpiredStates(ctx context.Context, now time.Time) error {
+	return r.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&domain.OIDCLoginState{}).Error
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
+	"gorm.io/gorm"
+)
+
+type KeyRepository interface {
+	Create(ctx context.Context, k *domain.SigningKey) error
+	FindUsable(ctx context.Context, retiredAfter time.Time) ([]domain.SigningKey, error)
+	RetireAllExcept(ctx context.Context, kid string, at time.Time) error
+	DeleteRetiredBefore(ctx context.Context, before time.Time) error
+}
+
+type keyRepository struct {
+	db *gorm.DB
+}
+
+func NewKeyRepository(db *gorm.DB) KeyRepository {
+	return &keyRepository{db: db}
+}
+
+func (r *keyRepository) Create(ctx context.Context, k *domain.SigningKey) error {
+	return r.db.WithContext(ctx).Create(k).Error
+}
+
+// FindUsable returns the active keys and the keys retired after the given
+// time, newest first.
+func (r *keyRepository) FindUsable(ctx context.Context, retiredAfter time.Time) ([]domain.SigningKey, error) {
+	var keys []domain.SigningKey
+	err := r.db.WithContext(ctx).
+		Where("retired_at IS NULL OR retired_at > ?", retiredAfter).
+		Order("created_at DESC").
+		Find(&keys).Error
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RetireAllExcept retires every active key but kid.
+func (r *keyRepository) RetireAllExcept(ctx context.Context, kid string, at time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&domain.SigningKey{}).
+		Where("kid <> ? AND retired_at IS NULL", kid).
+		Update("retired_at", at).Error
+}
+
+func (r *keyRepository) DeleteRetiredBefore(ctx context.Context, before time.Time) error {
+	return r.db.WithContext(ctx).Where("retired_at < ?", before).Delete(&domain.SigningKey{}).Error
+}
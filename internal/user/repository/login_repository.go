@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LoginRepository interface {
+	FindThrottle(ctx context.Context, email string) (*domain.LoginThrottle, error)
+	RecordFailure(ctx context.Context, email string, at, forgetBefore time.Time) (int, error)
+	Lock(ctx context.Context, email string, until time.Time) error
+	DeleteThrottle(ctx context.Context, email string) error
+	DeleteStale(ctx context.Context, lastFailureBefore time.Time) error
+	CreateEvent(ctx context.Context, e *domain.LoginEvent) error
+	FindEvents(ctx context.Context, userID uint, limit int) ([]domain.LoginEvent, error)
+}
+
+type loginRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginRepository(db *gorm.DB) LoginRepository {
+	return &loginRepository{db: db}
+}
+
+func (r *loginRepository) FindThrottle(ctx context.Context, email string) (*domain.LoginThrottle, error) {
+	var t domain.LoginThrottle
+	err := r.db.WithContext(ctx).Where("email = ?", email).First(&t).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// RecordFailure counts a failed login in a single statement, so concurrent
+// attempts can't lose updates, and returns the new count. A count whose
+// last failure is older than forgetBefore starts over.
+func (r *loginRepository) RecordFailure(ctx context.Context, email string, at, forgetBefore time.Time) (int, error) {
+	t := domain.LoginThrottle{Email: email, Failures: 1, LastFailureAt: at}
+	err := r.db.WithContext(ctx).
+		Clauses(
+			clause.OnConflict{
+				Columns: []clause.Column{{Name: "email"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"failures":        gorm.Expr("CASE WHEN login_throttles.last_failure_at < ? THEN 1 ELSE login_throttles.failures + 1 END", forgetBefore),
+					"last_failure_at": at,
+				}),
+			},
+			clause.Returning{Columns: []clause.Column{{Name: "failures"}}},
+		).
+		Create(&t).Error
+	if err != nil {
+		return 0, err
+	}
+	return t.Failures, nil
+}
+
+func (r *loginRepository) Lock(ctx context.Context, email string, until time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&domain.LoginThrottle{}).
+		Where("email = ?", email).
+		Update("locked_until", until).Error
+}
+
+func (r *loginRepository) DeleteThrottle(ctx context.Context, email string) error {
+	return r.db.WithContext(ctx).Where("email = ?", email).Delete(&domain.LoginThrottle{}).Error
+}
+
+// DeleteStale removes the counts whose last failure is older than the given
+// time and that are no longer locked.
+func (r *loginRepository) DeleteStale(ctx context.Context, lastFailureBefore time.Time) error {
+	return r.db.WithContext(ctx).
+		Where("last_failure_at < ? AND (locked_until IS NULL OR locked_until < ?)", lastFailureBefore, time.Now()).
+		Delete(&domain.LoginThrottle{}).Error
+}
+
+func (r *loginRepository) CreateEvent(ctx context.Context, e *domain.LoginEvent) error {
+	return r.db.WithContext(ctx).Create(e).Error
+}
+
+// FindEvents returns the user's most recent audit entries first.
+func (r *loginRepository) FindEvents(ctx context.Context, userID uint, limit int) ([]domain.LoginEvent, error) {
+	var events []domain.LoginEvent
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
+	"gorm.io/gorm"
+)
+
+type MFARepository interface {
+	FindEnrollment(ctx context.Context, userID uint) (*domain.MFAEnrollment, error)
+	SaveEnrollment(ctx context.Context, e *domain.MFAEnrollment) error
+	ConfirmEnrollment(ctx context.Context, userID uint, step int64, codeHashes []string, at time.Time) (bool, error)
+	UseStep(ctx context.Context, userID uint, step int64) (bool, error)
+	DeleteEnrollment(ctx context.Context, userID uint) error
+	ReplaceRecoveryCodes(ctx context.Context, userID uint, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userID uint, codeHash string, at time.Time) (bool, error)
+	FindPolicies(ctx context.Context) ([]domain.MFAPolicy, error)
+	FindPolicy(ctx context.Context, role domain.Role) (*domain.MFAPolicy, error)
+	SavePolicy(ctx context.Context, p *domain.MFAPolicy) error
+}
+
+type mfaRepository struct {
+	db *gorm.DB
+}
+
+func NewMFARepository(db *gorm.DB) MFARepository {
+	return &mfaRepository{db: db}
+}
+
+func (r *mfaRepository) FindEnrollment(ctx context.Context, userID uint) (*domain.MFAEnrollment, error) {
+	var e domain.MFAEnrollment
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&e).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (r *mfaRepository) SaveEnrollment(ctx context.Context, e *domain.MFAEnrollment) error {
+	return r.db.WithContext(ctx).Save(e).Error
+}
+
+// ConfirmEnrollment activates a pending enrollment with the step of its
+// first code and stores the recovery codes. It reports false when the
+// enrollment was confirmed or replaced meanwhile.
+func (r *mfaRepository) ConfirmEnrollment(ctx context.Context, userID uint, step int64, codeHashes []string, at time.Time) (bool, error) {
+	confirmed := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&domain.MFAEnrollment{}).
+			Where("user_id = ? AND confirmed_at IS NULL", userID).
+			Updates(map[string]interface{}{
+				"confirmed_at":   at,
+				"last_used_step": step,
+				"updated_at":     at,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		confirmed = true
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+	return confirmed, err
+}
+
+// UseStep records that the code of the given time step was accepted. It
+// reports false when that step, or a later one, was already used.
+func (r *mfaRepository) UseStep(ctx context.Context, userID uint, step int64) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&domain.MFAEnrollment{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// DeleteEnrollment removes the user's secret and recovery codes.
+func (r *mfaRepository) DeleteEnrollment(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&domain.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&domain.MFAEnrollment{}).Error
+	})
+}
+
+func (r *mfaRepository) ReplaceRecoveryCodes(ctx context.Context, userID uint, codeHashes []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+func (r *mfaRepository) UseRecoveryCode(ctx context.Context, userID uint, codeHash string, at time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&domain.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", at)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *mfaRepository) FindPolicies(ctx context.Context) ([]domain.MFAPolicy, error) {
+	var policies []domain.MFAPolicy
+	if err := r.db.WithContext(ctx).Order("role").Find(&policies).Error; err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+func (r *mfaRepository) FindPolicy(ctx context.Context, role domain.Role) (*domain.MFAPolicy, error) {
+	var p domain.MFAPolicy
+	err := r.db.WithContext(ctx).Where("role = ?", role).First(&p).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *mfaRepository) SavePolicy(ctx context.Context, p *domain.MFAPolicy) error {
+	return r.db.WithContext(ctx).Save(p).Error
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uint, codeHashes []string) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&domain.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	codes := make([]domain.RecoveryCode, len(codeHashes))
+	for i, hash := range codeHashes {
+		codes[i] = domain.RecoveryCode{UserID: userID, CodeHash: hash}
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	return tx.Create(&codes).Error
+}
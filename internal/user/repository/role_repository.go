@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
+	"gorm.io/gorm"
+)
+
+type RoleRepository interface {
+	FindAll(ctx context.Context) ([]domain.RoleDefinition, error)
+	FindByName(ctx context.Context, name domain.Role) (*domain.RoleDefinition, error)
+	Create(ctx context.Context, role *domain.RoleDefinition) error
+	Update(ctx context.Context, role *domain.RoleDefinition) error
+	Delete(ctx context.Context, name domain.Role) error
+	CountUsers(ctx context.Context, name domain.Role) (int64, error)
+	FindUserRoles(ctx context.Context, userID uint) ([]domain.Role, error)
+	SetUserRoles(ctx context.Context, userID uint, roles []domain.Role) error
+	FindPermissions(ctx context.Context, roles []domain.Role) ([]domain.Permission, error)
+}
+
+type roleRepository struct {
+	db *gorm.DB
+}
+
+func NewRoleRepository(db *gorm.DB) RoleRepository {
+	return &roleRepository{db: db}
+}
+
+func (r *roleRepository) FindAll(ctx context.Context) ([]domain.RoleDefinition, error) {
+	var roles []domain.RoleDefinition
+	if err := r.db.WithContext(ctx).Preload("Permissions").Order("name").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (r *roleRepository) FindByName(ctx context.Context, name domain.Role) (*domain.RoleDefinition, error) {
+	var role domain.RoleDefinition
+	err := r.db.WithContext(ctx).Preload("Permissions").Where("name = ?", name).First(&role).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *roleRepository) Create(ctx context.Context, role *domain.RoleDefinition) error {
+	return r.db.WithContext(ctx).Create(role).Error
+}
+
+// Update saves the description and replaces the permission set.
+func (r *roleRepository) Update(ctx context.Context, role *domain.RoleDefinition) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&domain.RoleDefinition{}).
+			Where("name = ?", role.Name).
+			Update("description", role.Description).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("role = ?", role.Name).Delete(&domain.RolePermission{}).Error; err != nil {
+			return err
+		}
+		if len(role.Permissions) == 0 {
+			return nil
+		}
+		return tx.Create(&role.Permissions).Error
+	})
+}
+
+func (r *roleRepository) Delete(ctx context.Context, name domain.Role) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role = ?", name).Delete(&domain.RolePermission{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("name = ?", name).Delete(&domain.RoleDefinition{}).Error
+	})
+}
+
+func (r *roleRepository) CountUsers(ctx context.Context, name domain.Role) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&domain.UserRole{}).Where("role = ?", name).Count(&count).Error
+	return count, err
+}
+
+func (r *roleRepository) FindUserRoles(ctx context.Context, userID uint) ([]domain.Role, error) {
+	var roles []domain.Role
+	err := r.db.WithContext(ctx).
+		Model(&domain.UserRole{}).
+		Where("user_id = ?", userID).
+		Order("role").
+		Pluck("role", &roles).Error
+	if err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (r *roleRepository) SetUserRoles(ctx context.Context, userID uint, roles []domain.Role) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&domain.UserRole{}).Error; err != nil {
+			return err
+		}
+		grants := make([]domain.UserRole, len(roles))
+		for i, role := range roles {
+			grants[i] = domain.UserRole{UserID: userID, Role: role}
+		}
+		if len(grants) == 0 {
+			return nil
+		}
+		return tx.Create(&grants).Error
+	})
+}
+
+// FindPermissions returns the union of the permissions of the roles.
+func (r *roleRepository) FindPermissions(ctx context.Context, roles []domain.Role) ([]domain.Permission, error) {
+	var permissions []domain.Permission
+	if len(roles) == 0 {
+		return permissions, nil
+	}
+	err := r.db.WithContext(ctx).
+		Model(&domain.RolePermission{}).
+		Where("role IN ?", roles).
+		Distinct("permission").
+		Order("permission").
+		Pluck("permission", &permissions).Error
+	if err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
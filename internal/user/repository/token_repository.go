@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TokenRepository interface {
+	CreateRefreshToken(ctx context.Context, t *domain.RefreshToken) error
+	FindRefreshToken(ctx context.Context, hash string) (*domain.RefreshToken, error)
+	MarkUsed(ctx context.Context, id int64, at time.Time) (bool, error)
+	RevokeFamily(ctx context.Context, familyID string, at time.Time) error
+	RevokeUser(ctx context.Context, userID uint, at time.Time) error
+	RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error
+	IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error)
+	CreateResetToken(ctx context.Context, t *domain.PasswordResetToken) error
+	FindResetToken(ctx context.Context, hash string) (*domain.PasswordResetToken, error)
+	MarkResetTokenUsed(ctx context.Context, id int64, at time.Time) (bool, error)
+	UseResetTokens(ctx context.Context, userID uint, at time.Time) error
+	DeleteExpired(ctx context.Context, before time.Time) error
+}
+
+type tokenRepository struct {
+	db *gorm.DB
+}
+
+func NewTokenRepository(db *gorm.DB) TokenRepository {
+	return &tokenRepository{db: db}
+}
+
+func (r *tokenRepository) CreateRefreshToken(ctx context.Context, t *domain.RefreshToken) error {
+	return r.db.WithContext(ctx).Create(t).Error
+}
+
+func (r *tokenRepository) FindRefreshToken(ctx context.Context, hash string) (*domain.RefreshToken, error) {
+	var t domain.RefreshToken
+	err := r.db.WithContext(ctx).Where("token_hash = ?", hash).First(&t).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// MarkUsed flags the token as rotated. It reports false when the token was
+// already used, so two concurrent refreshes cannot both succeed.
+func (r *tokenRepository) MarkUsed(ctx context.Context, id int64, at time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&domain.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", at)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *tokenRepository) RevokeFamily(ctx context.Context, familyID string, at time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&domain.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", at).Error
+}
+
+// RevokeUser ends every session of the user.
+func (r *tokenRepository) RevokeUser(ctx context.Context, userID uint, at time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&domain.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", at).Error
+}
+
+func (r *tokenRepository) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&domain.RevokedToken{JTI: jti, ExpiresAt: expiresAt}).Error
+}
+
+func (r *tokenRepository) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&domain.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *tokenRepository) CreateResetToken(ctx context.Context, t *domain.PasswordResetToken) error {
+	return r.db.WithContext(ctx).Create(t).Error
+}
+
+func (r *tokenRepository) FindResetToken(ctx context.Context, hash string) (*domain.PasswordResetToken, error) {
+	var t domain.PasswordResetToken
+	err := r.db.WithContext(ctx).Where("token_hash = ?", hash).First(&t).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// MarkResetTokenUsed reports false when the token was already used, so a
+// reset link can't be replayed, even concurrently.
+func (r *tokenRepository) MarkResetTokenUsed(ctx context.Context, id int64, at time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&domain.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", at)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// UseResetTokens invalidates every outstanding reset token of the user.
+func (r *tokenRepository) UseResetTokens(ctx context.Context, userID uint, at time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&domain.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", at).Error
+}
+
+// DeleteExpired removes refresh tokens, reset tokens and denylist entries
+// that expired before the given time; they can no longer be presented.
+func (r *tokenRepository) DeleteExpired(ctx context.Context, before time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at < ?", before).Delete(&domain.RefreshToken{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("expires_at < ?", before).Delete(&domain.PasswordResetToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("expires_at < ?", before).Delete(&domain.RevokedToken{}).Error
+	})
+}
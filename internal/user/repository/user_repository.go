@@ -2,9 +2,11 @@ package repository
 
 import (
 	"errors"
+	"time"
 
 	"github.com/rkweber-max/checkout-backend/internal/user/domain"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type UserRepository interface {
@@ -14,8 +16,14 @@ type UserRepository interface {
 	List() ([]domain.User, error)
 	Update(user *domain.User) error
 	Delete(id uint) error
+	MarkEmailVerified(id uint, email string, at time.Time) (bool, error)
+	UpdatePassword(id uint, hash string) error
 }
 
+// ErrVersionConflict is returned when a user was modified since the version
+// the caller based its update on.
+var ErrVersionConflict = errors.New("user was modified by someone else")
+
 type userRepository struct {
 	db *gorm.DB
 }
@@ -24,8 +32,15 @@ func NewUserRepository(db *gorm.DB) UserRepository {
 	return &userRepository{db: db}
 }
 
+// Create stores the user and grants them their primary role.
 func (r *userRepository) Create(user *domain.User) error {
-	return r.db.Create(user).Error
+	user.Version = 1
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return grantRole(tx, user.ID, user.Role)
+	})
 }
 
 func (r *userRepository) FindByID(id uint) (*domain.User, error) {
@@ -58,10 +73,95 @@ func (r *userRepository) List() ([]domain.User, error) {
 	return users, err
 }
 
+// Update writes the profile fields if the user is still at user.Version and
+// bumps the version. The password is never touched here. A new primary
+// role replaces the previous one among the roles the user holds.
 func (r *userRepository) Update(user *domain.User) error {
-	return r.db.Save(user).Error
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var previous domain.Role
+		err := tx.Model(&domain.User{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", user.ID).
+			Pluck("role", &previous).Error
+		if err != nil {
+			return err
+		}
+
+		result := tx.Model(&domain.User{}).
+			Where("id = ? AND version = ?", user.ID, user.Version).
+			Updates(map[string]interface{}{
+				"name":       user.Name,
+				"email":      user.Email,
+				"role":       user.Role,
+				"version":    gorm.Expr("version + 1"),
+				"updated_at": time.Now(),
+				// A new address has to be verified again.
+				"email_verified_at": gorm.Expr("CASE WHEN email = ? THEN email_verified_at END", user.Email),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrVersionConflict
+		}
+
+		if previous == user.Role {
+			return nil
+		}
+		err = tx.Where("user_id = ? AND role = ?", user.ID, previous).
+			Delete(&domain.UserRole{}).Error
+		if err != nil {
+			return err
+		}
+		return grantRole(tx, user.ID, user.Role)
+	})
+	if err != nil {
+		return err
+	}
+
+	user.Version++
+	return nil
 }
 
 func (r *userRepository) Delete(id uint) error {
-	return r.db.Unscoped().Delete(&domain.User{}, id).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", id).Delete(&domain.UserRole{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&domain.UserIdentity{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&domain.User{}, id).Error
+	})
+}
+
+func grantRole(tx *gorm.DB, userID uint, role domain.Role) error {
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&domain.UserRole{UserID: userID, Role: role}).Error
+}
+
+// MarkEmailVerified verifies the user's email if it is still the given
+// address and not verified yet. It reports whether the user was updated,
+// which makes each verification link single-use.
+func (r *userRepository) MarkEmailVerified(id uint, email string, at time.Time) (bool, error) {
+	result := r.db.Model(&domain.User{}).
+		Where("id = ? AND email = ? AND email_verified_at IS NULL", id, email).
+		Updates(map[string]interface{}{
+			"email_verified_at": at,
+			"updated_at":        at,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// UpdatePassword replaces the user's password hash.
+func (r *userRepository) UpdatePassword(id uint, hash string) error {
+	return r.db.Model(&domain.User{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"password":   hash,
+			"updated_at": time.Now(),
+		}).Error
 }
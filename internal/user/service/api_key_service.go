@@ -0,0 +1,276 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/auth"
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
+	"github.com/rkweber-max/checkout-backend/internal/user/repository"
+)
+
+const (
+	apiKeyPrefix = "ck_"
+
+	// apiKeyTouchInterval is how stale the recorded last use of a key
+	// may get before a request updates it.
+	apiKeyTouchInterval = time.Minute
+)
+
+var (
+	ErrInvalidAPIKey  = auth.ErrInvalidAPIKey
+	ErrAPIKeyNotFound = errors.New("API key not found")
+)
+
+// APIKeyDetail describes a key without its secret.
+type APIKeyDetail struct {
+	ID          uint                `json:"id"`
+	Name        string              `json:"name"`
+	Prefix      string              `json:"prefix"`
+	UserID      uint                `json:"user_id"`
+	Permissions []domain.Permission `json:"permissions"`
+	ExpiresAt   *time.Time          `json:"expires_at,omitempty"`
+	LastUsedAt  *time.Time          `json:"last_used_at,omitempty"`
+	LastUsedIP  string              `json:"last_used_ip,omitempty"`
+	RevokedAt   *time.Time          `json:"revoked_at,omitempty"`
+	CreatedBy   *uint               `json:"created_by,omitempty"`
+	CreatedAt   time.Time           `json:"created_at"`
+}
+
+// NewAPIKey is a key that was just created. Key is shown this once and
+// can't be recovered later.
+type NewAPIKey struct {
+	APIKeyDetail
+	Key string `json:"key"`
+}
+
+// CreateAPIKey describes a key to create. The key acts as UserID, or the
+// admin creating it when zero, and holds Permissions, which must all be
+// permissions of that user.
+type CreateAPIKey struct {
+	Name        string
+	UserID      uint
+	Permissions []domain.Permission
+	ExpiresAt   *time.Time
+}
+
+// APIKeyService manages the API keys other systems authenticate with.
+type APIKeyService interface {
+	auth.APIKeyVerifier
+	Create(ctx context.Context, req CreateAPIKey) (*NewAPIKey, error)
+	List(ctx context.Context) ([]APIKeyDetail, error)
+	Get(ctx context.Context, id uint) (*APIKeyDetail, error)
+	Revoke(ctx context.Context, id uint) error
+}
+
+type apiKeyService struct {
+	repo  repository.APIKeyRepository
+	users repository.UserRepository
+	roles RoleService
+}
+
+func NewAPIKeyService(repo repository.APIKeyRepository, users repository.UserRepository, roles RoleService) APIKeyService {
+	return &apiKeyService{repo: repo, users: users, roles: roles}
+}
+
+func (s *apiKeyService) Create(ctx context.Context, req CreateAPIKey) (*NewAPIKey, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	if len(req.Permissions) == 0 {
+		return nil, errors.New("at least one permission is required")
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("expires_at must be in the future")
+	}
+
+	userID := req.UserID
+	if actor := auth.ActorFromContext(ctx); userID == 0 && actor != nil {
+		userID = *actor
+	}
+	user, err := s.users.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	_, held, err := s.roles.Resolve(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &domain.APIKey{
+		Name:      name,
+		UserID:    user.ID,
+		ExpiresAt: req.ExpiresAt,
+		CreatedBy: auth.ActorFromContext(ctx),
+	}
+	seen := make(map[domain.Permission]bool, len(req.Permissions))
+	for _, p := range req.Permissions {
+		if !p.IsValid() {
+			return nil, fmt.Errorf("unknown permission %q", p)
+		}
+		if !containsPermission(held, p) {
+			return nil, fmt.Errorf("user %d doesn't hold permission %q", user.ID, p)
+		}
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		key.Permissions = append(key.Permissions, domain.APIKeyPermission{Permission: p})
+	}
+
+	random, err := auth.RandomToken(6)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := auth.RandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	key.Prefix = apiKeyPrefix + random
+	key.SecretHash = hashToken(secret)
+
+	if err := s.repo.Create(ctx, key); err != nil {
+		return nil, err
+	}
+
+	return &NewAPIKey{
+		APIKeyDetail: newAPIKeyDetail(key),
+		Key:          key.Prefix + "." + secret,
+	}, nil
+}
+
+func (s *apiKeyService) List(ctx context.Context) ([]APIKeyDetail, error) {
+	keys, err := s.repo.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	details := make([]APIKeyDetail, len(keys))
+	for i := range keys {
+		details[i] = newAPIKeyDetail(&keys[i])
+	}
+	return details, nil
+}
+
+func (s *apiKeyService) Get(ctx context.Context, id uint) (*APIKeyDetail, error) {
+	key, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, ErrAPIKeyNotFound
+	}
+	detail := newAPIKeyDetail(key)
+	return &detail, nil
+}
+
+// Revoke stops the key from working. Revoked keys are kept so their use
+// can still be traced.
+func (s *apiKeyService) Revoke(ctx context.Context, id uint) error {
+	key, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		return ErrAPIKeyNotFound
+	}
+
+	_, err = s.repo.Revoke(ctx, id, time.Now())
+	return err
+}
+
+// VerifyAPIKey checks the presented key and returns the user it acts as.
+// The permissions are those of the key that the user still holds, so
+// taking a role away from the user also narrows their keys.
+func (s *apiKeyService) VerifyAPIKey(ctx context.Context, presented, ip string) (*auth.Subject, error) {
+	prefix, secret, ok := strings.Cut(presented, ".")
+	if !ok || !strings.HasPrefix(prefix, apiKeyPrefix) || secret == "" {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, err := s.repo.FindByPrefix(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, ErrInvalidAPIKey
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(secret)), []byte(key.SecretHash)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+
+	now := time.Now()
+	if !key.Usable(now) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	user, err := s.users.FindByID(key.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidAPIKey
+	}
+	roles, held, err := s.roles.Resolve(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := make([]domain.Permission, 0, len(key.Permissions))
+	for _, p := range key.Permissions {
+		if containsPermission(held, p.Permission) {
+			permissions = append(permissions, p.Permission)
+		}
+	}
+
+	if err := s.repo.TouchLastUsed(ctx, key.ID, now, ip, now.Add(-apiKeyTouchInterval)); err != nil {
+		return nil, err
+	}
+
+	return &auth.Subject{
+		UserID:      user.ID,
+		Role:        user.Role,
+		Roles:       roles,
+		Permissions: permissions,
+	}, nil
+}
+
+func containsPermission(permissions []domain.Permission, p domain.Permission) bool {
+	for _, held := range permissions {
+		if held == p {
+			return true
+		}
+	}
+	return false
+}
+
+func newAPIKeyDetail(key *domain.APIKey) APIKeyDetail {
+	permissions := make([]domain.Permission, len(key.Permissions))
+	for i, p := range key.Permissions {
+		permissions[i] = p.Permission
+	}
+	sort.Slice(permissions, func(i, j int) bool { return permissions[i] < permissions[j] })
+
+	return APIKeyDetail{
+		ID:          key.ID,
+		Name:        key.Name,
+		Prefix:      key.Prefix,
+		UserID:      key.UserID,
+		Permissions: permissions,
+		ExpiresAt:   key.ExpiresAt,
+		LastUsedAt:  key.LastUsedAt,
+		LastUsedIP:  key.LastUsedIP,
+		RevokedAt:   key.RevokedAt,
+		CreatedBy:   key.CreatedBy,
+		CreatedAt:   key.CreatedAt,
+	}
+}
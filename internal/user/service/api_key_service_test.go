@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/auth"
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
+	"github.com/rkweber-max/checkout-backend/internal/user/repository"
+)
+
+var _ repository.APIKeyRepository = (*fakeAPIKeys)(nil)
+
+// fakeAPIKeys is an in-memory repository.APIKeyRepository.
+type fakeAPIKeys struct {
+	keys map[uint]*domain.APIKey
+}
+
+func newFakeAPIKeys() *fakeAPIKeys {
+	return &fakeAPIKeys{keys: make(map[uint]*domain.APIKey)}
+}
+
+func (f *fakeAPIKeys) Create(ctx context.Context, key *domain.APIKey) error {
+	key.ID = uint(len(f.keys) + 1)
+	key.CreatedAt = time.Now()
+	stored := *key
+	f.keys[key.ID] = &stored
+	return nil
+}
+
+func (f *fakeAPIKeys) FindAll(ctx context.Context) ([]domain.APIKey, error) {
+	var keys []domain.APIKey
+	for _, key := range f.keys {
+		keys = append(keys, *key)
+	}
+	return keys, nil
+}
+
+func (f *fakeAPIKeys) FindByID(ctx context.Context, id uint) (*domain.APIKey, error) {
+	if key, ok := f.keys[id]; ok {
+		found := *key
+		return &found, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeAPIKeys) FindByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error) {
+	for _, key := range f.keys {
+		if key.Prefix == prefix {
+			found := *key
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeAPIKeys) Revoke(ctx context.Context, id uint, at time.Time) (bool, error) {
+	key, ok := f.keys[id]
+	if !ok || key.RevokedAt != nil {
+		return false, nil
+	}
+	key.RevokedAt = &at
+	return true, nil
+}
+
+func (f *fakeAPIKeys) TouchLastUsed(ctx context.Context, id uint, at time.Time, ip string, staleBefore time.Time) error {
+	key, ok := f.keys[id]
+	if ok && (key.LastUsedAt == nil || key.LastUsedAt.Before(staleBefore)) {
+		key.LastUsedAt = &at
+		key.LastUsedIP = ip
+	}
+	return nil
+}
+
+type apiKeyFixture struct {
+	keys    *fakeAPIKeys
+	roles   RoleService
+	users   *fakeUsers
+	admin   *domain.User
+	erp     *domain.User
+	service APIKeyService
+}
+
+// newAPIKeyFixture has an admin and an employee account the ERP's keys
+// act as.
+func newAPIKeyFixture() *apiKeyFixture {
+	admin := &domain.User{Name: "Admin", Email: "admin@example.com", Role: domain.RoleAdmin}
+	erp := &domain.User{Name: "ERP", Email: "erp@example.com", Role: domain.RoleEmployee}
+	f := &apiKeyFixture{keys: newFakeAPIKeys(), users: newFakeUsers(admin, erp), admin: admin, erp: erp}
+	f.roles = NewRoleService(newFakeRoleDefinitions(), f.users)
+	f.service = NewAPIKeyService(f.keys, f.users, f.roles)
+	return f
+}
+
+func (f *apiKeyFixture) create(t *testing.T, req CreateAPIKey) *NewAPIKey {
+	t.Helper()
+
+	key, err := f.service.Create(auth.WithActor(context.Background(), f.admin.ID), req)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	return key
+}
+
+func TestAPIKeyCreateAndVerify(t *testing.T) {
+	f := newAPIKeyFixture()
+	ctx := context.Background()
+
+	key := f.create(t, CreateAPIKey{
+		Name:        " ERP ",
+		UserID:      f.erp.ID,
+		Permissions: []domain.Permission{domain.PermPurchasingRead, domain.PermInventoryRead, domain.PermPurchasingRead},
+	})
+	if !strings.HasPrefix(key.Key, key.Prefix+".") || !strings.HasPrefix(key.Prefix, apiKeyPrefix) {
+		t.Errorf("key %q doesn't start with its prefix %q", key.Key, key.Prefix)
+	}
+	want := []domain.Permission{domain.PermInventoryRead, domain.PermPurchasingRead}
+	if key.Name != "ERP" || key.UserID != f.erp.ID || !reflect.DeepEqual(key.Permissions, want) {
+		t.Errorf("key = %+v, want permissions %v", key.APIKeyDetail, want)
+	}
+	if key.CreatedBy == nil || *key.CreatedBy != f.admin.ID {
+		t.Errorf("created by %v, want the admin", key.CreatedBy)
+	}
+	stored := f.keys.keys[key.ID]
+	if _, secret, _ := strings.Cut(key.Key, "."); stored.SecretHash == secret || stored.SecretHash != hashToken(secret) {
+		t.Error("secret not stored as its hash")
+	}
+
+	subject, err := f.service.VerifyAPIKey(ctx, key.Key, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("VerifyAPIKey: %v", err)
+	}
+	if subject.UserID != f.erp.ID || subject.Role != domain.RoleEmployee || !reflect.DeepEqual(subject.Permissions, []domain.Permission{domain.PermPurchasingRead, domain.PermInventoryRead}) {
+		t.Errorf("subject = %+v", subject)
+	}
+	if stored.LastUsedAt == nil || stored.LastUsedIP != "10.0.0.1" {
+		t.Errorf("last used %v from %q", stored.LastUsedAt, stored.LastUsedIP)
+	}
+
+	// A busy key doesn't record every request.
+	firstUse := *stored.LastUsedAt
+	if _, err := f.service.VerifyAPIKey(ctx, key.Key, "10.0.0.2"); err != nil {
+		t.Fatalf("VerifyAPIKey: %v", err)
+	}
+	if !stored.LastUsedAt.Equal(firstUse) || stored.LastUsedIP != "10.0.0.1" {
+		t.Errorf("last use rewritten within %v", apiKeyTouchInterval)
+	}
+}
+
+func TestAPIKeyCreateDefaultsToActor(t *testing.T) {
+	f := newAPIKeyFixture()
+
+	key := f.create(t, CreateAPIKey{Name: "Reports", Permissions: []domain.Permission{domain.PermUsersRead}})
+	if key.UserID != f.admin.ID {
+		t.Errorf("key acts as user %d, want the admin %d", key.UserID, f.admin.ID)
+	}
+}
+
+func TestAPIKeyCreateRejectsInvalidRequests(t *testing.T) {
+	f := newAPIKeyFixture()
+	ctx := auth.WithActor(context.Background(), f.admin.ID)
+	past := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name string
+		req  CreateAPIKey
+		err  error
+	}{
+		{"blank name", CreateAPIKey{Name: " ", UserID: f.erp.ID, Permissions: []domain.Permission{domain.PermInventoryRead}}, nil},
+		{"no permissions", CreateAPIKey{Name: "ERP", UserID: f.erp.ID}, nil},
+		{"expired", CreateAPIKey{Name: "ERP", UserID: f.erp.ID, Permissions: []domain.Permission{domain.PermInventoryRead}, ExpiresAt: &past}, nil},
+		{"unknown permission", CreateAPIKey{Name: "ERP", UserID: f.erp.ID, Permissions: []domain.Permission{"orders:delete"}}, nil},
+		{"permission the user lacks", CreateAPIKey{Name: "ERP", UserID: f.erp.ID, Permissions: []domain.Permission{domain.PermUsersWrite}}, nil},
+		{"unknown user", CreateAPIKey{Name: "ERP", UserID: 99, Permissions: []domain.Permission{domain.PermInventoryRead}}, ErrUserNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := f.service.Create(ctx, tt.req)
+			if err == nil {
+				t.Fatal("Create succeeded")
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+	if len(f.keys.keys) != 0 {
+		t.Errorf("stored %d keys", len(f.keys.keys))
+	}
+}
+
+func TestVerifyAPIKeyRejectsUnusableKeys(t *testing.T) {
+	f := newAPIKeyFixture()
+	ctx := context.Background()
+	permissions := []domain.Permission{domain.PermInventoryRead}
+
+	valid := f.create(t, CreateAPIKey{Name: "ERP", UserID: f.erp.ID, Permissions: permissions})
+	revoked := f.create(t, CreateAPIKey{Name: "Old ERP", UserID: f.erp.ID, Permissions: permissions})
+	if err := f.service.Revoke(ctx, revoked.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	expiring := time.Now().Add(time.Hour)
+	expired := f.create(t, CreateAPIKey{Name: "Trial", UserID: f.erp.ID, Permissions: permissions, ExpiresAt: &expiring})
+	past := time.Now().Add(-time.Second)
+	f.keys.keys[expired.ID].ExpiresAt = &past
+
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{"empty", ""},
+		{"no secret", valid.Prefix},
+		{"empty secret", valid.Prefix + "."},
+		{"wrong secret", valid.Prefix + ".wrong"},
+		{"foreign prefix", "sk_" + strings.TrimPrefix(valid.Key, apiKeyPrefix)},
+		{"unknown prefix", apiKeyPrefix + "unknown.secret"},
+		{"revoked", revoked.Key},
+		{"expired", expired.Key},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := f.service.VerifyAPIKey(ctx, tt.key, "10.0.0.1"); !errors.Is(err, ErrInvalidAPIKey) {
+				t.Errorf("err = %v, want %v", err, ErrInvalidAPIKey)
+			}
+		})
+	}
+	for _, key := range f.keys.keys {
+		if key.ID != valid.ID && key.LastUsedAt != nil {
+			t.Errorf("rejected key %s recorded as used", key.Name)
+		}
+	}
+
+	if err := f.service.Revoke(ctx, 99); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("revoking a missing key: err = %v, want %v", err, ErrAPIKeyNotFound)
+	}
+	f.users.Delete(f.erp.ID)
+	if _, err := f.service.VerifyAPIKey(ctx, valid.Key, "10.0.0.1"); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("key of a deleted user: err = %v, want %v", err, ErrInvalidAPIKey)
+	}
+}
+
+func TestVerifyAPIKeyNarrowsToPermissionsTheUserHolds(t *testing.T) {
+	f := newAPIKeyFixture()
+	ctx := context.Background()
+
+	if _, err := f.roles.SetUserRoles(ctx, f.erp.ID, []domain.Role{domain.RoleEmployee, domain.RoleSeller}); err != nil {
+		t.Fatalf("SetUserRoles: %v", err)
+	}
+	key := f.create(t, CreateAPIKey{
+		Name:        "ERP",
+		UserID:      f.erp.ID,
+		Permissions: []domain.Permission{domain.PermInventoryRead, domain.PermProductsWriteOwn},
+	})
+
+	// Taking the seller role away also takes its permission from the key.
+	if _, err := f.roles.SetUserRoles(ctx, f.erp.ID, []domain.Role{domain.RoleEmployee}); err != nil {
+		t.Fatalf("SetUserRoles: %v", err)
+	}
+	subject, err := f.service.VerifyAPIKey(ctx, key.Key, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("VerifyAPIKey: %v", err)
+	}
+	if !reflect.DeepEqual(subject.Permissions, []domain.Permission{domain.PermInventoryRead}) {
+		t.Errorf("permissions = %v, want only %s", subject.Permissions, domain.PermInventoryRead)
+	}
+}
@@ -0,0 +1,417 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
+)
+
+// fakeUsers is an in-memory repository.UserRepository.
+type fakeUsers struct {
+	mu     sync.Mutex
+	nextID uint
+	users  map[uint]*domain.User
+}
+
+func newFakeUsers(users ...*domain.User) *fakeUsers {
+	f := &fakeUsers{users: make(map[uint]*domain.User)}
+	for _, u := range users {
+		f.Create(u)
+	}
+	return f
+}
+
+func (f *fakeUsers) Create(user *domain.User) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.nextID++
+	user.ID = f.nextID
+	user.Version = 1
+	stored := *user
+	f.users[user.ID] = &stored
+	return nil
+}
+
+func (f *fakeUsers) FindByID(id uint) (*domain.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if u, ok := f.users[id]; ok {
+		found := *u
+		return &found, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeUsers) FindByEmail(email string) (*domain.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, u := range f.users {
+		if strings.EqualFold(u.Email, email) {
+			found := *u
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeUsers) List() ([]domain.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var users []domain.User
+	for _, u := range f.users {
+		users = append(users, *u)
+	}
+	return users, nil
+}
+
+func (f *fakeUsers) Update(user *domain.User) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	user.Version++
+	stored := *user
+	f.users[user.ID] = &stored
+	return nil
+}
+
+func (f *fakeUsers) Delete(id uint) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.users, id)
+	return nil
+}
+
+func (f *fakeUsers) MarkEmailVerified(id uint, email string, at time.Time) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	u, ok := f.users[id]
+	if !ok || u.Email != email {
+		return false, nil
+	}
+	u.EmailVerifiedAt = &at
+	return true, nil
+}
+
+func (f *fakeUsers) UpdatePassword(id uint, hash string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if u, ok := f.users[id]; ok {
+		u.Password = hash
+	}
+	return nil
+}
+
+func (f *fakeUsers) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.users)
+}
+
+// fakeIdentities is an in-memory repository.IdentityRepository.
+type fakeIdentities struct {
+	mu         sync.Mutex
+	identities []domain.UserIdentity
+	states     map[string]domain.OIDCLoginState
+}
+
+func newFakeIdentities() *fakeIdentities {
+	return &fakeIdentities{states: make(map[string]domain.OIDCLoginState)}
+}
+
+func (f *fakeIdentities) FindIdentity(ctx context.Context, issuer, subject string) (*domain.UserIdentity, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, identity := range f.identities {
+		if identity.Issuer == issuer && identity.Subject == subject {
+			found := identity
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeIdentities) CreateIdentity(ctx context.Context, identity *domain.UserIdentity) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	identity.ID = uint(len(f.identities) + 1)
+	f.identities = append(f.identities, *identity)
+	return nil
+}
+
+func (f *fakeIdentities) TouchIdentity(ctx context.Context, id uint, email string, at time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := range f.identities {
+		if f.identities[i].ID == id {
+			f.identities[i].Email = email
+			f.identities[i].LastLoginAt = &at
+		}
+	}
+	return nil
+}
+
+func (f *fakeIdentities) HasIdentity(ctx context.Context, userID uint) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, identity := range f.identities {
+		if identity.UserID == userID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeIdentities) CreateState(ctx context.Context, state *domain.OIDCLoginState) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.states[state.StateHash] = *state
+	return nil
+}
+
+func (f *fakeIdentities) TakeState(ctx context.Context, stateHash string, now time.Time) (*domain.OIDCLoginState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	state, ok := f.states[stateHash]
+	if !ok {
+		return nil, nil
+	}
+	delete(f.states, stateHash)
+	if !state.ExpiresAt.After(now) {
+		return nil, nil
+	}
+	return &state, nil
+}
+
+func (f *fakeIdentities) DeleteExpiredStates(ctx context.Context, now time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for hash, state := range f.states {
+		if !state.ExpiresAt.After(now) {
+			delete(f.states, hash)
+		}
+	}
+	return nil
+}
+
+// fakeRoles records the roles assigned through SetUserRoles. Other
+// RoleService methods are not expected to be called.
+type fakeRoles struct {
+	RoleService
+
+	mu    sync.Mutex
+	roles map[uint][]domain.Role
+}
+
+func newFakeRoles() *fakeRoles {
+	return &fakeRoles{roles: make(map[uint][]domain.Role)}
+}
+
+func (f *fakeRoles) SetUserRoles(ctx context.Context, userID uint, roles []domain.Role) ([]domain.Role, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.roles[userID] = append([]domain.Role(nil), roles...)
+	return roles, nil
+}
+
+func (f *fakeRoles) UserRoles(ctx context.Context, userID uint) ([]domain.Role, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.roles[userID], nil
+}
+
+// Resolve gives users their primary role plus any assigned roles, without
+// permissions.
+func (f *fakeRoles) Resolve(ctx context.Context, user *domain.User) ([]domain.Role, []domain.Permission, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	roles := []domain.Role{user.Role}
+	for _, role := range f.roles[user.ID] {
+		if role != user.Role {
+			roles = append(roles, role)
+		}
+	}
+	return roles, nil, nil
+}
+
+// fakeTokens is an in-memory repository.TokenRepository.
+type fakeTokens struct {
+	mu      sync.Mutex
+	nextID  int64
+	refresh map[int64]*domain.RefreshToken
+	resets  map[int64]*domain.PasswordResetToken
+	denied  map[string]time.Time
+}
+
+func newFakeTokens() *fakeTokens {
+	return &fakeTokens{
+		refresh: make(map[int64]*domain.RefreshToken),
+		resets:  make(map[int64]*domain.PasswordResetToken),
+		denied:  make(map[string]time.Time),
+	}
+}
+
+func (f *fakeTokens) CreateRefreshToken(ctx context.Context, t *domain.RefreshToken) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.nextID++
+	t.ID = f.nextID
+	stored := *t
+	f.refresh[t.ID] = &stored
+	return nil
+}
+
+func (f *fakeTokens) FindRefreshToken(ctx context.Context, hash string) (*domain.RefreshToken, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, t := range f.refresh {
+		if t.TokenHash == hash {
+			found := *t
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeTokens) MarkUsed(ctx context.Context, id int64, at time.Time) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, ok := f.refresh[id]
+	if !ok || t.UsedAt != nil || t.RevokedAt != nil {
+		return false, nil
+	}
+	t.UsedAt = &at
+	return true, nil
+}
+
+func (f *fakeTokens) RevokeFamily(ctx context.Context, familyID string, at time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, t := range f.refresh {
+		if t.FamilyID == familyID && t.RevokedAt == nil {
+			t.RevokedAt = &at
+		}
+	}
+	return nil
+}
+
+func (f *fakeTokens) RevokeUser(ctx context.Context, userID uint, at time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, t := range f.refresh {
+		if t.UserID == userID && t.RevokedAt == nil {
+			t.RevokedAt = &at
+		}
+	}
+	return nil
+}
+
+func (f *fakeTokens) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.denied[jti] = expiresAt
+	return nil
+}
+
+func (f *fakeTokens) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	_, ok := f.denied[jti]
+	return ok, nil
+}
+
+func (f *fakeTokens) CreateResetToken(ctx context.Context, t *domain.PasswordResetToken) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.nextID++
+	t.ID = f.nextID
+	stored := *t
+	f.resets[t.ID] = &stored
+	return nil
+}
+
+func (f *fakeTokens) FindResetToken(ctx context.Context, hash string) (*domain.PasswordResetToken, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, t := range f.resets {
+		if t.TokenHash == hash {
+			found := *t
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeTokens) MarkResetTokenUsed(ctx context.Context, id int64, at time.Time) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, ok := f.resets[id]
+	if !ok || t.UsedAt != nil {
+		return false, nil
+	}
+	t.UsedAt = &at
+	return true, nil
+}
+
+func (f *fakeTokens) UseResetTokens(ctx context.Context, userID uint, at time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, t := range f.resets {
+		if t.UserID == userID && t.UsedAt == nil {
+			t.UsedAt = &at
+		}
+	}
+	return nil
+}
+
+func (f *fakeTokens) DeleteExpired(ctx context.Context, before time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for id, t := range f.refresh {
+		if t.ExpiresAt.Before(before) {
+			delete(f.refresh, id)
+		}
+	}
+	for jti, expiresAt := range f.denied {
+		if expiresAt.Before(before) {
+			delete(f.denied, jti)
+		}
+	}
+	return nil
+}
+
+func (f *fakeRoles) Roles(ctx context.Context, user *domain.User) ([]domain.Role, error) {
+	roles, _, err := f.Resolve(ctx, user)
+	return roles, err
+}
@@ -0,0 +1,268 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/auth"
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
+	"github.com/rkweber-max/checkout-backend/internal/user/repository"
+	"github.com/rkweber-max/checkout-backend/pkg/config"
+)
+
+const (
+	defaultJWTIssuer          = "checkout-backend"
+	defaultJWTAudience        = "checkout-api"
+	defaultKeyRotationDays    = 30
+	defaultKeyGraceHours      = 24
+	keyCacheTTL               = time.Minute
+	keyUnknownKIDReloadPeriod = 5 * time.Second
+)
+
+// KeyService manages the keys access tokens are signed with. Keys are
+// stored so every instance signs with the same key and can verify tokens
+// signed by the others; each instance caches them for a minute.
+type KeyService interface {
+	auth.KeyProvider
+	TokenOptions() auth.TokenOptions
+	JWKS(ctx context.Context) (auth.JWKSet, error)
+	Rotate(ctx context.Context) error
+}
+
+type keyService struct {
+	repo      repository.KeyRepository
+	box       *auth.SecretBox
+	algorithm string
+	issuer    string
+	audience  string
+	rotation  time.Duration
+	grace     time.Duration
+
+	mu              sync.Mutex
+	active          *auth.Key
+	activeCreatedAt time.Time
+	keys            map[string]*auth.Key
+	loadedAt        time.Time
+}
+
+func NewKeyService(repo repository.KeyRepository, cfg *config.Config) (KeyService, error) {
+	box, err := auth.NewSecretBox(cfg.JWTKeyEncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	if !box.Encrypted() {
+		log.Printf("JWT_KEY_ENCRYPTION_KEY is not set; JWT signing keys are stored unencrypted")
+	}
+
+	algorithm := cfg.JWTAlgorithm
+	if algorithm == "" {
+		algorithm = auth.AlgorithmRS256
+	}
+	issuer := cfg.JWTIssuer
+	if issuer == "" {
+		issuer = defaultJWTIssuer
+	}
+	audience := cfg.JWTAudience
+	if audience == "" {
+		audience = defaultJWTAudience
+	}
+	rotationDays := cfg.JWTKeyRotationDays
+	if rotationDays <= 0 {
+		rotationDays = defaultKeyRotationDays
+	}
+	graceHours := cfg.JWTKeyGraceHours
+	if graceHours <= 0 {
+		graceHours = defaultKeyGraceHours
+	}
+
+	// A retired key must outlive every token it signed: access tokens,
+	// email verification links and MFA challenges.
+	grace := time.Duration(graceHours) * time.Hour
+	for _, ttl := range []time.Duration{accessTokenTTL(cfg), emailVerificationTTL(cfg), mfaChallengeTTL} {
+		if grace < ttl {
+			grace = ttl
+		}
+	}
+
+	return &keyService{
+		repo:      repo,
+		box:       box,
+		algorithm: algorithm,
+		issuer:    issuer,
+		audience:  audience,
+		rotation:  time.Duration(rotationDays) * 24 * time.Hour,
+		grace:     grace,
+	}, nil
+}
+
+func (s *keyService) TokenOptions() auth.TokenOptions {
+	return auth.TokenOptions{Issuer: s.issuer, Audience: s.audience}
+}
+
+// SigningKey returns the active key, creating the first one when there
+// is none yet.
+func (s *keyService) SigningKey(ctx context.Context) (*auth.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.loadIfStale(ctx, keyCacheTTL); err != nil {
+		return nil, err
+	}
+	if s.active != nil {
+		return s.active, nil
+	}
+
+	if _, err := s.create(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.load(ctx); err != nil {
+		return nil, err
+	}
+	return s.active, nil
+}
+
+// VerificationKey returns the active or recently retired key with the
+// given ID, or nil. An unknown ID reloads the keys, since another instance
+// may have rotated.
+func (s *keyService) VerificationKey(ctx context.Context, kid string) (*auth.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.loadIfStale(ctx, keyCacheTTL); err != nil {
+		return nil, err
+	}
+	if key, ok := s.keys[kid]; ok {
+		return key, nil
+	}
+
+	if err := s.loadIfStale(ctx, keyUnknownKIDReloadPeriod); err != nil {
+		return nil, err
+	}
+	return s.keys[kid], nil
+}
+
+// JWKS publishes the public half of every key tokens may be verified
+// against.
+func (s *keyService) JWKS(ctx context.Context) (auth.JWKSet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.loadIfStale(ctx, keyCacheTTL); err != nil {
+		return auth.JWKSet{}, err
+	}
+
+	set := auth.JWKSet{Keys: make([]auth.JWK, 0, len(s.keys))}
+	for _, key := range s.keys {
+		jwk, err := auth.PublicJWK(key)
+		if err != nil {
+			return auth.JWKSet{}, err
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set, nil
+}
+
+// Rotate replaces the active key once it is older than the rotation
+// period, or when the configured algorithm changed, and deletes retired
+// keys past the grace period.
+func (s *keyService) Rotate(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if err := s.load(ctx); err != nil {
+		return err
+	}
+
+	if s.active == nil || s.active.Algorithm != s.algorithm || now.Sub(s.activeCreatedAt) >= s.rotation {
+		created, err := s.create(ctx)
+		if err != nil {
+			return err
+		}
+		if err := s.repo.RetireAllExcept(ctx, created.KID, now); err != nil {
+			return err
+		}
+		log.Printf("Rotated JWT signing key; new key %s", created.KID)
+	}
+
+	if err := s.repo.DeleteRetiredBefore(ctx, now.Add(-s.grace)); err != nil {
+		return err
+	}
+	return s.load(ctx)
+}
+
+func (s *keyService) loadIfStale(ctx context.Context, maxAge time.Duration) error {
+	if s.keys != nil && time.Since(s.loadedAt) < maxAge {
+		return nil
+	}
+	return s.load(ctx)
+}
+
+// load caches the usable keys. The newest key that is not retired is the
+// active one.
+func (s *keyService) load(ctx context.Context) error {
+	stored, err := s.repo.FindUsable(ctx, time.Now().Add(-s.grace))
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]*auth.Key, len(stored))
+	var active *auth.Key
+	var activeCreatedAt time.Time
+	for _, k := range stored {
+		encoded, err := s.box.Open(k.PrivateKey)
+		if err != nil {
+			log.Printf("Skipping unreadable JWT signing key %s: %v", k.KID, err)
+			continue
+		}
+		private, err := auth.DecodePrivateKey(encoded)
+		if err != nil {
+			log.Printf("Skipping unreadable JWT signing key %s: %v", k.KID, err)
+			continue
+		}
+		key := &auth.Key{ID: k.KID, Algorithm: k.Algorithm, Private: private}
+		keys[k.KID] = key
+		if active == nil && k.RetiredAt == nil {
+			active = key
+			activeCreatedAt = k.CreatedAt
+		}
+	}
+
+	s.keys = keys
+	s.active = active
+	s.activeCreatedAt = activeCreatedAt
+	s.loadedAt = time.Now()
+	return nil
+}
+
+func (s *keyService) create(ctx context.Context) (*domain.SigningKey, error) {
+	private, err := auth.GenerateKey(s.algorithm)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := auth.EncodePrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := s.box.Seal(encoded)
+	if err != nil {
+		return nil, err
+	}
+	kid, err := auth.RandomToken(8)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &domain.SigningKey{
+		KID:        kid,
+		Algorithm:  s.algorithm,
+		PrivateKey: sealed,
+		CreatedAt:  time.Now(),
+	}
+	if err := s.repo.Create(ctx, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
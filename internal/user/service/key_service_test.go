@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/auth"
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
+	"github.com/rkweber-max/checkout-backend/internal/user/repository"
+	"github.com/rkweber-max/checkout-backend/pkg/config"
+)
+
+var _ repository.KeyRepository = (*fakeSigningKeys)(nil)
+
+// fakeSigningKeys is an in-memory repository.KeyRepository.
+type fakeSigningKeys struct {
+	mu   sync.Mutex
+	keys []domain.SigningKey
+}
+
+func (f *fakeSigningKeys) Create(ctx context.Context, k *domain.SigningKey) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.keys = append(f.keys, *k)
+	return nil
+}
+
+func (f *fakeSigningKeys) FindUsable(ctx context.Context, retiredAfter time.Time) ([]domain.SigningKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var usable []domain.SigningKey
+	for _, k := range f.keys {
+		if k.RetiredAt == nil || k.RetiredAt.After(retiredAfter) {
+			usable = append(usable, k)
+		}
+	}
+	sort.SliceStable(usable, func(i, j int) bool { return usable[i].CreatedAt.After(usable[j].CreatedAt) })
+	return usable, nil
+}
+
+func (f *fakeSigningKeys) RetireAllExcept(ctx context.Context, kid string, at time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := range f.keys {
+		if f.keys[i].KID != kid && f.keys[i].RetiredAt == nil {
+			f.keys[i].RetiredAt = &at
+		}
+	}
+	return nil
+}
+
+func (f *fakeSigningKeys) DeleteRetiredBefore(ctx context.Context, before time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	kept := f.keys[:0]
+	for _, k := range f.keys {
+		if k.RetiredAt == nil || !k.RetiredAt.Before(before) {
+			kept = append(kept, k)
+		}
+	}
+	f.keys = kept
+	return nil
+}
+
+func newTestKeyService(t *testing.T, repo repository.KeyRepository, cfg *config.Config) *keyService {
+	t.Helper()
+
+	if cfg.JWTAlgorithm == "" {
+		cfg.JWTAlgorithm = auth.AlgorithmEdDSA
+	}
+	s, err := NewKeyService(repo, cfg)
+	if err != nil {
+		t.Fatalf("NewKeyService: %v", err)
+	}
+	return s.(*keyService)
+}
+
+func signTestToken(t *testing.T, s KeyService) string {
+	t.Helper()
+
+	key, err := s.SigningKey(context.Background())
+	if err != nil {
+		t.Fatalf("SigningKey: %v", err)
+	}
+	opts := s.TokenOptions()
+	opts.TTL = time.Minute
+	token, err := auth.GenerateToken(key, opts, auth.Subject{UserID: 1, Role: domain.RoleAdmin})
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	return token.Token
+}
+
+func TestKeyServiceSealsStoredKeys(t *testing.T) {
+	repo := &fakeSigningKeys{}
+	s := newTestKeyService(t, repo, &config.Config{JWTKeyEncryptionKey: "key-encryption-passphrase"})
+
+	token := signTestToken(t, s)
+	if len(repo.keys) != 1 {
+		t.Fatalf("stored %d keys, want 1", len(repo.keys))
+	}
+	if stored := repo.keys[0].PrivateKey; !strings.HasPrefix(stored, "v1:") || strings.Contains(stored, "PRIVATE KEY") {
+		t.Errorf("private key stored unsealed: %.40q", stored)
+	}
+
+	// Another instance with the same passphrase verifies the token.
+	other := newTestKeyService(t, repo, &config.Config{JWTKeyEncryptionKey: "key-encryption-passphrase"})
+	if _, err := auth.ParseToken(context.Background(), other, other.TokenOptions(), token); err != nil {
+		t.Errorf("ParseToken on another instance: %v", err)
+	}
+
+	// One with the wrong passphrase can't read the key.
+	wrong := newTestKeyService(t, repo, &config.Config{JWTKeyEncryptionKey: "another-passphrase"})
+	if _, err := auth.ParseToken(context.Background(), wrong, wrong.TokenOptions(), token); err == nil {
+		t.Error("token verified with a key opened by the wrong passphrase")
+	}
+}
+
+func TestKeyServiceReadsKeysStoredBeforeEncryption(t *testing.T) {
+	repo := &fakeSigningKeys{}
+	plain := newTestKeyService(t, repo, &config.Config{})
+	token := signTestToken(t, plain)
+
+	sealed := newTestKeyService(t, repo, &config.Config{JWTKeyEncryptionKey: "key-encryption-passphrase"})
+	if _, err := auth.ParseToken(context.Background(), sealed, sealed.TokenOptions(), token); err != nil {
+		t.Errorf("ParseToken with an unencrypted stored key: %v", err)
+	}
+}
+
+func TestKeyServiceRotate(t *testing.T) {
+	repo := &fakeSigningKeys{}
+	s := newTestKeyService(t, repo, &config.Config{JWTKeyRotationDays: 1, JWTKeyGraceHours: 1})
+	ctx := context.Background()
+
+	oldToken := signTestToken(t, s)
+	oldKey, _ := s.SigningKey(ctx)
+
+	// Not due yet: the active key stays.
+	if err := s.Rotate(ctx); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if key, _ := s.SigningKey(ctx); key.ID != oldKey.ID || len(repo.keys) != 1 {
+		t.Fatalf("key rotated before the rotation period")
+	}
+
+	repo.keys[0].CreatedAt = time.Now().Add(-25 * time.Hour)
+	if err := s.Rotate(ctx); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	newKey, _ := s.SigningKey(ctx)
+	if newKey.ID == oldKey.ID {
+		t.Fatal("key not rotated after the rotation period")
+	}
+
+	// Tokens signed with the retired key still verify during the grace
+	// period, and its public half is still published.
+	if _, err := auth.ParseToken(ctx, s, s.TokenOptions(), oldToken); err != nil {
+		t.Errorf("token signed before rotation rejected: %v", err)
+	}
+	if set, _ := s.JWKS(ctx); len(set.Keys) != 2 {
+		t.Errorf("JWKS has %d keys, want 2", len(set.Keys))
+	}
+
+	// Past the grace period the retired key is deleted.
+	retiredAt := time.Now().Add(-s.grace - time.Hour)
+	for i := range repo.keys {
+		if repo.keys[i].KID == oldKey.ID {
+			repo.keys[i].RetiredAt = &retiredAt
+		}
+	}
+	if err := s.Rotate(ctx); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if len(repo.keys) != 1 || repo.keys[0].KID != newKey.ID {
+		t.Errorf("keys after the grace period = %d, want only the active one", len(repo.keys))
+	}
+	if _, err := auth.ParseToken(ctx, s, s.TokenOptions(), oldToken); err == nil {
+		t.Error("token signed with a deleted key accepted")
+	}
+}
+
+func TestKeyServiceRotatesOnAlgorithmChange(t *testing.T) {
+	repo := &fakeSigningKeys{}
+	ctx := context.Background()
+	signTestToken(t, newTestKeyService(t, repo, &config.Config{JWTAlgorithm: auth.AlgorithmEdDSA}))
+
+	s := newTestKeyService(t, repo, &config.Config{JWTAlgorithm: auth.AlgorithmRS256})
+	if err := s.Rotate(ctx); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if key, _ := s.SigningKey(ctx); key.Algorithm != auth.AlgorithmRS256 {
+		t.Errorf("active key algorithm = %q, want RS256", key.Algorithm)
+	}
+}
+
+func TestKeyServiceGraceCoversEveryTokenTTL(t *testing.T) {
+	tests := []struct {
+		cfg  config.Config
+		want time.Duration
+	}{
+		{config.Config{JWTKeyGraceHours: 1}, 24 * time.Hour},
+		{config.Config{JWTKeyGraceHours: 1, EmailVerificationTTLHours: 72}, 72 * time.Hour},
+		{config.Config{JWTKeyGraceHours: 100, EmailVerificationTTLHours: 72}, 100 * time.Hour},
+		{config.Config{JWTKeyGraceHours: 1, EmailVerificationTTLHours: 1, AccessTokenTTLMinutes: 180}, 3 * time.Hour},
+	}
+	for _, tt := range tests {
+		cfg := tt.cfg
+		if got := newTestKeyService(t, &fakeSigningKeys{}, &cfg).grace; got != tt.want {
+			t.Errorf("grace for %+v = %v, want %v", tt.cfg, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/rkweber-max/checkout-backend/internal/auth"
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
+	"github.com/rkweber-max/checkout-backend/internal/user/repository"
+	"github.com/rkweber-max/checkout-backend/pkg/config"
+	"github.com/rkweber-max/checkout-backend/pkg/ratelimit"
+)
+
+const (
+	defaultLoginMaxFailures    = 10
+	defaultLoginLockoutMinutes = 30
+	defaultLoginIPMaxFailures  = 50
+	loginEventsLimit           = 100
+)
+
+// ErrInvalidCredentials is the only error a failed login reports, whatever
+// the cause, so it can't be used to find out which emails are registered.
+var ErrInvalidCredentials = errors.New("invalid email or password")
+
+// ErrSSORequired is returned, after the password was checked, for users
+// who sign in through the identity provider.
+var ErrSSORequired = errors.New("this account signs in with the company identity provider")
+
+// LoginService checks credentials while slowing down guessing: failures
+// are counted per email and per client IP, each further failure blocks
+// logins for longer, and enough of them lock the account for a while.
+type LoginService interface {
+	Authenticate(ctx context.Context, email, password, ip string) (*domain.User, error)
+	Unlock(ctx context.Context, userID uint) error
+	Events(ctx context.Context, userID uint) ([]domain.LoginEvent, error)
+	Cleanup(ctx context.Context) error
+}
+
+type loginService struct {
+	repo       repository.LoginRepository
+	users      repository.UserRepository
+	identities repository.IdentityRepository
+	policy     ratelimit.Policy
+	byIP       *ratelimit.Backoff
+	dummyHash  []byte
+}
+
+func NewLoginService(
+	repo repository.LoginRepository,
+	users repository.UserRepository,
+	identities repository.IdentityRepository,
+	cfg *config.Config,
+) (LoginService, error) {
+	maxFailures := cfg.LoginMaxFailures
+	if maxFailures <= 0 {
+		maxFailures = defaultLoginMaxFailures
+	}
+	lockoutMinutes := cfg.LoginLockoutMinutes
+	if lockoutMinutes <= 0 {
+		lockoutMinutes = defaultLoginLockoutMinutes
+	}
+	ipMaxFailures := cfg.LoginIPMaxFailures
+	if ipMaxFailures <= 0 {
+		ipMaxFailures = defaultLoginIPMaxFailures
+	}
+	lockout := time.Duration(lockoutMinutes) * time.Minute
+
+	// Unknown emails are checked against this hash so they take as long
+	// as a wrong password for a real account.
+	random, err := auth.RandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	dummyHash, err := bcrypt.GenerateFromPassword([]byte(random), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	return &loginService{
+		repo:       repo,
+		users:      users,
+		identities: identities,
+		policy: ratelimit.Policy{
+			FreeAttempts: 3,
+			MaxFailures:  maxFailures,
+			Base:         time.Second,
+			Cap:          5 * time.Minute,
+			Lockout:      lockout,
+			Window:       time.Hour,
+		},
+		byIP: ratelimit.NewBackoff(ratelimit.Policy{
+			FreeAttempts: 10,
+			MaxFailures:  ipMaxFailures,
+			Base:         time.Second,
+			Cap:          5 * time.Minute,
+			Lockout:      lockout,
+			Window:       time.Hour,
+		}),
+		dummyHash: dummyHash,
+	}, nil
+}
+
+// Authenticate returns the user the credentials belong to. Blocked
+// attempts fail with a RateLimitError before the password is looked at;
+// every other failure is ErrInvalidCredentials. Users linked to the
+// identity provider get ErrSSORequired instead of a session.
+func (s *loginService) Authenticate(ctx context.Context, email, password, ip string) (*domain.User, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	password = strings.TrimSpace(password)
+	now := time.Now()
+
+	if wait := s.byIP.Wait(ip); wait > 0 {
+		s.audit(ctx, &domain.LoginEvent{Kind: domain.LoginThrottled, Email: email, IP: ip, Reason: "ip blocked"})
+		return nil, &RateLimitError{RetryAfter: wait}
+	}
+
+	throttle, err := s.repo.FindThrottle(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if throttle != nil && throttle.LockedUntil != nil && throttle.LockedUntil.After(now) {
+		s.audit(ctx, &domain.LoginEvent{Kind: domain.LoginThrottled, Email: email, IP: ip, Reason: "account blocked"})
+		return nil, &RateLimitError{RetryAfter: throttle.LockedUntil.Sub(now)}
+	}
+
+	user, err := s.users.FindByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, reason := s.dummyHash, "unknown email"
+	if user != nil {
+		hash, reason = []byte(user.Password), "wrong password"
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || user == nil {
+		if user != nil && !strings.HasPrefix(user.Password, "$2") {
+			reason = "password hash unreadable"
+		}
+		if err := s.fail(ctx, email, ip, user, reason, now); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	if throttle != nil {
+		if err := s.repo.DeleteThrottle(ctx, email); err != nil {
+			return nil, err
+		}
+	}
+
+	linked, err := s.identities.HasIdentity(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if linked {
+		return nil, ErrSSORequired
+	}
+	return user, nil
+}
+
+// Unlock clears the failures of the user's account, ending a lockout. The
+// admin acting in ctx is recorded.
+func (s *loginService) Unlock(ctx context.Context, userID uint) error {
+	user, err := s.users.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	if err := s.repo.DeleteThrottle(ctx, user.Email); err != nil {
+		return err
+	}
+	return s.repo.CreateEvent(ctx, &domain.LoginEvent{
+		Kind:    domain.LoginUnlocked,
+		Email:   user.Email,
+		UserID:  &user.ID,
+		ActorID: auth.ActorFromContext(ctx),
+	})
+}
+
+func (s *loginService) Events(ctx context.Context, userID uint) ([]domain.LoginEvent, error) {
+	user, err := s.users.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return s.repo.FindEvents(ctx, userID, loginEventsLimit)
+}
+
+// Cleanup forgets failure counts that are no longer blocking anything.
+func (s *loginService) Cleanup(ctx context.Context) error {
+	return s.repo.DeleteStale(ctx, time.Now().Add(-s.policy.Window))
+}
+
+// fail counts the failure against the email and the IP, blocks the email
+// for as long as the policy says, and records the attempt.
+func (s *loginService) fail(ctx context.Context, email, ip string, user *domain.User, reason string, at time.Time) error {
+	var userID *uint
+	if user != nil {
+		userID = &user.ID
+	}
+	s.byIP.Fail(ip)
+	s.audit(ctx, &domain.LoginEvent{Kind: domain.LoginFailed, Email: email, UserID: userID, IP: ip, Reason: reason})
+
+	failures, err := s.repo.RecordFailure(ctx, email, at, at.Add(-s.policy.Window))
+	if err != nil {
+		return err
+	}
+	delay := s.policy.Delay(failures)
+	if delay == 0 {
+		return nil
+	}
+	if err := s.repo.Lock(ctx, email, at.Add(delay)); err != nil {
+		return err
+	}
+	if failures == s.policy.MaxFailures {
+		s.audit(ctx, &domain.LoginEvent{Kind: domain.LoginLocked, Email: email, UserID: userID, IP: ip, Reason: reason})
+	}
+	return nil
+}
+
+// audit records the event. A failure to write it is logged rather than
+// failing the login.
+func (s *loginService) audit(ctx context.Context, e *domain.LoginEvent) {
+	if err := s.repo.CreateEvent(ctx, e); err != nil {
+		log.Printf("Failed to record %s event for %s: %v", e.Kind, e.Email, err)
+	}
+}
@@ -0,0 +1,541 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"image/png"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+
+	"github.com/rkweber-max/checkout-backend/internal/auth"
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
+	"github.com/rkweber-max/checkout-backend/internal/user/repository"
+	"github.com/rkweber-max/checkout-backend/pkg/config"
+	"github.com/rkweber-max/checkout-backend/pkg/ratelimit"
+)
+
+const (
+	defaultMFAIssuer   = "Checkout"
+	mfaAudience        = "mfa"
+	mfaChallengeTTL    = 5 * time.Minute
+	mfaPeriod          = 30
+	mfaSkew            = 1
+	mfaQRCodeSize      = 256
+	recoveryCodeCount  = 10
+	purposeMFAVerify   = "verify"
+	purposeMFAEnroll   = "enroll"
+	recoveryCodeLength = 10
+)
+
+var (
+	ErrInvalidMFAToken    = errors.New("invalid or expired MFA token")
+	ErrInvalidMFACode     = errors.New("invalid authentication code")
+	ErrMFANotEnabled      = errors.New("two-factor authentication is not enabled")
+	ErrMFAAlreadyEnabled  = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnrolling    = errors.New("start enrollment before confirming it")
+	ErrMFARequiredForRole = errors.New("two-factor authentication is required for one of your roles")
+)
+
+// defaultMFARequired applies to roles without a policy of their own.
+var defaultMFARequired = map[domain.Role]bool{
+	domain.RoleAdmin:    true,
+	domain.RoleEmployee: true,
+}
+
+// MFAChallenge is returned by a login that needs a second step instead of
+// tokens. With EnrollmentRequired the user must set up TOTP first.
+type MFAChallenge struct {
+	MFARequired        bool   `json:"mfa_required"`
+	EnrollmentRequired bool   `json:"enrollment_required"`
+	Token              string `json:"mfa_token"`
+	ExpiresIn          int    `json:"expires_in"`
+}
+
+// MFASetup is what an authenticator app needs to add the account.
+type MFASetup struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+	QRCode     string `json:"qr_code"`
+}
+
+// MFAService manages TOTP two-factor authentication: enrollment, recovery
+// codes, the second login step and which roles must use it.
+type MFAService interface {
+	Challenge(ctx context.Context, user *domain.User) (*MFAChallenge, error)
+	ChallengeUser(ctx context.Context, token string, enrollment bool) (*domain.User, error)
+	Enroll(ctx context.Context, user *domain.User) (*MFASetup, error)
+	Confirm(ctx context.Context, userID uint, code string) ([]string, error)
+	Verify(ctx context.Context, userID uint, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID uint, code string) ([]string, error)
+	Disable(ctx context.Context, user *domain.User, code string) error
+	Reset(ctx context.Context, userID uint) error
+	Policies(ctx context.Context) ([]domain.MFAPolicy, error)
+	SetPolicy(ctx context.Context, role domain.Role, required bool) (*domain.MFAPolicy, error)
+}
+
+type mfaService struct {
+	repo     repository.MFARepository
+	users    repository.UserRepository
+	logins   repository.LoginRepository
+	roles    RoleService
+	keys     KeyService
+	box      *auth.SecretBox
+	issuer   string
+	failures *ratelimit.Backoff
+}
+
+func NewMFAService(
+	repo repository.MFARepository,
+	users repository.UserRepository,
+	logins repository.LoginRepository,
+	roles RoleService,
+	keys KeyService,
+	cfg *config.Config,
+) (MFAService, error) {
+	box, err := auth.NewSecretBox(cfg.MFAEncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	if !box.Encrypted() {
+		log.Printf("MFA_ENCRYPTION_KEY is not set; TOTP secrets are stored unencrypted")
+	}
+
+	issuer := cfg.MFAIssuer
+	if issuer == "" {
+		issuer = defaultMFAIssuer
+	}
+
+	return &mfaService{
+		repo:   repo,
+		users:  users,
+		logins: logins,
+		roles:  roles,
+		keys:   keys,
+		box:    box,
+		issuer: issuer,
+		failures: ratelimit.NewBackoff(ratelimit.Policy{
+			FreeAttempts: 3,
+			MaxFailures:  10,
+			Base:         time.Second,
+			Cap:          time.Minute,
+			Lockout:      15 * time.Minute,
+			Window:       time.Hour,
+		}),
+	}, nil
+}
+
+// Challenge decides whether the user, who just proved their password,
+// needs a second step. It returns nil when tokens can be issued right
+// away.
+func (s *mfaService) Challenge(ctx context.Context, user *domain.User) (*MFAChallenge, error) {
+	enrollment, err := s.repo.FindEnrollment(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	purpose := purposeMFAVerify
+	if enrollment == nil || !enrollment.Confirmed() {
+		required, err := s.required(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		if !required {
+			return nil, nil
+		}
+		purpose = purposeMFAEnroll
+	}
+
+	key, err := s.keys.SigningKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	token, err := auth.IssueToken(key, s.tokenOptions(), map[string]interface{}{
+		"sub":     user.ID,
+		"purpose": purpose,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &MFAChallenge{
+		MFARequired:        true,
+		EnrollmentRequired: purpose == purposeMFAEnroll,
+		Token:              token.Token,
+		ExpiresIn:          int(mfaChallengeTTL.Seconds()),
+	}, nil
+}
+
+// ChallengeUser returns the user an MFA token was issued to. enrollment
+// selects tokens that allow setting up TOTP rather than verifying a code.
+func (s *mfaService) ChallengeUser(ctx context.Context, token string, enrollment bool) (*domain.User, error) {
+	claims, err := auth.ParseToken(ctx, s.keys, s.tokenOptions(), token)
+	if err != nil {
+		return nil, ErrInvalidMFAToken
+	}
+
+	want := purposeMFAVerify
+	if enrollment {
+		want = purposeMFAEnroll
+	}
+	purpose, _ := claims["purpose"].(string)
+	sub, _ := claims["sub"].(float64)
+	if purpose != want || sub <= 0 {
+		return nil, ErrInvalidMFAToken
+	}
+
+	user, err := s.users.FindByID(uint(sub))
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidMFAToken
+	}
+	return user, nil
+}
+
+// Enroll generates a new secret for the user. It stays pending, and logins
+// don't ask for it, until confirmed with a code.
+func (s *mfaService) Enroll(ctx context.Context, user *domain.User) (*MFASetup, error) {
+	existing, err := s.repo.FindEnrollment(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.Confirmed() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      s.issuer,
+		AccountName: user.Email,
+		Period:      mfaPeriod,
+		Digits:      otp.DigitsSix,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := s.box.Seal(key.Secret())
+	if err != nil {
+		return nil, err
+	}
+	err = s.repo.SaveEnrollment(ctx, &domain.MFAEnrollment{UserID: user.ID, Secret: sealed})
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := key.Image(mfaQRCodeSize, mfaQRCodeSize)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	return &MFASetup{
+		Secret:     key.Secret(),
+		OTPAuthURI: key.URL(),
+		QRCode:     "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}, nil
+}
+
+// Confirm activates the pending enrollment with a first code from the
+// authenticator and returns the recovery codes. They are shown only once.
+func (s *mfaService) Confirm(ctx context.Context, userID uint, code string) ([]string, error) {
+	enrollment, err := s.repo.FindEnrollment(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if enrollment == nil {
+		return nil, ErrMFANotEnrolling
+	}
+	if enrollment.Confirmed() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	if err := s.allow(ctx, userID); err != nil {
+		return nil, err
+	}
+	step, err := s.matchCode(enrollment, code)
+	if err != nil {
+		return nil, err
+	}
+	if step == 0 {
+		return nil, s.fail(ctx, userID, "wrong code on enrollment")
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	confirmed, err := s.repo.ConfirmEnrollment(ctx, userID, step, hashes, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !confirmed {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	s.failures.Reset(failureKey(userID))
+	return codes, nil
+}
+
+// Verify accepts a current TOTP code or an unused recovery code. Each is
+// accepted once; repeated failures block further attempts for a while.
+func (s *mfaService) Verify(ctx context.Context, userID uint, code string) error {
+	enrollment, err := s.repo.FindEnrollment(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if enrollment == nil || !enrollment.Confirmed() {
+		return ErrMFANotEnabled
+	}
+
+	if err := s.allow(ctx, userID); err != nil {
+		return err
+	}
+
+	step, err := s.matchCode(enrollment, code)
+	if err != nil {
+		return err
+	}
+	if step > 0 {
+		used, err := s.repo.UseStep(ctx, userID, step)
+		if err != nil {
+			return err
+		}
+		if used {
+			s.failures.Reset(failureKey(userID))
+			return nil
+		}
+		return s.fail(ctx, userID, "code replayed")
+	}
+
+	used, err := s.repo.UseRecoveryCode(ctx, userID, hashToken(normalizeRecoveryCode(code)), time.Now())
+	if err != nil {
+		return err
+	}
+	if used {
+		s.failures.Reset(failureKey(userID))
+		log.Printf("User %d logged in with a recovery code", userID)
+		return nil
+	}
+	return s.fail(ctx, userID, "wrong code")
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes of the user after
+// checking a current code.
+func (s *mfaService) RegenerateRecoveryCodes(ctx context.Context, userID uint, code string) ([]string, error) {
+	if err := s.Verify(ctx, userID, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Disable turns two-factor authentication off after checking a current
+// code. Users holding a role that requires it can't turn it off.
+func (s *mfaService) Disable(ctx context.Context, user *domain.User, code string) error {
+	required, err := s.required(ctx, user)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrMFARequiredForRole
+	}
+
+	if err := s.Verify(ctx, user.ID, code); err != nil {
+		return err
+	}
+	return s.repo.DeleteEnrollment(ctx, user.ID)
+}
+
+// Reset removes the user's enrollment, e.g. after they lost their device
+// and recovery codes. If one of their roles requires MFA they enroll again
+// on the next login. The admin acting in ctx is recorded.
+func (s *mfaService) Reset(ctx context.Context, userID uint) error {
+	user, err := s.users.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	if err := s.repo.DeleteEnrollment(ctx, userID); err != nil {
+		return err
+	}
+	s.failures.Reset(failureKey(userID))
+	return s.logins.CreateEvent(ctx, &domain.LoginEvent{
+		Kind:    domain.MFAReset,
+		Email:   user.Email,
+		UserID:  &user.ID,
+		ActorID: auth.ActorFromContext(ctx),
+	})
+}
+
+// Policies returns the policy of every role, including the defaults of
+// roles that have none stored.
+func (s *mfaService) Policies(ctx context.Context) ([]domain.MFAPolicy, error) {
+	stored, err := s.repo.FindPolicies(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byRole := make(map[domain.Role]domain.MFAPolicy, len(stored))
+	for _, p := range stored {
+		byRole[p.Role] = p
+	}
+
+	roles, err := s.roles.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	policies := make([]domain.MFAPolicy, 0, len(roles))
+	for _, role := range roles {
+		p, ok := byRole[role.Name]
+		if !ok {
+			p = domain.MFAPolicy{Role: role.Name, Required: defaultMFARequired[role.Name]}
+		}
+		policies = append(policies, p)
+	}
+	return policies, nil
+}
+
+func (s *mfaService) SetPolicy(ctx context.Context, role domain.Role, required bool) (*domain.MFAPolicy, error) {
+	if _, err := s.roles.Get(ctx, role); err != nil {
+		return nil, err
+	}
+
+	policy := &domain.MFAPolicy{Role: role, Required: required}
+	if err := s.repo.SavePolicy(ctx, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// required reports whether any of the user's roles requires MFA.
+func (s *mfaService) required(ctx context.Context, user *domain.User) (bool, error) {
+	roles, err := s.roles.Roles(ctx, user)
+	if err != nil {
+		return false, err
+	}
+
+	for _, role := range roles {
+		policy, err := s.repo.FindPolicy(ctx, role)
+		if err != nil {
+			return false, err
+		}
+		required := defaultMFARequired[role]
+		if policy != nil {
+			required = policy.Required
+		}
+		if required {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// matchCode returns the time step of the TOTP code if it is valid within
+// the allowed clock skew, or 0.
+func (s *mfaService) matchCode(enrollment *domain.MFAEnrollment, code string) (int64, error) {
+	code = strings.TrimSpace(code)
+	if len(code) != otp.DigitsSix.Length() {
+		return 0, nil
+	}
+
+	secret, err := s.box.Open(enrollment.Secret)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	opts := totp.ValidateOpts{Period: mfaPeriod, Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1}
+	for skew := -mfaSkew; skew <= mfaSkew; skew++ {
+		at := now.Add(time.Duration(skew*mfaPeriod) * time.Second)
+		expected, err := totp.GenerateCodeCustom(secret, at, opts)
+		if err != nil {
+			return 0, err
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return at.Unix() / mfaPeriod, nil
+		}
+	}
+	return 0, nil
+}
+
+func (s *mfaService) allow(ctx context.Context, userID uint) error {
+	wait := s.failures.Wait(failureKey(userID))
+	if wait <= 0 {
+		return nil
+	}
+	s.audit(ctx, domain.MFAThrottled, userID, "")
+	return &RateLimitError{RetryAfter: wait}
+}
+
+func (s *mfaService) fail(ctx context.Context, userID uint, reason string) error {
+	s.failures.Fail(failureKey(userID))
+	s.audit(ctx, domain.MFAFailed, userID, reason)
+	return ErrInvalidMFACode
+}
+
+func (s *mfaService) audit(ctx context.Context, kind domain.LoginEventKind, userID uint, reason string) {
+	user, err := s.users.FindByID(userID)
+	if err != nil || user == nil {
+		return
+	}
+	err = s.logins.CreateEvent(ctx, &domain.LoginEvent{Kind: kind, Email: user.Email, UserID: &user.ID, Reason: reason})
+	if err != nil {
+		log.Printf("Failed to record %s event for user %d: %v", kind, userID, err)
+	}
+}
+
+func (s *mfaService) tokenOptions() auth.TokenOptions {
+	opts := s.keys.TokenOptions()
+	opts.Audience = mfaAudience
+	opts.TTL = mfaChallengeTTL
+	return opts
+}
+
+// newRecoveryCodes returns fresh codes formatted for display, e.g.
+// "3f9a1-c07b2", and their hashes.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		raw, err := auth.RandomToken(recoveryCodeLength / 2)
+		if err != nil {
+			return nil, nil, err
+		}
+		codes[i] = raw[:recoveryCodeLength/2] + "-" + raw[recoveryCodeLength/2:]
+		hashes[i] = hashToken(raw)
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+func failureKey(userID uint) string {
+	return "user:" + strconv.FormatUint(uint64(userID), 10)
+}
@@ -0,0 +1,425 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
+	"github.com/rkweber-max/checkout-backend/internal/user/repository"
+	"github.com/rkweber-max/checkout-backend/pkg/config"
+)
+
+var _ repository.MFARepository = (*fakeMFA)(nil)
+
+// fakeMFA is an in-memory repository.MFARepository.
+type fakeMFA struct {
+	mu          sync.Mutex
+	enrollments map[uint]*domain.MFAEnrollment
+	codes       map[uint]map[string]bool
+	policies    map[domain.Role]domain.MFAPolicy
+}
+
+func newFakeMFA() *fakeMFA {
+	return &fakeMFA{
+		enrollments: make(map[uint]*domain.MFAEnrollment),
+		codes:       make(map[uint]map[string]bool),
+		policies:    make(map[domain.Role]domain.MFAPolicy),
+	}
+}
+
+func (f *fakeMFA) FindEnrollment(ctx context.Context, userID uint) (*domain.MFAEnrollment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if e, ok := f.enrollments[userID]; ok {
+		found := *e
+		return &found, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeMFA) SaveEnrollment(ctx context.Context, e *domain.MFAEnrollment) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stored := *e
+	f.enrollments[e.UserID] = &stored
+	return nil
+}
+
+func (f *fakeMFA) ConfirmEnrollment(ctx context.Context, userID uint, step int64, codeHashes []string, at time.Time) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	e, ok := f.enrollments[userID]
+	if !ok || e.ConfirmedAt != nil {
+		return false, nil
+	}
+	e.ConfirmedAt = &at
+	e.LastUsedStep = step
+	f.replace(userID, codeHashes)
+	return true, nil
+}
+
+func (f *fakeMFA) UseStep(ctx context.Context, userID uint, step int64) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	e, ok := f.enrollments[userID]
+	if !ok || e.LastUsedStep >= step {
+		return false, nil
+	}
+	e.LastUsedStep = step
+	return true, nil
+}
+
+func (f *fakeMFA) DeleteEnrollment(ctx context.Context, userID uint) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.enrollments, userID)
+	delete(f.codes, userID)
+	return nil
+}
+
+func (f *fakeMFA) ReplaceRecoveryCodes(ctx context.Context, userID uint, codeHashes []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.replace(userID, codeHashes)
+	return nil
+}
+
+func (f *fakeMFA) replace(userID uint, codeHashes []string) {
+	f.codes[userID] = make(map[string]bool, len(codeHashes))
+	for _, hash := range codeHashes {
+		f.codes[userID][hash] = false
+	}
+}
+
+func (f *fakeMFA) UseRecoveryCode(ctx context.Context, userID uint, codeHash string, at time.Time) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	used, ok := f.codes[userID][codeHash]
+	if !ok || used {
+		return false, nil
+	}
+	f.codes[userID][codeHash] = true
+	return true, nil
+}
+
+func (f *fakeMFA) FindPolicies(ctx context.Context) ([]domain.MFAPolicy, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var policies []domain.MFAPolicy
+	for _, p := range f.policies {
+		policies = append(policies, p)
+	}
+	return policies, nil
+}
+
+func (f *fakeMFA) FindPolicy(ctx context.Context, role domain.Role) (*domain.MFAPolicy, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if p, ok := f.policies[role]; ok {
+		return &p, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeMFA) SavePolicy(ctx context.Context, p *domain.MFAPolicy) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.policies[p.Role] = *p
+	return nil
+}
+
+// fakeLogins records login events. Throttling methods are not expected to
+// be called.
+type fakeLogins struct {
+	repository.LoginRepository
+
+	mu     sync.Mutex
+	events []domain.LoginEvent
+}
+
+func (f *fakeLogins) CreateEvent(ctx context.Context, e *domain.LoginEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.events = append(f.events, *e)
+	return nil
+}
+
+type mfaFixture struct {
+	mfa     *fakeMFA
+	logins  *fakeLogins
+	user    *domain.User
+	service MFAService
+}
+
+func newMFAFixture(t *testing.T, role domain.Role) *mfaFixture {
+	t.Helper()
+
+	user := &domain.User{Name: "Ana", Email: "ana@example.com", Role: role}
+	f := &mfaFixture{mfa: newFakeMFA(), logins: &fakeLogins{}, user: user}
+	users := newFakeUsers(user)
+
+	s, err := NewMFAService(f.mfa, users, f.logins, newFakeRoles(), newStaticKeys(t), &config.Config{MFAEncryptionKey: "mfa-encryption-passphrase"})
+	if err != nil {
+		t.Fatalf("NewMFAService: %v", err)
+	}
+	f.service = s
+	return f
+}
+
+// totpCode returns the code of the secret for the step containing at.
+func totpCode(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+
+	code, err := totp.GenerateCodeCustom(secret, at, totp.ValidateOpts{
+		Period:    mfaPeriod,
+		Digits:    otp.DigitsSix,
+		Algorithm: otp.AlgorithmSHA1,
+	})
+	if err != nil {
+		t.Fatalf("GenerateCodeCustom: %v", err)
+	}
+	return code
+}
+
+// enroll sets up and confirms TOTP for the fixture user. It returns the
+// secret and the recovery codes.
+func (f *mfaFixture) enroll(t *testing.T) (string, []string) {
+	t.Helper()
+
+	setup, err := f.service.Enroll(context.Background(), f.user)
+	if err != nil {
+		t.Fatalf("Enroll: %v", err)
+	}
+	codes, err := f.service.Confirm(context.Background(), f.user.ID, totpCode(t, setup.Secret, time.Now()))
+	if err != nil {
+		t.Fatalf("Confirm: %v", err)
+	}
+	return setup.Secret, codes
+}
+
+func TestMFAEnrollAndConfirm(t *testing.T) {
+	f := newMFAFixture(t, domain.RoleCustomer)
+	ctx := context.Background()
+
+	setup, err := f.service.Enroll(ctx, f.user)
+	if err != nil {
+		t.Fatalf("Enroll: %v", err)
+	}
+	if !strings.HasPrefix(setup.OTPAuthURI, "otpauth://totp/") || !strings.HasPrefix(setup.QRCode, "data:image/png;base64,") {
+		t.Errorf("unexpected setup %+v", setup)
+	}
+	if stored := f.mfa.enrollments[f.user.ID].Secret; strings.Contains(stored, setup.Secret) {
+		t.Error("TOTP secret stored unencrypted")
+	}
+
+	// A pending enrollment doesn't protect logins yet.
+	if err := f.service.Verify(ctx, f.user.ID, totpCode(t, setup.Secret, time.Now())); !errors.Is(err, ErrMFANotEnabled) {
+		t.Errorf("Verify before Confirm error = %v, want ErrMFANotEnabled", err)
+	}
+
+	if _, err := f.service.Confirm(ctx, f.user.ID, "000000"); !errors.Is(err, ErrInvalidMFACode) {
+		// A random secret could produce 000000, but only once in a million.
+		t.Errorf("Confirm with a wrong code error = %v, want ErrInvalidMFACode", err)
+	}
+
+	codes, err := f.service.Confirm(ctx, f.user.ID, totpCode(t, setup.Secret, time.Now()))
+	if err != nil {
+		t.Fatalf("Confirm: %v", err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("got %d recovery codes, want %d", len(codes), recoveryCodeCount)
+	}
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		if len(code) != recoveryCodeLength+1 || code[recoveryCodeLength/2] != '-' || seen[code] {
+			t.Errorf("bad or repeated recovery code %q", code)
+		}
+		seen[code] = true
+	}
+	for hash := range f.mfa.codes[f.user.ID] {
+		if seen[hash] {
+			t.Error("recovery code stored in plain text")
+		}
+	}
+
+	if _, err := f.service.Enroll(ctx, f.user); !errors.Is(err, ErrMFAAlreadyEnabled) {
+		t.Errorf("Enroll when enabled error = %v, want ErrMFAAlreadyEnabled", err)
+	}
+}
+
+func TestMFAVerifyRejectsReplayedAndStaleCodes(t *testing.T) {
+	f := newMFAFixture(t, domain.RoleCustomer)
+	ctx := context.Background()
+	secret, _ := f.enroll(t)
+	now := time.Now()
+
+	// The code used to confirm can't be used again to log in.
+	if err := f.service.Verify(ctx, f.user.ID, totpCode(t, secret, now)); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("replayed confirmation code error = %v, want ErrInvalidMFACode", err)
+	}
+
+	// The next step is within the allowed skew.
+	next := totpCode(t, secret, now.Add(mfaPeriod*time.Second))
+	if err := f.service.Verify(ctx, f.user.ID, " "+next+" "); err != nil {
+		t.Fatalf("Verify with the next code: %v", err)
+	}
+	if err := f.service.Verify(ctx, f.user.ID, next); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("replayed code error = %v, want ErrInvalidMFACode", err)
+	}
+
+	// Once a later step was used, earlier codes are refused...
+	if err := f.service.Verify(ctx, f.user.ID, totpCode(t, secret, now.Add(-mfaPeriod*time.Second))); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("earlier code error = %v, want ErrInvalidMFACode", err)
+	}
+	// ...and codes beyond the skew are never accepted.
+	f.mfa.enrollments[f.user.ID].LastUsedStep = 0
+	if err := f.service.Verify(ctx, f.user.ID, totpCode(t, secret, now.Add(3*mfaPeriod*time.Second))); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("code from the future error = %v, want ErrInvalidMFACode", err)
+	}
+}
+
+func TestMFARecoveryCodes(t *testing.T) {
+	f := newMFAFixture(t, domain.RoleCustomer)
+	ctx := context.Background()
+	secret, codes := f.enroll(t)
+
+	// Codes are accepted however the user types them, but only once.
+	typed := strings.ToUpper(strings.Replace(codes[0], "-", " ", 1))
+	if err := f.service.Verify(ctx, f.user.ID, typed); err != nil {
+		t.Fatalf("Verify with recovery code %q: %v", typed, err)
+	}
+	if err := f.service.Verify(ctx, f.user.ID, codes[0]); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("reused recovery code error = %v, want ErrInvalidMFACode", err)
+	}
+
+	fresh, err := f.service.RegenerateRecoveryCodes(ctx, f.user.ID, totpCode(t, secret, time.Now().Add(mfaPeriod*time.Second)))
+	if err != nil {
+		t.Fatalf("RegenerateRecoveryCodes: %v", err)
+	}
+	if err := f.service.Verify(ctx, f.user.ID, codes[1]); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("replaced recovery code error = %v, want ErrInvalidMFACode", err)
+	}
+	if err := f.service.Verify(ctx, f.user.ID, fresh[0]); err != nil {
+		t.Errorf("Verify with a regenerated code: %v", err)
+	}
+}
+
+func TestMFAVerifyThrottlesFailures(t *testing.T) {
+	f := newMFAFixture(t, domain.RoleCustomer)
+	ctx := context.Background()
+	f.enroll(t)
+
+	var err error
+	for i := 0; i < 10; i++ {
+		if err = f.service.Verify(ctx, f.user.ID, "wrong-code"); !errors.Is(err, ErrInvalidMFACode) {
+			break
+		}
+	}
+	var limited *RateLimitError
+	if !errors.As(err, &limited) || limited.RetryAfter <= 0 {
+		t.Fatalf("Verify after repeated failures error = %v, want a RateLimitError", err)
+	}
+
+	var failed, throttled int
+	for _, e := range f.logins.events {
+		switch e.Kind {
+		case domain.MFAFailed:
+			failed++
+		case domain.MFAThrottled:
+			throttled++
+		}
+	}
+	if failed == 0 || throttled != 1 {
+		t.Errorf("recorded %d failures and %d throttled attempts", failed, throttled)
+	}
+}
+
+func TestMFAChallenge(t *testing.T) {
+	ctx := context.Background()
+
+	customer := newMFAFixture(t, domain.RoleCustomer)
+	if challenge, err := customer.service.Challenge(ctx, customer.user); err != nil || challenge != nil {
+		t.Errorf("Challenge for a customer = %+v, %v; want none", challenge, err)
+	}
+
+	admin := newMFAFixture(t, domain.RoleAdmin)
+	challenge, err := admin.service.Challenge(ctx, admin.user)
+	if err != nil || challenge == nil || !challenge.EnrollmentRequired {
+		t.Fatalf("Challenge for an unenrolled admin = %+v, %v; want enrollment", challenge, err)
+	}
+	if _, err := admin.service.ChallengeUser(ctx, challenge.Token, false); !errors.Is(err, ErrInvalidMFAToken) {
+		t.Errorf("enrollment token used to verify error = %v, want ErrInvalidMFAToken", err)
+	}
+	if user, err := admin.service.ChallengeUser(ctx, challenge.Token, true); err != nil || user.ID != admin.user.ID {
+		t.Errorf("ChallengeUser = %v, %v", user, err)
+	}
+
+	admin.enroll(t)
+	challenge, err = admin.service.Challenge(ctx, admin.user)
+	if err != nil || challenge == nil || challenge.EnrollmentRequired {
+		t.Fatalf("Challenge for an enrolled admin = %+v, %v; want verification", challenge, err)
+	}
+	if _, err := admin.service.ChallengeUser(ctx, challenge.Token, false); err != nil {
+		t.Errorf("ChallengeUser: %v", err)
+	}
+	if _, err := admin.service.ChallengeUser(ctx, "not-a-token", false); !errors.Is(err, ErrInvalidMFAToken) {
+		t.Errorf("ChallengeUser with garbage error = %v, want ErrInvalidMFAToken", err)
+	}
+}
+
+func TestMFAPolicyOverridesDefault(t *testing.T) {
+	ctx := context.Background()
+
+	admin := newMFAFixture(t, domain.RoleAdmin)
+	admin.mfa.SavePolicy(ctx, &domain.MFAPolicy{Role: domain.RoleAdmin, Required: false})
+	if challenge, _ := admin.service.Challenge(ctx, admin.user); challenge != nil {
+		t.Error("Challenge required enrollment for a role whose policy was turned off")
+	}
+
+	customer := newMFAFixture(t, domain.RoleCustomer)
+	secret, _ := customer.enroll(t)
+	customer.mfa.SavePolicy(ctx, &domain.MFAPolicy{Role: domain.RoleCustomer, Required: true})
+	code := totpCode(t, secret, time.Now().Add(mfaPeriod*time.Second))
+	if err := customer.service.Disable(ctx, customer.user, code); !errors.Is(err, ErrMFARequiredForRole) {
+		t.Errorf("Disable with a required role error = %v, want ErrMFARequiredForRole", err)
+	}
+
+	customer.mfa.SavePolicy(ctx, &domain.MFAPolicy{Role: domain.RoleCustomer, Required: false})
+	if err := customer.service.Disable(ctx, customer.user, code); err != nil {
+		t.Fatalf("Disable: %v", err)
+	}
+	if e, _ := customer.mfa.FindEnrollment(ctx, customer.user.ID); e != nil {
+		t.Error("enrollment kept after Disable")
+	}
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	tests := map[string]string{
+		"3f9a1-c07b2":     "3f9a1c07b2",
+		" 3F9A1 C07B2 \n": "3f9a1c07b2",
+		"3f9a1c07b2":      "3f9a1c07b2",
+	}
+	for in, want := range tests {
+		if got := normalizeRecoveryCode(in); got != want {
+			t.Errorf("normalizeRecoveryCode(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
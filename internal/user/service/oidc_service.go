@@ -0,0 +1,352 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/auth"
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
+	"github.com/rkweber-max/checkout-backend/internal/user/repository"
+	"github.com/rkweber-max/checkout-backend/pkg/config"
+	"github.com/rkweber-max/checkout-backend/pkg/oidc"
+)
+
+const (
+	oidcLoginTTL            = 10 * time.Minute
+	defaultOIDCGroupsClaim  = "groups"
+	defaultOIDCRedirectPath = "/api/login/oidc/callback"
+)
+
+var (
+	ErrOIDCDisabled         = errors.New("single sign-on is not configured")
+	ErrInvalidOIDCState     = errors.New("sign-in expired or was already used; start again")
+	ErrOIDCFailed           = errors.New("sign-in with the identity provider failed")
+	ErrOIDCEmailNotVerified = errors.New("the identity provider hasn't verified your email address")
+	ErrOIDCNoRole           = errors.New("none of your groups gives access to this application")
+)
+
+// primaryRoleOrder decides which mapped built-in role becomes the user's
+// primary role when their groups map to several.
+var primaryRoleOrder = []domain.Role{domain.RoleAdmin, domain.RoleEmployee, domain.RoleSeller, domain.RoleCustomer}
+
+// OIDCLogin is a sign-in started at the identity provider. State must
+// come back with the callback from the same browser.
+type OIDCLogin struct {
+	AuthorizationURL string
+	State            string
+	ExpiresIn        int
+}
+
+// OIDCService signs staff in with the company identity provider using the
+// authorization code flow with PKCE. Users are created on their first
+// sign-in, or linked to the account with the same verified email, and
+// their roles follow the groups the provider puts in the ID token.
+type OIDCService interface {
+	Enabled() bool
+	Begin(ctx context.Context) (*OIDCLogin, error)
+	Complete(ctx context.Context, state, code string) (*domain.User, error)
+	Cleanup(ctx context.Context) error
+}
+
+type oidcService struct {
+	repo        repository.IdentityRepository
+	users       repository.UserRepository
+	roles       RoleService
+	provider    *oidc.Provider
+	issuer      string
+	groupsClaim string
+	roleMapping map[string][]domain.Role
+}
+
+func NewOIDCService(
+	repo repository.IdentityRepository,
+	users repository.UserRepository,
+	roles RoleService,
+	cfg *config.Config,
+) (OIDCService, error) {
+	s := &oidcService{repo: repo, users: users, roles: roles}
+	if cfg.OIDCIssuer == "" {
+		return s, nil
+	}
+	if cfg.OIDCClientID == "" {
+		return nil, errors.New("OIDC_CLIENT_ID is required when OIDC_ISSUER is set")
+	}
+
+	mapping, err := parseRoleMapping(cfg.OIDCRoleMapping)
+	if err != nil {
+		return nil, err
+	}
+
+	redirectURL := cfg.OIDCRedirectURL
+	if redirectURL == "" {
+		baseURL := cfg.AppURL
+		if baseURL == "" {
+			baseURL = "http://localhost:" + cfg.AppPort
+		}
+		redirectURL = strings.TrimRight(baseURL, "/") + defaultOIDCRedirectPath
+	}
+
+	s.groupsClaim = cfg.OIDCGroupsClaim
+	if s.groupsClaim == "" {
+		s.groupsClaim = defaultOIDCGroupsClaim
+	}
+	s.issuer = cfg.OIDCIssuer
+	s.roleMapping = mapping
+	s.provider = oidc.NewProvider(oidc.Config{
+		Issuer:       cfg.OIDCIssuer,
+		ClientID:     cfg.OIDCClientID,
+		ClientSecret: cfg.OIDCClientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       strings.Fields(cfg.OIDCScopes),
+	})
+	return s, nil
+}
+
+func (s *oidcService) Enabled() bool {
+	return s.provider != nil
+}
+
+// Begin starts a sign-in. The nonce and PKCE verifier are kept on the
+// server under the hashed state.
+func (s *oidcService) Begin(ctx context.Context) (*OIDCLogin, error) {
+	if !s.Enabled() {
+		return nil, ErrOIDCDisabled
+	}
+
+	state, err := oidc.RandomString(32)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := oidc.RandomString(32)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := oidc.NewVerifier()
+	if err != nil {
+		return nil, err
+	}
+
+	authURL, err := s.provider.AuthCodeURL(ctx, state, nonce, oidc.Challenge(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCFailed, err)
+	}
+
+	err = s.repo.CreateState(ctx, &domain.OIDCLoginState{
+		StateHash:    hashToken(state),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(oidcLoginTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &OIDCLogin{
+		AuthorizationURL: authURL,
+		State:            state,
+		ExpiresIn:        int(oidcLoginTTL.Seconds()),
+	}, nil
+}
+
+// Complete exchanges the code the provider sent back, verifies the ID
+// token and returns the user it belongs to.
+func (s *oidcService) Complete(ctx context.Context, state, code string) (*domain.User, error) {
+	if !s.Enabled() {
+		return nil, ErrOIDCDisabled
+	}
+
+	login, err := s.repo.TakeState(ctx, hashToken(state), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if login == nil {
+		return nil, ErrInvalidOIDCState
+	}
+
+	tokens, err := s.provider.Exchange(ctx, code, login.CodeVerifier)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCFailed, err)
+	}
+	idToken, err := s.provider.VerifyIDToken(ctx, tokens.IDToken, login.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCFailed, err)
+	}
+
+	roles := s.mapRoles(idToken.Strings(s.groupsClaim))
+	if len(roles) == 0 {
+		return nil, ErrOIDCNoRole
+	}
+
+	user, identity, err := s.findOrProvision(ctx, idToken, roles[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := s.syncRoles(ctx, user, roles); err != nil {
+		return nil, err
+	}
+	if err := s.repo.TouchIdentity(ctx, identity.ID, strings.ToLower(idToken.Email), time.Now()); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Cleanup drops sign-ins that were started but never completed.
+func (s *oidcService) Cleanup(ctx context.Context) error {
+	return s.repo.DeleteExpiredStates(ctx, time.Now())
+}
+
+// findOrProvision returns the user linked to the provider account. An
+// account seen for the first time is linked to the user with the same
+// email, which the provider must have verified, or a new user is created.
+func (s *oidcService) findOrProvision(ctx context.Context, token *oidc.IDToken, role domain.Role) (*domain.User, *domain.UserIdentity, error) {
+	identity, err := s.repo.FindIdentity(ctx, s.issuer, token.Subject)
+	if err != nil {
+		return nil, nil, err
+	}
+	if identity != nil {
+		user, err := s.users.FindByID(identity.UserID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if user != nil {
+			return user, identity, nil
+		}
+	}
+
+	email := strings.ToLower(strings.TrimSpace(token.Email))
+	if email == "" || !token.EmailVerified {
+		return nil, nil, ErrOIDCEmailNotVerified
+	}
+
+	user, err := s.users.FindByEmail(email)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	if user == nil {
+		user, err = s.provision(token, email, role, now)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else if !user.EmailVerified() {
+		if _, err := s.users.MarkEmailVerified(user.ID, user.Email, now); err != nil {
+			return nil, nil, err
+		}
+		user.EmailVerifiedAt = &now
+	}
+
+	identity = &domain.UserIdentity{
+		UserID:  user.ID,
+		Issuer:  s.issuer,
+		Subject: token.Subject,
+		Email:   email,
+	}
+	if err := s.repo.CreateIdentity(ctx, identity); err != nil {
+		return nil, nil, err
+	}
+	return user, identity, nil
+}
+
+// provision creates the user of a provider account. The password is
+// random and never shown; the user signs in through the provider.
+func (s *oidcService) provision(token *oidc.IDToken, email string, role domain.Role, now time.Time) (*domain.User, error) {
+	name := strings.TrimSpace(token.Name)
+	if name == "" {
+		name, _, _ = strings.Cut(email, "@")
+	}
+
+	random, err := auth.RandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	password, err := hashPassword(random)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &domain.User{
+		Name:            name,
+		Email:           email,
+		Password:        password,
+		Role:            role,
+		EmailVerifiedAt: &now,
+	}
+	if err := s.users.Create(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// syncRoles makes the user's roles those their groups map to, with
+// roles[0] as the primary role.
+func (s *oidcService) syncRoles(ctx context.Context, user *domain.User, roles []domain.Role) error {
+	if user.Role != roles[0] {
+		user.Role = roles[0]
+		if err := s.users.Update(user); err != nil {
+			return err
+		}
+	}
+	_, err := s.roles.SetUserRoles(ctx, user.ID, roles)
+	return err
+}
+
+// mapRoles returns the roles the groups map to, the primary role first.
+// Users whose groups map only to custom roles get the employee role as
+// their primary role, since single sign-on is for staff.
+func (s *oidcService) mapRoles(groups []string) []domain.Role {
+	seen := make(map[domain.Role]bool)
+	var mapped []domain.Role
+	for _, group := range groups {
+		for _, role := range s.roleMapping[group] {
+			if !seen[role] {
+				seen[role] = true
+				mapped = append(mapped, role)
+			}
+		}
+	}
+	if len(mapped) == 0 {
+		return nil
+	}
+
+	primary := domain.RoleEmployee
+	for _, role := range primaryRoleOrder {
+		if seen[role] {
+			primary = role
+			break
+		}
+	}
+
+	roles := []domain.Role{primary}
+	for _, role := range mapped {
+		if role != primary {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// parseRoleMapping reads OIDC_ROLE_MAPPING, a comma-separated list of
+// group=role pairs. A group may be listed more than once to grant several
+// roles.
+func parseRoleMapping(value string) (map[string][]domain.Role, error) {
+	mapping := make(map[string][]domain.Role)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		group, role, ok := strings.Cut(pair, "=")
+		group, role = strings.TrimSpace(group), strings.TrimSpace(role)
+		if !ok || group == "" || !domain.ValidRoleName(role) {
+			return nil, fmt.Errorf("invalid OIDC_ROLE_MAPPING entry %q; expected group=role", pair)
+		}
+		mapping[group] = append(mapping[group], domain.Role(role))
+	}
+	if len(mapping) == 0 {
+		return nil, errors.New("OIDC_ROLE_MAPPING is required when OIDC_ISSUER is set")
+	}
+	return mapping, nil
+}
@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
+	"github.com/rkweber-max/checkout-backend/internal/user/repository"
+	"github.com/rkweber-max/checkout-backend/pkg/config"
+	"github.com/rkweber-max/checkout-backend/pkg/oidc/oidctest"
+)
+
+var (
+	_ repository.UserRepository     = (*fakeUsers)(nil)
+	_ repository.IdentityRepository = (*fakeIdentities)(nil)
+)
+
+const testRoleMapping = "checkout-admins=admin, store-staff=employee, store-staff=stock-keeper, partners=seller"
+
+type oidcFixture struct {
+	provider   *oidctest.Server
+	users      *fakeUsers
+	identities *fakeIdentities
+	roles      *fakeRoles
+	service    OIDCService
+}
+
+func newOIDCFixture(t *testing.T, users ...*domain.User) *oidcFixture {
+	t.Helper()
+
+	f := &oidcFixture{
+		provider:   oidctest.NewServer(t),
+		users:      newFakeUsers(users...),
+		identities: newFakeIdentities(),
+		roles:      newFakeRoles(),
+	}
+	service, err := NewOIDCService(f.identities, f.users, f.roles, &config.Config{
+		AppURL:           "http://localhost:8080",
+		OIDCIssuer:       f.provider.Issuer,
+		OIDCClientID:     oidctest.ClientID,
+		OIDCClientSecret: oidctest.ClientSecret,
+		OIDCRoleMapping:  testRoleMapping,
+	})
+	if err != nil {
+		t.Fatalf("NewOIDCService: %v", err)
+	}
+	f.service = service
+	return f
+}
+
+// login runs a sign-in in which the provider vouches for the given claims.
+func (f *oidcFixture) login(t *testing.T, claims jwt.MapClaims) (*domain.User, error) {
+	t.Helper()
+
+	ctx := context.Background()
+	login, err := f.service.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	state, code := f.provider.Authorize(t, login.AuthorizationURL, claims)
+	if state != login.State {
+		t.Fatalf("provider got state %q, want %q", state, login.State)
+	}
+	return f.service.Complete(ctx, state, code)
+}
+
+func TestOIDCProvisionsNewUser(t *testing.T) {
+	f := newOIDCFixture(t)
+
+	user, err := f.login(t, jwt.MapClaims{"groups": []string{"store-staff"}})
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+
+	if user.Email != "jane@example.com" || user.Name != "Jane Doe" {
+		t.Errorf("provisioned %s <%s>, want Jane Doe <jane@example.com>", user.Name, user.Email)
+	}
+	if user.Role != domain.RoleEmployee {
+		t.Errorf("role = %q, want employee", user.Role)
+	}
+	if !user.EmailVerified() {
+		t.Error("provisioned user's email is not verified")
+	}
+	if user.Password == "" {
+		t.Error("provisioned user has no password hash")
+	}
+	if got, want := f.roles.roles[user.ID], []domain.Role{domain.RoleEmployee, "stock-keeper"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("roles = %v, want %v", got, want)
+	}
+
+	linked, err := f.identities.HasIdentity(context.Background(), user.ID)
+	if err != nil || !linked {
+		t.Fatalf("identity not linked to the new user")
+	}
+
+	// The next sign-in finds the same user through the identity, even
+	// after the email changed at the provider.
+	again, err := f.login(t, jwt.MapClaims{"groups": []string{"store-staff"}, "email": "jane.doe@example.com"})
+	if err != nil {
+		t.Fatalf("second Complete: %v", err)
+	}
+	if again.ID != user.ID || f.users.count() != 1 {
+		t.Errorf("second sign-in returned user %d of %d, want user %d", again.ID, f.users.count(), user.ID)
+	}
+}
+
+func TestOIDCLinksUserByVerifiedEmail(t *testing.T) {
+	existing := &domain.User{Name: "Jane", Email: "jane@example.com", Password: "hash", Role: domain.RoleCustomer}
+	f := newOIDCFixture(t, existing)
+
+	user, err := f.login(t, jwt.MapClaims{"groups": []string{"checkout-admins"}, "email": "Jane@Example.com"})
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+
+	if user.ID != existing.ID || f.users.count() != 1 {
+		t.Fatalf("signed in as user %d of %d, want the existing user %d", user.ID, f.users.count(), existing.ID)
+	}
+	stored, _ := f.users.FindByID(existing.ID)
+	if stored.Role != domain.RoleAdmin {
+		t.Errorf("role = %q, want admin", stored.Role)
+	}
+	if !stored.EmailVerified() {
+		t.Error("linking didn't mark the email verified")
+	}
+	if stored.Password != "hash" {
+		t.Error("linking changed the password")
+	}
+}
+
+func TestOIDCRejectsUnverifiedEmail(t *testing.T) {
+	existing := &domain.User{Name: "Jane", Email: "jane@example.com", Password: "hash", Role: domain.RoleAdmin}
+	f := newOIDCFixture(t, existing)
+
+	_, err := f.login(t, jwt.MapClaims{"groups": []string{"store-staff"}, "email_verified": false})
+	if !errors.Is(err, ErrOIDCEmailNotVerified) {
+		t.Fatalf("Complete error = %v, want ErrOIDCEmailNotVerified", err)
+	}
+	if linked, _ := f.identities.HasIdentity(context.Background(), existing.ID); linked {
+		t.Error("unverified email was linked to the existing user")
+	}
+	if f.users.count() != 1 {
+		t.Error("a user was created for an unverified email")
+	}
+}
+
+func TestOIDCRejectsUnmappedGroups(t *testing.T) {
+	f := newOIDCFixture(t)
+
+	_, err := f.login(t, jwt.MapClaims{"groups": []string{"marketing"}})
+	if !errors.Is(err, ErrOIDCNoRole) {
+		t.Fatalf("Complete error = %v, want ErrOIDCNoRole", err)
+	}
+	if f.users.count() != 0 {
+		t.Error("a user was created without any mapped role")
+	}
+}
+
+func TestOIDCStateIsSingleUse(t *testing.T) {
+	f := newOIDCFixture(t)
+	ctx := context.Background()
+
+	login, err := f.service.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	state, code := f.provider.Authorize(t, login.AuthorizationURL, jwt.MapClaims{"groups": []string{"partners"}})
+
+	if _, err := f.service.Complete(ctx, state, code); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if _, err := f.service.Complete(ctx, state, code); !errors.Is(err, ErrInvalidOIDCState) {
+		t.Fatalf("replayed Complete error = %v, want ErrInvalidOIDCState", err)
+	}
+	if _, err := f.service.Complete(ctx, "forged", code); !errors.Is(err, ErrInvalidOIDCState) {
+		t.Fatalf("forged state error = %v, want ErrInvalidOIDCState", err)
+	}
+}
+
+func TestOIDCCleanupDropsExpiredStates(t *testing.T) {
+	f := newOIDCFixture(t)
+	ctx := context.Background()
+
+	f.identities.CreateState(ctx, &domain.OIDCLoginState{StateHash: "old", ExpiresAt: time.Now().Add(-time.Minute)})
+	if _, err := f.service.Begin(ctx); err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+
+	if err := f.service.Cleanup(ctx); err != nil {
+		t.Fatalf("Cleanup: %v", err)
+	}
+	if _, ok := f.identities.states["old"]; ok || len(f.identities.states) != 1 {
+		t.Errorf("states after cleanup = %d, want only the pending one", len(f.identities.states))
+	}
+}
+
+func TestOIDCDisabledWithoutIssuer(t *testing.T) {
+	service, err := NewOIDCService(newFakeIdentities(), newFakeUsers(), newFakeRoles(), &config.Config{})
+	if err != nil {
+		t.Fatalf("NewOIDCService: %v", err)
+	}
+	if service.Enabled() {
+		t.Error("service enabled without an issuer")
+	}
+	if _, err := service.Begin(context.Background()); !errors.Is(err, ErrOIDCDisabled) {
+		t.Errorf("Begin error = %v, want ErrOIDCDisabled", err)
+	}
+}
+
+func TestMapRoles(t *testing.T) {
+	mapping, err := parseRoleMapping(testRoleMapping + ", auditors=auditor")
+	if err != nil {
+		t.Fatalf("parseRoleMapping: %v", err)
+	}
+	s := &oidcService{roleMapping: mapping}
+
+	tests := []struct {
+		groups []string
+		want   []domain.Role
+	}{
+		{nil, nil},
+		{[]string{"marketing"}, nil},
+		{[]string{"partners"}, []domain.Role{domain.RoleSeller}},
+		{[]string{"partners", "checkout-admins"}, []domain.Role{domain.RoleAdmin, domain.RoleSeller}},
+		{[]string{"store-staff", "store-staff"}, []domain.Role{domain.RoleEmployee, "stock-keeper"}},
+		// Custom roles alone come with the employee role as primary.
+		{[]string{"auditors"}, []domain.Role{domain.RoleEmployee, "auditor"}},
+	}
+	for _, tt := range tests {
+		if got := s.mapRoles(tt.groups); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("mapRoles(%v) = %v, want %v", tt.groups, got, tt.want)
+		}
+	}
+}
+
+func TestParseRoleMapping(t *testing.T) {
+	mapping, err := parseRoleMapping(" admins = admin ,staff=employee,staff=stock-keeper,")
+	if err != nil {
+		t.Fatalf("parseRoleMapping: %v", err)
+	}
+	want := map[string][]domain.Role{
+		"admins": {domain.RoleAdmin},
+		"staff":  {domain.RoleEmployee, "stock-keeper"},
+	}
+	if !reflect.DeepEqual(mapping, want) {
+		t.Errorf("mapping = %v, want %v", mapping, want)
+	}
+
+	for _, value := range []string{"", " , ", "admins", "=admin", "admins=", "admins=Not A Role"} {
+		if _, err := parseRoleMapping(value); err == nil {
+			t.Errorf("parseRoleMapping(%q) succeeded", value)
+		}
+	}
+}
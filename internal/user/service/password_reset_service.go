@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/auth"
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
+	"github.com/rkweber-max/checkout-backend/internal/user/repository"
+	"github.com/rkweber-max/checkout-backend/pkg/config"
+	"github.com/rkweber-max/checkout-backend/pkg/mail"
+	"github.com/rkweber-max/checkout-backend/pkg/ratelimit"
+)
+
+const (
+	defaultPasswordResetTTLMinutes = 60
+	defaultPasswordResetEmailLimit = 3
+	defaultPasswordResetIPLimit    = 20
+	passwordResetLimitWindow       = time.Hour
+)
+
+var (
+	ErrInvalidResetToken = errors.New("invalid or expired password reset link")
+	ErrTooManyRequests   = errors.New("too many requests, try again later")
+)
+
+// RateLimitError is returned when a caller went over a rate limit.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return ErrTooManyRequests.Error()
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return ErrTooManyRequests
+}
+
+// PasswordResetService lets users who forgot their password choose a new
+// one through a link sent to their email.
+type PasswordResetService interface {
+	Forgot(ctx context.Context, email, ip string) error
+	Reset(ctx context.Context, token, password, ip string) error
+}
+
+type passwordResetService struct {
+	users    UserService
+	repo     repository.UserRepository
+	tokens   repository.TokenRepository
+	sessions TokenService
+	mailer   mail.Mailer
+	resetURL string
+	ttl      time.Duration
+	byEmail  *ratelimit.Limiter
+	byIP     *ratelimit.Limiter
+}
+
+func NewPasswordResetService(
+	users UserService,
+	repo repository.UserRepository,
+	tokens repository.TokenRepository,
+	sessions TokenService,
+	mailer mail.Mailer,
+	cfg *config.Config,
+) PasswordResetService {
+	// The link opens the client's reset form, which posts the token back
+	// to /api/password/reset.
+	resetURL := cfg.PasswordResetURL
+	if resetURL == "" {
+		baseURL := cfg.AppURL
+		if baseURL == "" {
+			baseURL = "http://localhost:" + cfg.AppPort
+		}
+		resetURL = strings.TrimRight(baseURL, "/") + "/password/reset"
+	}
+	ttlMinutes := cfg.PasswordResetTTLMinutes
+	if ttlMinutes <= 0 {
+		ttlMinutes = defaultPasswordResetTTLMinutes
+	}
+	emailLimit := cfg.PasswordResetEmailLimit
+	if emailLimit <= 0 {
+		emailLimit = defaultPasswordResetEmailLimit
+	}
+	ipLimit := cfg.PasswordResetIPLimit
+	if ipLimit <= 0 {
+		ipLimit = defaultPasswordResetIPLimit
+	}
+
+	return &passwordResetService{
+		users:    users,
+		repo:     repo,
+		tokens:   tokens,
+		sessions: sessions,
+		mailer:   mailer,
+		resetURL: resetURL,
+		ttl:      time.Duration(ttlMinutes) * time.Minute,
+		byEmail:  ratelimit.New(emailLimit, passwordResetLimitWindow),
+		byIP:     ratelimit.New(ipLimit, passwordResetLimitWindow),
+	}
+}
+
+// Forgot mails a reset link when the email belongs to an account. The
+// outcome is the same whether or not it does, so callers can't probe for
+// registered addresses; the limits apply to unknown addresses too.
+func (s *passwordResetService) Forgot(ctx context.Context, email, ip string) error {
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	if err := s.allow(s.byIP, ip); err != nil {
+		return err
+	}
+	if err := s.allow(s.byEmail, email); err != nil {
+		return err
+	}
+
+	user, err := s.users.GetByEmail(email)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return nil
+	}
+
+	token, err := auth.RandomToken(32)
+	if err != nil {
+		return err
+	}
+	err = s.tokens.CreateResetToken(ctx, &domain.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(s.ttl),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := s.resetURL + "?token=" + url.QueryEscape(token)
+	err = s.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nSomeone asked to reset the password of your account. To choose a new one, open:\n\n%s\n\nThe link expires in %d minutes and works once. If it wasn't you, ignore this email; your password stays the same.\n",
+			user.Name, link, int(s.ttl.Minutes()),
+		),
+	})
+	if err != nil {
+		log.Printf("Failed to send password reset email to user %d: %v", user.ID, err)
+	}
+	return nil
+}
+
+// Reset sets the new password and ends every session of the user, along
+// with any other reset link still outstanding.
+func (s *passwordResetService) Reset(ctx context.Context, token, password, ip string) error {
+	if err := s.allow(s.byIP, ip); err != nil {
+		return err
+	}
+
+	password = strings.TrimSpace(password)
+	if password == "" {
+		return errors.New("password cannot be empty")
+	}
+
+	stored, err := s.tokens.FindResetToken(ctx, hashToken(token))
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if stored == nil || stored.UsedAt != nil || now.After(stored.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+
+	used, err := s.tokens.MarkResetTokenUsed(ctx, stored.ID, now)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidResetToken
+	}
+
+	user, err := s.repo.FindByID(stored.UserID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrInvalidResetToken
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.UpdatePassword(user.ID, hash); err != nil {
+		return err
+	}
+	if err := s.tokens.UseResetTokens(ctx, user.ID, now); err != nil {
+		return err
+	}
+
+	// The link reached the user's inbox, which proves they own the address.
+	if !user.EmailVerified() {
+		if _, err := s.repo.MarkEmailVerified(user.ID, user.Email, now); err != nil {
+			return err
+		}
+	}
+
+	return s.sessions.RevokeUser(ctx, user.ID)
+}
+
+func (s *passwordResetService) allow(limiter *ratelimit.Limiter, key string) error {
+	if ok, retryAfter := limiter.Allow(key); !ok {
+		return &RateLimitError{RetryAfter: retryAfter}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
+)
+
+// RegistrationService lets customers sign up on their own and prove they
+// own their email address.
+type RegistrationService interface {
+	Register(ctx context.Context, user *domain.User) error
+	Verify(ctx context.Context, token string) (*domain.User, error)
+	ResendVerification(ctx context.Context, email string) error
+}
+
+type registrationService struct {
+	users        UserService
+	verification VerificationService
+}
+
+func NewRegistrationService(users UserService, verification VerificationService) RegistrationService {
+	return &registrationService{users: users, verification: verification}
+}
+
+// Register creates an unverified customer account and mails the
+// verification link. A failed delivery doesn't undo the registration; the
+// customer can ask for the link again.
+func (s *registrationService) Register(ctx context.Context, user *domain.User) error {
+	user.Role = domain.RoleCustomer
+	user.EmailVerifiedAt = nil
+
+	if err := s.users.Create(user); err != nil {
+		return err
+	}
+
+	if err := s.verification.Send(ctx, user); err != nil {
+		log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+	}
+	return nil
+}
+
+// Verify marks the email in the token as verified.
+func (s *registrationService) Verify(ctx context.Context, token string) (*domain.User, error) {
+	return s.verification.Verify(ctx, token)
+}
+
+// ResendVerification mails a new link to an unverified account. Unknown
+// and already verified addresses are ignored so the caller can't tell
+// which emails are registered.
+func (s *registrationService) ResendVerification(ctx context.Context, email string) error {
+	user, err := s.users.GetByEmail(email)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.EmailVerified() {
+		return nil
+	}
+	return s.verification.Send(ctx, user)
+}
@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
+	"github.com/rkweber-max/checkout-backend/internal/user/repository"
+)
+
+var (
+	ErrRoleNotFound        = errors.New("role not found")
+	ErrRoleExists          = errors.New("a role with this name already exists")
+	ErrRoleBuiltIn         = errors.New("built-in roles can't be deleted")
+	ErrRoleNotEditable     = errors.New("the admin role always holds every permission")
+	ErrRoleInUse           = errors.New("role is still assigned to users")
+	ErrPrimaryRoleRequired = errors.New("users keep their primary role; change it on the user instead")
+)
+
+// RoleDetail is a role with its permissions.
+type RoleDetail struct {
+	Name        domain.Role         `json:"name"`
+	Description string              `json:"description"`
+	BuiltIn     bool                `json:"built_in"`
+	Permissions []domain.Permission `json:"permissions"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+}
+
+// RoleService manages roles as named permission sets and which roles each
+// user holds.
+type RoleService interface {
+	List(ctx context.Context) ([]RoleDetail, error)
+	Get(ctx context.Context, name domain.Role) (*RoleDetail, error)
+	Create(ctx context.Context, name domain.Role, description string, permissions []domain.Permission) (*RoleDetail, error)
+	Update(ctx context.Context, name domain.Role, description string, permissions []domain.Permission) (*RoleDetail, error)
+	Delete(ctx context.Context, name domain.Role) error
+	UserRoles(ctx context.Context, userID uint) ([]domain.Role, error)
+	SetUserRoles(ctx context.Context, userID uint, roles []domain.Role) ([]domain.Role, error)
+	Roles(ctx context.Context, user *domain.User) ([]domain.Role, error)
+	Resolve(ctx context.Context, user *domain.User) ([]domain.Role, []domain.Permission, error)
+}
+
+type roleService struct {
+	repo  repository.RoleRepository
+	users repository.UserRepository
+}
+
+func NewRoleService(repo repository.RoleRepository, users repository.UserRepository) RoleService {
+	return &roleService{repo: repo, users: users}
+}
+
+func (s *roleService) List(ctx context.Context) ([]RoleDetail, error) {
+	roles, err := s.repo.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	details := make([]RoleDetail, len(roles))
+	for i := range roles {
+		details[i] = newRoleDetail(&roles[i])
+	}
+	return details, nil
+}
+
+func (s *roleService) Get(ctx context.Context, name domain.Role) (*RoleDetail, error) {
+	role, err := s.repo.FindByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, ErrRoleNotFound
+	}
+	detail := newRoleDetail(role)
+	return &detail, nil
+}
+
+func (s *roleService) Create(ctx context.Context, name domain.Role, description string, permissions []domain.Permission) (*RoleDetail, error) {
+	if !domain.ValidRoleName(string(name)) {
+		return nil, errors.New("role name must be 2 to 50 lowercase letters, digits, '-' or '_', starting with a letter")
+	}
+	grants, err := rolePermissions(name, permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.repo.FindByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrRoleExists
+	}
+
+	role := &domain.RoleDefinition{
+		Name:        name,
+		Description: strings.TrimSpace(description),
+		Permissions: grants,
+	}
+	if err := s.repo.Create(ctx, role); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, name)
+}
+
+// Update replaces the role's description and permissions. Users holding
+// the role get the new permissions with their next access token.
+func (s *roleService) Update(ctx context.Context, name domain.Role, description string, permissions []domain.Permission) (*RoleDetail, error) {
+	if name == domain.RoleAdmin {
+		return nil, ErrRoleNotEditable
+	}
+	grants, err := rolePermissions(name, permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := s.repo.FindByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, ErrRoleNotFound
+	}
+
+	role.Description = strings.TrimSpace(description)
+	role.Permissions = grants
+	if err := s.repo.Update(ctx, role); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, name)
+}
+
+func (s *roleService) Delete(ctx context.Context, name domain.Role) error {
+	role, err := s.repo.FindByName(ctx, name)
+	if err != nil {
+		return err
+	}
+	if role == nil {
+		return ErrRoleNotFound
+	}
+	if role.BuiltIn {
+		return ErrRoleBuiltIn
+	}
+
+	users, err := s.repo.CountUsers(ctx, name)
+	if err != nil {
+		return err
+	}
+	if users > 0 {
+		return ErrRoleInUse
+	}
+	return s.repo.Delete(ctx, name)
+}
+
+func (s *roleService) UserRoles(ctx context.Context, userID uint) ([]domain.Role, error) {
+	user, err := s.users.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return s.Roles(ctx, user)
+}
+
+// SetUserRoles replaces the roles the user holds. The list must include
+// the user's primary role.
+func (s *roleService) SetUserRoles(ctx context.Context, userID uint, roles []domain.Role) ([]domain.Role, error) {
+	user, err := s.users.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	unique := make([]domain.Role, 0, len(roles))
+	seen := make(map[domain.Role]bool, len(roles))
+	for _, name := range roles {
+		if seen[name] {
+			continue
+		}
+		role, err := s.repo.FindByName(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			return nil, fmt.Errorf("%w: %s", ErrRoleNotFound, name)
+		}
+		seen[name] = true
+		unique = append(unique, name)
+	}
+	if !seen[user.Role] {
+		return nil, ErrPrimaryRoleRequired
+	}
+
+	if err := s.repo.SetUserRoles(ctx, userID, unique); err != nil {
+		return nil, err
+	}
+	return s.Roles(ctx, user)
+}
+
+// Roles returns every role the user holds, including their primary role.
+func (s *roleService) Roles(ctx context.Context, user *domain.User) ([]domain.Role, error) {
+	roles, err := s.repo.FindUserRoles(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, role := range roles {
+		if role == user.Role {
+			return roles, nil
+		}
+	}
+	return append([]domain.Role{user.Role}, roles...), nil
+}
+
+// Resolve returns the user's roles and the union of their permissions.
+func (s *roleService) Resolve(ctx context.Context, user *domain.User) ([]domain.Role, []domain.Permission, error) {
+	roles, err := s.Roles(ctx, user)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, role := range roles {
+		if role == domain.RoleAdmin {
+			return roles, domain.AllPermissions(), nil
+		}
+	}
+
+	permissions, err := s.repo.FindPermissions(ctx, roles)
+	if err != nil {
+		return nil, nil, err
+	}
+	return roles, permissions, nil
+}
+
+func rolePermissions(role domain.Role, permissions []domain.Permission) ([]domain.RolePermission, error) {
+	grants := make([]domain.RolePermission, 0, len(permissions))
+	seen := make(map[domain.Permission]bool, len(permissions))
+	for _, p := range permissions {
+		if !p.IsValid() {
+			return nil, fmt.Errorf("unknown permission %q", p)
+		}
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		grants = append(grants, domain.RolePermission{Role: role, Permission: p})
+	}
+	return grants, nil
+}
+
+func newRoleDetail(role *domain.RoleDefinition) RoleDetail {
+	permissions := make([]domain.Permission, len(role.Permissions))
+	for i, p := range role.Permissions {
+		permissions[i] = p.Permission
+	}
+	if role.Name == domain.RoleAdmin {
+		permissions = domain.AllPermissions()
+	}
+	sort.Slice(permissions, func(i, j int) bool { return permissions[i] < permissions[j] })
+
+	return RoleDetail{
+		Name:        role.Name,
+		Description: role.Description,
+		BuiltIn:     role.BuiltIn,
+		Permissions: permissions,
+		CreatedAt:   role.CreatedAt,
+		UpdatedAt:   role.UpdatedAt,
+	}
+}
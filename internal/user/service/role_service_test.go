@@ -0,0 +1,293 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
+	"github.com/rkweber-max/checkout-backend/internal/user/repository"
+)
+
+var _ repository.RoleRepository = (*fakeRoleDefinitions)(nil)
+
+// fakeRoleDefinitions is an in-memory repository.RoleRepository.
+type fakeRoleDefinitions struct {
+	roles     map[domain.Role]domain.RoleDefinition
+	userRoles map[uint][]domain.Role
+}
+
+func newFakeRoleDefinitions() *fakeRoleDefinitions {
+	f := &fakeRoleDefinitions{
+		roles:     make(map[domain.Role]domain.RoleDefinition),
+		userRoles: make(map[uint][]domain.Role),
+	}
+	f.roles[domain.RoleAdmin] = domain.RoleDefinition{Name: domain.RoleAdmin, BuiltIn: true}
+	for role, permissions := range domain.DefaultRolePermissions {
+		definition := domain.RoleDefinition{Name: role, BuiltIn: true}
+		for _, p := range permissions {
+			definition.Permissions = append(definition.Permissions, domain.RolePermission{Role: role, Permission: p})
+		}
+		f.roles[role] = definition
+	}
+	return f
+}
+
+func (f *fakeRoleDefinitions) FindAll(ctx context.Context) ([]domain.RoleDefinition, error) {
+	var roles []domain.RoleDefinition
+	for _, role := range f.roles {
+		roles = append(roles, role)
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles, nil
+}
+
+func (f *fakeRoleDefinitions) FindByName(ctx context.Context, name domain.Role) (*domain.RoleDefinition, error) {
+	if role, ok := f.roles[name]; ok {
+		return &role, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeRoleDefinitions) Create(ctx context.Context, role *domain.RoleDefinition) error {
+	f.roles[role.Name] = *role
+	return nil
+}
+
+func (f *fakeRoleDefinitions) Update(ctx context.Context, role *domain.RoleDefinition) error {
+	f.roles[role.Name] = *role
+	return nil
+}
+
+func (f *fakeRoleDefinitions) Delete(ctx context.Context, name domain.Role) error {
+	delete(f.roles, name)
+	return nil
+}
+
+func (f *fakeRoleDefinitions) CountUsers(ctx context.Context, name domain.Role) (int64, error) {
+	var count int64
+	for _, roles := range f.userRoles {
+		for _, role := range roles {
+			if role == name {
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+func (f *fakeRoleDefinitions) FindUserRoles(ctx context.Context, userID uint) ([]domain.Role, error) {
+	return f.userRoles[userID], nil
+}
+
+func (f *fakeRoleDefinitions) SetUserRoles(ctx context.Context, userID uint, roles []domain.Role) error {
+	f.userRoles[userID] = append([]domain.Role(nil), roles...)
+	return nil
+}
+
+func (f *fakeRoleDefinitions) FindPermissions(ctx context.Context, roles []domain.Role) ([]domain.Permission, error) {
+	seen := make(map[domain.Permission]bool)
+	var permissions []domain.Permission
+	for _, name := range roles {
+		for _, p := range f.roles[name].Permissions {
+			if !seen[p.Permission] {
+				seen[p.Permission] = true
+				permissions = append(permissions, p.Permission)
+			}
+		}
+	}
+	return permissions, nil
+}
+
+type roleFixture struct {
+	roles   *fakeRoleDefinitions
+	user    *domain.User
+	service RoleService
+}
+
+func newRoleFixture() *roleFixture {
+	user := &domain.User{Name: "Ana", Email: "ana@example.com", Role: domain.RoleCustomer}
+	f := &roleFixture{roles: newFakeRoleDefinitions(), user: user}
+	f.service = NewRoleService(f.roles, newFakeUsers(user))
+	return f
+}
+
+func sortedPermissions(permissions []domain.Permission) []domain.Permission {
+	sorted := append([]domain.Permission(nil), permissions...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+func TestResolveUnitesPermissionsOfEveryRole(t *testing.T) {
+	f := newRoleFixture()
+	ctx := context.Background()
+
+	// Without other roles a user holds what their primary role allows.
+	roles, permissions, err := f.service.Resolve(ctx, f.user)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if !reflect.DeepEqual(roles, []domain.Role{domain.RoleCustomer}) {
+		t.Errorf("roles = %v, want customer", roles)
+	}
+	want := sortedPermissions(domain.DefaultRolePermissions[domain.RoleCustomer])
+	if got := sortedPermissions(permissions); !reflect.DeepEqual(got, want) {
+		t.Errorf("permissions = %v, want %v", got, want)
+	}
+
+	if _, err := f.service.SetUserRoles(ctx, f.user.ID, []domain.Role{domain.RoleCustomer, domain.RoleSeller, domain.RoleSeller}); err != nil {
+		t.Fatalf("SetUserRoles: %v", err)
+	}
+	roles, permissions, err = f.service.Resolve(ctx, f.user)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if !reflect.DeepEqual(roles, []domain.Role{domain.RoleCustomer, domain.RoleSeller}) {
+		t.Errorf("roles = %v, want customer and seller", roles)
+	}
+	want = sortedPermissions(append(domain.DefaultRolePermissions[domain.RoleCustomer], domain.DefaultRolePermissions[domain.RoleSeller]...))
+	if got := sortedPermissions(permissions); !reflect.DeepEqual(got, want) {
+		t.Errorf("permissions = %v, want %v", got, want)
+	}
+}
+
+func TestResolveGivesAdminsEveryPermission(t *testing.T) {
+	f := newRoleFixture()
+	ctx := context.Background()
+
+	// Admin holds nothing in the roles table but still gets every
+	// permission, including ones added after the role was stored.
+	if _, err := f.service.SetUserRoles(ctx, f.user.ID, []domain.Role{domain.RoleCustomer, domain.RoleAdmin}); err != nil {
+		t.Fatalf("SetUserRoles: %v", err)
+	}
+	_, permissions, err := f.service.Resolve(ctx, f.user)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if got, want := sortedPermissions(permissions), sortedPermissions(domain.AllPermissions()); !reflect.DeepEqual(got, want) {
+		t.Errorf("permissions = %v, want every permission", got)
+	}
+
+	admin, err := f.service.Get(ctx, domain.RoleAdmin)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(admin.Permissions) != len(domain.Permissions) {
+		t.Errorf("admin lists %d permissions, want %d", len(admin.Permissions), len(domain.Permissions))
+	}
+}
+
+func TestCreateRole(t *testing.T) {
+	f := newRoleFixture()
+	ctx := context.Background()
+
+	role, err := f.service.Create(ctx, "refunds", " Handles refunds ", []domain.Permission{
+		domain.PermUsersRead, domain.PermOrdersCreate, domain.PermUsersRead,
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	want := []domain.Permission{domain.PermOrdersCreate, domain.PermUsersRead}
+	if role.Description != "Handles refunds" || role.BuiltIn || !reflect.DeepEqual(role.Permissions, want) {
+		t.Errorf("role = %+v, want permissions %v", role, want)
+	}
+
+	tests := []struct {
+		name        string
+		role        domain.Role
+		permissions []domain.Permission
+		err         error
+	}{
+		{"existing name", "refunds", nil, ErrRoleExists},
+		{"built-in name", domain.RoleEmployee, nil, ErrRoleExists},
+		{"unknown permission", "auditors", []domain.Permission{"orders:delete"}, nil},
+		{"uppercase name", "Auditors", nil, nil},
+		{"one letter", "a", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := f.service.Create(ctx, tt.role, "", tt.permissions)
+			if err == nil {
+				t.Fatal("Create succeeded")
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+	if _, ok := f.roles.roles["auditors"]; ok {
+		t.Error("role stored with an unknown permission")
+	}
+}
+
+func TestUpdateRole(t *testing.T) {
+	f := newRoleFixture()
+	ctx := context.Background()
+
+	role, err := f.service.Update(ctx, domain.RoleSeller, "Sellers", []domain.Permission{domain.PermProductsWriteOwn})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if !reflect.DeepEqual(role.Permissions, []domain.Permission{domain.PermProductsWriteOwn}) {
+		t.Errorf("permissions = %v", role.Permissions)
+	}
+
+	if _, err := f.service.Update(ctx, domain.RoleAdmin, "", nil); !errors.Is(err, ErrRoleNotEditable) {
+		t.Errorf("updating admin: err = %v, want %v", err, ErrRoleNotEditable)
+	}
+	if _, err := f.service.Update(ctx, "auditors", "", nil); !errors.Is(err, ErrRoleNotFound) {
+		t.Errorf("updating a missing role: err = %v, want %v", err, ErrRoleNotFound)
+	}
+	if _, err := f.service.Update(ctx, domain.RoleSeller, "", []domain.Permission{"products:delete"}); err == nil {
+		t.Error("updated with an unknown permission")
+	}
+}
+
+func TestDeleteRole(t *testing.T) {
+	f := newRoleFixture()
+	ctx := context.Background()
+
+	if err := f.service.Delete(ctx, domain.RoleEmployee); !errors.Is(err, ErrRoleBuiltIn) {
+		t.Errorf("deleting a built-in role: err = %v, want %v", err, ErrRoleBuiltIn)
+	}
+	if err := f.service.Delete(ctx, "auditors"); !errors.Is(err, ErrRoleNotFound) {
+		t.Errorf("deleting a missing role: err = %v, want %v", err, ErrRoleNotFound)
+	}
+
+	if _, err := f.service.Create(ctx, "auditors", "", []domain.Permission{domain.PermUsersRead}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := f.service.SetUserRoles(ctx, f.user.ID, []domain.Role{domain.RoleCustomer, "auditors"}); err != nil {
+		t.Fatalf("SetUserRoles: %v", err)
+	}
+	if err := f.service.Delete(ctx, "auditors"); !errors.Is(err, ErrRoleInUse) {
+		t.Errorf("deleting an assigned role: err = %v, want %v", err, ErrRoleInUse)
+	}
+
+	if _, err := f.service.SetUserRoles(ctx, f.user.ID, []domain.Role{domain.RoleCustomer}); err != nil {
+		t.Fatalf("SetUserRoles: %v", err)
+	}
+	if err := f.service.Delete(ctx, "auditors"); err != nil {
+		t.Errorf("Delete: %v", err)
+	}
+}
+
+func TestSetUserRoles(t *testing.T) {
+	f := newRoleFixture()
+	ctx := context.Background()
+
+	if _, err := f.service.SetUserRoles(ctx, f.user.ID, []domain.Role{domain.RoleSeller}); !errors.Is(err, ErrPrimaryRoleRequired) {
+		t.Errorf("dropping the primary role: err = %v, want %v", err, ErrPrimaryRoleRequired)
+	}
+	if _, err := f.service.SetUserRoles(ctx, f.user.ID, []domain.Role{domain.RoleCustomer, "auditors"}); !errors.Is(err, ErrRoleNotFound) {
+		t.Errorf("unknown role: err = %v, want %v", err, ErrRoleNotFound)
+	}
+	if _, err := f.service.SetUserRoles(ctx, 99, []domain.Role{domain.RoleCustomer}); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("unknown user: err = %v, want %v", err, ErrUserNotFound)
+	}
+	if len(f.roles.userRoles) != 0 {
+		t.Errorf("rejected changes stored roles %v", f.roles.userRoles)
+	}
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/auth"
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
+	"github.com/rkweber-max/checkout-backend/internal/user/repository"
+	"github.com/rkweber-max/checkout-backend/pkg/config"
+)
+
+const (
+	defaultAccessTokenTTLMinutes = 15
+	defaultRefreshTokenTTLHours  = 30 * 24
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used; all sessions of this login were revoked")
+)
+
+// Tokens is what a client receives when it logs in or refreshes.
+type Tokens struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type TokenService interface {
+	Issue(ctx context.Context, user *domain.User) (*Tokens, error)
+	Refresh(ctx context.Context, refreshToken string) (*Tokens, error)
+	Logout(ctx context.Context, jti string, expiresAt time.Time, refreshToken string) error
+	RevokeUser(ctx context.Context, userID uint) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+	Cleanup(ctx context.Context) error
+}
+
+type tokenService struct {
+	repo       repository.TokenRepository
+	users      repository.UserRepository
+	roles      RoleService
+	keys       KeyService
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+func NewTokenService(
+	repo repository.TokenRepository,
+	users repository.UserRepository,
+	roles RoleService,
+	keys KeyService,
+	cfg *config.Config,
+) TokenService {
+	refreshHours := cfg.RefreshTokenTTLHours
+	if refreshHours <= 0 {
+		refreshHours = defaultRefreshTokenTTLHours
+	}
+
+	return &tokenService{
+		repo:       repo,
+		users:      users,
+		roles:      roles,
+		keys:       keys,
+		accessTTL:  accessTokenTTL(cfg),
+		refreshTTL: time.Duration(refreshHours) * time.Hour,
+	}
+}
+
+// Issue starts a new session for the user: a short-lived access token and
+// the first refresh token of a new family.
+func (s *tokenService) Issue(ctx context.Context, user *domain.User) (*Tokens, error) {
+	family, err := auth.RandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	return s.issue(ctx, user, family)
+}
+
+// Refresh exchanges a refresh token for a new pair. The presented token
+// can't be used again; presenting it a second time revokes every token
+// descended from the same login.
+func (s *tokenService) Refresh(ctx context.Context, refreshToken string) (*Tokens, error) {
+	stored, err := s.repo.FindRefreshToken(ctx, hashToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil || stored.RevokedAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	now := time.Now()
+	if stored.UsedAt != nil {
+		return nil, s.reused(ctx, stored, now)
+	}
+	if now.After(stored.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	rotated, err := s.repo.MarkUsed(ctx, stored.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		return nil, s.reused(ctx, stored, now)
+	}
+
+	user, err := s.users.FindByID(stored.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	return s.issue(ctx, user, stored.FamilyID)
+}
+
+// Logout denies the current access token until it expires and, when the
+// client sends its refresh token, revokes the session it belongs to.
+func (s *tokenService) Logout(ctx context.Context, jti string, expiresAt time.Time, refreshToken string) error {
+	if jti != "" {
+		if err := s.repo.RevokeAccessToken(ctx, jti, expiresAt); err != nil {
+			return err
+		}
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+	stored, err := s.repo.FindRefreshToken(ctx, hashToken(refreshToken))
+	if err != nil || stored == nil {
+		return err
+	}
+	return s.repo.RevokeFamily(ctx, stored.FamilyID, time.Now())
+}
+
+// RevokeUser ends every session of the user. Access tokens already issued
+// stay valid until they expire, which is why they are short-lived.
+func (s *tokenService) RevokeUser(ctx context.Context, userID uint) error {
+	return s.repo.RevokeUser(ctx, userID, time.Now())
+}
+
+func (s *tokenService) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	return s.repo.IsAccessTokenRevoked(ctx, jti)
+}
+
+// Cleanup drops expired refresh tokens and denylist entries.
+func (s *tokenService) Cleanup(ctx context.Context) error {
+	return s.repo.DeleteExpired(ctx, time.Now())
+}
+
+func (s *tokenService) issue(ctx context.Context, user *domain.User, family string) (*Tokens, error) {
+	roles, permissions, err := s.roles.Resolve(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := s.keys.SigningKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	opts := s.keys.TokenOptions()
+	opts.TTL = s.accessTTL
+	access, err := auth.GenerateToken(key, opts, auth.Subject{
+		UserID:      user.ID,
+		Role:        user.Role,
+		Roles:       roles,
+		Permissions: permissions,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := auth.RandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	err = s.repo.CreateRefreshToken(ctx, &domain.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  family,
+		TokenHash: hashToken(refresh),
+		ExpiresAt: time.Now().Add(s.refreshTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tokens{
+		AccessToken:  access.Token,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.accessTTL.Seconds()),
+		RefreshToken: refresh,
+	}, nil
+}
+
+func (s *tokenService) reused(ctx context.Context, stored *domain.RefreshToken, at time.Time) error {
+	log.Printf("Refresh token reuse detected for user %d; revoking token family", stored.UserID)
+	if err := s.repo.RevokeFamily(ctx, stored.FamilyID, at); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+func accessTokenTTL(cfg *config.Config) time.Duration {
+	minutes := cfg.AccessTokenTTLMinutes
+	if minutes <= 0 {
+		minutes = defaultAccessTokenTTLMinutes
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/auth"
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
+	"github.com/rkweber-max/checkout-backend/internal/user/repository"
+	"github.com/rkweber-max/checkout-backend/pkg/config"
+)
+
+var _ repository.TokenRepository = (*fakeTokens)(nil)
+
+// staticKeys signs with a single key that never rotates.
+type staticKeys struct {
+	key *auth.Key
+}
+
+func newStaticKeys(t *testing.T) *staticKeys {
+	t.Helper()
+
+	private, err := auth.GenerateKey(auth.AlgorithmEdDSA)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	return &staticKeys{key: &auth.Key{ID: "test", Algorithm: auth.AlgorithmEdDSA, Private: private}}
+}
+
+func (k *staticKeys) SigningKey(ctx context.Context) (*auth.Key, error) {
+	return k.key, nil
+}
+
+func (k *staticKeys) VerificationKey(ctx context.Context, kid string) (*auth.Key, error) {
+	if kid == k.key.ID {
+		return k.key, nil
+	}
+	return nil, nil
+}
+
+func (k *staticKeys) TokenOptions() auth.TokenOptions {
+	return auth.TokenOptions{Issuer: "test-issuer", Audience: "test-api"}
+}
+
+func (k *staticKeys) JWKS(ctx context.Context) (auth.JWKSet, error) {
+	return auth.JWKSet{}, nil
+}
+
+func (k *staticKeys) Rotate(ctx context.Context) error {
+	return nil
+}
+
+type tokenFixture struct {
+	tokens  *fakeTokens
+	keys    *staticKeys
+	user    *domain.User
+	service TokenService
+}
+
+func newTokenFixture(t *testing.T) *tokenFixture {
+	t.Helper()
+
+	user := &domain.User{Name: "Ana", Email: "ana@example.com", Role: domain.RoleEmployee}
+	users := newFakeUsers(user)
+	roles := newFakeRoles()
+	roles.SetUserRoles(context.Background(), user.ID, []domain.Role{domain.RoleEmployee, domain.RoleSeller})
+
+	f := &tokenFixture{tokens: newFakeTokens(), keys: newStaticKeys(t), user: user}
+	f.service = NewTokenService(f.tokens, users, roles, f.keys, &config.Config{AccessTokenTTLMinutes: 5})
+	return f
+}
+
+func TestIssueSignsAccessToken(t *testing.T) {
+	f := newTokenFixture(t)
+
+	tokens, err := f.service.Issue(context.Background(), f.user)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if tokens.TokenType != "Bearer" || tokens.ExpiresIn != 300 || tokens.RefreshToken == "" {
+		t.Errorf("unexpected tokens %+v", tokens)
+	}
+
+	claims, err := auth.ParseToken(context.Background(), f.keys, f.keys.TokenOptions(), tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("ParseToken: %v", err)
+	}
+	if claims["sub"] != float64(f.user.ID) || claims["role"] != string(domain.RoleEmployee) {
+		t.Errorf("claims = %v", claims)
+	}
+	if roles, _ := claims["roles"].([]interface{}); len(roles) != 2 || roles[1] != string(domain.RoleSeller) {
+		t.Errorf("roles claim = %v, want employee and seller", claims["roles"])
+	}
+
+	for _, stored := range f.tokens.refresh {
+		if stored.TokenHash == tokens.RefreshToken {
+			t.Error("refresh token stored in plain text")
+		}
+	}
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	f := newTokenFixture(t)
+	ctx := context.Background()
+
+	first, err := f.service.Issue(ctx, f.user)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	second, err := f.service.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken || second.AccessToken == first.AccessToken {
+		t.Fatal("Refresh returned the same tokens")
+	}
+
+	third, err := f.service.Refresh(ctx, second.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh of the rotated token: %v", err)
+	}
+
+	firstStored, _ := f.tokens.FindRefreshToken(ctx, hashToken(first.RefreshToken))
+	thirdStored, _ := f.tokens.FindRefreshToken(ctx, hashToken(third.RefreshToken))
+	if firstStored.FamilyID != thirdStored.FamilyID {
+		t.Error("rotated token left the family of the login")
+	}
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	f := newTokenFixture(t)
+	ctx := context.Background()
+
+	first, _ := f.service.Issue(ctx, f.user)
+	second, err := f.service.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	other, _ := f.service.Issue(ctx, f.user)
+
+	// Someone replays the token the client already exchanged.
+	if _, err := f.service.Refresh(ctx, first.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reused Refresh error = %v, want ErrRefreshTokenReused", err)
+	}
+
+	// The legitimate successor dies with the family...
+	if _, err := f.service.Refresh(ctx, second.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("successor Refresh error = %v, want ErrInvalidRefreshToken", err)
+	}
+	// ...but other logins of the user are left alone.
+	if _, err := f.service.Refresh(ctx, other.RefreshToken); err != nil {
+		t.Errorf("Refresh of another login: %v", err)
+	}
+}
+
+func TestRefreshRejectsUnknownAndExpiredTokens(t *testing.T) {
+	f := newTokenFixture(t)
+	ctx := context.Background()
+
+	if _, err := f.service.Refresh(ctx, "not-a-token"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("unknown token error = %v, want ErrInvalidRefreshToken", err)
+	}
+
+	tokens, _ := f.service.Issue(ctx, f.user)
+	for _, stored := range f.tokens.refresh {
+		stored.ExpiresAt = time.Now().Add(-time.Second)
+	}
+	if _, err := f.service.Refresh(ctx, tokens.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("expired token error = %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestLogoutRevokesSessionAndAccessToken(t *testing.T) {
+	f := newTokenFixture(t)
+	ctx := context.Background()
+
+	tokens, _ := f.service.Issue(ctx, f.user)
+	claims, err := auth.ParseToken(ctx, f.keys, f.keys.TokenOptions(), tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("ParseToken: %v", err)
+	}
+	jti := claims["jti"].(string)
+
+	if err := f.service.Logout(ctx, jti, time.Now().Add(5*time.Minute), tokens.RefreshToken); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	if revoked, _ := f.service.IsRevoked(ctx, jti); !revoked {
+		t.Error("access token not denied after logout")
+	}
+	if _, err := f.service.Refresh(ctx, tokens.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Refresh after logout error = %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestRevokeUserEndsEverySession(t *testing.T) {
+	f := newTokenFixture(t)
+	ctx := context.Background()
+
+	a, _ := f.service.Issue(ctx, f.user)
+	b, _ := f.service.Issue(ctx, f.user)
+	if err := f.service.RevokeUser(ctx, f.user.ID); err != nil {
+		t.Fatalf("RevokeUser: %v", err)
+	}
+
+	for _, refresh := range []string{a.RefreshToken, b.RefreshToken} {
+		if _, err := f.service.Refresh(ctx, refresh); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Errorf("Refresh after RevokeUser error = %v, want ErrInvalidRefreshToken", err)
+		}
+	}
+}
@@ -1,16 +1,24 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"log"
 	"strings"
 
 	"golang.org/x/crypto/bcrypt"
 
-	"github.com/rkweber-max/checkout-backend/internal/auth"
 	"github.com/rkweber-max/checkout-backend/internal/user/domain"
 	"github.com/rkweber-max/checkout-backend/internal/user/repository"
 	"github.com/rkweber-max/checkout-backend/pkg/config"
+	"github.com/rkweber-max/checkout-backend/pkg/mergepatch"
+)
+
+var (
+	ErrUserNotFound    = errors.New("user not found")
+	ErrEmailInUse      = errors.New("email already in use")
+	ErrVersionConflict = repository.ErrVersionConflict
 )
 
 type UserService interface {
@@ -19,17 +27,25 @@ type UserService interface {
 	GetByEmail(email string) (*domain.User, error)
 	List() ([]domain.User, error)
 	Update(user *domain.User) error
+	Patch(id uint, expectedVersion *int64, patch []byte) (*domain.User, error)
 	Delete(id uint) error
-	Login(email, password string) (string, error)
+	RevokeSessions(id uint) error
 }
 
 type userService struct {
-	repo   repository.UserRepository
-	config *config.Config
+	repo         repository.UserRepository
+	tokens       TokenService
+	verification VerificationService
+	config       *config.Config
 }
 
-func NewUserService(repo repository.UserRepository, cfg *config.Config) UserService {
-	return &userService{repo: repo, config: cfg}
+func NewUserService(
+	repo repository.UserRepository,
+	tokens TokenService,
+	verification VerificationService,
+	cfg *config.Config,
+) UserService {
+	return &userService{repo: repo, tokens: tokens, verification: verification, config: cfg}
 }
 
 func (s *userService) Create(user *domain.User) error {
@@ -45,31 +61,16 @@ func (s *userService) Create(user *domain.User) error {
 		return err
 	}
 	if existingUser != nil {
-		return errors.New("email already in use")
+		return ErrEmailInUse
 	}
 
-	log.Printf("Creating user with email: %s, password length: %d", user.Email, len(user.Password))
-
-	hashPassword, err := bcrypt.GenerateFromPassword(
-		[]byte(user.Password),
-		bcrypt.DefaultCost,
-	)
+	hash, err := hashPassword(user.Password)
 	if err != nil {
-		log.Printf("Error generating password hash: %v", err)
 		return err
 	}
+	user.Password = hash
 
-	user.Password = string(hashPassword)
-	log.Printf("Password hash generated, length: %d, starts with: %s", len(user.Password), user.Password[:min(10, len(user.Password))])
-
-	err = s.repo.Create(user)
-	if err != nil {
-		log.Printf("Error creating user: %v", err)
-		return err
-	}
-
-	log.Printf("User created successfully with ID: %d", user.ID)
-	return nil
+	return s.repo.Create(user)
 }
 
 func (s *userService) GetByID(id uint) (*domain.User, error) {
@@ -85,89 +86,166 @@ func (s *userService) List() ([]domain.User, error) {
 	return s.repo.List()
 }
 
+// Update replaces the user's profile. user.Version must be the version the
+// caller read; it is incremented on success.
 func (s *userService) Update(user *domain.User) error {
 	if user.ID == 0 {
 		return errors.New("Invalid user id")
 	}
 
-	return s.repo.Update(user)
-}
+	existing, err := s.repo.FindByID(user.ID)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return ErrUserNotFound
+	}
 
-func (s *userService) Delete(id uint) error {
-	if id == 0 {
-		return errors.New("invalid user id")
+	if err := s.validateProfile(user); err != nil {
+		return err
+	}
+	user.EmailVerifiedAt = existing.EmailVerifiedAt
+	if user.Email != existing.Email {
+		user.EmailVerifiedAt = nil
 	}
 
-	return s.repo.Delete(id)
+	if err := s.repo.Update(user); err != nil {
+		return err
+	}
+	if err := s.revokeOnRoleChange(existing, user.Role); err != nil {
+		return err
+	}
+	s.verifyChangedEmail(existing, user)
+	return nil
 }
 
-func (s *userService) Login(email, password string) (string, error) {
-	email = strings.ToLower(strings.TrimSpace(email))
-	password = strings.TrimSpace(password)
-
-	if password == "" {
-		return "", errors.New("password cannot be empty")
+// Patch applies a JSON Merge Patch to the user's profile. When
+// expectedVersion is set the patch is rejected if the user has moved on.
+func (s *userService) Patch(id uint, expectedVersion *int64, patch []byte) (*domain.User, error) {
+	existing, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, ErrUserNotFound
+	}
+	if expectedVersion != nil && *expectedVersion != existing.Version {
+		return nil, ErrVersionConflict
 	}
 
-	log.Printf("Login attempt - Email: %s, Password length: %d", email, len(password))
+	original, err := json.Marshal(existing)
+	if err != nil {
+		return nil, err
+	}
 
-	user, err := s.repo.FindByEmail(email)
+	merged, err := mergepatch.Apply(original, patch)
 	if err != nil {
-		log.Printf("Error finding user by email: %v", err)
-		return "", err
+		return nil, err
+	}
+
+	var updated domain.User
+	if err := json.Unmarshal(merged, &updated); err != nil {
+		return nil, err
 	}
-	if user == nil {
-		log.Printf("User not found for email: %s", email)
-		return "", errors.New("invalid credentials")
+
+	// Only profile fields are editable through a patch.
+	previous := *existing
+	existing.Name = updated.Name
+	existing.Email = updated.Email
+	existing.Role = updated.Role
+
+	if err := s.validateProfile(existing); err != nil {
+		return nil, err
+	}
+	if existing.Email != previous.Email {
+		existing.EmailVerifiedAt = nil
 	}
 
-	if user.Password == "" {
-		log.Printf("Password is empty for user ID: %d, Email: %s", user.ID, user.Email)
-		return "", errors.New("invalid credentials: password not set for user")
+	if err := s.repo.Update(existing); err != nil {
+		return nil, err
 	}
+	if err := s.revokeOnRoleChange(&previous, existing.Role); err != nil {
+		return nil, err
+	}
+	s.verifyChangedEmail(&previous, existing)
+
+	return existing, nil
+}
 
-	if len(user.Password) < 10 || !strings.HasPrefix(user.Password, "$2") {
-		log.Printf("Invalid password hash format for user ID: %d, Email: %s, Hash: %s", user.ID, user.Email, user.Password[:min(20, len(user.Password))])
-		return "", errors.New("invalid credentials: corrupted password hash")
+// revokeOnRoleChange ends the user's sessions when their role changed, so
+// refreshed tokens don't keep carrying the old role.
+func (s *userService) revokeOnRoleChange(before *domain.User, role domain.Role) error {
+	if before.Role == role {
+		return nil
 	}
+	return s.tokens.RevokeUser(context.Background(), before.ID)
+}
 
-	log.Printf("Comparing password - User ID: %d, Email: %s, Hash length: %d, Hash prefix: %s, Input password length: %d",
-		user.ID, user.Email, len(user.Password), user.Password[:min(10, len(user.Password))], len(password))
+// verifyChangedEmail mails a verification link when the user's email
+// changed. Until it is followed the new address counts as unverified. A
+// failed delivery doesn't undo the update; the user can ask for the link
+// again.
+func (s *userService) verifyChangedEmail(before, after *domain.User) {
+	if before.Email == after.Email {
+		return
+	}
+	if err := s.verification.Send(context.Background(), after); err != nil {
+		log.Printf("Failed to send verification email to user %d: %v", after.ID, err)
+	}
+}
+
+func (s *userService) validateProfile(user *domain.User) error {
+	user.Name = strings.TrimSpace(user.Name)
+	user.Email = strings.ToLower(strings.TrimSpace(user.Email))
 
-	testHash, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	log.Printf("Test hash generated from input password - Length: %d, Prefix: %s", len(testHash), string(testHash)[:min(10, len(testHash))])
+	if user.Name == "" {
+		return errors.New("name cannot be empty")
+	}
+	if user.Email == "" {
+		return errors.New("email cannot be empty")
+	}
+	if !user.Role.IsValid() {
+		return errors.New("invalid role. Must be 'admin', 'employee', 'seller', or 'customer'")
+	}
 
-	err = bcrypt.CompareHashAndPassword(
-		[]byte(user.Password),
-		[]byte(password),
-	)
+	other, err := s.repo.FindByEmail(user.Email)
 	if err != nil {
-		log.Printf("Password comparison failed for user ID: %d, Error: %v", user.ID, err)
-		log.Printf("Stored hash: %s...", user.Password[:min(30, len(user.Password))])
-		return "", errors.New("invalid credentials")
+		return err
+	}
+	if other != nil && other.ID != user.ID {
+		return ErrEmailInUse
 	}
 
-	log.Printf("Password comparison successful for user ID: %d", user.ID)
+	return nil
+}
+
+func (s *userService) Delete(id uint) error {
+	if id == 0 {
+		return errors.New("invalid user id")
+	}
 
-	if s.config.JWTSecret == "" {
-		log.Printf("JWT_SECRET not configured")
-		return "", errors.New("JWT_SECRET not configured")
+	if err := s.repo.Delete(id); err != nil {
+		return err
 	}
+	return s.tokens.RevokeUser(context.Background(), id)
+}
 
-	log.Printf("Generating token for user ID: %d", user.ID)
-	token, err := auth.GenerateToken(user.ID, s.config.JWTSecret, user.Role)
+func (s *userService) RevokeSessions(id uint) error {
+	existing, err := s.repo.FindByID(id)
 	if err != nil {
-		log.Printf("Error generating token: %v", err)
-		return "", err
+		return err
+	}
+	if existing == nil {
+		return ErrUserNotFound
 	}
 
-	log.Printf("Token generated successfully, length: %d", len(token))
-	return token, nil
+	return s.tokens.RevokeUser(context.Background(), id)
 }
 
-func min(a, b int) int {
-	if a < b {
-		return a
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
 	}
-	return b
+	return string(hash), nil
 }
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
+	"github.com/rkweber-max/checkout-backend/pkg/config"
+)
+
+// sentVerifications records the users verification links were sent to.
+type sentVerifications struct {
+	VerificationService
+
+	sent []string
+}
+
+func (f *sentVerifications) Send(ctx context.Context, user *domain.User) error {
+	f.sent = append(f.sent, user.Email)
+	return nil
+}
+
+func newVerifiedUser() *domain.User {
+	verifiedAt := time.Now()
+	return &domain.User{Name: "Ana", Email: "ana@example.com", Role: domain.RoleCustomer, EmailVerifiedAt: &verifiedAt}
+}
+
+func TestUpdateEmailRequiresVerification(t *testing.T) {
+	user := newVerifiedUser()
+	users := newFakeUsers(user)
+	mails := &sentVerifications{}
+	s := NewUserService(users, nil, mails, &config.Config{})
+
+	// Renaming keeps the address verified.
+	rename := &domain.User{ID: user.ID, Version: user.Version, Name: "Ana Maria", Email: user.Email, Role: user.Role}
+	if err := s.Update(rename); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if stored, _ := users.FindByID(user.ID); !stored.EmailVerified() || len(mails.sent) != 0 {
+		t.Fatalf("rename: verified = %v, sent = %v", stored.EmailVerified(), mails.sent)
+	}
+
+	change := &domain.User{ID: user.ID, Version: rename.Version, Name: "Ana Maria", Email: " Ana@Other.example ", Role: user.Role}
+	if err := s.Update(change); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if stored, _ := users.FindByID(user.ID); stored.EmailVerified() {
+		t.Error("new email counts as verified")
+	}
+	if len(mails.sent) != 1 || mails.sent[0] != "ana@other.example" {
+		t.Errorf("verification sent to %v, want the new address", mails.sent)
+	}
+}
+
+func TestPatchEmailRequiresVerification(t *testing.T) {
+	user := newVerifiedUser()
+	users := newFakeUsers(user)
+	mails := &sentVerifications{}
+	s := NewUserService(users, nil, mails, &config.Config{})
+
+	updated, err := s.Patch(user.ID, nil, []byte(`{"email": "ana@other.example"}`))
+	if err != nil {
+		t.Fatalf("Patch: %v", err)
+	}
+	if updated.EmailVerified() {
+		t.Error("patched user still verified")
+	}
+	if stored, _ := users.FindByID(user.ID); stored.EmailVerified() {
+		t.Error("new email counts as verified")
+	}
+	if len(mails.sent) != 1 || mails.sent[0] != "ana@other.example" {
+		t.Errorf("verification sent to %v, want the new address", mails.sent)
+	}
+}
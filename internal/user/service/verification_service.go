@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/internal/auth"
+	"github.com/rkweber-max/checkout-backend/internal/user/domain"
+	"github.com/rkweber-max/checkout-backend/internal/user/repository"
+	"github.com/rkweber-max/checkout-backend/pkg/config"
+	"github.com/rkweber-max/checkout-backend/pkg/mail"
+)
+
+const (
+	defaultEmailVerificationTTLHours = 24
+	emailVerificationAudience        = "email-verification"
+)
+
+var ErrInvalidVerificationToken = errors.New("invalid or expired verification link")
+
+// VerificationService mails email verification links and checks them. A
+// link is bound to the address it was sent to, so it stops working when
+// the user's email changes.
+type VerificationService interface {
+	Send(ctx context.Context, user *domain.User) error
+	Verify(ctx context.Context, token string) (*domain.User, error)
+}
+
+type verificationService struct {
+	repo    repository.UserRepository
+	keys    KeyService
+	mailer  mail.Mailer
+	baseURL string
+	ttl     time.Duration
+}
+
+func NewVerificationService(
+	repo repository.UserRepository,
+	keys KeyService,
+	mailer mail.Mailer,
+	cfg *config.Config,
+) VerificationService {
+	baseURL := cfg.AppURL
+	if baseURL == "" {
+		baseURL = "http://localhost:" + cfg.AppPort
+	}
+
+	return &verificationService{
+		repo:    repo,
+		keys:    keys,
+		mailer:  mailer,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		ttl:     emailVerificationTTL(cfg),
+	}
+}
+
+func (s *verificationService) Send(ctx context.Context, user *domain.User) error {
+	key, err := s.keys.SigningKey(ctx)
+	if err != nil {
+		return err
+	}
+	token, err := auth.IssueToken(key, s.tokenOptions(), map[string]interface{}{
+		"sub":   user.ID,
+		"email": user.Email,
+	})
+	if err != nil {
+		return err
+	}
+
+	link := s.baseURL + "/api/register/verify?token=" + url.QueryEscape(token.Token)
+	return s.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nConfirm your email address to start placing orders:\n\n%s\n\nThe link expires in %d hours. If you didn't ask for this, ignore this email.\n",
+			user.Name, link, int(s.ttl.Hours()),
+		),
+	})
+}
+
+// Verify marks the email in the token as verified. The token stops
+// working once used.
+func (s *verificationService) Verify(ctx context.Context, token string) (*domain.User, error) {
+	claims, err := auth.ParseToken(ctx, s.keys, s.tokenOptions(), token)
+	if err != nil {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	sub, _ := claims["sub"].(float64)
+	email, _ := claims["email"].(string)
+	if sub <= 0 || email == "" {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	verified, err := s.repo.MarkEmailVerified(uint(sub), email, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !verified {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	return s.repo.FindByID(uint(sub))
+}
+
+func (s *verificationService) tokenOptions() auth.TokenOptions {
+	opts := s.keys.TokenOptions()
+	opts.Audience = emailVerificationAudience
+	opts.TTL = s.ttl
+	return opts
+}
+
+func emailVerificationTTL(cfg *config.Config) time.Duration {
+	hours := cfg.EmailVerificationTTLHours
+	if hours <= 0 {
+		hours = defaultEmailVerificationTTLHours
+	}
+	return time.Duration(hours) * time.Hour
+}
@@ -7,8 +7,17 @@ import (
 )
 
 type Config struct {
-	AppPort   string `mapstructure:"APP_PORT"`
-	JWTSecret string `mapstructure:"JWT_SECRET"`
+	AppPort string `mapstructure:"APP_PORT"`
+
+	JWTAlgorithm        string `mapstructure:"JWT_ALGORITHM"`
+	JWTIssuer           string `mapstructure:"JWT_ISSUER"`
+	JWTAudience         string `mapstructure:"JWT_AUDIENCE"`
+	JWTKeyRotationDays  int    `mapstructure:"JWT_KEY_ROTATION_DAYS"`
+	JWTKeyGraceHours    int    `mapstructure:"JWT_KEY_GRACE_HOURS"`
+	JWTKeyEncryptionKey string `mapstructure:"JWT_KEY_ENCRYPTION_KEY"`
+
+	AccessTokenTTLMinutes int `mapstructure:"ACCESS_TOKEN_TTL_MINUTES"`
+	RefreshTokenTTLHours  int `mapstructure:"REFRESH_TOKEN_TTL_HOURS"`
 
 	DBHost     string `mapstructure:"DB_HOST"`
 	DBPort     string `mapstructure:"DB_PORT"`
@@ -16,6 +25,57 @@ type Config struct {
 	DBPassword string `mapstructure:"DB_PASSWORD"`
 	DBName     string `mapstructure:"DB_NAME"`
 	DBSSLMode  string `mapstructure:"DB_SSLMODE"`
+
+	StorageDriver    string `mapstructure:"STORAGE_DRIVER"`
+	StorageLocalDir  string `mapstructure:"STORAGE_LOCAL_DIR"`
+	StoragePublicURL string `mapstructure:"STORAGE_PUBLIC_URL"`
+	S3Endpoint       string `mapstructure:"S3_ENDPOINT"`
+	S3Region         string `mapstructure:"S3_REGION"`
+	S3Bucket         string `mapstructure:"S3_BUCKET"`
+	S3AccessKey      string `mapstructure:"S3_ACCESS_KEY"`
+	S3SecretKey      string `mapstructure:"S3_SECRET_KEY"`
+	ImageMaxSizeMB   int    `mapstructure:"IMAGE_MAX_SIZE_MB"`
+
+	ProductTrashRetentionDays int `mapstructure:"PRODUCT_TRASH_RETENTION_DAYS"`
+
+	NotifierDriver     string `mapstructure:"NOTIFIER_DRIVER"`
+	NotifierFilePath   string `mapstructure:"NOTIFIER_FILE_PATH"`
+	NotifierWebhookURL string `mapstructure:"NOTIFIER_WEBHOOK_URL"`
+
+	ReorderVelocityDays int `mapstructure:"REORDER_VELOCITY_DAYS"`
+	ReorderCoverDays    int `mapstructure:"REORDER_COVER_DAYS"`
+
+	AppURL string `mapstructure:"APP_URL"`
+
+	MailDriver    string `mapstructure:"MAIL_DRIVER"`
+	MailFrom      string `mapstructure:"MAIL_FROM"`
+	MailOutboxDir string `mapstructure:"MAIL_OUTBOX_DIR"`
+	SMTPHost      string `mapstructure:"SMTP_HOST"`
+	SMTPPort      string `mapstructure:"SMTP_PORT"`
+	SMTPUsername  string `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword  string `mapstructure:"SMTP_PASSWORD"`
+
+	EmailVerificationTTLHours int `mapstructure:"EMAIL_VERIFICATION_TTL_HOURS"`
+
+	LoginMaxFailures    int `mapstructure:"LOGIN_MAX_FAILURES"`
+	LoginLockoutMinutes int `mapstructure:"LOGIN_LOCKOUT_MINUTES"`
+	LoginIPMaxFailures  int `mapstructure:"LOGIN_IP_MAX_FAILURES"`
+
+	MFAIssuer        string `mapstructure:"MFA_ISSUER"`
+	MFAEncryptionKey string `mapstructure:"MFA_ENCRYPTION_KEY"`
+
+	PasswordResetURL        string `mapstructure:"PASSWORD_RESET_URL"`
+	PasswordResetTTLMinutes int    `mapstructure:"PASSWORD_RESET_TTL_MINUTES"`
+	PasswordResetEmailLimit int    `mapstructure:"PASSWORD_RESET_EMAIL_LIMIT"`
+	PasswordResetIPLimit    int    `mapstructure:"PASSWORD_RESET_IP_LIMIT"`
+
+	OIDCIssuer       string `mapstructure:"OIDC_ISSUER"`
+	OIDCClientID     string `mapstructure:"OIDC_CLIENT_ID"`
+	OIDCClientSecret string `mapstructure:"OIDC_CLIENT_SECRET"`
+	OIDCRedirectURL  string `mapstructure:"OIDC_REDIRECT_URL"`
+	OIDCScopes       string `mapstructure:"OIDC_SCOPES"`
+	OIDCGroupsClaim  string `mapstructure:"OIDC_GROUPS_CLAIM"`
+	OIDCRoleMapping  string `mapstructure:"OIDC_ROLE_MAPPING"`
 }
 
 func LoadConfig() (*Config, error) {
@@ -3,10 +3,14 @@ package database
 import (
 	"fmt"
 
+	checkout "github.com/rkweber-max/checkout-backend/internal/checkout/domain"
+	inventory "github.com/rkweber-max/checkout-backend/internal/inventory/domain"
+	"github.com/rkweber-max/checkout-backend/internal/product"
 	"github.com/rkweber-max/checkout-backend/internal/user/domain"
 	"github.com/rkweber-max/checkout-backend/pkg/config"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func NewPostgresDB(cfg *config.Config) (*gorm.DB, error) {
@@ -25,9 +29,110 @@ func NewPostgresDB(cfg *config.Config) (*gorm.DB, error) {
 		return nil, err
 	}
 
-	if err := db.AutoMigrate(&domain.User{}); err != nil {
+	// Users that existed before email verification was introduced are
+	// trusted as verified.
+	backfillVerified := !db.Migrator().HasColumn(&domain.User{}, "email_verified_at")
+	// Users that existed before multiple roles hold just their primary role.
+	backfillRoles := !db.Migrator().HasTable(&domain.UserRole{})
+
+	if err := db.AutoMigrate(
+		&domain.User{},
+		&domain.RefreshToken{},
+		&domain.RevokedToken{},
+		&domain.PasswordResetToken{},
+		&domain.LoginThrottle{},
+		&domain.LoginEvent{},
+		&domain.MFAEnrollment{},
+		&domain.RecoveryCode{},
+		&domain.MFAPolicy{},
+		&domain.SigningKey{},
+		&domain.RoleDefinition{},
+		&domain.RolePermission{},
+		&domain.UserRole{},
+		&domain.APIKey{},
+		&domain.APIKeyPermission{},
+		&domain.UserIdentity{},
+		&domain.OIDCLoginState{},
+		&product.Product{},
+		&product.Category{},
+		&product.ProductCategory{},
+		&product.AttributeDefinition{},
+		&product.Review{},
+		&product.WishlistItem{},
+		&product.StockSubscription{},
+		&product.OptionType{},
+		&product.OptionValue{},
+		&product.Variant{},
+		&product.Image{},
+		&product.PriceChange{},
+		&product.ScheduledPrice{},
+		&product.Bundle{},
+		&product.BundleComponent{},
+		&inventory.Warehouse{},
+		&inventory.StockLevel{},
+		&inventory.StockMovement{},
+		&inventory.Transfer{},
+		&inventory.TransferLine{},
+		&inventory.Supplier{},
+		&inventory.PurchaseOrder{},
+		&inventory.PurchaseOrderLine{},
+		&inventory.ProductCost{},
+		&inventory.ReorderRule{},
+		&checkout.Order{},
+		&checkout.OrderLine{},
+		&checkout.OrderLineComponent{},
+	); err != nil {
+		return nil, err
+	}
+
+	if backfillVerified {
+		if err := db.Exec("UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL").Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if err := seedRoles(db); err != nil {
 		return nil, err
 	}
+	if backfillRoles {
+		if err := db.Exec("INSERT INTO user_roles (user_id, role) SELECT id, role FROM users ON CONFLICT DO NOTHING").Error; err != nil {
+			return nil, err
+		}
+	}
 
 	return db, nil
 }
+
+// seedRoles creates the built-in roles that are missing, with the
+// permissions matching what each could do before roles were editable.
+// Roles that exist are left alone so admins' edits survive restarts.
+func seedRoles(db *gorm.DB) error {
+	builtIn := []domain.Role{domain.RoleAdmin, domain.RoleEmployee, domain.RoleSeller, domain.RoleCustomer}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, name := range builtIn {
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&domain.RoleDefinition{
+				Name:    name,
+				BuiltIn: true,
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				continue
+			}
+
+			defaults := domain.DefaultRolePermissions[name]
+			if len(defaults) == 0 {
+				continue
+			}
+			grants := make([]domain.RolePermission, len(defaults))
+			for i, p := range defaults {
+				grants[i] = domain.RolePermission{Role: name, Permission: p}
+			}
+			if err := tx.Create(&grants).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
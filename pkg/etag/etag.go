@@ -0,0 +1,44 @@
+// Package etag converts between entity versions and HTTP entity tags.
+package etag
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalid = errors.New("invalid entity tag")
+
+// Format returns the strong entity tag for a version.
+func Format(version int64) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// Parse extracts the version from an entity tag. Weak tags are accepted
+// since versions identify the representation exactly.
+func Parse(tag string) (int64, error) {
+	tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, ErrInvalid
+	}
+
+	version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+	if err != nil {
+		return 0, ErrInvalid
+	}
+	return version, nil
+}
+
+// IfMatch returns the version from the If-Match header, and whether the
+// header was present.
+func IfMatch(r *http.Request) (int64, bool, error) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return 0, false, nil
+	}
+
+	version, err := Parse(header)
+	return version, true, err
+}
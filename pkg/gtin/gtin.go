@@ -0,0 +1,52 @@
+// Package gtin validates the GS1 barcodes printed on products: EAN-8, UPC-A
+// and EAN-13.
+package gtin
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalid = errors.New("invalid GTIN: must be a valid EAN-8, UPC-A or EAN-13 code")
+
+// Normalize validates the code and returns its canonical form. UPC-A codes
+// are returned as EAN-13 with a leading zero, so a product registered with
+// either form is found by a scanner reading the other. Spaces and dashes
+// are ignored.
+func Normalize(code string) (string, error) {
+	code = strings.NewReplacer(" ", "", "-", "").Replace(code)
+
+	switch len(code) {
+	case 8, 13:
+	case 12:
+		code = "0" + code
+	default:
+		return "", ErrInvalid
+	}
+
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return "", ErrInvalid
+		}
+	}
+
+	if checkDigit(code[:len(code)-1]) != code[len(code)-1] {
+		return "", ErrInvalid
+	}
+
+	return code, nil
+}
+
+// checkDigit computes the GS1 check digit: digits are weighted 3 and 1
+// alternately starting from the rightmost one.
+func checkDigit(digits string) byte {
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
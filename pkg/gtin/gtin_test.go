@@ -0,0 +1,45 @@
+package gtin
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"4006381333931", "4006381333931"},
+		{"96385074", "96385074"},
+		// UPC-A becomes EAN-13 with a leading zero.
+		{"036000291452", "0036000291452"},
+		{"0036000291452", "0036000291452"},
+		{"400-6381 333931", "4006381333931"},
+	}
+	for _, tt := range tests {
+		got, err := Normalize(tt.code)
+		if err != nil {
+			t.Errorf("Normalize(%q): %v", tt.code, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeRejects(t *testing.T) {
+	for _, code := range []string{
+		"",
+		"4006381333932",  // wrong check digit
+		"036000291453",   // wrong check digit
+		"400638133393A",  // not a digit
+		"12345",          // wrong length
+		"40063813339310", // GTIN-14 is not accepted
+	} {
+		if _, err := Normalize(code); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Normalize(%q) error = %v, want ErrInvalid", code, err)
+		}
+	}
+}
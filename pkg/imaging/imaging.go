@@ -0,0 +1,135 @@
+// Package imaging decodes uploaded images and produces thumbnails using
+// only the standard library codecs (JPEG, PNG and GIF).
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+// MaxPixels bounds width*height of accepted images to guard against
+// decompression bombs.
+const MaxPixels = 40_000_000
+
+var (
+	ErrUnsupportedImage = errors.New("unsupported image type")
+	ErrInvalidImage     = errors.New("invalid image")
+)
+
+var extensions = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+// Info describes a decoded image.
+type Info struct {
+	ContentType string
+	Extension   string
+	Width       int
+	Height      int
+}
+
+// Decode sniffs the content type from the bytes themselves, ignoring any
+// client-supplied type, and decodes the image.
+func Decode(data []byte) (image.Image, Info, error) {
+	contentType := http.DetectContentType(data)
+	ext, ok := extensions[contentType]
+	if !ok {
+		return nil, Info{}, fmt.Errorf("%w: %s", ErrUnsupportedImage, contentType)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, Info{}, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, Info{}, fmt.Errorf("%w: %dx%d pixels exceeds the limit of %d", ErrInvalidImage, cfg.Width, cfg.Height, MaxPixels)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, Info{}, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+
+	return img, Info{ContentType: contentType, Extension: ext, Width: cfg.Width, Height: cfg.Height}, nil
+}
+
+// Thumbnail scales img down so that neither side exceeds maxSide, keeping
+// the aspect ratio. Images already small enough are returned unchanged.
+// Each destination pixel averages the source pixels it covers.
+func Thumbnail(img image.Image, maxSide int) image.Image {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if srcW <= maxSide && srcH <= maxSide {
+		return img
+	}
+
+	dstW, dstH := maxSide, maxSide
+	if srcW > srcH {
+		dstH = max(1, srcH*maxSide/srcW)
+	} else {
+		dstW = max(1, srcW*maxSide/srcH)
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		y0 := bounds.Min.Y + y*srcH/dstH
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*srcH/dstH)
+		for x := 0; x < dstW; x++ {
+			x0 := bounds.Min.X + x*srcW/dstW
+			x1 := max(x0+1, bounds.Min.X+(x+1)*srcW/dstW)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := color.NRGBAModel.Convert(img.At(sx, sy)).(color.NRGBA)
+					r += uint64(c.R)
+					g += uint64(c.G)
+					b += uint64(c.B)
+					a += uint64(c.A)
+					n++
+				}
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: uint8(a / n)})
+		}
+	}
+
+	return dst
+}
+
+// ThumbnailContentType returns the type thumbnails of a source image are
+// encoded in: JPEG stays JPEG, everything else becomes PNG to keep
+// transparency.
+func ThumbnailContentType(sourceType string) string {
+	if sourceType == "image/jpeg" {
+		return "image/jpeg"
+	}
+	return "image/png"
+}
+
+// EncodeThumbnail encodes img in ThumbnailContentType(sourceType).
+func EncodeThumbnail(img image.Image, sourceType string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+
+	if ThumbnailContentType(sourceType) == "image/jpeg" {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+
+	return buf.Bytes(), err
+}
+
+// Extension returns the file extension for a supported content type.
+func Extension(contentType string) string {
+	return extensions[contentType]
+}
@@ -0,0 +1,33 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const defaultOutboxDir = "outbox"
+
+// FileMailer writes each message to its own .eml file in a directory, so
+// development setups can read the mail without a mail server.
+type FileMailer struct {
+	dir string
+}
+
+func NewFileMailer(dir string) (*FileMailer, error) {
+	if dir == "" {
+		dir = defaultOutboxDir
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileMailer{dir: dir}, nil
+}
+
+func (f *FileMailer) Send(_ context.Context, m Message) error {
+	now := time.Now()
+	name := fmt.Sprintf("%s-%d.eml", now.Format("20060102T150405"), now.UnixNano())
+	return os.WriteFile(filepath.Join(f.dir, name), format(m, now), 0o644)
+}
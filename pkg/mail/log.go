@@ -0,0 +1,15 @@
+package mail
+
+import (
+	"context"
+	"log"
+)
+
+// LogMailer writes messages to the application log instead of sending
+// them.
+type LogMailer struct{}
+
+func (LogMailer) Send(_ context.Context, m Message) error {
+	log.Printf("[mail] to=%s subject=%q\n%s", m.To, m.Subject, m.Body)
+	return nil
+}
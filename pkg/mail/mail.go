@@ -0,0 +1,69 @@
+// Package mail sends transactional email, such as verification links, to
+// users.
+package mail
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rkweber-max/checkout-backend/pkg/config"
+)
+
+const (
+	DriverLog  = "log"
+	DriverFile = "file"
+	DriverSMTP = "smtp"
+)
+
+const defaultFrom = "no-reply@checkout.local"
+
+// Message is a plain-text email.
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// NewMailer builds the mailer selected by MAIL_DRIVER, defaulting to the
+// application log. Messages without a sender are sent from MAIL_FROM.
+func NewMailer(cfg *config.Config) (Mailer, error) {
+	from := cfg.MailFrom
+	if from == "" {
+		from = defaultFrom
+	}
+
+	var mailer Mailer
+	var err error
+	switch cfg.MailDriver {
+	case "", DriverLog:
+		mailer = LogMailer{}
+	case DriverFile:
+		mailer, err = NewFileMailer(cfg.MailOutboxDir)
+	case DriverSMTP:
+		mailer, err = NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword)
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.MailDriver)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &defaultSender{from: from, next: mailer}, nil
+}
+
+type defaultSender struct {
+	from string
+	next Mailer
+}
+
+func (d *defaultSender) Send(ctx context.Context, m Message) error {
+	if m.From == "" {
+		m.From = d.from
+	}
+	return d.next.Send(ctx, m)
+}
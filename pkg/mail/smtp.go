@@ -0,0 +1,52 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// SMTPMailer delivers messages through an SMTP server. Without credentials
+// it sends unauthenticated, which suits local catchers such as MailHog.
+type SMTPMailer struct {
+	addr string
+	auth smtp.Auth
+}
+
+func NewSMTPMailer(host, port, username, password string) (*SMTPMailer, error) {
+	if host == "" {
+		return nil, errors.New("smtp mailer requires SMTP_HOST")
+	}
+	if port == "" {
+		port = "25"
+	}
+
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &SMTPMailer{addr: net.JoinHostPort(host, port), auth: auth}, nil
+}
+
+func (s *SMTPMailer) Send(_ context.Context, m Message) error {
+	return smtp.SendMail(s.addr, s.auth, m.From, []string{m.To}, format(m, time.Now()))
+}
+
+// format renders the message in RFC 5322 form.
+func format(m Message, at time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", m.From)
+	fmt.Fprintf(&b, "To: %s\r\n", m.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", at.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(m.Body)
+	return b.Bytes()
+}
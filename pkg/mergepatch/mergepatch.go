@@ -0,0 +1,53 @@
+// Package mergepatch implements JSON Merge Patch (RFC 7396).
+package mergepatch
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+const ContentType = "application/merge-patch+json"
+
+var ErrInvalidPatch = errors.New("merge patch must be a JSON object")
+
+// Apply merges patch into the original document: members set to null are
+// removed, objects are merged recursively and any other value replaces the
+// original one.
+func Apply(original, patch []byte) ([]byte, error) {
+	var patchDoc interface{}
+	if err := json.Unmarshal(patch, &patchDoc); err != nil {
+		return nil, err
+	}
+	if _, ok := patchDoc.(map[string]interface{}); !ok {
+		return nil, ErrInvalidPatch
+	}
+
+	var originalDoc interface{}
+	if err := json.Unmarshal(original, &originalDoc); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(merge(originalDoc, patchDoc))
+}
+
+func merge(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = map[string]interface{}{}
+	}
+
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = merge(targetObj[key], value)
+	}
+
+	return targetObj
+}
@@ -0,0 +1,46 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const defaultNotificationFile = "notifications.log"
+
+// FileNotifier appends notifications to a file, one JSON object per line.
+type FileNotifier struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileNotifier(path string) (*FileNotifier, error) {
+	if path == "" {
+		path = defaultNotificationFile
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	return &FileNotifier{path: path}, nil
+}
+
+func (f *FileNotifier) Notify(_ context.Context, n Notification) error {
+	line, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
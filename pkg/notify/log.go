@@ -0,0 +1,14 @@
+package notify
+
+import (
+	"context"
+	"log"
+)
+
+// LogNotifier writes notifications to the application log.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(_ context.Context, n Notification) error {
+	log.Printf("[%s] %s", n.Kind, n.Message)
+	return nil
+}
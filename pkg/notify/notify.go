@@ -0,0 +1,45 @@
+// Package notify delivers operational alerts, such as low stock, to the
+// people who act on them.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rkweber-max/checkout-backend/pkg/config"
+)
+
+const (
+	DriverLog     = "log"
+	DriverFile    = "file"
+	DriverWebhook = "webhook"
+)
+
+// Notification is a single alert. Kind identifies the type of alert, such
+// as "low_stock", and Data carries its details for machine consumers.
+type Notification struct {
+	Kind    string                 `json:"kind"`
+	Message string                 `json:"message"`
+	Data    map[string]interface{} `json:"data,omitempty"`
+	At      time.Time              `json:"at"`
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// NewNotifier builds the notifier selected by NOTIFIER_DRIVER, defaulting to
+// the application log.
+func NewNotifier(cfg *config.Config) (Notifier, error) {
+	switch cfg.NotifierDriver {
+	case "", DriverLog:
+		return LogNotifier{}, nil
+	case DriverFile:
+		return NewFileNotifier(cfg.NotifierFilePath)
+	case DriverWebhook:
+		return NewWebhookNotifier(cfg.NotifierWebhookURL)
+	default:
+		return nil, fmt.Errorf("unknown notifier driver %q", cfg.NotifierDriver)
+	}
+}
@@ -0,0 +1,240 @@
+// Package oidc signs users in with an OpenID Connect provider using the
+// authorization code flow with PKCE (RFC 7636).
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxResponseBytes = 1 << 20
+
+	// discoveryRetry is how long a failed discovery is remembered before
+	// the provider is asked again.
+	discoveryRetry = 30 * time.Second
+)
+
+// Config identifies the provider and this application as its client.
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	HTTPClient   *http.Client
+}
+
+// Metadata is the part of the provider's discovery document the login
+// flow needs.
+type Metadata struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	SigningAlgorithms     []string `json:"id_token_signing_alg_values_supported"`
+	CodeChallengeMethods  []string `json:"code_challenge_methods_supported"`
+}
+
+// TokenResponse is what the token endpoint returns for an authorization
+// code.
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	IDToken     string `json:"id_token"`
+}
+
+// Provider talks to one OpenID Connect provider. The discovery document
+// and signing keys are fetched on first use and cached, so the
+// application starts even while the provider is unreachable.
+type Provider struct {
+	cfg    Config
+	client *http.Client
+
+	mu          sync.Mutex
+	metadata    *Metadata
+	discoverErr error
+	discoverAt  time.Time
+
+	keys *keySet
+}
+
+func NewProvider(cfg Config) *Provider {
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	p := &Provider{cfg: cfg, client: client}
+	p.keys = &keySet{provider: p}
+	return p
+}
+
+// Metadata returns the provider's discovery document. Its issuer must be
+// exactly the configured one, so a document served for another issuer
+// can't redirect the login elsewhere.
+func (p *Provider) Metadata(ctx context.Context) (*Metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+	if p.discoverErr != nil && time.Since(p.discoverAt) < discoveryRetry {
+		return nil, p.discoverErr
+	}
+
+	metadata, err := p.discover(ctx)
+	p.discoverAt = time.Now()
+	p.discoverErr = err
+	if err != nil {
+		return nil, err
+	}
+	p.metadata = metadata
+	return metadata, nil
+}
+
+func (p *Provider) discover(ctx context.Context) (*Metadata, error) {
+	endpoint := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+
+	var metadata Metadata
+	if err := p.getJSON(ctx, endpoint, &metadata); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if metadata.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer %q doesn't match %q", metadata.Issuer, p.cfg.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, errors.New("oidc discovery: authorization, token or jwks endpoint missing")
+	}
+	if len(metadata.CodeChallengeMethods) > 0 && !contains(metadata.CodeChallengeMethods, "S256") {
+		return nil, errors.New("oidc discovery: provider doesn't support PKCE with S256")
+	}
+	return &metadata, nil
+}
+
+// AuthCodeURL returns where to send the user to sign in. state and nonce
+// tie the answer to this login; challenge is the PKCE code challenge.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, challenge string) (string, error) {
+	metadata, err := p.Metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return metadata.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange trades the authorization code for tokens, proving with the
+// PKCE verifier that this client started the login.
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (*TokenResponse, error) {
+	metadata, err := p.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	if p.cfg.ClientSecret == "" {
+		form.Set("client_id", p.cfg.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		// RFC 6749 section 2.3.1 form-encodes the credentials first.
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc token request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, fmt.Errorf("oidc token request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}
+		if json.Unmarshal(body, &failure) == nil && failure.Error != "" {
+			return nil, fmt.Errorf("oidc token request: %s: %s", failure.Error, failure.Description)
+		}
+		return nil, fmt.Errorf("oidc token request: status %d", resp.StatusCode)
+	}
+
+	var tokens TokenResponse
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return nil, fmt.Errorf("oidc token response: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return nil, errors.New("oidc token response has no id_token")
+	}
+	return &tokens, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, endpoint string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", endpoint, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(v)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,251 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rkweber-max/checkout-backend/pkg/oidc/oidctest"
+)
+
+const redirectURL = "http://localhost:8080/api/login/oidc/callback"
+
+func newTestProvider(server *oidctest.Server) *Provider {
+	return NewProvider(Config{
+		Issuer:       server.Issuer,
+		ClientID:     oidctest.ClientID,
+		ClientSecret: oidctest.ClientSecret,
+		RedirectURL:  redirectURL,
+	})
+}
+
+func TestMetadata(t *testing.T) {
+	server := oidctest.NewServer(t)
+	metadata, err := newTestProvider(server).Metadata(context.Background())
+	if err != nil {
+		t.Fatalf("Metadata: %v", err)
+	}
+	if metadata.Issuer != server.Issuer {
+		t.Errorf("issuer = %q, want %q", metadata.Issuer, server.Issuer)
+	}
+	if metadata.TokenEndpoint != server.Issuer+"/token" || metadata.JWKSURI != server.Issuer+"/jwks" {
+		t.Errorf("unexpected endpoints in %+v", metadata)
+	}
+}
+
+func TestMetadataRejectsBadDiscovery(t *testing.T) {
+	tests := map[string]map[string]interface{}{
+		"other issuer":     {"issuer": "https://evil.example.com"},
+		"no token URL":     {"token_endpoint": ""},
+		"no jwks URL":      {"jwks_uri": ""},
+		"no S256 for PKCE": {"code_challenge_methods_supported": []string{"plain"}},
+	}
+	for name, override := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := oidctest.NewServer(t)
+			server.Discovery = override
+			if _, err := newTestProvider(server).Metadata(context.Background()); err == nil {
+				t.Fatal("Metadata accepted the discovery document")
+			}
+		})
+	}
+}
+
+func TestVerifierAndChallenge(t *testing.T) {
+	verifier, err := NewVerifier()
+	if err != nil {
+		t.Fatalf("NewVerifier: %v", err)
+	}
+	// RFC 7636 section 4.1: 43 to 128 unreserved characters.
+	if !regexp.MustCompile(`^[A-Za-z0-9\-._~]{43,128}$`).MatchString(verifier) {
+		t.Errorf("verifier %q is not a valid PKCE verifier", verifier)
+	}
+
+	other, _ := NewVerifier()
+	if other == verifier {
+		t.Error("NewVerifier returned the same value twice")
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	if got, want := Challenge(verifier), base64.RawURLEncoding.EncodeToString(sum[:]); got != want {
+		t.Errorf("Challenge = %q, want %q", got, want)
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	server := oidctest.NewServer(t)
+	authURL, err := newTestProvider(server).AuthCodeURL(context.Background(), "the-state", "the-nonce", "the-challenge")
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse %q: %v", authURL, err)
+	}
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             oidctest.ClientID,
+		"redirect_uri":          redirectURL,
+		"scope":                 "openid email profile",
+		"state":                 "the-state",
+		"nonce":                 "the-nonce",
+		"code_challenge":        "the-challenge",
+		"code_challenge_method": "S256",
+	}
+	for name, value := range want {
+		if got := u.Query().Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestLoginFlow(t *testing.T) {
+	server := oidctest.NewServer(t)
+	provider := newTestProvider(server)
+	ctx := context.Background()
+
+	verifier, _ := NewVerifier()
+	authURL, err := provider.AuthCodeURL(ctx, "state", "nonce-1", Challenge(verifier))
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	_, code := server.Authorize(t, authURL, jwt.MapClaims{"groups": []string{"staff", "it"}})
+
+	tokens, err := provider.Exchange(ctx, code, verifier)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	token, err := provider.VerifyIDToken(ctx, tokens.IDToken, "nonce-1")
+	if err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+	if token.Subject != "user-1" || token.Email != "jane@example.com" || !token.EmailVerified || token.Name != "Jane Doe" {
+		t.Errorf("unexpected token %+v", token)
+	}
+	if got := strings.Join(token.Strings("groups"), ","); got != "staff,it" {
+		t.Errorf("groups = %q, want staff,it", got)
+	}
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	server := oidctest.NewServer(t)
+	provider := newTestProvider(server)
+	ctx := context.Background()
+
+	verifier, _ := NewVerifier()
+	authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", Challenge(verifier))
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	_, code := server.Authorize(t, authURL, nil)
+
+	other, _ := NewVerifier()
+	_, err = provider.Exchange(ctx, code, other)
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("Exchange error = %v, want invalid_grant", err)
+	}
+}
+
+func TestVerifyIDTokenRejects(t *testing.T) {
+	server := oidctest.NewServer(t)
+	forgeKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token func(claims jwt.MapClaims) string
+	}{
+		{"bad signature", func(claims jwt.MapClaims) string {
+			return server.SignWith(t, forgeKey, claims)
+		}},
+		{"other issuer", func(claims jwt.MapClaims) string {
+			claims["iss"] = "https://evil.example.com"
+			return server.Sign(t, claims)
+		}},
+		{"other audience", func(claims jwt.MapClaims) string {
+			claims["aud"] = "another-client"
+			return server.Sign(t, claims)
+		}},
+		{"other authorized party", func(claims jwt.MapClaims) string {
+			claims["aud"] = []string{oidctest.ClientID, "another-client"}
+			claims["azp"] = "another-client"
+			return server.Sign(t, claims)
+		}},
+		{"wrong nonce", func(claims jwt.MapClaims) string {
+			claims["nonce"] = "replayed"
+			return server.Sign(t, claims)
+		}},
+		{"expired", func(claims jwt.MapClaims) string {
+			claims["exp"] = time.Now().Add(-2 * clockSkew).Unix()
+			return server.Sign(t, claims)
+		}},
+		{"no expiry", func(claims jwt.MapClaims) string {
+			delete(claims, "exp")
+			return server.Sign(t, claims)
+		}},
+		{"no subject", func(claims jwt.MapClaims) string {
+			delete(claims, "sub")
+			return server.Sign(t, claims)
+		}},
+		{"unknown key", func(claims jwt.MapClaims) string {
+			token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+			token.Header["kid"] = "rotated-away"
+			signed, _ := token.SignedString(forgeKey)
+			return signed
+		}},
+		{"symmetric algorithm", func(claims jwt.MapClaims) string {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+			token.Header["kid"] = oidctest.KeyID
+			signed, _ := token.SignedString([]byte(oidctest.ClientSecret))
+			return signed
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newTestProvider(server)
+			raw := tt.token(server.Claims("nonce"))
+			if _, err := provider.VerifyIDToken(context.Background(), raw, "nonce"); err == nil {
+				t.Fatal("VerifyIDToken accepted the token")
+			}
+		})
+	}
+
+	// The same claims, correctly signed, pass.
+	if _, err := newTestProvider(server).VerifyIDToken(context.Background(), server.Sign(t, server.Claims("nonce")), "nonce"); err != nil {
+		t.Fatalf("VerifyIDToken rejected a valid token: %v", err)
+	}
+}
+
+func TestIDTokenStrings(t *testing.T) {
+	token := &IDToken{Claims: jwt.MapClaims{
+		"single": "admins",
+		"list":   []interface{}{"a", 1.0, "b"},
+		"number": 3.0,
+	}}
+
+	if got := token.Strings("single"); len(got) != 1 || got[0] != "admins" {
+		t.Errorf("single = %v", got)
+	}
+	if got := strings.Join(token.Strings("list"), ","); got != "a,b" {
+		t.Errorf("list = %q, want a,b", got)
+	}
+	if got := token.Strings("number"); got != nil {
+		t.Errorf("number = %v, want nil", got)
+	}
+	if got := token.Strings("missing"); got != nil {
+		t.Errorf("missing = %v, want nil", got)
+	}
+}
@@ -0,0 +1,237 @@
+// Package oidctest runs a local OpenID Connect provider for tests. It
+// serves discovery, its signing keys and a token endpoint that checks the
+// PKCE verifier; the browser part of the login is replaced by Authorize.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	ClientID     = "checkout"
+	ClientSecret = "s3cret&more"
+	KeyID        = "test-key"
+)
+
+// Server is a mock provider. Issuer is its base URL.
+type Server struct {
+	Issuer string
+
+	// Discovery, when set, replaces fields of the discovery document.
+	Discovery map[string]interface{}
+
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]grant
+}
+
+type grant struct {
+	challenge   string
+	redirectURI string
+	claims      jwt.MapClaims
+}
+
+// NewServer starts a provider that is shut down when the test ends.
+func NewServer(t *testing.T) *Server {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate provider key: %v", err)
+	}
+
+	s := &Server{key: key, grants: make(map[string]grant)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/jwks", s.jwks)
+	mux.HandleFunc("/token", s.token)
+
+	s.server = httptest.NewServer(mux)
+	s.Issuer = s.server.URL
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+// Authorize plays the user signing in at the authorization URL the client
+// built. It returns the state and the code the provider would send back
+// to the redirect URL; the ID token issued for the code carries the
+// standard claims plus the given ones.
+func (s *Server) Authorize(t *testing.T, authURL string, claims jwt.MapClaims) (state, code string) {
+	t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse authorization URL: %v", err)
+	}
+	query := u.Query()
+	if got := query.Get("client_id"); got != ClientID {
+		t.Fatalf("authorization request client_id = %q, want %q", got, ClientID)
+	}
+	if got := query.Get("code_challenge_method"); got != "S256" {
+		t.Fatalf("authorization request code_challenge_method = %q, want S256", got)
+	}
+
+	idClaims := s.Claims(query.Get("nonce"))
+	for name, value := range claims {
+		idClaims[name] = value
+	}
+
+	code = randomString(t)
+	s.mu.Lock()
+	s.grants[code] = grant{
+		challenge:   query.Get("code_challenge"),
+		redirectURI: query.Get("redirect_uri"),
+		claims:      idClaims,
+	}
+	s.mu.Unlock()
+
+	return query.Get("state"), code
+}
+
+// Claims returns valid ID token claims for a verified user.
+func (s *Server) Claims(nonce string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            s.Issuer,
+		"aud":            ClientID,
+		"sub":            "user-1",
+		"nonce":          nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"email":          "jane@example.com",
+		"email_verified": true,
+		"name":           "Jane Doe",
+	}
+}
+
+// Sign signs an ID token with the provider key.
+func (s *Server) Sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	return s.SignWith(t, s.key, claims)
+}
+
+// SignWith signs an ID token with another key under the provider's key ID,
+// as a forger would.
+func (s *Server) SignWith(t *testing.T, key *rsa.PrivateKey, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = KeyID
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign id token: %v", err)
+	}
+	return signed
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	document := map[string]interface{}{
+		"issuer":                                s.Issuer,
+		"authorization_endpoint":                s.Issuer + "/authorize",
+		"token_endpoint":                        s.Issuer + "/token",
+		"jwks_uri":                              s.Issuer + "/jwks",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	}
+	for name, value := range s.Discovery {
+		document[name] = value
+	}
+	writeJSON(w, http.StatusOK, document)
+}
+
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	public := s.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": KeyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+		}},
+	})
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	id, secret, ok := r.BasicAuth()
+	if ok {
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	}
+	if !ok || id != ClientID || secret != ClientSecret {
+		writeError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	s.mu.Lock()
+	g, found := s.grants[code]
+	delete(s.grants, code)
+	s.mu.Unlock()
+
+	if !found || r.PostForm.Get("redirect_uri") != g.redirectURI {
+		writeError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+		writeError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, g.claims)
+	token.Header["kid"] = KeyID
+	idToken, err := token.SignedString(s.key)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "server_error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": "access-" + code,
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	writeJSON(w, status, map[string]string{"error": code})
+}
+
+func randomString(t *testing.T) string {
+	t.Helper()
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatalf("random: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
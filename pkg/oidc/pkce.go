@@ -0,0 +1,30 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// RandomString returns n random bytes encoded as unpadded base64url, e.g.
+// for state, nonce and PKCE verifier values.
+func RandomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// NewVerifier returns a PKCE code verifier: 32 random bytes, which encode
+// to the 43 characters RFC 7636 asks for at least.
+func NewVerifier() (string, error) {
+	return RandomString(32)
+}
+
+// Challenge derives the S256 code challenge sent with the authorization
+// request from the verifier.
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,290 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// keyRefreshInterval limits how often an unknown kid makes the
+	// provider's keys be fetched again, e.g. after it rotated them.
+	keyRefreshInterval = 30 * time.Second
+
+	clockSkew = time.Minute
+)
+
+var signingAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// IDToken is a verified ID token.
+type IDToken struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Claims        jwt.MapClaims
+}
+
+// Strings returns a claim holding a list of strings, such as groups. A
+// single string counts as a list of one.
+func (t *IDToken) Strings(claim string) []string {
+	switch value := t.Claims[claim].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		out := make([]string, 0, len(value))
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+// VerifyIDToken checks the ID token's signature against the provider's
+// published keys and its iss, aud, azp, exp, iat and nonce claims.
+func (p *Provider) VerifyIDToken(ctx context.Context, raw, nonce string) (*IDToken, error) {
+	metadata, err := p.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, err := p.keys.find(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		if key.alg != "" && key.alg != t.Method.Alg() {
+			return nil, fmt.Errorf("id token algorithm %q doesn't match key %q", t.Method.Alg(), key.alg)
+		}
+		if !algorithmFits(t.Method.Alg(), key.public) {
+			return nil, fmt.Errorf("id token algorithm %q doesn't fit the key type", t.Method.Alg())
+		}
+		return key.public, nil
+	},
+		jwt.WithValidMethods(signingAlgorithms),
+		jwt.WithIssuer(metadata.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(clockSkew),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id token: %w", err)
+	}
+
+	audience, _ := claims.GetAudience()
+	azp, _ := claims["azp"].(string)
+	if (len(audience) > 1 || azp != "") && azp != p.cfg.ClientID {
+		return nil, errors.New("invalid id token: issued to another party")
+	}
+	if got, _ := claims["nonce"].(string); got == "" || got != nonce {
+		return nil, errors.New("invalid id token: nonce doesn't match")
+	}
+
+	token := &IDToken{Claims: claims}
+	token.Subject, _ = claims.GetSubject()
+	if token.Subject == "" {
+		return nil, errors.New("invalid id token: no subject")
+	}
+	token.Email, _ = claims["email"].(string)
+	token.Name, _ = claims["name"].(string)
+	// Some providers send email_verified as a string.
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		token.EmailVerified = verified
+	case string:
+		token.EmailVerified = verified == "true"
+	}
+	return token, nil
+}
+
+func algorithmFits(alg string, key crypto.PublicKey) bool {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS")
+	case *ecdsa.PublicKey:
+		return strings.HasPrefix(alg, "ES")
+	case ed25519.PublicKey:
+		return alg == "EdDSA"
+	default:
+		return false
+	}
+}
+
+type publicKey struct {
+	public crypto.PublicKey
+	alg    string
+}
+
+// keySet caches the provider's signing keys by kid.
+type keySet struct {
+	provider *Provider
+
+	mu        sync.Mutex
+	keys      map[string]publicKey
+	fetchedAt time.Time
+}
+
+func (s *keySet) find(ctx context.Context, kid string) (*publicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+	if time.Since(s.fetchedAt) < keyRefreshInterval {
+		return nil, fmt.Errorf("unknown id token signing key %q", kid)
+	}
+
+	if err := s.fetch(ctx); err != nil {
+		return nil, err
+	}
+	if key, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown id token signing key %q", kid)
+}
+
+// lookup finds the key by kid. Tokens without a kid are accepted only
+// while the provider publishes a single key.
+func (s *keySet) lookup(kid string) (*publicKey, bool) {
+	if kid == "" {
+		if len(s.keys) != 1 {
+			return nil, false
+		}
+		for _, key := range s.keys {
+			return &key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return &key, ok
+}
+
+func (s *keySet) fetch(ctx context.Context) error {
+	metadata, err := s.provider.Metadata(ctx)
+	if err != nil {
+		return err
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	s.fetchedAt = time.Now()
+	if err := s.provider.getJSON(ctx, metadata.JWKSURI, &set); err != nil {
+		return fmt.Errorf("oidc keys: %w", err)
+	}
+
+	keys := make(map[string]publicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		public, err := jwk.publicKey()
+		if err != nil {
+			// Keys of unsupported types can't have signed anything we
+			// accept.
+			continue
+		}
+		keys[jwk.KeyID] = publicKey{public: public, alg: jwk.Algorithm}
+	}
+	s.keys = keys
+	return nil
+}
+
+// jsonWebKey is a public key in JSON Web Key format (RFC 7517).
+type jsonWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n"`
+	E         string `json:"e"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	Y         string `json:"y"`
+}
+
+func (k *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("rsa exponent too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Curve)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("ec point not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Curve != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.KeyType)
+	}
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
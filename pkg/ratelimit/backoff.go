@@ -0,0 +1,114 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Policy decides how long to block a key after repeated failures: nothing
+// for the first FreeAttempts, then an exponentially growing delay from
+// Base up to Cap, and Lockout once MaxFailures is reached. Failures are
+// forgotten after Window without any.
+type Policy struct {
+	FreeAttempts int
+	MaxFailures  int
+	Base         time.Duration
+	Cap          time.Duration
+	Lockout      time.Duration
+	Window       time.Duration
+}
+
+// Delay returns how long to block after the given number of consecutive
+// failures.
+func (p Policy) Delay(failures int) time.Duration {
+	if failures >= p.MaxFailures {
+		return p.Lockout
+	}
+	if failures < p.FreeAttempts {
+		return 0
+	}
+
+	delay := p.Base
+	for i := p.FreeAttempts; i < failures && delay < p.Cap; i++ {
+		delay *= 2
+	}
+	if delay > p.Cap {
+		delay = p.Cap
+	}
+	return delay
+}
+
+// Backoff tracks failures per key in memory under a Policy.
+type Backoff struct {
+	policy Policy
+
+	mu      sync.Mutex
+	entries map[string]*backoffEntry
+}
+
+type backoffEntry struct {
+	failures int
+	last     time.Time
+	until    time.Time
+}
+
+func NewBackoff(policy Policy) *Backoff {
+	return &Backoff{policy: policy, entries: make(map[string]*backoffEntry)}
+}
+
+// Wait returns how long the key is still blocked for.
+func (b *Backoff) Wait(key string) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e, ok := b.entries[key]
+	if !ok {
+		return 0
+	}
+	if wait := time.Until(e.until); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// Fail records a failure for the key and returns how long it is blocked
+// for as a result.
+func (b *Backoff) Fail(key string) time.Duration {
+	now := time.Now()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.sweep(now)
+
+	e, ok := b.entries[key]
+	if !ok || now.Sub(e.last) >= b.policy.Window {
+		e = &backoffEntry{}
+		b.entries[key] = e
+	}
+	e.failures++
+	e.last = now
+
+	delay := b.policy.Delay(e.failures)
+	e.until = now.Add(delay)
+	return delay
+}
+
+// Reset forgets the failures of the key.
+func (b *Backoff) Reset(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.entries, key)
+}
+
+func (b *Backoff) sweep(now time.Time) {
+	if len(b.entries) < 1024 {
+		return
+	}
+	for key, e := range b.entries {
+		if now.Sub(e.last) >= b.policy.Window && !now.Before(e.until) {
+			delete(b.entries, key)
+		}
+	}
+}
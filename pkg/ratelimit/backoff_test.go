@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+var testPolicy = Policy{
+	FreeAttempts: 3,
+	MaxFailures:  10,
+	Base:         time.Second,
+	Cap:          time.Minute,
+	Lockout:      15 * time.Minute,
+	Window:       time.Hour,
+}
+
+func TestPolicyDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{1, 0},
+		{2, 0},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{5, 4 * time.Second},
+		{8, 32 * time.Second},
+		{9, time.Minute},
+		{10, 15 * time.Minute},
+		{50, 15 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := testPolicy.Delay(tt.failures); got != tt.want {
+			t.Errorf("Delay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestPolicyDelayDoesNotOverflow(t *testing.T) {
+	p := Policy{FreeAttempts: 0, MaxFailures: 1 << 30, Base: time.Second, Cap: time.Hour}
+	if got := p.Delay(1 << 20); got != time.Hour {
+		t.Errorf("Delay = %v, want the cap", got)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	b := NewBackoff(testPolicy)
+
+	for i := 0; i < 2; i++ {
+		if delay := b.Fail("user"); delay != 0 {
+			t.Fatalf("failure %d blocked for %v", i+1, delay)
+		}
+	}
+	if wait := b.Wait("user"); wait != 0 {
+		t.Fatalf("Wait = %v during the free attempts", wait)
+	}
+
+	if delay := b.Fail("user"); delay != time.Second {
+		t.Fatalf("third failure blocked for %v, want 1s", delay)
+	}
+	if wait := b.Wait("user"); wait <= 0 || wait > time.Second {
+		t.Errorf("Wait = %v, want up to 1s", wait)
+	}
+	if wait := b.Wait("other"); wait != 0 {
+		t.Errorf("Wait for another key = %v", wait)
+	}
+
+	b.Reset("user")
+	if wait := b.Wait("user"); wait != 0 {
+		t.Errorf("Wait after Reset = %v", wait)
+	}
+	if delay := b.Fail("user"); delay != 0 {
+		t.Errorf("failure after Reset blocked for %v", delay)
+	}
+}
+
+func TestBackoffForgetsAfterWindow(t *testing.T) {
+	policy := testPolicy
+	policy.Window = 20 * time.Millisecond
+	b := NewBackoff(policy)
+
+	for i := 0; i < 3; i++ {
+		b.Fail("user")
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	if delay := b.Fail("user"); delay != 0 {
+		t.Errorf("failure after the window blocked for %v", delay)
+	}
+}
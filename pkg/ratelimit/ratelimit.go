@@ -0,0 +1,61 @@
+// Package ratelimit counts events per key in fixed time windows.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter allows up to Limit events per key in each window. Counts are kept
+// in memory, so each instance of the application limits on its own.
+type Limiter struct {
+	limit  int
+	window time.Duration
+
+	mu      sync.Mutex
+	windows map[string]*window
+}
+
+type window struct {
+	start time.Time
+	count int
+}
+
+func New(limit int, per time.Duration) *Limiter {
+	return &Limiter{limit: limit, window: per, windows: make(map[string]*window)}
+}
+
+// Allow records an event for key. When the key is over its limit the event
+// is refused and Allow returns how long until the window resets.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= l.window {
+		w = &window{start: now}
+		l.windows[key] = w
+	}
+	if w.count >= l.limit {
+		return false, w.start.Add(l.window).Sub(now)
+	}
+	w.count++
+	return true, 0
+}
+
+// sweep forgets expired windows once the map has grown, so keys seen only
+// once don't pile up.
+func (l *Limiter) sweep(now time.Time) {
+	if len(l.windows) < 1024 {
+		return
+	}
+	for key, w := range l.windows {
+		if now.Sub(w.start) >= l.window {
+			delete(l.windows, key)
+		}
+	}
+}
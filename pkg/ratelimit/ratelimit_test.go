@@ -0,0 +1,42 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	l := New(2, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("event %d refused under the limit", i+1)
+		}
+	}
+
+	ok, retry := l.Allow("a")
+	if ok {
+		t.Fatal("event over the limit allowed")
+	}
+	if retry <= 0 || retry > time.Hour {
+		t.Errorf("retry after %v, want up to an hour", retry)
+	}
+
+	if ok, _ := l.Allow("b"); !ok {
+		t.Error("another key shares the limit")
+	}
+}
+
+func TestLimiterResetsAfterWindow(t *testing.T) {
+	l := New(1, 20*time.Millisecond)
+
+	l.Allow("a")
+	if ok, _ := l.Allow("a"); ok {
+		t.Fatal("event over the limit allowed")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if ok, _ := l.Allow("a"); !ok {
+		t.Error("event refused in a new window")
+	}
+}
@@ -0,0 +1,60 @@
+// Package scheduler runs periodic background jobs tied to the fx
+// application lifecycle.
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.uber.org/fx"
+)
+
+// Every runs fn every interval from application start until stop. A run
+// that panics or is slow does not affect other jobs.
+func Every(lc fx.Lifecycle, name string, interval time.Duration, fn func(ctx context.Context) error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				defer close(done)
+
+				ticker := time.NewTicker(interval)
+				defer ticker.Stop()
+
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case <-ticker.C:
+						run(ctx, name, fn)
+					}
+				}
+			}()
+			log.Printf("Scheduled job %s every %s", name, interval)
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+			case <-stopCtx.Done():
+			}
+			return nil
+		},
+	})
+}
+
+func run(ctx context.Context, name string, fn func(ctx context.Context) error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Job %s panicked: %v", name, r)
+		}
+	}()
+
+	if err := fn(ctx); err != nil {
+		log.Printf("Job %s failed: %v", name, err)
+	}
+}
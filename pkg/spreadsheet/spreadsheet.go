@@ -0,0 +1,94 @@
+// Package spreadsheet reads and writes tabular data as CSV or XLSX.
+//
+// The XLSX support covers what catalogue spreadsheets need: the first
+// worksheet is read as plain text cells, and written files contain a single
+// worksheet of inline strings.
+package spreadsheet
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatXLSX Format = "xlsx"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported spreadsheet format")
+
+// Writer writes rows one at a time so large exports can be streamed.
+type Writer interface {
+	WriteRow(cells []string) error
+	Close() error
+}
+
+// FormatFromFilename detects the format from the file extension.
+func FormatFromFilename(name string) (Format, error) {
+	return ParseFormat(strings.TrimPrefix(filepath.Ext(name), "."))
+}
+
+func ParseFormat(value string) (Format, error) {
+	switch Format(strings.ToLower(value)) {
+	case FormatCSV:
+		return FormatCSV, nil
+	case FormatXLSX:
+		return FormatXLSX, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnsupportedFormat, value)
+	}
+}
+
+// ContentType returns the MIME type used when serving the format.
+func (f Format) ContentType() string {
+	if f == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv"
+}
+
+// ReadAll returns every row of the document. XLSX files need random access,
+// hence the io.ReaderAt and size.
+func ReadAll(r io.ReaderAt, size int64, format Format) ([][]string, error) {
+	switch format {
+	case FormatCSV:
+		reader := csv.NewReader(io.NewSectionReader(r, 0, size))
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		return reader.ReadAll()
+	case FormatXLSX:
+		return readXLSX(r, size)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+func NewWriter(w io.Writer, format Format) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case FormatXLSX:
+		return newXLSXWriter(w)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvWriter) WriteRow(cells []string) error {
+	return c.w.Write(cells)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
@@ -0,0 +1,267 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const relationshipsNS = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+
+// maxPartSize caps the decompressed size of each part read from an XLSX
+// file, so a small upload cannot expand into gigabytes.
+const maxPartSize = 100 << 20
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		RelID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.Text
+	}
+	var b strings.Builder
+	for _, r := range t.Runs {
+		b.WriteString(r.Text)
+	}
+	return b.String()
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		Cells []struct {
+			Ref    string   `xml:"r,attr"`
+			Type   string   `xml:"t,attr"`
+			Value  string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func readXLSX(r io.ReaderAt, size int64) ([][]string, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid xlsx file: %w", err)
+	}
+
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+
+	var shared xlsxSharedStrings
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := decodeZipXML(f, &shared); err != nil {
+			return nil, err
+		}
+	}
+
+	sheetFile, ok := files[firstSheetPath(files)]
+	if !ok {
+		return nil, fmt.Errorf("invalid xlsx file: no worksheet found")
+	}
+
+	var sheet xlsxSheet
+	if err := decodeZipXML(sheetFile, &sheet); err != nil {
+		return nil, err
+	}
+
+	rows := make([][]string, 0, len(sheet.Rows))
+	for _, row := range sheet.Rows {
+		var cells []string
+		for i, c := range row.Cells {
+			col := i
+			if c.Ref != "" {
+				col = columnIndex(c.Ref)
+			}
+			for len(cells) < col {
+				cells = append(cells, "")
+			}
+
+			value := c.Value
+			switch c.Type {
+			case "s":
+				idx, err := strconv.Atoi(c.Value)
+				if err != nil || idx < 0 || idx >= len(shared.Items) {
+					return nil, fmt.Errorf("invalid xlsx file: bad shared string reference in %s", c.Ref)
+				}
+				value = shared.Items[idx].String()
+			case "inlineStr":
+				value = c.Inline.String()
+			}
+			cells = append(cells, value)
+		}
+		rows = append(rows, cells)
+	}
+
+	return rows, nil
+}
+
+// firstSheetPath resolves the first worksheet through the workbook
+// relationships, falling back to the conventional location.
+func firstSheetPath(files map[string]*zip.File) string {
+	const fallback = "xl/worksheets/sheet1.xml"
+
+	var workbook xlsxWorkbook
+	var rels xlsxRelationships
+	wb, okWB := files["xl/workbook.xml"]
+	rf, okRels := files["xl/_rels/workbook.xml.rels"]
+	if !okWB || !okRels || decodeZipXML(wb, &workbook) != nil || decodeZipXML(rf, &rels) != nil || len(workbook.Sheets) == 0 {
+		return fallback
+	}
+
+	for _, rel := range rels.Relationships {
+		if rel.ID != workbook.Sheets[0].RelID {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/")
+		}
+		return path.Join("xl", rel.Target)
+	}
+
+	return fallback
+}
+
+func decodeZipXML(f *zip.File, v interface{}) error {
+	if f.UncompressedSize64 > maxPartSize {
+		return fmt.Errorf("invalid xlsx file: %s is larger than %d MB", f.Name, maxPartSize>>20)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	// The declared size cannot be trusted, so the read is capped as well.
+	if err := xml.NewDecoder(io.LimitReader(rc, maxPartSize)).Decode(v); err != nil {
+		return fmt.Errorf("invalid xlsx file: %s: %w", f.Name, err)
+	}
+	return nil
+}
+
+// columnIndex converts a cell reference such as "AB12" to a zero-based
+// column index.
+func columnIndex(ref string) int {
+	col := 0
+	for _, ch := range ref {
+		if ch < 'A' || ch > 'Z' {
+			break
+		}
+		col = col*26 + int(ch-'A'+1)
+	}
+	return col - 1
+}
+
+func columnName(idx int) string {
+	name := ""
+	for idx >= 0 {
+		name = string(rune('A'+idx%26)) + name
+		idx = idx/26 - 1
+	}
+	return name
+}
+
+// xlsxWriter streams the worksheet directly into the zip archive; the
+// remaining package parts are written on Close.
+type xlsxWriter struct {
+	zw    *zip.Writer
+	sheet *bufio.Writer
+	row   int
+}
+
+func newXLSXWriter(w io.Writer) (*xlsxWriter, error) {
+	zw := zip.NewWriter(w)
+	sheet, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	xw := &xlsxWriter{zw: zw, sheet: bufio.NewWriter(sheet)}
+	_, err = xw.sheet.WriteString(xml.Header +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	if err != nil {
+		return nil, err
+	}
+
+	return xw, nil
+}
+
+func (x *xlsxWriter) WriteRow(cells []string) error {
+	x.row++
+	fmt.Fprintf(x.sheet, `<row r="%d">`, x.row)
+	for i, cell := range cells {
+		fmt.Fprintf(x.sheet, `<c r="%s%d" t="inlineStr"><is><t xml:space="preserve">`, columnName(i), x.row)
+		if err := xml.EscapeText(x.sheet, []byte(cell)); err != nil {
+			return err
+		}
+		x.sheet.WriteString(`</t></is></c>`)
+	}
+	_, err := x.sheet.WriteString(`</row>`)
+	return err
+}
+
+func (x *xlsxWriter) Close() error {
+	if _, err := x.sheet.WriteString(`</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+
+	parts := map[string]string{
+		"[Content_Types].xml": `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`</Types>`,
+		"_rels/.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="` + relationshipsNS + `/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`,
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="` + relationshipsNS + `">` +
+			`<sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets>` +
+			`</workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="` + relationshipsNS + `/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`</Relationships>`,
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels"} {
+		f, err := x.zw.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, xml.Header+parts[name]); err != nil {
+			return err
+		}
+	}
+
+	return x.zw.Close()
+}
@@ -0,0 +1,174 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// buildXLSX zips the given parts into an XLSX file.
+func buildXLSX(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range parts {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("create %s: %v", name, err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func readXLSXBytes(data []byte) ([][]string, error) {
+	return ReadAll(bytes.NewReader(data), int64(len(data)), FormatXLSX)
+}
+
+func TestXLSXRoundTrip(t *testing.T) {
+	rows := [][]string{
+		{"sku", "name", "price"},
+		{"CAM-001", `Camiseta "Básica" <P> & M`, "49,90"},
+		{"", "  spaces kept  ", ""},
+		strings.Split("a b c d e f g h i j k l m n o p q r s t u v w x y z aa ab", " "),
+	}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, FormatXLSX)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	for _, row := range rows {
+		if err := w.WriteRow(row); err != nil {
+			t.Fatalf("WriteRow: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	got, err := readXLSXBytes(buf.Bytes())
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if !reflect.DeepEqual(got, rows) {
+		t.Errorf("read back %q, want %q", got, rows)
+	}
+}
+
+func TestReadXLSXSharedStringsAndSparseCells(t *testing.T) {
+	data := buildXLSX(t, map[string]string{
+		"xl/workbook.xml": `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Produtos" sheetId="1" r:id="rId7"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships>` +
+			`<Relationship Id="rId7" Target="worksheets/produtos.xml"/></Relationships>`,
+		"xl/sharedStrings.xml": `<sst><si><t>sku</t></si><si><r><t>Rich </t></r><r><t>text</t></r></si></sst>`,
+		"xl/worksheets/produtos.xml": `<worksheet><sheetData>` +
+			`<row r="1"><c r="A1" t="s"><v>0</v></c><c r="C1" t="s"><v>1</v></c></row>` +
+			`<row r="2"><c r="B2"><v>12.5</v></c><c r="D2" t="inlineStr"><is><t>inline</t></is></c></row>` +
+			`</sheetData></worksheet>`,
+		// A sheet at the conventional path that the workbook doesn't point to.
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row><c><v>wrong sheet</v></c></row></sheetData></worksheet>`,
+	})
+
+	got, err := readXLSXBytes(data)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	want := [][]string{
+		{"sku", "", "Rich text"},
+		{"", "12.5", "", "inline"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %q, want %q", got, want)
+	}
+}
+
+func TestReadXLSXRejectsInvalidFiles(t *testing.T) {
+	tests := map[string][]byte{
+		"not a zip":   []byte("sku,name,price\n"),
+		"no sheet":    buildXLSX(t, map[string]string{"xl/workbook.xml": "<workbook/>"}),
+		"broken XML":  buildXLSX(t, map[string]string{"xl/worksheets/sheet1.xml": "<worksheet><sheetData><row>"}),
+		"bad shared":  buildXLSX(t, map[string]string{"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row><c r="A1" t="s"><v>3</v></c></row></sheetData></worksheet>`}),
+		"bad strings": buildXLSX(t, map[string]string{"xl/sharedStrings.xml": "<sst><si>", "xl/worksheets/sheet1.xml": "<worksheet/>"}),
+	}
+	for name, data := range tests {
+		if _, err := readXLSXBytes(data); err == nil {
+			t.Errorf("%s: ReadAll succeeded", name)
+		}
+	}
+}
+
+func TestReadXLSXRejectsOversizedParts(t *testing.T) {
+	// A small entry that claims to expand past the cap, as a zip bomb
+	// would, is refused before it is decompressed.
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               "xl/worksheets/sheet1.xml",
+		Method:             zip.Store,
+		CompressedSize64:   11,
+		UncompressedSize64: maxPartSize + 1,
+	})
+	if err != nil {
+		t.Fatalf("CreateRaw: %v", err)
+	}
+	f.Write([]byte("<worksheet>"))
+	zw.Close()
+
+	_, err = readXLSXBytes(buf.Bytes())
+	if err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Fatalf("ReadAll error = %v, want a size error", err)
+	}
+}
+
+func TestColumnNames(t *testing.T) {
+	tests := map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"}
+	for idx, name := range tests {
+		if got := columnName(idx); got != name {
+			t.Errorf("columnName(%d) = %q, want %q", idx, got, name)
+		}
+		if got := columnIndex(name + "12"); got != idx {
+			t.Errorf("columnIndex(%q) = %d, want %d", name+"12", got, idx)
+		}
+	}
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	rows := [][]string{{"sku", "name"}, {"A-1", "Caneca, azul"}, {"B-2"}}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, FormatCSV)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	for _, row := range rows {
+		w.WriteRow(row)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	got, err := ReadAll(bytes.NewReader(buf.Bytes()), int64(buf.Len()), FormatCSV)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if !reflect.DeepEqual(got, rows) {
+		t.Errorf("read back %q, want %q", got, rows)
+	}
+}
+
+func TestFormatFromFilename(t *testing.T) {
+	if f, err := FormatFromFilename("produtos.XLSX"); err != nil || f != FormatXLSX {
+		t.Errorf("FormatFromFilename(produtos.XLSX) = %q, %v", f, err)
+	}
+	if _, err := FormatFromFilename("produtos.ods"); err == nil {
+		t.Error("FormatFromFilename accepted .ods")
+	}
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStorage keeps objects under a directory on disk. The directory is
+// expected to be served at publicURL.
+type LocalStorage struct {
+	dir       string
+	publicURL string
+}
+
+func NewLocalStorage(dir, publicURL string) (*LocalStorage, error) {
+	if dir == "" {
+		dir = "uploads"
+	}
+	if publicURL == "" {
+		publicURL = "/media"
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	return &LocalStorage{dir: dir, publicURL: strings.TrimSuffix(publicURL, "/")}, nil
+}
+
+// Dir returns the root directory, so it can be served statically.
+func (s *LocalStorage) Dir() string {
+	return s.dir
+}
+
+func (s *LocalStorage) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o644)
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStorage) URL(key string) string {
+	return s.publicURL + "/" + key
+}
+
+// path maps a key to a file inside the storage directory, rejecting keys
+// that would escape it.
+func (s *LocalStorage) path(key string) (string, error) {
+	cleaned := filepath.Clean("/" + key)
+	if cleaned == "/" {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(cleaned)), nil
+}
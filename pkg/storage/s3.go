@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3Options configures an S3-compatible backend. Requests use path-style
+// addressing ({endpoint}/{bucket}/{key}) so stand-ins such as MinIO work
+// without DNS setup.
+type S3Options struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PublicURL is the base objects are served from. Defaults to the
+	// bucket URL on the endpoint.
+	PublicURL string
+}
+
+// S3Storage talks to an S3-compatible API using Signature Version 4.
+type S3Storage struct {
+	opts   S3Options
+	client *http.Client
+}
+
+func NewS3Storage(opts S3Options) (*S3Storage, error) {
+	if opts.Endpoint == "" || opts.Bucket == "" || opts.AccessKey == "" || opts.SecretKey == "" {
+		return nil, errors.New("s3 storage requires endpoint, bucket, access key and secret key")
+	}
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	opts.Endpoint = strings.TrimSuffix(opts.Endpoint, "/")
+	if opts.PublicURL == "" {
+		opts.PublicURL = opts.Endpoint + "/" + opts.Bucket
+	}
+	opts.PublicURL = strings.TrimSuffix(opts.PublicURL, "/")
+
+	return &S3Storage{opts: opts, client: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+func (s *S3Storage) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	return s.do(ctx, http.MethodPut, key, data, contentType)
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	return s.do(ctx, http.MethodDelete, key, nil, "")
+}
+
+func (s *S3Storage) URL(key string) string {
+	return s.opts.PublicURL + "/" + key
+}
+
+func (s *S3Storage) do(ctx context.Context, method, key string, body []byte, contentType string) error {
+	endpoint, err := url.Parse(s.opts.Endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid s3 endpoint: %w", err)
+	}
+
+	path := "/" + uriEncode(s.opts.Bucket) + "/" + uriEncodePath(key)
+	req, err := http.NewRequestWithContext(ctx, method, endpoint.Scheme+"://"+endpoint.Host+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	s.sign(req, path, body, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("s3 %s %s failed: %s: %s", method, key, resp.Status, strings.TrimSpace(string(detail)))
+	}
+
+	return nil
+}
+
+// sign adds the AWS Signature Version 4 Authorization header.
+func (s *S3Storage) sign(req *http.Request, canonicalURI string, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	if ct := req.Header.Get("Content-Type"); ct != "" {
+		signedHeaders = "content-type;" + signedHeaders
+		canonicalHeaders = "content-type:" + ct + "\n" + canonicalHeaders
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI,
+		"",
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.opts.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.opts.SecretKey), date)
+	signingKey = hmacSHA256(signingKey, s.opts.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.opts.AccessKey, scope, signedHeaders, signature,
+	))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func uriEncodePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = uriEncode(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// uriEncode percent-encodes everything except the unreserved characters,
+// as required by SigV4.
+func uriEncode(value string) string {
+	var b strings.Builder
+	for _, c := range []byte(value) {
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
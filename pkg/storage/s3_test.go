@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const (
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+)
+
+// fakeS3 is a stand-in for an S3-compatible server. It checks the SigV4
+// signature of every request and keeps the objects it receives.
+type fakeS3 struct {
+	t       *testing.T
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *httptest.Server) {
+	f := &fakeS3{t: t, objects: make(map[string][]byte), types: make(map[string]string)}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, server
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if msg := f.verify(r, body); msg != "" {
+		f.t.Errorf("bad signature: %s", msg)
+		http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		f.objects[r.URL.Path] = body
+		f.types[r.URL.Path] = r.Header.Get("Content-Type")
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// verify recomputes the signature from the request as received and returns
+// what is wrong with it, if anything.
+func (f *fakeS3) verify(r *http.Request, body []byte) string {
+	if got := r.Header.Get("X-Amz-Content-Sha256"); got != sha256Hex(body) {
+		return "payload hash " + got + " does not match the body"
+	}
+
+	auth := strings.TrimPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ")
+	fields := make(map[string]string)
+	for _, part := range strings.Split(auth, ", ") {
+		name, value, _ := strings.Cut(part, "=")
+		fields[name] = value
+	}
+
+	credential := strings.SplitN(fields["Credential"], "/", 2)
+	if len(credential) != 2 || credential[0] != testAccessKey {
+		return "unexpected credential " + fields["Credential"]
+	}
+	scope := credential[1]
+	scopeParts := strings.Split(scope, "/")
+	if len(scopeParts) != 4 || scopeParts[2] != "s3" || scopeParts[3] != "aws4_request" {
+		return "unexpected scope " + scope
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, name := range strings.Split(fields["SignedHeaders"], ";") {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		r.URL.RawQuery,
+		canonicalHeaders.String(),
+		fields["SignedHeaders"],
+		r.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+	stringToSign := "AWS4-HMAC-SHA256\n" + r.Header.Get("X-Amz-Date") + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := []byte("AWS4" + testSecretKey)
+	for _, part := range scopeParts {
+		key = hmacSHA256(key, part)
+	}
+	if want := hex.EncodeToString(hmacSHA256(key, stringToSign)); fields["Signature"] != want {
+		return "signature " + fields["Signature"] + ", want " + want
+	}
+	return ""
+}
+
+func newTestS3Storage(t *testing.T, endpoint string) *S3Storage {
+	t.Helper()
+	s, err := NewS3Storage(S3Options{
+		Endpoint:  endpoint + "/",
+		Region:    "sa-east-1",
+		Bucket:    "catalog",
+		AccessKey: testAccessKey,
+		SecretKey: testSecretKey,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Storage: %v", err)
+	}
+	return s
+}
+
+func TestS3StoragePutAndDelete(t *testing.T) {
+	fake, server := newFakeS3(t)
+	s := newTestS3Storage(t, server.URL)
+	ctx := context.Background()
+
+	key := "products/42/front view+1.jpg"
+	if err := s.Put(ctx, key, []byte("jpeg bytes"), "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	stored, ok := fake.objects["/catalog/"+key]
+	if !ok {
+		t.Fatalf("object not stored, have %v", fake.objects)
+	}
+	if string(stored) != "jpeg bytes" {
+		t.Errorf("stored %q, want %q", stored, "jpeg bytes")
+	}
+	if got := fake.types["/catalog/"+key]; got != "image/jpeg" {
+		t.Errorf("content type %q, want image/jpeg", got)
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok := fake.objects["/catalog/"+key]; ok {
+		t.Error("object still stored after Delete")
+	}
+}
+
+func TestS3StorageReportsErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	s := newTestS3Storage(t, server.URL)
+	err := s.Put(context.Background(), "a.jpg", []byte("x"), "image/jpeg")
+	if err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Fatalf("Put error = %v, want the AccessDenied response", err)
+	}
+}
+
+func TestS3StorageURL(t *testing.T) {
+	s := newTestS3Storage(t, "http://minio:9000")
+	if got, want := s.URL("products/1/a.jpg"), "http://minio:9000/catalog/products/1/a.jpg"; got != want {
+		t.Errorf("URL = %q, want %q", got, want)
+	}
+
+	s, err := NewS3Storage(S3Options{
+		Endpoint:  "http://minio:9000",
+		Bucket:    "catalog",
+		AccessKey: testAccessKey,
+		SecretKey: testSecretKey,
+		PublicURL: "https://cdn.example.com/",
+	})
+	if err != nil {
+		t.Fatalf("NewS3Storage: %v", err)
+	}
+	if got, want := s.URL("a.jpg"), "https://cdn.example.com/a.jpg"; got != want {
+		t.Errorf("URL = %q, want %q", got, want)
+	}
+}
+
+func TestUriEncode(t *testing.T) {
+	tests := map[string]string{
+		"plain-name_1.~": "plain-name_1.~",
+		"a b+c":          "a%20b%2Bc",
+		"ação":           "a%C3%A7%C3%A3o",
+	}
+	for in, want := range tests {
+		if got := uriEncode(in); got != want {
+			t.Errorf("uriEncode(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
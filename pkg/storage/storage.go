@@ -0,0 +1,42 @@
+// Package storage stores binary objects such as uploaded images.
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rkweber-max/checkout-backend/pkg/config"
+)
+
+const (
+	DriverLocal = "local"
+	DriverS3    = "s3"
+)
+
+// Storage puts and deletes objects addressed by a slash-separated key and
+// knows the public URL each object is served from.
+type Storage interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Delete(ctx context.Context, key string) error
+	URL(key string) string
+}
+
+// NewStorage builds the backend selected by STORAGE_DRIVER, defaulting to
+// the local filesystem.
+func NewStorage(cfg *config.Config) (Storage, error) {
+	switch cfg.StorageDriver {
+	case "", DriverLocal:
+		return NewLocalStorage(cfg.StorageLocalDir, cfg.StoragePublicURL)
+	case DriverS3:
+		return NewS3Storage(S3Options{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PublicURL: cfg.StoragePublicURL,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.StorageDriver)
+	}
+}